@@ -0,0 +1,156 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+type changeRecord struct {
+	Kind     jp.ChangeKind `json:"kind"`
+	AreaCode string        `json:"area_code"`
+	Old      *record       `json:"old"`
+	New      *record       `json:"new"`
+	Distance float64       `json:"distance,omitempty"`
+}
+
+func newChangeRecord(c jp.Change) changeRecord {
+	cr := changeRecord{Kind: c.Kind, AreaCode: c.AreaCode(), Distance: c.Distance}
+	if c.Old != nil {
+		r := newRecord(*c.Old)
+		cr.Old = &r
+	}
+	if c.New != nil {
+		r := newRecord(*c.New)
+		cr.New = &r
+	}
+	return cr
+}
+
+func diff(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: geojp diff [flags] <old file> <new file>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output format: text, json or geojson")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	var write func(io.Writer, []jp.Change) error
+	switch *format {
+	case "text":
+		write = writeChangesText
+	case "json":
+		write = writeChangesJSON
+	case "geojson":
+		write = writeChangesGeoJSON
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+
+	prev, err := jp.ReadAPsFromFile(positional[0])
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	next, err := jp.ReadAPsFromFile(positional[1])
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	if err := write(stdout, jp.Diff(prev, next)); err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func writeChangesText(w io.Writer, changes []jp.Change) error {
+	counts := map[jp.ChangeKind]int{}
+	for _, c := range changes {
+		counts[c.Kind]++
+		var err error
+		switch c.Kind {
+		case jp.ChangeAdded:
+			_, err = fmt.Fprintf(w, "added    %s %s\n", c.New.AreaCode, fullName(c.New))
+		case jp.ChangeRemoved:
+			_, err = fmt.Fprintf(w, "removed  %s %s\n", c.Old.AreaCode, fullName(c.Old))
+		case jp.ChangeRenamed:
+			_, err = fmt.Fprintf(w, "renamed  %s %s -> %s\n", c.New.AreaCode, fullName(c.Old), fullName(c.New))
+		case jp.ChangeRecoded:
+			_, err = fmt.Fprintf(w, "recoded  %s -> %s %s\n", c.Old.AreaCode, c.New.AreaCode, fullName(c.New))
+		case jp.ChangeMoved:
+			_, err = fmt.Fprintf(w, "moved    %s %s %.1f m\n", c.New.AreaCode, fullName(c.New), c.Distance)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d renamed, %d recoded, %d moved\n",
+		counts[jp.ChangeAdded], counts[jp.ChangeRemoved], counts[jp.ChangeRenamed],
+		counts[jp.ChangeRecoded], counts[jp.ChangeMoved])
+	return err
+}
+
+func fullName(ap *jp.AddressPosition) string {
+	return ap.PrefName + ap.CityName + ap.AreaName
+}
+
+func writeChangesJSON(w io.Writer, changes []jp.Change) error {
+	records := make([]changeRecord, len(changes))
+	for i, c := range changes {
+		records[i] = newChangeRecord(c)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// writeChangesGeoJSON writes the changes as GeoJSON features. A move is a
+// line from the old to the new position, and the other changes are points.
+func writeChangesGeoJSON(w io.Writer, changes []jp.Change) error {
+	fc := geo.FeatureCollection{Features: make([]geo.Feature, len(changes))}
+	for i, c := range changes {
+		var g geo.Geometry
+		switch {
+		case c.Kind == jp.ChangeMoved:
+			g = geo.LineString{latLong(c.Old), latLong(c.New)}
+		case c.New != nil:
+			g = latLong(c.New)
+		default:
+			g = latLong(c.Old)
+		}
+		cr := newChangeRecord(c)
+		props := map[string]interface{}{
+			"kind":      cr.Kind,
+			"area_code": cr.AreaCode,
+			"old":       cr.Old,
+			"new":       cr.New,
+		}
+		if c.Kind == jp.ChangeMoved {
+			props["distance"] = c.Distance
+		}
+		fc.Features[i] = geo.Feature{Geometry: g, Properties: props}
+	}
+	return json.NewEncoder(w).Encode(fc)
+}
+
+func latLong(ap *jp.AddressPosition) geo.LatLong {
+	return geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/geo/mvt"
+	"github.com/twihike/go-geojp/pkg/geo/tiles"
+)
+
+const (
+	// addressLayer is the name of the layer of the vector tiles.
+	addressLayer = "addresses"
+	// maxExportZoom is the highest zoom level of the exported tiles.
+	maxExportZoom = 20
+	// maxTileSize is the default budget of the compressed tiles in bytes,
+	// which the common clients and hosts accept.
+	maxTileSize = 500 * 1024
+)
+
+// addressFields are the properties of the features keyed by name.
+var addressFields = []struct {
+	name  string
+	value func(ap jp.AddressPosition) string
+}{
+	{"pref_code", func(ap jp.AddressPosition) string { return ap.PrefCode }},
+	{"pref_name", func(ap jp.AddressPosition) string { return ap.PrefName }},
+	{"city_code", func(ap jp.AddressPosition) string { return ap.CityCode }},
+	{"city_name", func(ap jp.AddressPosition) string { return ap.CityName }},
+	{"area_code", func(ap jp.AddressPosition) string { return ap.AreaCode }},
+	{"area_name", func(ap jp.AddressPosition) string { return ap.AreaName }},
+}
+
+func exportTiles(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export-tiles", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: geojp export-tiles [flags] <output file>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	data := fs.String("data", defaultDataPath(), "path to the address CSV (env ADDR_POS_PATH)")
+	format := fs.String("format", "", "output format: mbtiles or pmtiles (default: by the extension of the output file)")
+	minZoom := fs.Int("minzoom", 10, "lowest zoom level of the tiles")
+	maxZoom := fs.Int("maxzoom", 14, fmt.Sprintf("highest zoom level of the tiles, up to %d", maxExportZoom))
+	name := fs.String("name", "japanese-addresses", "name of the tileset")
+	attribution := fs.String("attribution", jp.Attribution, "attribution of the tileset as HTML")
+	maxSize := fs.Int("max-tile-size", maxTileSize, "maximum size of a compressed tile in bytes; the areas of larger tiles are thinned")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 || *minZoom < 0 || *minZoom > *maxZoom || *maxZoom > maxExportZoom || *maxSize <= 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	path := positional[0]
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	var write func(io.Writer, tiles.Metadata, map[geo.Tile][]byte) error
+	switch *format {
+	case "mbtiles":
+		write = tiles.WriteMBTiles
+	case "pmtiles":
+		write = tiles.WritePMTiles
+	default:
+		fmt.Fprintf(stderr, "geojp: unknown output format %q\n", *format)
+		return exitUsage
+	}
+
+	aps, err := jp.ReadAPsFromFile(*data)
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	tileset, dropped, err := addressTiles(aps, *minZoom, *maxZoom, *maxSize)
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	md := addressMetadata(aps, *minZoom, *maxZoom)
+	md.Name, md.Attribution = *name, *attribution
+	if err := writeFile(path, func(w io.Writer) error { return write(w, md, tileset) }); err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	fmt.Fprintf(stdout, "%s: %d tiles of %d areas at zoom levels %d-%d\n",
+		path, len(tileset), len(aps), *minZoom, *maxZoom)
+	if dropped > 0 {
+		fmt.Fprintf(stdout, "%s: %d points dropped from the tiles to fit in %d bytes\n", path, dropped, *maxSize)
+	}
+	return exitOK
+}
+
+// addressTiles returns the vector tiles of the addresses from the lowest to
+// the highest zoom level, compressed with gzip. Each tile has the addresses
+// in it as points of a layer, thinned to fit in maxSize bytes, and the
+// number of the points dropped.
+func addressTiles(aps jp.AddressPositions, minZoom, maxZoom, maxSize int) (map[geo.Tile][]byte, int, error) {
+	result := map[geo.Tile][]byte{}
+	dropped := 0
+	for z := minZoom; z <= maxZoom; z++ {
+		layers := map[geo.Tile]*mvt.Layer{}
+		for _, ap := range aps {
+			p := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+			px, py := geo.LatLongToPixel(p.Latitude, p.Longitude, z)
+			tx, ty := geo.PixelToTile(px, py)
+			t := geo.Tile{X: tx, Y: ty, Z: z}
+			l, ok := layers[t]
+			if !ok {
+				l = &mvt.Layer{Name: addressLayer, Extent: mvt.DefaultExtent}
+				layers[t] = l
+			}
+			f := mvt.Feature{Properties: map[string]interface{}{}}
+			f.X, f.Y = mvt.Point(p, t, l.Extent)
+			// The area codes are numbers, which identify the features.
+			if id, err := strconv.ParseUint(ap.AreaCode, 10, 64); err == nil {
+				f.ID = id
+			}
+			for _, field := range addressFields {
+				f.Properties[field.name] = field.value(ap)
+			}
+			l.Features = append(l.Features, f)
+		}
+		for t, l := range layers {
+			b, n, err := encodeTile(*l, maxSize)
+			if err != nil {
+				return nil, 0, fmt.Errorf("tile %d/%d/%d: %v", t.Z, t.X, t.Y, err)
+			}
+			result[t] = b
+			dropped += n
+		}
+	}
+	return result, dropped, nil
+}
+
+// encodeTile encodes the layer compressed with gzip. If the tile exceeds
+// maxSize bytes, it keeps the first point in each cell of a grid over the
+// tile, doubling the cells until the tile fits, and returns the number of
+// the points dropped.
+func encodeTile(l mvt.Layer, maxSize int) ([]byte, int, error) {
+	features := l.Features
+	for cell := int(l.Extent) / 256; ; cell *= 2 {
+		b, err := mvt.Encode(l)
+		if err != nil {
+			return nil, 0, err
+		}
+		if b, err = gzipBytes(b); err != nil {
+			return nil, 0, err
+		}
+		if len(b) <= maxSize {
+			return b, len(features) - len(l.Features), nil
+		}
+		if cell > int(l.Extent) {
+			return nil, 0, fmt.Errorf("%d bytes exceed %d bytes even when thinned", len(b), maxSize)
+		}
+		l.Features = thinFeatures(features, cell)
+	}
+}
+
+// thinFeatures returns the first feature in each cell of the size in tile
+// units.
+func thinFeatures(features []mvt.Feature, cell int) []mvt.Feature {
+	type key struct{ x, y int }
+	seen := map[key]bool{}
+	var thinned []mvt.Feature
+	for _, f := range features {
+		k := key{f.X / cell, f.Y / cell}
+		if !seen[k] {
+			seen[k] = true
+			thinned = append(thinned, f)
+		}
+	}
+	return thinned
+}
+
+// addressMetadata returns the metadata of the tiles of the addresses.
+func addressMetadata(aps jp.AddressPositions, minZoom, maxZoom int) tiles.Metadata {
+	bounds := geo.EmptyBBox()
+	for _, ap := range aps {
+		bounds = bounds.Extend(geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude})
+	}
+	fields := map[string]string{}
+	for _, f := range addressFields {
+		fields[f.name] = "String"
+	}
+	return tiles.Metadata{
+		Description: "Representative points of the areas of Japanese addresses.",
+		Format:      "pbf",
+		Compression: "gzip",
+		MinZoom:     minZoom,
+		MaxZoom:     maxZoom,
+		Bounds:      bounds,
+		VectorLayers: []tiles.VectorLayer{{
+			ID:          addressLayer,
+			Description: "Areas of the addresses. The feature IDs are the area codes.",
+			MinZoom:     minZoom,
+			MaxZoom:     maxZoom,
+			Fields:      fields,
+		}},
+	}
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFile writes the file with the function, removing the file if it
+// fails.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
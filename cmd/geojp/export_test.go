@@ -0,0 +1,159 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/geo/mvt"
+	"github.com/twihike/go-geojp/pkg/geo/tiles"
+)
+
+func TestExportTiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geojp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 芝公園三丁目 at 35.659943,139.747207.
+	p := geo.LatLong{Latitude: 35.659943, Longitude: 139.747207}
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		wantOut string
+	}{
+		{"mbtiles", "a.mbtiles", nil, "122 tiles of 117 areas at zoom levels 13-16\n"},
+		{"pmtiles", "a.pmtiles", nil, "122 tiles of 117 areas at zoom levels 13-16\n"},
+		{"format flag", "a.bin", []string{"-format", "pmtiles"}, "122 tiles of 117 areas at zoom levels 13-16\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			args := append([]string{"export-tiles", "-data", testData, "-minzoom", "13", "-maxzoom", "16"}, tt.args...)
+			var stdout, stderr bytes.Buffer
+			if got := run(append(args, path), &stdout, &stderr); got != exitOK {
+				t.Fatalf("want = %v, got = %v, stderr = %v", exitOK, got, stderr.String())
+			}
+			if want := path + ": " + tt.wantOut; stdout.String() != want {
+				t.Errorf("want = %v, got = %v", want, stdout.String())
+			}
+
+			s, err := tiles.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			md := s.(interface{ Metadata() map[string]string }).Metadata()
+			if md["attribution"] != jp.Attribution || md["format"] != "pbf" {
+				t.Errorf("want = %v, got = %v", jp.Attribution, md)
+			}
+			for z := 13; z <= 16; z++ {
+				px, py := geo.LatLongToPixel(p.Latitude, p.Longitude, z)
+				tx, ty := geo.PixelToTile(px, py)
+				tile := geo.Tile{X: tx, Y: ty, Z: z}
+				f := findFeature(t, s, tile, 131030002003)
+				if f == nil {
+					t.Fatalf("%v: want = %v, got = %v", tile, "芝公園三丁目", f)
+				}
+				if got := f.Properties["area_name"]; got != "芝公園三丁目" {
+					t.Errorf("%v: want = %v, got = %v", tile, "芝公園三丁目", got)
+				}
+				x, y := mvt.Point(p, tile, mvt.DefaultExtent)
+				if f.X != x || f.Y != y {
+					t.Errorf("%v: want = %v %v, got = %v %v", tile, x, y, f.X, f.Y)
+				}
+			}
+			if _, err := s.Tile(geo.Tile{X: 0, Y: 0, Z: 12}); err != tiles.ErrNotExist {
+				t.Errorf("want = %v, got = %v", tiles.ErrNotExist, err)
+			}
+		})
+	}
+}
+
+func TestAddressTiles(t *testing.T) {
+	aps, err := jp.ReadAPsFromFile(testData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tileset, dropped, err := addressTiles(aps, 10, 10, maxTileSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped != 0 {
+		t.Errorf("want = %v, got = %v", 0, dropped)
+	}
+
+	// The points of the tiles over the budget are thinned to fit.
+	const maxSize = 1000
+	thinned, dropped, err := addressTiles(aps, 10, 10, maxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dropped == 0 || len(thinned) != len(tileset) {
+		t.Errorf("want = %v %v, got = %v %v", "dropped", len(tileset), dropped, len(thinned))
+	}
+	for tile, b := range thinned {
+		if len(b) > maxSize {
+			t.Errorf("%v: want = %v, got = %v", tile, maxSize, len(b))
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err = ioutil.ReadAll(zr); err != nil {
+			t.Fatal(err)
+		}
+		layers, err := mvt.Decode(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(layers) != 1 || len(layers[0].Features) == 0 {
+			t.Errorf("%v: want = %v, got = %v", tile, "points", layers)
+		}
+	}
+
+	if _, _, err := addressTiles(aps, 10, 10, 10); err == nil {
+		t.Errorf("want = %v, got = %v", "error", err)
+	}
+}
+
+// findFeature returns the feature of the ID in the tile, or nil if there is
+// no such feature.
+func findFeature(t *testing.T, s tiles.Source, tile geo.Tile, id uint64) *mvt.Feature {
+	t.Helper()
+	b, err := s.Tile(tile)
+	if err != nil {
+		t.Fatalf("%v: %v", tile, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = ioutil.ReadAll(zr); err != nil {
+		t.Fatal(err)
+	}
+	layers, err := mvt.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range layers {
+		for i, f := range l.Features {
+			if l.Name == addressLayer && f.ID == id {
+				return &l.Features[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+// commonFlags are the flags shared by the commands that query the dataset.
+type commonFlags struct {
+	data          string
+	format        string
+	distanceModel string
+}
+
+func newFlagSet(name, args string, stderr io.Writer) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: geojp %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+
+	cf := &commonFlags{}
+	fs.StringVar(&cf.data, "data", defaultDataPath(), "path to the address CSV (env ADDR_POS_PATH)")
+	fs.StringVar(&cf.format, "format", "table", "output format: table, json, ndjson or csv")
+	fs.StringVar(&cf.distanceModel, "distance-model", "spherical", "distance model: spherical or ellipsoidal")
+	return fs, cf
+}
+
+// defaultDataPath returns the path of the address CSV given by
+// ADDR_POS_PATH, or latest.csv.
+func defaultDataPath() string {
+	if data := addressPath(os.Getenv("ADDR_POS_PATH")); data != "" {
+		return data
+	}
+	return "latest.csv"
+}
+
+// addressPath returns the path of the address CSV from the value of
+// ADDR_POS_PATH, skipping the name=path entries of the layers.
+func addressPath(env string) string {
+	for _, entry := range strings.Split(env, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" && !strings.Contains(entry, "=") {
+			return entry
+		}
+	}
+	return ""
+}
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, and returns the positional arguments. Negative
+// numbers are positional arguments rather than flags.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		for len(args) > 0 && isNegativeNumber(args[0]) {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if n := len(args) - fs.NArg(); n > 0 && args[n-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func isNegativeNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && strings.HasPrefix(s, "-")
+}
+
+// load checks the common flags and reads the dataset. It returns the
+// distance model of the flags too.
+func (cf *commonFlags) load() (jp.AddressPositions, geo.DistanceModel, error) {
+	if _, err := newFormatter(cf.format, ioutil.Discard); err != nil {
+		return nil, 0, err
+	}
+	m, err := geo.ParseDistanceModel(cf.distanceModel)
+	if err != nil {
+		return nil, 0, err
+	}
+	aps, err := jp.ReadAPsFromFile(cf.data)
+	return aps, m, err
+}
+
+func parseLatLong(lat, long string) (geo.LatLong, error) {
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return geo.LatLong{}, fmt.Errorf("invalid latitude: %q", lat)
+	}
+	lo, err := strconv.ParseFloat(long, 64)
+	if err != nil {
+		return geo.LatLong{}, fmt.Errorf("invalid longitude: %q", long)
+	}
+	return geo.LatLong{Latitude: la, Longitude: lo}, nil
+}
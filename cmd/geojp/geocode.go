@@ -0,0 +1,72 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+func geocode(args []string, stdout, stderr io.Writer) int {
+	fs, cf := newFlagSet("geocode", "<area name>", stderr)
+	lat := fs.Float64("lat", 0, "latitude of the current location to sort by distance")
+	long := fs.Float64("long", 0, "longitude of the current location to sort by distance")
+	limit := fs.Int("limit", jp.DefaultSearchLimit, fmt.Sprintf("maximum number of areas, up to %d", jp.MaxSearchLimit))
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) == 0 || *limit < 1 || *limit > jp.MaxSearchLimit {
+		fs.Usage()
+		return exitUsage
+	}
+	name := strings.Join(positional, " ")
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["lat"] != set["long"] {
+		fmt.Fprintln(stderr, "geojp: -lat and -long must be given together")
+		return exitUsage
+	}
+	withDistance := set["lat"]
+
+	aps, model, err := cf.load()
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	// Without a location, the areas are sorted by how well their names
+	// match.
+	opts := jp.SearchOptions{Sort: jp.SortByRelevance, Limit: *limit}
+	if withDistance {
+		opts.Origin = &geo.LatLong{Latitude: *lat, Longitude: *long}
+		opts.Model = &model
+		opts.Sort = jp.SortByDistance
+	}
+	result, err := aps.Search(name, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	records := newNearbyRecords(result.Areas, withDistance)
+	return output(cf, records, withDistance, stdout, stderr)
+}
+
+func output(cf *commonFlags, records []record, withDistance bool, stdout, stderr io.Writer) int {
+	f, err := newFormatter(cf.format, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitUsage
+	}
+	if err := f(records, withDistance); err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -2,10 +2,84 @@
 // Use of this source code is governed by a MIT license
 // that can be found in the LICENSE file.
 
+// Command geojp is an API server and command line tool for Japanese
+// geographics.
 package main
 
-import webapp "github.com/twihike/go-geojp/pkg/webapp"
+import (
+	"fmt"
+	"io"
+	"os"
+
+	webapp "github.com/twihike/go-geojp/pkg/webapp"
+)
+
+const usage = `Usage:
+  geojp [serve]                          Run the API server.
+  geojp geocode [flags] <area name>      Find areas by name.
+  geojp reverse [flags] <lat> <long>     Find the nearest areas.
+  geojp near [flags] <lat> <long>        Find areas around a position.
+  geojp validate [flags] <file>          Check a dataset for problems.
+  geojp diff [flags] <old> <new>         Compare two datasets.
+  geojp export-tiles [flags] <file>      Export the addresses as vector tiles.
+
+Run "geojp <command> -h" for the flags of each command.
+`
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+	// exitInvalid means the command ran but found problems in the input.
+	exitInvalid = 3
+)
+
+type command func(args []string, stdout, stderr io.Writer) int
+
+var commands = map[string]command{
+	"serve":        serve,
+	"geocode":      geocode,
+	"reverse":      reverse,
+	"near":         near,
+	"validate":     validate,
+	"diff":         diff,
+	"export-tiles": exportTiles,
+}
 
 func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	os.Exit(run(args, os.Stdout, os.Stderr))
+}
+
+// serve runs the API server. The server takes no flags as it is configured
+// by environment variables.
+func serve(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprint(stderr, "Usage: geojp serve\n\nThe server is configured by environment variables.\n")
+		return exitUsage
+	}
 	webapp.RunServer()
+	return exitOK
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "geojp: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return cmd(args[1:], stdout, stderr)
 }
@@ -0,0 +1,221 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testData = "../../testdata/japanese-addresses.csv"
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       []string
+		wantCode int
+		wantOut  string
+	}{
+		{
+			"geocode json",
+			[]string{"geocode", "-data", testData, "-format", "json", "芝公園三丁目"},
+			exitOK,
+			`[
+  {
+    "pref_name": "東京都",
+    "city_name": "港区",
+    "area_code": "131030002003",
+    "area_name": "芝公園三丁目",
+    "latitude": 35.659943,
+    "longitude": 139.747207
+  }
+]
+`,
+		},
+		{
+			"geocode csv with location",
+			[]string{"geocode", "芝公園三丁目", "-data", testData, "-format", "csv", "-lat", "35.658584", "-long", "139.7454316"},
+			exitOK,
+			`pref_name,city_name,area_code,area_name,latitude,longitude,distance
+東京都,港区,131030002003,芝公園三丁目,35.659943,139.747207,220.37123693585445
+`,
+		},
+		{
+			"geocode csv at null island",
+			[]string{"geocode", "芝公園三丁目", "-data", testData, "-format", "csv", "-lat", "0", "-long", "0"},
+			exitOK,
+			`pref_name,city_name,area_code,area_name,latitude,longitude,distance
+東京都,港区,131030002003,芝公園三丁目,35.659943,139.747207,14268867.683909336
+`,
+		},
+		{
+			"geocode csv by relevance",
+			[]string{"geocode", "芝", "-data", testData, "-format", "csv", "-limit", "3"},
+			exitOK,
+			`pref_name,city_name,area_code,area_name,latitude,longitude
+東京都,港区,131030003001,芝一丁目,35.650981,139.754769
+東京都,港区,131030003002,芝二丁目,35.651338,139.751694
+東京都,港区,131030003003,芝三丁目,35.65233,139.747302
+`,
+		},
+		{
+			"reverse table",
+			[]string{"reverse", "-data", testData, "35.658584", "139.7454316"},
+			exitOK,
+			`pref_name  city_name  area_code     area_name  latitude   longitude   distance
+東京都        港区         131030002003  芝公園三丁目     35.659943  139.747207  220.37123693585445
+`,
+		},
+		{
+			"reverse ndjson k",
+			[]string{"reverse", "35.658584", "139.7454316", "-k", "2", "-data", testData, "-format", "ndjson"},
+			exitOK,
+			`{"pref_name":"東京都","city_name":"港区","area_code":"131030002003","area_name":"芝公園三丁目","latitude":35.659943,"longitude":139.747207,"distance":220.37123693585445}
+{"pref_name":"東京都","city_name":"港区","area_code":"131030023001","area_name":"東麻布一丁目","latitude":35.656698,"longitude":139.743777,"distance":257.53983715026243}
+`,
+		},
+		{
+			"near csv",
+			[]string{"near", "-data", testData, "-format", "csv", "-radius", "250", "35.658584", "139.7454316"},
+			exitOK,
+			`pref_name,city_name,area_code,area_name,latitude,longitude,distance
+東京都,港区,131030002003,芝公園三丁目,35.659943,139.747207,220.37123693585445
+`,
+		},
+		{
+			"validate",
+			[]string{"validate", testData},
+			exitOK,
+			testData + ": 117 records, 0 issues\n",
+		},
+		{
+			"validate invalid",
+			[]string{"validate", "-format", "json", "../../testdata/invalid-addresses.csv"},
+			exitInvalid,
+			`{
+  "path": "../../testdata/invalid-addresses.csv",
+  "records": 2,
+  "counts": {
+    "duplicate_code": 1,
+    "malformed": 3
+  },
+  "issues": [
+    {
+      "kind": "duplicate_code",
+      "index": 1,
+      "area_code": "131030001002",
+      "message": "duplicate of #0",
+      "line": 3
+    },
+    {
+      "kind": "malformed",
+      "index": -1,
+      "area_code": "131030001003",
+      "message": "invalid latitude \"北緯35度\"",
+      "line": 4
+    },
+    {
+      "kind": "malformed",
+      "index": -1,
+      "area_code": "",
+      "message": "want 12 fields, got 6",
+      "line": 5
+    },
+    {
+      "kind": "malformed",
+      "index": -1,
+      "area_code": "",
+      "message": "extraneous or missing \" in quoted-field",
+      "line": 6
+    }
+  ]
+}
+`,
+		},
+		{
+			"validate invalid text",
+			[]string{"validate", "../../testdata/invalid-addresses.csv"},
+			exitInvalid,
+			`../../testdata/invalid-addresses.csv:3: 131030001002: duplicate_code: duplicate of #0
+../../testdata/invalid-addresses.csv:4: 131030001003: malformed: invalid latitude "北緯35度"
+../../testdata/invalid-addresses.csv:5: : malformed: want 12 fields, got 6
+../../testdata/invalid-addresses.csv:6: : malformed: extraneous or missing " in quoted-field
+../../testdata/invalid-addresses.csv: 2 records, 4 issues
+`,
+		},
+		{
+			"diff",
+			[]string{"diff", "../../testdata/japanese-addresses-old.csv", "../../testdata/japanese-addresses-new.csv"},
+			exitOK,
+			`moved    131030001002 東京都港区虎ノ門二丁目 111.2 m
+renamed  131030001003 東京都港区虎ノ門三丁目 -> 東京都港区虎ノ門3丁目
+removed  131030003003 東京都港区芝三丁目
+added    131039999001 東京都港区新町一丁目
+1 added, 1 removed, 1 renamed, 0 recoded, 1 moved
+`,
+		},
+		{
+			"diff geojson",
+			[]string{"diff", "-format", "geojson", "../../testdata/japanese-addresses-old.csv", "../../testdata/japanese-addresses-old.csv"},
+			exitOK,
+			`{"type":"FeatureCollection","features":[]}
+`,
+		},
+		{"unknown command", []string{"foo"}, exitUsage, ""},
+		{"serve with flags", []string{"serve", "-port", "8080"}, exitUsage, ""},
+		{"latitude without longitude", []string{"geocode", "-data", testData, "-lat", "35.6", "芝"}, exitUsage, ""},
+		{"missing name", []string{"geocode", "-data", testData}, exitUsage, ""},
+		{"zero limit", []string{"geocode", "-data", testData, "-limit", "0", "芝"}, exitUsage, ""},
+		{"unknown format", []string{"geocode", "-data", testData, "-format", "xml", "芝"}, exitError, ""},
+		{"invalid latitude", []string{"reverse", "-data", testData, "north", "139"}, exitUsage, ""},
+		{"missing data", []string{"reverse", "-data", "nonexistent.csv", "35", "139"}, exitError, ""},
+		{"missing output", []string{"export-tiles", "-data", testData}, exitUsage, ""},
+		{"invalid zoom", []string{"export-tiles", "-data", testData, "-minzoom", "15", "-maxzoom", "14", "a.mbtiles"}, exitUsage, ""},
+		{"unknown tile format", []string{"export-tiles", "-data", testData, "a.zip"}, exitUsage, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			got := run(tt.in, &stdout, &stderr)
+			if got != tt.wantCode {
+				t.Errorf("want = %v, got = %v, stderr = %v", tt.wantCode, got, stderr.String())
+			}
+			if got := stdout.String(); got != tt.wantOut {
+				t.Errorf("\nwant = %v\ngot  = %v", tt.wantOut, got)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs, _ := newFlagSet("test", "", &bytes.Buffer{})
+	k := fs.Int("k", 1, "")
+	got, err := parseArgs(fs, []string{"-33.8", "-k", "3", "151.2", "--", "-x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-33.8 151.2 -x"; strings.Join(got, " ") != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if *k != 3 {
+		t.Errorf("want = %v, got = %v", 3, *k)
+	}
+}
+
+func TestAddressPath(t *testing.T) {
+	tests := map[string]string{
+		"":                               "",
+		"latest.csv":                     "latest.csv",
+		"stores=stores.csv, latest.csv":  "latest.csv",
+		"stores=stores.csv,depots=a.csv": "",
+	}
+	for in, want := range tests {
+		if got := addressPath(in); got != want {
+			t.Errorf("%q: want = %v, got = %v", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+func near(args []string, stdout, stderr io.Writer) int {
+	fs, cf := newFlagSet("near", "<lat> <long>", stderr)
+	radius := fs.Float64("radius", 1000, "search radius in meters")
+	zoom := fs.Int("zoom", 0, "search the tiles around the position at this zoom level instead of the radius")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 2 || *radius <= 0 || *zoom < 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	p, err := parseLatLong(positional[0], positional[1])
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitUsage
+	}
+
+	aps, model, err := cf.load()
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	iaps := jp.CreateAPIndex(aps, model)
+	var nearby []jp.NearbyAP
+	if *zoom > 0 {
+		nearby = iaps.Near(p, *zoom)
+	} else {
+		nearby = iaps.WithinRadius(p, *radius)
+	}
+	return output(cf, newNearbyRecords(nearby, true), true, stdout, stderr)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+type record struct {
+	PrefName  string   `json:"pref_name"`
+	CityName  string   `json:"city_name"`
+	AreaCode  string   `json:"area_code"`
+	AreaName  string   `json:"area_name"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Distance  *float64 `json:"distance,omitempty"`
+}
+
+func newRecord(ap jp.AddressPosition) record {
+	return record{
+		PrefName:  ap.PrefName,
+		CityName:  ap.CityName,
+		AreaCode:  ap.AreaCode,
+		AreaName:  ap.AreaName,
+		Latitude:  ap.Latitude,
+		Longitude: ap.Longitude,
+	}
+}
+
+func newNearbyRecords(aps []jp.NearbyAP, withDistance bool) []record {
+	records := make([]record, len(aps))
+	for i, ap := range aps {
+		records[i] = newRecord(ap.AddressPosition)
+		if withDistance {
+			d := ap.Distance
+			records[i].Distance = &d
+		}
+	}
+	return records
+}
+
+// formatter writes records in an output format.
+type formatter func(records []record, withDistance bool) error
+
+func newFormatter(format string, w io.Writer) (formatter, error) {
+	switch format {
+	case "table":
+		return func(records []record, withDistance bool) error {
+			return writeTable(w, records, withDistance)
+		}, nil
+	case "json":
+		return func(records []record, _ bool) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(records)
+		}, nil
+	case "ndjson":
+		return func(records []record, _ bool) error {
+			enc := json.NewEncoder(w)
+			for _, r := range records {
+				if err := enc.Encode(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case "csv":
+		return func(records []record, withDistance bool) error {
+			return writeCSV(w, records, withDistance)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown format: %q", format)
+	}
+}
+
+func header(withDistance bool) []string {
+	h := []string{"pref_name", "city_name", "area_code", "area_name", "latitude", "longitude"}
+	if withDistance {
+		h = append(h, "distance")
+	}
+	return h
+}
+
+func (r record) fields(withDistance bool) []string {
+	f := []string{
+		r.PrefName,
+		r.CityName,
+		r.AreaCode,
+		r.AreaName,
+		strconv.FormatFloat(r.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(r.Longitude, 'f', -1, 64),
+	}
+	if withDistance && r.Distance != nil {
+		f = append(f, strconv.FormatFloat(*r.Distance, 'f', -1, 64))
+	}
+	return f
+}
+
+func writeTable(w io.Writer, records []record, withDistance bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	write := func(fields []string) {
+		for i, f := range fields {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, f)
+		}
+		fmt.Fprintln(tw)
+	}
+	write(header(withDistance))
+	for _, r := range records {
+		write(r.fields(withDistance))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, records []record, withDistance bool) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header(withDistance)); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := cw.Write(r.fields(withDistance)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
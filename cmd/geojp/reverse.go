@@ -0,0 +1,44 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+func reverse(args []string, stdout, stderr io.Writer) int {
+	fs, cf := newFlagSet("reverse", "<lat> <long>", stderr)
+	k := fs.Int("k", 1, "number of nearest areas")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 2 || *k < 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	p, err := parseLatLong(positional[0], positional[1])
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitUsage
+	}
+
+	aps, model, err := cf.load()
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	iaps := jp.CreateAPIndex(aps, model)
+	var nearest []jp.NearbyAP
+	if *k == 1 {
+		nearest = []jp.NearbyAP{iaps.Nearest(p)}
+	} else {
+		nearest = iaps.KNearest(p, *k)
+	}
+	return output(cf, newNearbyRecords(nearest, true), true, stdout, stderr)
+}
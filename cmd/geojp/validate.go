@@ -0,0 +1,75 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+type validationReport struct {
+	Path    string               `json:"path"`
+	Records int                  `json:"records"`
+	Counts  map[jp.IssueKind]int `json:"counts"`
+	Issues  []jp.FileIssue       `json:"issues"`
+}
+
+func validate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: geojp validate [flags] <file>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	if len(positional) != 1 || (*format != "text" && *format != "json") {
+		fs.Usage()
+		return exitUsage
+	}
+
+	aps, issues, err := jp.ValidateFile(positional[0])
+	if err != nil {
+		fmt.Fprintln(stderr, "geojp:", err)
+		return exitError
+	}
+	report := validationReport{
+		Path:    positional[0],
+		Records: len(aps),
+		Counts:  map[jp.IssueKind]int{},
+		Issues:  issues,
+	}
+	for _, i := range report.Issues {
+		report.Counts[i.Kind]++
+	}
+
+	if *format == "json" {
+		if report.Issues == nil {
+			report.Issues = []jp.FileIssue{}
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintln(stderr, "geojp:", err)
+			return exitError
+		}
+	} else {
+		for _, i := range report.Issues {
+			fmt.Fprintf(stdout, "%s:%d: %s: %s: %s\n", report.Path, i.Line, i.AreaCode, i.Kind, i.Message)
+		}
+		fmt.Fprintf(stdout, "%s: %d records, %d issues\n", report.Path, report.Records, len(report.Issues))
+	}
+	if len(report.Issues) > 0 {
+		return exitInvalid
+	}
+	return exitOK
+}
@@ -2,6 +2,19 @@ module github.com/twihike/go-geojp
 
 go 1.17
 
-require github.com/twihike/go-structconv v0.0.0-20210919130734-15d2a7789c0d
+require (
+	github.com/graph-gophers/graphql-go v1.3.0
+	github.com/twihike/go-structconv v0.0.0-20210919130734-15d2a7789c0d
+	golang.org/x/net v0.0.0-20211020060615-d418f374d309
+	google.golang.org/grpc v1.48.0
+	google.golang.org/protobuf v1.31.0
+)
 
-require github.com/twihike/go-strcase v0.0.0-20210918145406-6daf5890f181 // indirect
+require (
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/opentracing/opentracing-go v1.1.0 // indirect
+	github.com/twihike/go-strcase v0.0.0-20210918145406-6daf5890f181 // indirect
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da // indirect
+	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+)
@@ -0,0 +1,166 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+)
+
+// BBox represents a bounding box by its south-west and north-east corners.
+type BBox struct {
+	Min LatLong
+	Max LatLong
+}
+
+// EmptyBBox returns a bounding box that contains nothing. Extending it with a
+// point yields a bounding box of that point.
+func EmptyBBox() BBox {
+	return BBox{
+		Min: LatLong{Latitude: math.Inf(1), Longitude: math.Inf(1)},
+		Max: LatLong{Latitude: math.Inf(-1), Longitude: math.Inf(-1)},
+	}
+}
+
+// NewBBox returns the smallest bounding box that contains the specified
+// points.
+func NewBBox(points ...LatLong) BBox {
+	b := EmptyBBox()
+	for _, p := range points {
+		b = b.Extend(p)
+	}
+	return b
+}
+
+// IsEmpty reports whether the bounding box contains nothing.
+func (b BBox) IsEmpty() bool {
+	return b.Min.Latitude > b.Max.Latitude || b.Min.Longitude > b.Max.Longitude
+}
+
+// Bounds returns the bounding box itself.
+func (b BBox) Bounds() BBox {
+	return b
+}
+
+// Center returns the center of the bounding box.
+func (b BBox) Center() LatLong {
+	return LatLong{
+		Latitude:  (b.Min.Latitude + b.Max.Latitude) / 2,
+		Longitude: (b.Min.Longitude + b.Max.Longitude) / 2,
+	}
+}
+
+// Contains reports whether the bounding box contains the specified point,
+// including its boundary.
+func (b BBox) Contains(p LatLong) bool {
+	return b.Min.Latitude <= p.Latitude && p.Latitude <= b.Max.Latitude &&
+		b.Min.Longitude <= p.Longitude && p.Longitude <= b.Max.Longitude
+}
+
+// ContainsBBox reports whether the bounding box contains the other one.
+func (b BBox) ContainsBBox(other BBox) bool {
+	if other.IsEmpty() {
+		return true
+	}
+	return b.Contains(other.Min) && b.Contains(other.Max)
+}
+
+// Intersects reports whether the bounding boxes share any point.
+func (b BBox) Intersects(other BBox) bool {
+	if b.IsEmpty() || other.IsEmpty() {
+		return false
+	}
+	return b.Min.Latitude <= other.Max.Latitude && other.Min.Latitude <= b.Max.Latitude &&
+		b.Min.Longitude <= other.Max.Longitude && other.Min.Longitude <= b.Max.Longitude
+}
+
+// Extend returns the bounding box extended to contain the specified point.
+func (b BBox) Extend(p LatLong) BBox {
+	return BBox{
+		Min: LatLong{
+			Latitude:  math.Min(b.Min.Latitude, p.Latitude),
+			Longitude: math.Min(b.Min.Longitude, p.Longitude),
+		},
+		Max: LatLong{
+			Latitude:  math.Max(b.Max.Latitude, p.Latitude),
+			Longitude: math.Max(b.Max.Longitude, p.Longitude),
+		},
+	}
+}
+
+// Union returns the smallest bounding box that contains both bounding boxes.
+func (b BBox) Union(other BBox) BBox {
+	if other.IsEmpty() {
+		return b
+	}
+	return b.Extend(other.Min).Extend(other.Max)
+}
+
+// Buffer returns the bounding box grown by the specified distance in meters
+// on every side. The latitudes stop at the poles, and a buffer that reaches
+// a pole covers every longitude.
+func (b BBox) Buffer(meters float64) BBox {
+	if b.IsEmpty() {
+		return b
+	}
+	// Destination would go over a pole and come down on the other side, so
+	// the buffer is compared with the meridian arcs to the poles first.
+	toNorth, _, _ := GRS80.Inverse(b.Max, LatLong{Latitude: 90, Longitude: b.Max.Longitude})
+	toSouth, _, _ := GRS80.Inverse(b.Min, LatLong{Latitude: -90, Longitude: b.Min.Longitude})
+	north, south := 90.0, -90.0
+	if meters < toNorth {
+		north = b.Max.Destination(0, meters).Latitude
+	}
+	if meters < toSouth {
+		south = b.Min.Destination(180, meters).Latitude
+	}
+	if meters >= toNorth || meters >= toSouth {
+		return BBox{
+			Min: LatLong{Latitude: south, Longitude: MinLongitude},
+			Max: LatLong{Latitude: north, Longitude: MaxLongitude},
+		}
+	}
+
+	// Meridians converge towards the poles, so the longitude offset is taken
+	// at the latitude farthest from the equator.
+	lat := north
+	if math.Abs(south) > math.Abs(lat) {
+		lat = south
+	}
+	edge := LatLong{Latitude: lat, Longitude: 0}
+	dLong := edge.Destination(90, meters).Longitude
+	if dLong <= 0 {
+		// The buffer goes more than half way round the parallel.
+		dLong = MaxLongitude - MinLongitude
+	}
+	return BBox{
+		Min: LatLong{
+			Latitude:  south,
+			Longitude: math.Max(b.Min.Longitude-dLong, MinLongitude),
+		},
+		Max: LatLong{
+			Latitude:  north,
+			Longitude: math.Min(b.Max.Longitude+dLong, MaxLongitude),
+		},
+	}
+}
+
+// Area returns the area of the bounding box in square meters.
+func (b BBox) Area() float64 {
+	if b.IsEmpty() {
+		return 0
+	}
+	return b.Polygon().Area()
+}
+
+// Polygon returns the bounding box as a polygon.
+func (b BBox) Polygon() Polygon {
+	return Polygon{{
+		{Latitude: b.Min.Latitude, Longitude: b.Min.Longitude},
+		{Latitude: b.Min.Latitude, Longitude: b.Max.Longitude},
+		{Latitude: b.Max.Latitude, Longitude: b.Max.Longitude},
+		{Latitude: b.Max.Latitude, Longitude: b.Min.Longitude},
+		{Latitude: b.Min.Latitude, Longitude: b.Min.Longitude},
+	}}
+}
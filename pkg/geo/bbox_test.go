@@ -0,0 +1,138 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewBBox(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        []LatLong
+		want      BBox
+		wantEmpty bool
+	}{
+		{"empty", nil, EmptyBBox(), true},
+		{
+			"normal",
+			[]LatLong{{Latitude: 35, Longitude: 140}, {Latitude: 34, Longitude: 141}},
+			BBox{Min: LatLong{Latitude: 34, Longitude: 140}, Max: LatLong{Latitude: 35, Longitude: 141}},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := NewBBox(tt.in...)
+			if got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			if got.IsEmpty() != tt.wantEmpty {
+				t.Errorf("want = %v, got = %v", tt.wantEmpty, got.IsEmpty())
+			}
+		})
+	}
+}
+
+func TestBBox_Intersects(t *testing.T) {
+	b := BBox{Min: LatLong{Latitude: 0, Longitude: 0}, Max: LatLong{Latitude: 1, Longitude: 1}}
+	tests := []struct {
+		name string
+		in   BBox
+		want bool
+	}{
+		{"overlap", BBox{Min: LatLong{Latitude: 0.5, Longitude: 0.5}, Max: LatLong{Latitude: 2, Longitude: 2}}, true},
+		{"touch", BBox{Min: LatLong{Latitude: 1, Longitude: 1}, Max: LatLong{Latitude: 2, Longitude: 2}}, true},
+		{"apart", BBox{Min: LatLong{Latitude: 1.5, Longitude: 0}, Max: LatLong{Latitude: 2, Longitude: 1}}, false},
+		{"empty", EmptyBBox(), false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := b.Intersects(tt.in); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+	if !b.ContainsBBox(BBox{Min: LatLong{Latitude: 0.2, Longitude: 0.2}, Max: LatLong{Latitude: 0.8, Longitude: 0.8}}) {
+		t.Error("want contained, got not contained")
+	}
+	if got := b.Union(EmptyBBox()); got != b {
+		t.Errorf("want = %v, got = %v", b, got)
+	}
+}
+
+func TestBBox_Buffer(t *testing.T) {
+	p := LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	got := p.Bounds().Buffer(1000)
+	corners := []LatLong{
+		p.Destination(0, 1000),
+		p.Destination(90, 1000),
+		p.Destination(180, 1000),
+		p.Destination(270, 1000),
+	}
+	for _, c := range corners {
+		if !got.Buffer(1).Contains(c) {
+			t.Errorf("want %v to contain %v", got, c)
+		}
+	}
+	wantArea := 2000.0 * 2000.0
+	if a := got.Area(); math.Abs(a-wantArea)/wantArea > 0.01 {
+		t.Errorf("want = about %v, got = %v", wantArea, a)
+	}
+}
+
+func TestBBox_BufferPoles(t *testing.T) {
+	world := BBox{
+		Min: LatLong{Latitude: -90, Longitude: MinLongitude},
+		Max: LatLong{Latitude: 90, Longitude: MaxLongitude},
+	}
+	tests := []struct {
+		name   string
+		in     LatLong
+		meters float64
+		want   BBox
+	}{
+		{
+			"over the north pole",
+			LatLong{Latitude: 89, Longitude: 179},
+			500000,
+			BBox{
+				Min: LatLong{Latitude: 84.52331570829041, Longitude: MinLongitude},
+				Max: LatLong{Latitude: 90, Longitude: MaxLongitude},
+			},
+		},
+		{
+			"over the south pole",
+			LatLong{Latitude: -89.9, Longitude: 0},
+			50000,
+			BBox{
+				Min: LatLong{Latitude: -90, Longitude: MinLongitude},
+				Max: LatLong{Latitude: -89.45234813090673, Longitude: MaxLongitude},
+			},
+		},
+		{"half the circumference", LatLong{Latitude: 0, Longitude: 0}, 20100000, world},
+		{"beyond the circumference", LatLong{Latitude: 35.658584, Longitude: 139.7454316}, 1e9, world},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.in.Bounds().Buffer(tt.meters)
+			if math.Abs(got.Min.Latitude-tt.want.Min.Latitude) > 1e-6 ||
+				math.Abs(got.Max.Latitude-tt.want.Max.Latitude) > 1e-6 ||
+				got.Min.Longitude != tt.want.Min.Longitude || got.Max.Longitude != tt.want.Max.Longitude {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			if !got.Contains(tt.in) {
+				t.Errorf("want %v to contain %v", got, tt.in)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// MaxCellLevel is the maximum level of a CellID.
+const MaxCellLevel = 30
+
+// CellID is a compact representation of a quadkey as a 64-bit integer.
+//
+// The bits of the tile coordinates are interleaved in quadkey digit order and
+// followed by a single marker bit whose position encodes the level, in the
+// same way as S2 cell IDs. As a result, sorting cell IDs sorts them like
+// quadkey strings, and all descendants of a cell lie in the contiguous range
+// from RangeMin to RangeMax.
+type CellID uint64
+
+// TileToCellID converts tile coordinates to a cell ID.
+func TileToCellID(tileX, tileY uint, zoom int) CellID {
+	var pos uint64
+	for i := zoom; i > 0; i-- {
+		mask := uint(1) << uint(i-1)
+		digit := uint64(0)
+		if (tileX & mask) != 0 {
+			digit++
+		}
+		if (tileY & mask) != 0 {
+			digit += 2
+		}
+		pos = pos<<2 | digit
+	}
+	return CellID((pos<<1 | 1) << uint(2*(MaxCellLevel-zoom)))
+}
+
+// LatLongToCellID converts latitude and longitude to a cell ID.
+func LatLongToCellID(lat, long float64, level int) CellID {
+	pixelX, pixelY := LatLongToPixel(lat, long, level)
+	tileX, tileY := PixelToTile(pixelX, pixelY)
+	return TileToCellID(tileX, tileY, level)
+}
+
+// QuadkeyToCellID converts a quadkey to a cell ID.
+// It returns zero if the quadkey is invalid.
+func QuadkeyToCellID(quadkey string) CellID {
+	if len(quadkey) > MaxCellLevel {
+		return 0
+	}
+	var pos uint64
+	for i := 0; i < len(quadkey); i++ {
+		d := quadkey[i] - '0'
+		if d > 3 {
+			return 0
+		}
+		pos = pos<<2 | uint64(d)
+	}
+	return CellID((pos<<1 | 1) << uint(2*(MaxCellLevel-len(quadkey))))
+}
+
+// IsValid reports whether the cell ID has a valid level marker.
+func (c CellID) IsValid() bool {
+	if c == 0 || c>>(2*MaxCellLevel+1) != 0 {
+		return false
+	}
+	return bits.TrailingZeros64(uint64(c))%2 == 0
+}
+
+// Level returns the level of the cell, which equals the zoom level of the
+// corresponding tile. It returns -1 if the cell ID is invalid.
+func (c CellID) Level() int {
+	if !c.IsValid() {
+		return -1
+	}
+	return MaxCellLevel - bits.TrailingZeros64(uint64(c))/2
+}
+
+func (c CellID) lsb() uint64 {
+	return uint64(c) & -uint64(c)
+}
+
+// Tile returns the tile coordinates of the cell. The zoom level is -1 if
+// the cell ID is invalid.
+func (c CellID) Tile() (tileX, tileY uint, zoom int) {
+	zoom = c.Level()
+	if zoom < 0 {
+		return 0, 0, zoom
+	}
+	pos := uint64(c) >> uint(2*(MaxCellLevel-zoom)+1)
+	for i := 0; i < zoom; i++ {
+		digit := pos >> uint(2*i) & 3
+		tileX |= uint(digit&1) << uint(i)
+		tileY |= uint(digit>>1) << uint(i)
+	}
+	return tileX, tileY, zoom
+}
+
+// Quadkey returns the quadkey of the cell, or "" if the cell ID is invalid.
+func (c CellID) Quadkey() string {
+	zoom := c.Level()
+	if zoom < 0 {
+		return ""
+	}
+	pos := uint64(c) >> uint(2*(MaxCellLevel-zoom)+1)
+	var quadkey strings.Builder
+	quadkey.Grow(zoom)
+	for i := zoom - 1; i >= 0; i-- {
+		quadkey.WriteByte(byte('0' + pos>>uint(2*i)&3))
+	}
+	return quadkey.String()
+}
+
+// LatLong returns the latitude and longitude of the north-west corner of the
+// cell, or zeros if the cell ID is invalid.
+func (c CellID) LatLong() (lat, long float64) {
+	tileX, tileY, zoom := c.Tile()
+	if zoom < 0 {
+		return 0, 0
+	}
+	pixelX, pixelY := TileToPixel(tileX, tileY)
+	return PixelToLatLong(pixelX, pixelY, zoom)
+}
+
+// Parent returns the cell one level above. The parent of the cell at level
+// zero is itself, and that of an invalid cell ID is zero.
+func (c CellID) Parent() CellID {
+	switch c.Level() {
+	case -1:
+		return 0
+	case 0:
+		return c
+	}
+	p, _ := c.ParentAt(c.Level() - 1)
+	return p
+}
+
+// ParentAt returns the ancestor of the cell at the specified level, or the
+// cell itself at its own level. It returns false if the level is negative or
+// below the level of the cell, or if the cell ID is invalid.
+func (c CellID) ParentAt(level int) (CellID, bool) {
+	if level < 0 || level > c.Level() {
+		return 0, false
+	}
+	lsb := uint64(1) << uint(2*(MaxCellLevel-level))
+	return CellID(uint64(c)&-lsb | lsb), true
+}
+
+// IsLeaf reports whether the cell is at the maximum level. An invalid cell ID
+// is not a leaf.
+func (c CellID) IsLeaf() bool {
+	return c.Level() == MaxCellLevel
+}
+
+// Children returns the four cells one level below in quadkey digit order.
+// It returns false if the cell is a leaf or the cell ID is invalid.
+func (c CellID) Children() ([4]CellID, bool) {
+	if !c.IsValid() || c.IsLeaf() {
+		return [4]CellID{}, false
+	}
+	lsb := c.lsb()
+	childLSB := lsb >> 2
+	base := uint64(c) - lsb
+	return [4]CellID{
+		CellID(base + childLSB),
+		CellID(base + 3*childLSB),
+		CellID(base + 5*childLSB),
+		CellID(base + 7*childLSB),
+	}, true
+}
+
+// RangeMin returns the smallest cell ID among the descendants at the maximum
+// level, or zero if the cell ID is invalid.
+func (c CellID) RangeMin() CellID {
+	if !c.IsValid() {
+		return 0
+	}
+	return CellID(uint64(c) - (c.lsb() - 1))
+}
+
+// RangeMax returns the largest cell ID among the descendants at the maximum
+// level, or zero if the cell ID is invalid.
+func (c CellID) RangeMax() CellID {
+	if !c.IsValid() {
+		return 0
+	}
+	return CellID(uint64(c) + (c.lsb() - 1))
+}
+
+// Contains reports whether the cell contains the other cell. Invalid cell
+// IDs contain nothing and are contained in nothing.
+func (c CellID) Contains(other CellID) bool {
+	if !c.IsValid() || !other.IsValid() {
+		return false
+	}
+	return c.RangeMin() <= other && other <= c.RangeMax()
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"testing"
+)
+
+func TestCellID_Quadkey(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"root", ""},
+		{"normal", "13300211230311333132022"},
+		{"max", "133002112303113331320221230123"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := QuadkeyToCellID(tt.in)
+			if !c.IsValid() {
+				t.Fatalf("want valid, got = %x", uint64(c))
+			}
+			if got := c.Level(); got != len(tt.in) {
+				t.Errorf("want = %v, got = %v", len(tt.in), got)
+			}
+			if got := c.Quadkey(); got != tt.in {
+				t.Errorf("want = %v, got = %v", tt.in, got)
+			}
+			x, y, z := QuadkeyToTile(tt.in)
+			if got := TileToCellID(x, y, z); got != c {
+				t.Errorf("want = %v, got = %v", c, got)
+			}
+			gotX, gotY, gotZ := c.Tile()
+			if gotX != x || gotY != y || gotZ != z {
+				t.Errorf("want = %v/%v/%v, got = %v/%v/%v", z, x, y, gotZ, gotX, gotY)
+			}
+		})
+	}
+}
+
+func TestLatLongToCellID(t *testing.T) {
+	got := LatLongToCellID(35.658584, 139.7454316, 23)
+	want := QuadkeyToCellID("13300211230311333132022")
+	if got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	lat, long := got.LatLong()
+	wantLat, wantLong := QuadkeyToLatLong("13300211230311333132022")
+	if lat != wantLat || long != wantLong {
+		t.Errorf("want = %v,%v, got = %v,%v", wantLat, wantLong, lat, long)
+	}
+}
+
+func TestCellID_IsValid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   CellID
+		want bool
+	}{
+		{"zero", 0, false},
+		{"odd trailing zeros", 2, false},
+		{"too large", 1 << 62, false},
+		{"invalid quadkey", QuadkeyToCellID("0124"), false},
+		{"leaf", 1, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.in.IsValid(); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCellID_Hierarchy(t *testing.T) {
+	c := QuadkeyToCellID("1330021")
+	children, ok := c.Children()
+	if !ok {
+		t.Fatalf("want = %v, got = %v", true, ok)
+	}
+	for i, child := range children {
+		want := "1330021" + string(rune('0'+i))
+		if got := child.Quadkey(); got != want {
+			t.Errorf("want = %v, got = %v", want, got)
+		}
+		if got := child.Parent(); got != c {
+			t.Errorf("want = %v, got = %v", c, got)
+		}
+		if !c.Contains(child) {
+			t.Errorf("want %v to contain %v", c.Quadkey(), child.Quadkey())
+		}
+		if i > 0 && children[i-1].RangeMax() >= child.RangeMin() {
+			t.Errorf("want ranges of %v and %v to be disjoint", children[i-1], child)
+		}
+	}
+	if got := children[0].RangeMin(); got != c.RangeMin() {
+		t.Errorf("want = %v, got = %v", c.RangeMin(), got)
+	}
+	if got := children[3].RangeMax(); got != c.RangeMax() {
+		t.Errorf("want = %v, got = %v", c.RangeMax(), got)
+	}
+	if got, ok := c.ParentAt(3); !ok || got.Quadkey() != "133" {
+		t.Errorf("want = %v, got = %v %v", "133", got.Quadkey(), ok)
+	}
+	if got, ok := c.ParentAt(7); !ok || got != c {
+		t.Errorf("want = %v, got = %v %v", c, got, ok)
+	}
+	for _, level := range []int{-1, 8, MaxCellLevel + 1} {
+		if got, ok := c.ParentAt(level); ok {
+			t.Errorf("%d: want = %v, got = %v %v", level, false, got, ok)
+		}
+	}
+	if c.Contains(QuadkeyToCellID("1330")) {
+		t.Errorf("want %v not to contain its parent", c.Quadkey())
+	}
+	if got := QuadkeyToCellID("").Parent(); got != QuadkeyToCellID("") {
+		t.Errorf("want = %v, got = %v", QuadkeyToCellID(""), got)
+	}
+
+	leaf := LatLongToCellID(35.658584, 139.7454316, MaxCellLevel)
+	if !leaf.IsLeaf() || c.IsLeaf() {
+		t.Errorf("want = %v %v, got = %v %v", true, false, leaf.IsLeaf(), c.IsLeaf())
+	}
+	if got, ok := leaf.Children(); ok {
+		t.Errorf("want = %v, got = %v %v", false, got, ok)
+	}
+	if got := leaf.Parent(); got.Level() != MaxCellLevel-1 || !got.Contains(leaf) {
+		t.Errorf("want = parent of %v, got = %v", leaf, got)
+	}
+}
+
+func TestCellID_Invalid(t *testing.T) {
+	for _, c := range []CellID{QuadkeyToCellID("x"), 2, 1 << 62} {
+		if got := c.Quadkey(); got != "" {
+			t.Errorf("%d: want = %q, got = %q", c, "", got)
+		}
+		if got := c.Level(); got != -1 {
+			t.Errorf("%d: want = %v, got = %v", c, -1, got)
+		}
+		if x, y, zoom := c.Tile(); x != 0 || y != 0 || zoom != -1 {
+			t.Errorf("%d: want = %v, got = %v", c, []int{0, 0, -1}, []int{int(x), int(y), zoom})
+		}
+		if lat, long := c.LatLong(); lat != 0 || long != 0 {
+			t.Errorf("%d: want = %v,%v, got = %v,%v", c, 0, 0, lat, long)
+		}
+		if got := c.Parent(); got != 0 {
+			t.Errorf("%d: want = %v, got = %v", c, 0, got)
+		}
+		if got, ok := c.ParentAt(0); ok {
+			t.Errorf("%d: want = %v, got = %v %v", c, false, got, ok)
+		}
+		if got, ok := c.Children(); ok || c.IsLeaf() {
+			t.Errorf("%d: want = %v, got = %v %v", c, false, got, ok)
+		}
+		if c.RangeMin() != 0 || c.RangeMax() != 0 {
+			t.Errorf("%d: want = %v, got = %v-%v", c, 0, c.RangeMin(), c.RangeMax())
+		}
+		if root := QuadkeyToCellID(""); root.Contains(c) || c.Contains(root) {
+			t.Errorf("%d: want = %v, got = %v", c, false, true)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package fence
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+var (
+	shibakoen = geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	hamamatsu = geo.LatLong{Latitude: 35.655391, Longitude: 139.757135}
+	roppongi  = geo.LatLong{Latitude: 35.662836, Longitude: 139.731443}
+)
+
+// square returns a polygon of about 4 km square around the position.
+func square(p geo.LatLong) geo.Polygon {
+	const d = 0.02
+	return geo.Polygon{{
+		{Latitude: p.Latitude - d, Longitude: p.Longitude - d},
+		{Latitude: p.Latitude - d, Longitude: p.Longitude + d},
+		{Latitude: p.Latitude + d, Longitude: p.Longitude + d},
+		{Latitude: p.Latitude + d, Longitude: p.Longitude - d},
+		{Latitude: p.Latitude - d, Longitude: p.Longitude - d},
+	}}
+}
+
+func zoneIDs(zones []Zone) []string {
+	ids := []string{}
+	for _, z := range zones {
+		ids = append(ids, z.ID)
+	}
+	return ids
+}
+
+func newTestIndex(t *testing.T) *Index {
+	t.Helper()
+	x := NewIndex(0)
+	for _, z := range []Zone{
+		{ID: "minato", Region: square(shibakoen)},
+		{ID: "shibakoen", Region: geo.Cap{Center: shibakoen, Radius: 500}},
+		{ID: "hamamatsu", Region: geo.Cap{Center: hamamatsu, Radius: 300}},
+	} {
+		if _, err := x.Put(z); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return x
+}
+
+func TestIndex_Containing(t *testing.T) {
+	x := newTestIndex(t)
+	tests := []struct {
+		name string
+		p    geo.LatLong
+		want []string
+	}{
+		{"two zones", shibakoen, []string{"minato", "shibakoen"}},
+		{"cap", hamamatsu, []string{"hamamatsu", "minato"}},
+		{"polygon only", roppongi, []string{"minato"}},
+		{"outside", geo.LatLong{Latitude: 34.7, Longitude: 135.5}, []string{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := zoneIDs(x.Containing(tt.p)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIndex_PutDelete(t *testing.T) {
+	x := newTestIndex(t)
+	added, err := x.Put(Zone{ID: "shibakoen", Region: geo.Cap{Center: roppongi, Radius: 100}})
+	if err != nil || added {
+		t.Fatalf("want = %v, got = %v, %v", false, added, err)
+	}
+	if got, want := zoneIDs(x.Containing(shibakoen)), []string{"minato"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got, want := zoneIDs(x.Containing(roppongi)), []string{"minato", "shibakoen"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+
+	if !x.Delete("minato") || x.Delete("minato") {
+		t.Errorf("want = %v, got = %v", "deleted once", "otherwise")
+	}
+	if _, ok := x.Get("minato"); ok {
+		t.Errorf("want = %v, got = %v", false, ok)
+	}
+	if got, want := zoneIDs(x.Zones()), []string{"hamamatsu", "shibakoen"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	for q, ids := range x.cells {
+		if ids["minato"] {
+			t.Errorf("want = %v, got = %v", "no cell", q)
+		}
+	}
+
+	for _, z := range []Zone{
+		{Region: square(shibakoen)},
+		{ID: "nil"},
+		{ID: "negative", Region: square(shibakoen), Dwell: -time.Second},
+	} {
+		if _, err := x.Put(z); err == nil {
+			t.Errorf("%s: want = %v, got = %v", z.ID, "error", err)
+		}
+	}
+}
+
+func TestIndex_Limits(t *testing.T) {
+	x := NewIndex(2)
+	for _, id := range []string{"a", "b"} {
+		if _, err := x.Put(Zone{ID: id, Region: square(shibakoen)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := x.Put(Zone{ID: "c", Region: square(shibakoen)}); err != ErrTooManyZones {
+		t.Errorf("want = %v, got = %v", ErrTooManyZones, err)
+	}
+	if _, err := x.Put(Zone{ID: "a", Region: square(roppongi)}); err != nil {
+		t.Errorf("want = %v, got = %v", nil, err)
+	}
+	x.Delete("b")
+	if _, err := x.Put(Zone{ID: "c", Region: square(shibakoen)}); err != nil {
+		t.Errorf("want = %v, got = %v", nil, err)
+	}
+
+	// A ring of MaxVertices vertices around Shiba Park, and one more.
+	ring := make([]geo.LatLong, MaxVertices)
+	for i := range ring[:MaxVertices-1] {
+		ring[i] = shibakoen.Destination(360*float64(i)/float64(MaxVertices-1), 500)
+	}
+	ring[MaxVertices-1] = ring[0]
+	x = NewIndex(0)
+	if _, err := x.Put(Zone{ID: "max", Region: geo.Polygon{ring}}); err != nil {
+		t.Errorf("want = %v, got = %v", nil, err)
+	}
+	over := geo.MultiPolygon{{ring}, square(roppongi)}
+	if _, err := x.Put(Zone{ID: "over", Region: over}); err != ErrTooManyVertices {
+		t.Errorf("want = %v, got = %v", ErrTooManyVertices, err)
+	}
+}
+
+func TestTracker_Update(t *testing.T) {
+	x := newTestIndex(t)
+	if _, err := x.Put(Zone{ID: "hamamatsu", Region: geo.Cap{Center: hamamatsu, Radius: 300}, Dwell: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTracker(x, 5*time.Minute, 0, 0)
+	t0 := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return t0.Add(time.Hour) }
+
+	type event struct {
+		Type     EventType
+		Zone     string
+		Duration time.Duration
+	}
+	steps := []struct {
+		p    geo.LatLong
+		at   time.Duration
+		want []event
+	}{
+		{roppongi, 0, []event{{Enter, "minato", 0}}},
+		{shibakoen, time.Minute, []event{{Enter, "shibakoen", 0}}},
+		{shibakoen, 4 * time.Minute, []event{}},
+		{shibakoen, 6 * time.Minute, []event{{Dwell, "minato", 6 * time.Minute}, {Dwell, "shibakoen", 5 * time.Minute}}},
+		{shibakoen, 7 * time.Minute, []event{}},
+		{hamamatsu, 8 * time.Minute, []event{{Exit, "shibakoen", 7 * time.Minute}, {Enter, "hamamatsu", 0}}},
+		{hamamatsu, 20 * time.Minute, []event{}},
+		{geo.LatLong{Latitude: 34.7, Longitude: 135.5}, 30 * time.Minute, []event{
+			{Exit, "hamamatsu", 22 * time.Minute},
+			{Exit, "minato", 30 * time.Minute},
+		}},
+	}
+	for i, s := range steps {
+		events, err := tr.Update("d1", s.p, t0.Add(s.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []event{}
+		for _, e := range events {
+			if e.DeviceID != "d1" || e.Position != s.p || !e.Time.Equal(t0.Add(s.at)) {
+				t.Errorf("%d: want = %v, got = %+v", i, "the update", e)
+			}
+			got = append(got, event{e.Type, e.Zone.ID, e.Duration})
+		}
+		if !reflect.DeepEqual(got, s.want) {
+			t.Errorf("%d: want = %v, got = %v", i, s.want, got)
+		}
+	}
+
+	if _, err := tr.Update("d1", shibakoen, t0); err != ErrStalePosition {
+		t.Errorf("want = %v, got = %v", ErrStalePosition, err)
+	}
+	if _, err := tr.Update("d1", shibakoen, t0.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := zoneIDs(tr.Zones("d1")), []string{"minato", "shibakoen"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if _, err := tr.Update("d1", shibakoen, t0.Add(time.Hour+MaxClockSkew+time.Second)); err != ErrFuturePosition {
+		t.Errorf("want = %v, got = %v", ErrFuturePosition, err)
+	}
+	if got := tr.Expire(t0.Add(100 * time.Hour)); got != nil {
+		t.Errorf("want = %v, got = %v", nil, got)
+	}
+}
+
+func TestTracker_Expire(t *testing.T) {
+	x := newTestIndex(t)
+	tr := NewTracker(x, 0, time.Hour, 2)
+	t0 := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	now := t0
+	tr.now = func() time.Time { return now }
+
+	if _, err := tr.Update("d1", shibakoen, t0); err != nil {
+		t.Fatal(err)
+	}
+	now = t0.Add(30 * time.Minute)
+	if _, err := tr.Update("d2", roppongi, t0.Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Update("d3", hamamatsu, now); err != ErrTooManyDevices {
+		t.Errorf("want = %v, got = %v", ErrTooManyDevices, err)
+	}
+
+	exits := tr.Expire(t0.Add(time.Hour))
+	var got []string
+	for _, e := range exits {
+		if e.Type != Exit || !e.Expired || e.DeviceID != "d1" || e.Position != shibakoen || !e.Time.Equal(t0) {
+			t.Errorf("want = %v, got = %+v", "an expired exit of d1", e)
+		}
+		got = append(got, e.Zone.ID)
+	}
+	if want := []string{"minato", "shibakoen"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if tr.Len() != 1 || tr.Zones("d1") != nil {
+		t.Errorf("want = %v, got = %v", 1, tr.Len())
+	}
+	// The device that was expired is new again, and a slot is free.
+	events, err := tr.Update("d1", shibakoen, now)
+	if err != nil || len(events) != 2 || events[0].Type != Enter {
+		t.Errorf("want = %v, got = %v %v", "enters", events, err)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package fence handles geofences.
+package fence
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+const (
+	maxZoomLevel = 23
+	minZoomLevel = 4
+	maxCells     = 32
+)
+
+// MaxVertices is the maximum number of vertices of a polygonal zone over all
+// its rings.
+const MaxVertices = 10000
+
+var (
+	// ErrTooManyZones is returned for a new zone when an index has reached
+	// its maximum number of zones.
+	ErrTooManyZones = errors.New("too many zones")
+	// ErrTooManyVertices is returned for a zone with more than MaxVertices
+	// vertices.
+	ErrTooManyVertices = errors.New("too many vertices")
+)
+
+// Zone is a region devices enter and exit, such as a delivery zone or the
+// catchment of a store.
+type Zone struct {
+	ID   string
+	Name string
+	// Region is the area of the zone, such as a geo.Polygon or a geo.Cap.
+	Region geo.Region
+	// Dwell is how long a device stays in the zone before a dwell event.
+	// Zero uses the default of the tracker.
+	Dwell time.Duration
+}
+
+// Index is a spatial index of zones. The zones are stored in the cells
+// of their coverings, so a position is tested only against the zones whose
+// cells contain it. It is safe for concurrent use.
+type Index struct {
+	maxZones int
+
+	mu    sync.RWMutex
+	zones map[string]*indexedZone
+	// cells are the IDs of the zones keyed by the quadkeys of the cells
+	// covering them.
+	cells map[string]map[string]bool
+}
+
+type indexedZone struct {
+	zone     Zone
+	quadkeys []string
+}
+
+// NewIndex returns an empty index that holds at most maxZones zones, or any
+// number if maxZones is zero.
+func NewIndex(maxZones int) *Index {
+	return &Index{
+		maxZones: maxZones,
+		zones:    map[string]*indexedZone{},
+		cells:    map[string]map[string]bool{},
+	}
+}
+
+// Put adds the zone, replacing the zone with the same ID. It reports
+// whether the zone is new.
+func (x *Index) Put(z Zone) (bool, error) {
+	if z.ID == "" {
+		return false, errors.New("zone has no ID")
+	}
+	if z.Region == nil || z.Region.Bounds().IsEmpty() {
+		return false, errors.New("zone has no region")
+	}
+	if z.Dwell < 0 {
+		return false, errors.New("negative dwell")
+	}
+	if vertices(z.Region) > MaxVertices {
+		return false, ErrTooManyVertices
+	}
+	coverer := geo.RegionCoverer{
+		MinLevel: minZoomLevel,
+		MaxLevel: maxZoomLevel,
+		MaxCells: maxCells,
+	}
+	quadkeys := coverer.Quadkeys(z.Region)
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	_, exists := x.zones[z.ID]
+	if !exists && x.maxZones > 0 && len(x.zones) >= x.maxZones {
+		return false, ErrTooManyZones
+	}
+	x.remove(z.ID)
+	x.zones[z.ID] = &indexedZone{z, quadkeys}
+	for _, q := range quadkeys {
+		ids, ok := x.cells[q]
+		if !ok {
+			ids = map[string]bool{}
+			x.cells[q] = ids
+		}
+		ids[z.ID] = true
+	}
+	return !exists, nil
+}
+
+// vertices returns the number of vertices of a polygonal region, and zero
+// for the other regions.
+func vertices(r geo.Region) int {
+	var polygons []geo.Polygon
+	switch r := r.(type) {
+	case geo.Polygon:
+		polygons = []geo.Polygon{r}
+	case geo.MultiPolygon:
+		polygons = r
+	}
+	var n int
+	for _, pg := range polygons {
+		for _, ring := range pg {
+			n += len(ring)
+		}
+	}
+	return n
+}
+
+// Delete removes the zone with the ID and reports whether it existed.
+func (x *Index) Delete(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.remove(id)
+}
+
+func (x *Index) remove(id string) bool {
+	iz, ok := x.zones[id]
+	if !ok {
+		return false
+	}
+	for _, q := range iz.quadkeys {
+		delete(x.cells[q], id)
+		if len(x.cells[q]) == 0 {
+			delete(x.cells, q)
+		}
+	}
+	delete(x.zones, id)
+	return true
+}
+
+// Get returns the zone with the ID.
+func (x *Index) Get(id string) (Zone, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	iz, ok := x.zones[id]
+	if !ok {
+		return Zone{}, false
+	}
+	return iz.zone, true
+}
+
+// Zones returns all zones sorted by ID.
+func (x *Index) Zones() []Zone {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	zones := make([]Zone, 0, len(x.zones))
+	for _, iz := range x.zones {
+		zones = append(zones, iz.zone)
+	}
+	sortZones(zones)
+	return zones
+}
+
+// Containing returns the zones containing the position sorted by ID.
+func (x *Index) Containing(p geo.LatLong) []Zone {
+	quadkey := geo.LatLongToQuadkey(p.Latitude, p.Longitude, maxZoomLevel)
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	seen := map[string]bool{}
+	var zones []Zone
+	for zoom := minZoomLevel; zoom <= maxZoomLevel; zoom++ {
+		for id := range x.cells[quadkey[0:zoom]] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if z := x.zones[id].zone; z.Region.Contains(p) {
+				zones = append(zones, z)
+			}
+		}
+	}
+	sortZones(zones)
+	return zones
+}
+
+func sortZones(zones []Zone) {
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].ID < zones[j].ID
+	})
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package fence
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// EventType is the type of a geofence event.
+type EventType string
+
+// Event types.
+const (
+	// Enter is reported when a device enters a zone.
+	Enter EventType = "enter"
+	// Exit is reported when a device leaves a zone.
+	Exit EventType = "exit"
+	// Dwell is reported once when a device has stayed in a zone for its
+	// dwell time.
+	Dwell EventType = "dwell"
+)
+
+// MaxClockSkew is how far in the future the time of a position may be, to
+// allow for the clocks of the devices.
+const MaxClockSkew = time.Minute
+
+var (
+	// ErrStalePosition is returned for a position older than the last
+	// position of the device.
+	ErrStalePosition = errors.New("position is older than the last one")
+	// ErrFuturePosition is returned for a position later than now by more
+	// than MaxClockSkew.
+	ErrFuturePosition = errors.New("position is in the future")
+	// ErrTooManyDevices is returned for a new device when a tracker has
+	// reached its maximum number of devices.
+	ErrTooManyDevices = errors.New("too many devices")
+)
+
+// Event is a change of a device relative to a zone.
+type Event struct {
+	Type     EventType
+	DeviceID string
+	Zone     Zone
+	Position geo.LatLong
+	Time     time.Time
+	// Duration is how long the device has been in the zone, for exit and
+	// dwell events.
+	Duration time.Duration
+	// Expired is set on the exits of a device that has sent no position
+	// for the idle time of the tracker. Their position and time are the
+	// last ones of the device.
+	Expired bool
+}
+
+// Tracker keeps the zones each device is in and reports the events of
+// their positions. It is safe for concurrent use.
+type Tracker struct {
+	index      *Index
+	dwell      time.Duration
+	idle       time.Duration
+	maxDevices int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	devices map[string]*device
+}
+
+type device struct {
+	// last and position are the time and the position of the last update,
+	// and seen is when it was received.
+	last     time.Time
+	position geo.LatLong
+	seen     time.Time
+	zones    map[string]*presence
+}
+
+// presence is the stay of a device in a zone. The zone is kept so that an
+// exit can be reported after the zone is replaced or deleted.
+type presence struct {
+	zone    Zone
+	since   time.Time
+	dwelled bool
+}
+
+// NewTracker returns a tracker of the zones of the index. A zone without a
+// dwell time uses dwell, and zero disables dwell events for such zones.
+// Expire removes the devices that have sent no position for idle, unless it
+// is zero. The tracker tracks at most maxDevices devices, or any number if
+// maxDevices is zero.
+func NewTracker(index *Index, dwell, idle time.Duration, maxDevices int) *Tracker {
+	return &Tracker{
+		index:      index,
+		dwell:      dwell,
+		idle:       idle,
+		maxDevices: maxDevices,
+		now:        time.Now,
+		devices:    map[string]*device{},
+	}
+}
+
+// Update records the position of the device at the time and returns the
+// events in the order of exits, enters and dwells, each sorted by zone ID.
+func (t *Tracker) Update(deviceID string, p geo.LatLong, at time.Time) ([]Event, error) {
+	containing := t.index.Containing(p)
+
+	now := t.now()
+	if at.After(now.Add(MaxClockSkew)) {
+		return nil, ErrFuturePosition
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.devices[deviceID]
+	if !ok {
+		if t.maxDevices > 0 && len(t.devices) >= t.maxDevices {
+			return nil, ErrTooManyDevices
+		}
+		d = &device{zones: map[string]*presence{}}
+		t.devices[deviceID] = d
+	} else if at.Before(d.last) {
+		return nil, ErrStalePosition
+	}
+	d.last, d.position, d.seen = at, p, now
+
+	event := func(typ EventType, pr *presence) Event {
+		e := Event{Type: typ, DeviceID: deviceID, Zone: pr.zone, Position: p, Time: at}
+		if typ != Enter {
+			e.Duration = at.Sub(pr.since)
+		}
+		return e
+	}
+
+	inside := map[string]bool{}
+	for _, z := range containing {
+		inside[z.ID] = true
+	}
+	var exits, enters, dwells []Event
+	for id, pr := range d.zones {
+		if !inside[id] {
+			exits = append(exits, event(Exit, pr))
+			delete(d.zones, id)
+		}
+	}
+	for _, z := range containing {
+		pr, ok := d.zones[z.ID]
+		if !ok {
+			pr = &presence{zone: z, since: at}
+			d.zones[z.ID] = pr
+			enters = append(enters, event(Enter, pr))
+		}
+		pr.zone = z
+		dwell := z.Dwell
+		if dwell == 0 {
+			dwell = t.dwell
+		}
+		if !pr.dwelled && dwell > 0 && at.Sub(pr.since) >= dwell {
+			pr.dwelled = true
+			dwells = append(dwells, event(Dwell, pr))
+		}
+	}
+	sort.Slice(exits, func(i, j int) bool {
+		return exits[i].Zone.ID < exits[j].Zone.ID
+	})
+	return append(append(exits, enters...), dwells...), nil
+}
+
+// Zones returns the zones the device is in sorted by ID.
+func (t *Tracker) Zones(deviceID string) []Zone {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.devices[deviceID]
+	if !ok {
+		return nil
+	}
+	zones := make([]Zone, 0, len(d.zones))
+	for _, pr := range d.zones {
+		zones = append(zones, pr.zone)
+	}
+	sortZones(zones)
+	return zones
+}
+
+// Expire removes the devices that have sent no position for the idle time
+// of the tracker until now, and returns the exits from the zones they were
+// in sorted by device and zone ID.
+func (t *Tracker) Expire(now time.Time) []Event {
+	if t.idle <= 0 {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var exits []Event
+	for id, d := range t.devices {
+		if now.Sub(d.seen) < t.idle {
+			continue
+		}
+		for _, pr := range d.zones {
+			exits = append(exits, Event{
+				Type:     Exit,
+				DeviceID: id,
+				Zone:     pr.zone,
+				Position: d.position,
+				Time:     d.last,
+				Duration: d.last.Sub(pr.since),
+				Expired:  true,
+			})
+		}
+		delete(t.devices, id)
+	}
+	sort.Slice(exits, func(i, j int) bool {
+		if exits[i].DeviceID != exits[j].DeviceID {
+			return exits[i].DeviceID < exits[j].DeviceID
+		}
+		return exits[i].Zone.ID < exits[j].Zone.ID
+	})
+	return exits
+}
+
+// Len returns the number of devices.
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.devices)
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Ellipsoid represents a reference ellipsoid of the earth.
+type Ellipsoid struct {
+	// SemiMajorAxis is the equatorial radius in meters.
+	SemiMajorAxis float64
+	// Flattening is the flattening of the ellipsoid.
+	Flattening float64
+}
+
+var (
+	// GRS80 is the ellipsoid used by JGD2000 and JGD2011.
+	GRS80 = Ellipsoid{SemiMajorAxis: 6378137, Flattening: 1 / 298.257222101}
+	// WGS84 is the ellipsoid used by GPS.
+	WGS84 = Ellipsoid{SemiMajorAxis: 6378137, Flattening: 1 / 298.257223563}
+)
+
+const (
+	vincentyMaxIterations = 200
+	vincentyTolerance     = 1e-12
+)
+
+// SemiMinorAxis returns the polar radius in meters.
+func (e Ellipsoid) SemiMinorAxis() float64 {
+	return e.SemiMajorAxis * (1 - e.Flattening)
+}
+
+// Inverse solves the inverse geodesic problem with Vincenty's formulae.
+// It returns the distance in meters and the initial and final bearings in
+// degrees clockwise from north. If the iteration does not converge, which can
+// happen for nearly antipodal points, it falls back to the spherical solution
+// with the mean radius of the earth.
+func (e Ellipsoid) Inverse(p1, p2 LatLong) (distance, initialBearing, finalBearing float64) {
+	rad := math.Pi / 180
+	a := e.SemiMajorAxis
+	b := e.SemiMinorAxis()
+	f := e.Flattening
+
+	l := (p2.Longitude - p1.Longitude) * rad
+	tanU1 := (1 - f) * math.Tan(p1.Latitude*rad)
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	tanU2 := (1 - f) * math.Tan(p2.Latitude*rad)
+	cosU2 := 1 / math.Sqrt(1+tanU2*tanU2)
+	sinU2 := tanU2 * cosU2
+
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM float64
+	lambda := l
+	converged := false
+	for i := 0; i < vincentyMaxIterations; i++ {
+		sinLambda = math.Sin(lambda)
+		cosLambda = math.Cos(lambda)
+		x := cosU2 * sinLambda
+		y := cosU1*sinU2 - sinU1*cosU2*cosLambda
+		sinSigma = math.Sqrt(x*x + y*y)
+		if sinSigma == 0 {
+			// Coincident points.
+			return 0, 0, 0
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			// Otherwise both points are on the equator.
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = l + (1-c)*f*sinAlpha*
+			(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < vincentyTolerance {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return sphericalInverse(p1, p2, meanRadius)
+	}
+
+	u2 := cos2Alpha * (a*a - b*b) / (b * b)
+	aa, bb := vincentyCoefficients(u2)
+	deltaSigma := vincentyDeltaSigma(bb, sinSigma, cosSigma, cos2SigmaM)
+	distance = b * aa * (sigma - deltaSigma)
+
+	alpha1 := math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+	alpha2 := math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)
+	return distance, normalizeBearing(alpha1 / rad), normalizeBearing(alpha2 / rad)
+}
+
+// Direct solves the direct geodesic problem with Vincenty's formulae.
+// It returns the destination reached by travelling the specified distance in
+// meters from p along the initial bearing in degrees, and the final bearing
+// at the destination.
+func (e Ellipsoid) Direct(p LatLong, bearing, distance float64) (LatLong, float64) {
+	rad := math.Pi / 180
+	a := e.SemiMajorAxis
+	b := e.SemiMinorAxis()
+	f := e.Flattening
+
+	alpha1 := bearing * rad
+	sinAlpha1 := math.Sin(alpha1)
+	cosAlpha1 := math.Cos(alpha1)
+	tanU1 := (1 - f) * math.Tan(p.Latitude*rad)
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cos2Alpha := 1 - sinAlpha*sinAlpha
+	u2 := cos2Alpha * (a*a - b*b) / (b * b)
+	aa, bb := vincentyCoefficients(u2)
+
+	var sinSigma, cosSigma, cos2SigmaM float64
+	sigma := distance / (b * aa)
+	for i := 0; i < vincentyMaxIterations; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma = math.Sin(sigma)
+		cosSigma = math.Cos(sigma)
+		deltaSigma := vincentyDeltaSigma(bb, sinSigma, cosSigma, cos2SigmaM)
+		prev := sigma
+		sigma = distance/(b*aa) + deltaSigma
+		if math.Abs(sigma-prev) < vincentyTolerance {
+			break
+		}
+	}
+	sinSigma = math.Sin(sigma)
+	cosSigma = math.Cos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	lat := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1,
+		(1-f)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	c := f / 16 * cos2Alpha * (4 + f*(4-3*cos2Alpha))
+	l := lambda - (1-c)*f*sinAlpha*
+		(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+	alpha2 := math.Atan2(sinAlpha, -x)
+
+	dest := LatLong{
+		Latitude:  lat / rad,
+		Longitude: normalizeLongitude(p.Longitude + l/rad),
+	}
+	return dest, normalizeBearing(alpha2 / rad)
+}
+
+func vincentyCoefficients(u2 float64) (a, b float64) {
+	a = 1 + u2/16384*(4096+u2*(-768+u2*(320-175*u2)))
+	b = u2 / 1024 * (256 + u2*(-128+u2*(74-47*u2)))
+	return a, b
+}
+
+func vincentyDeltaSigma(b, sinSigma, cosSigma, cos2SigmaM float64) float64 {
+	c2 := cos2SigmaM * cos2SigmaM
+	return b * sinSigma * (cos2SigmaM + b/4*(cosSigma*(-1+2*c2)-
+		b/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*c2)))
+}
+
+func sphericalInverse(p1, p2 LatLong, r float64) (distance, initialBearing, finalBearing float64) {
+	distance = haversine(p1, p2, r)
+	initialBearing = sphericalBearing(p1, p2)
+	finalBearing = normalizeBearing(sphericalBearing(p2, p1) + 180)
+	return distance, initialBearing, finalBearing
+}
+
+func haversine(p1, p2 LatLong, r float64) float64 {
+	rad := math.Pi / 180
+	lat1 := p1.Latitude * rad
+	lat2 := p2.Latitude * rad
+	sinDLat := math.Sin((p2.Latitude - p1.Latitude) * rad / 2)
+	sinDLong := math.Sin((p2.Longitude - p1.Longitude) * rad / 2)
+	a := sinDLat*sinDLat + math.Cos(lat1)*math.Cos(lat2)*sinDLong*sinDLong
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	return r * c
+}
+
+func sphericalBearing(p1, p2 LatLong) float64 {
+	rad := math.Pi / 180
+	lat1 := p1.Latitude * rad
+	lat2 := p2.Latitude * rad
+	dLong := (p2.Longitude - p1.Longitude) * rad
+	y := math.Sin(dLong) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLong)
+	return normalizeBearing(math.Atan2(y, x) / rad)
+}
+
+func normalizeBearing(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+func normalizeLongitude(deg float64) float64 {
+	deg = math.Mod(deg+180, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg - 180
+}
+
+// DistanceModel is a model of the earth used to measure distances.
+type DistanceModel int
+
+const (
+	// Spherical measures the great-circle distance on a sphere with the
+	// mean radius of the earth.
+	Spherical DistanceModel = iota
+	// Ellipsoidal measures the geodesic distance on the GRS80 ellipsoid.
+	Ellipsoidal
+)
+
+// ParseDistanceModel parses the name of a distance model.
+func ParseDistanceModel(s string) (DistanceModel, error) {
+	switch s {
+	case "", "spherical", "haversine":
+		return Spherical, nil
+	case "ellipsoidal", "vincenty":
+		return Ellipsoidal, nil
+	default:
+		return 0, fmt.Errorf("geo: unknown distance model: %q", s)
+	}
+}
+
+// String returns the name of the distance model.
+func (m DistanceModel) String() string {
+	switch m {
+	case Spherical:
+		return "spherical"
+	case Ellipsoidal:
+		return "ellipsoidal"
+	default:
+		return fmt.Sprintf("DistanceModel(%d)", int(m))
+	}
+}
+
+// Distance returns the distance between the specified points in meters.
+func (m DistanceModel) Distance(p1, p2 LatLong) float64 {
+	if m == Ellipsoidal {
+		d, _, _ := GRS80.Inverse(p1, p2)
+		return d
+	}
+	return p1.Distance(p2)
+}
+
+// Nearest returns the point closest to p among the specified points.
+func (m DistanceModel) Nearest(p LatLong, targets []LatLong) (int, float64) {
+	var minIndex int
+	minDistance := math.MaxFloat64
+	for i, target := range targets {
+		d := m.Distance(p, target)
+		if d < minDistance {
+			minDistance = d
+			minIndex = i
+		}
+	}
+	return minIndex, minDistance
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func dms(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+// Flinders Peak and Buninyong, the example in Vincenty's paper.
+var (
+	flindersPeak = LatLong{Latitude: dms(-37, 57, 3.72030), Longitude: dms(144, 25, 29.52440)}
+	buninyong    = LatLong{Latitude: dms(-37, 39, 10.15610), Longitude: dms(143, 55, 35.38390)}
+)
+
+func TestEllipsoid_Inverse(t *testing.T) {
+	tests := []struct {
+		name      string
+		in1       LatLong
+		in2       LatLong
+		wantDist  float64
+		wantInit  float64
+		wantFinal float64
+	}{
+		{
+			"normal",
+			flindersPeak,
+			buninyong,
+			54972.271,
+			dms(306, 52, 5.37),
+			dms(307, 10, 25.07),
+		},
+		{
+			"coincident",
+			flindersPeak,
+			flindersPeak,
+			0,
+			0,
+			0,
+		},
+		{
+			"equator",
+			LatLong{Latitude: 0, Longitude: 0},
+			LatLong{Latitude: 0, Longitude: 1},
+			111319.491,
+			90,
+			90,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gotDist, gotInit, gotFinal := GRS80.Inverse(tt.in1, tt.in2)
+			if math.Abs(gotDist-tt.wantDist) > 1e-3 {
+				t.Errorf("want = %v, got = %v", tt.wantDist, gotDist)
+			}
+			if math.Abs(gotInit-tt.wantInit) > 1e-5 {
+				t.Errorf("want = %v, got = %v", tt.wantInit, gotInit)
+			}
+			if math.Abs(gotFinal-tt.wantFinal) > 1e-5 {
+				t.Errorf("want = %v, got = %v", tt.wantFinal, gotFinal)
+			}
+		})
+	}
+}
+
+func TestEllipsoid_Inverse_antipodal(t *testing.T) {
+	p1 := LatLong{Latitude: 0, Longitude: 0}
+	p2 := LatLong{Latitude: 0.5, Longitude: 179.7}
+	// The geodesic distance by Karney's method. The spherical fallback is
+	// within 0.05% of it, which the equatorial radius would exceed.
+	want := 19944127.421
+	got, _, _ := GRS80.Inverse(p1, p2)
+	if math.IsNaN(got) || math.Abs(got-want) > want*0.0005 {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+}
+
+func TestEllipsoid_Direct(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        LatLong
+		inBearing float64
+		inDist    float64
+		want      LatLong
+		wantFinal float64
+	}{
+		{
+			"normal",
+			flindersPeak,
+			dms(306, 52, 5.37),
+			54972.271,
+			buninyong,
+			dms(307, 10, 25.07),
+		},
+		{
+			"antimeridian",
+			LatLong{Latitude: 0, Longitude: 179.5},
+			90,
+			111319.491,
+			LatLong{Latitude: 0, Longitude: -179.5},
+			90,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, gotFinal := GRS80.Direct(tt.in, tt.inBearing, tt.inDist)
+			if math.Abs(got.Latitude-tt.want.Latitude) > 1e-7 ||
+				math.Abs(got.Longitude-tt.want.Longitude) > 1e-7 {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			if math.Abs(gotFinal-tt.wantFinal) > 1e-5 {
+				t.Errorf("want = %v, got = %v", tt.wantFinal, gotFinal)
+			}
+		})
+	}
+}
+
+func TestLatLong_Destination(t *testing.T) {
+	p := LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	target := LatLong{Latitude: 35.681236, Longitude: 139.767125}
+	bearing := p.Bearing(target)
+	dist := p.GeodesicDistance(target)
+	got := p.Destination(bearing, dist)
+	if math.Abs(got.Latitude-target.Latitude) > 1e-9 ||
+		math.Abs(got.Longitude-target.Longitude) > 1e-9 {
+		t.Errorf("want = %v, got = %v", target, got)
+	}
+	if got := p.FinalBearing(target); math.Abs(got-bearing) > 0.1 {
+		t.Errorf("want = about %v, got = %v", bearing, got)
+	}
+}
+
+func TestDistanceModel_Distance(t *testing.T) {
+	p1 := LatLong{Latitude: 1, Longitude: 1}
+	p2 := LatLong{Latitude: 2, Longitude: 2}
+	tests := []struct {
+		name string
+		in   string
+		want DistanceModel
+	}{
+		{"default", "", Spherical},
+		{"spherical", "spherical", Spherical},
+		{"ellipsoidal", "ellipsoidal", Ellipsoidal},
+		{"vincenty", "vincenty", Ellipsoidal},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseDistanceModel(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			wantDist := p1.Distance(p2)
+			if got == Ellipsoidal {
+				wantDist = p1.GeodesicDistance(p2)
+			}
+			if gotDist := got.Distance(p1, p2); gotDist != wantDist {
+				t.Errorf("want = %v, got = %v", wantDist, gotDist)
+			}
+		})
+	}
+	if _, err := ParseDistanceModel("flat"); err == nil {
+		t.Error("want error, got nil")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"strings"
+)
+
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxGeohashPrecision is the maximum length of a geohash.
+const MaxGeohashPrecision = 12
+
+// LatLongToGeohash converts latitude and longitude to a geohash of the
+// specified length.
+func LatLongToGeohash(lat, long float64, precision int) string {
+	if precision > MaxGeohashPrecision {
+		precision = MaxGeohashPrecision
+	}
+	minLat, maxLat := -90.0, 90.0
+	minLong, maxLong := -180.0, 180.0
+
+	var geohash strings.Builder
+	geohash.Grow(precision)
+	even := true
+	bit := 0
+	ch := 0
+	for geohash.Len() < precision {
+		if even {
+			mid := (minLong + maxLong) / 2
+			if long >= mid {
+				ch = ch<<1 | 1
+				minLong = mid
+			} else {
+				ch <<= 1
+				maxLong = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+		bit++
+		if bit == 5 {
+			geohash.WriteByte(geohashBase32[ch])
+			bit = 0
+			ch = 0
+		}
+	}
+	return geohash.String()
+}
+
+// GeohashToLatLong converts a geohash to the latitude and longitude of its
+// center. It returns zeros if the geohash is invalid.
+func GeohashToLatLong(geohash string) (lat, long float64) {
+	c := GeohashBounds(geohash).Center()
+	return c.Latitude, c.Longitude
+}
+
+// GeohashBounds returns the bounding box of a geohash.
+// It returns a zero bounding box if the geohash is invalid.
+func GeohashBounds(geohash string) BBox {
+	minLat, maxLat := -90.0, 90.0
+	minLong, maxLong := -180.0, 180.0
+	even := true
+	for i := 0; i < len(geohash); i++ {
+		ch := strings.IndexByte(geohashBase32, geohash[i])
+		if ch < 0 {
+			return BBox{}
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			if even {
+				mid := (minLong + maxLong) / 2
+				if ch&mask != 0 {
+					minLong = mid
+				} else {
+					maxLong = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if ch&mask != 0 {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return BBox{
+		Min: LatLong{Latitude: minLat, Longitude: minLong},
+		Max: LatLong{Latitude: maxLat, Longitude: maxLong},
+	}
+}
+
+// GeohashParent returns the geohash one level above the specified geohash.
+func GeohashParent(geohash string) string {
+	if len(geohash) == 0 {
+		return ""
+	}
+	return geohash[:len(geohash)-1]
+}
+
+// GeohashChildren returns the 32 geohashes one level below the specified
+// geohash.
+func GeohashChildren(geohash string) []string {
+	children := make([]string, len(geohashBase32))
+	for i := range geohashBase32 {
+		children[i] = geohash + geohashBase32[i:i+1]
+	}
+	return children
+}
+
+// GeohashNeighbors returns geohashes that are adjacent to the specified
+// geohash, including itself, from north-west to south-east. Cells beyond the
+// poles are omitted and cells beyond the antimeridian wrap around.
+func GeohashNeighbors(geohash string) []string {
+	b := GeohashBounds(geohash)
+	height := b.Max.Latitude - b.Min.Latitude
+	width := b.Max.Longitude - b.Min.Longitude
+	c := b.Center()
+	lat, long := c.Latitude, c.Longitude
+
+	neighbors := make([]string, 0, 9)
+	for dy := 1; dy >= -1; dy-- {
+		la := lat + float64(dy)*height
+		if la < -90 || la > 90 {
+			continue
+		}
+		for dx := -1; dx <= 1; dx++ {
+			lo := normalizeLongitude(long + float64(dx)*width)
+			neighbors = append(neighbors, LatLongToGeohash(la, lo, len(geohash)))
+		}
+	}
+	return neighbors
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLatLongToGeohash(t *testing.T) {
+	tests := []struct {
+		name   string
+		inLat  float64
+		inLong float64
+		inP    int
+		want   string
+	}{
+		{"normal", 57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{"short", 57.64911, 10.40744, 5, "u4pru"},
+		{"too long", 57.64911, 10.40744, 20, "u4pruydqqvj8"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := LatLongToGeohash(tt.inLat, tt.inLong, tt.inP)
+			if got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGeohashToLatLong(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantLat  float64
+		wantLong float64
+	}{
+		{"normal", "u4pruydqqvj", 57.64911, 10.40744},
+		{"invalid", "u4pa", 0, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			gotLat, gotLong := GeohashToLatLong(tt.in)
+			if math.Abs(gotLat-tt.wantLat) > 1e-5 {
+				t.Errorf("want = %v, got = %v", tt.wantLat, gotLat)
+			}
+			if math.Abs(gotLong-tt.wantLong) > 1e-5 {
+				t.Errorf("want = %v, got = %v", tt.wantLong, gotLong)
+			}
+		})
+	}
+}
+
+func TestGeohashBounds(t *testing.T) {
+	got := GeohashBounds("xn")
+	want := BBox{
+		Min: LatLong{Latitude: 33.75, Longitude: 135},
+		Max: LatLong{Latitude: 39.375, Longitude: 146.25},
+	}
+	if got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+}
+
+func TestGeohashParent(t *testing.T) {
+	if got := GeohashParent("xn76"); got != "xn7" {
+		t.Errorf("want = %v, got = %v", "xn7", got)
+	}
+	if got := GeohashParent(""); got != "" {
+		t.Errorf("want = %v, got = %v", "", got)
+	}
+}
+
+func TestGeohashChildren(t *testing.T) {
+	got := GeohashChildren("xn7")
+	if len(got) != 32 {
+		t.Fatalf("want = %v, got = %v", 32, len(got))
+	}
+	for _, c := range got {
+		if GeohashParent(c) != "xn7" {
+			t.Errorf("want = %v, got = %v", "xn7", GeohashParent(c))
+		}
+	}
+}
+
+func TestGeohashNeighbors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{
+			"normal",
+			"gbsuv",
+			[]string{
+				"gbsvh", "gbsvj", "gbsvn",
+				"gbsuu", "gbsuv", "gbsuy",
+				"gbsus", "gbsut", "gbsuw",
+			},
+		},
+		{
+			"antimeridian",
+			"2",
+			[]string{"x", "8", "9", "r", "2", "3", "p", "0", "1"},
+		},
+		{
+			"pole",
+			"b",
+			[]string{"z", "b", "c", "x", "8", "9"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := GeohashNeighbors(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
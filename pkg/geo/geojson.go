@@ -0,0 +1,232 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// MarshalGeoJSON returns the GeoJSON geometry object of a geometry.
+// A BBox is encoded as a polygon.
+func MarshalGeoJSON(g Geometry) ([]byte, error) {
+	var typ string
+	var coords interface{}
+	switch g := g.(type) {
+	case LatLong:
+		typ, coords = "Point", geoJSONPosition(g)
+	case *LatLong:
+		typ, coords = "Point", geoJSONPosition(*g)
+	case LineString:
+		typ, coords = "LineString", geoJSONPositions(g)
+	case BBox:
+		typ, coords = "Polygon", geoJSONPolygon(g.Polygon())
+	case Polygon:
+		typ, coords = "Polygon", geoJSONPolygon(g)
+	case MultiPolygon:
+		c := make([][][][]float64, len(g))
+		for i, pg := range g {
+			c[i] = geoJSONPolygon(pg)
+		}
+		typ, coords = "MultiPolygon", c
+	default:
+		return nil, ErrUnsupportedGeometry
+	}
+	c, err := json.Marshal(coords)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(geoJSONGeometry{Type: typ, Coordinates: c})
+}
+
+func geoJSONPosition(p LatLong) []float64 {
+	return []float64{p.Longitude, p.Latitude}
+}
+
+func geoJSONPositions(points []LatLong) [][]float64 {
+	c := make([][]float64, len(points))
+	for i, p := range points {
+		c[i] = geoJSONPosition(p)
+	}
+	return c
+}
+
+func geoJSONPolygon(pg Polygon) [][][]float64 {
+	c := make([][][]float64, len(pg))
+	for i, ring := range pg {
+		c[i] = geoJSONPositions(ring)
+	}
+	return c
+}
+
+// UnmarshalGeoJSON parses a GeoJSON geometry object of a point, line string,
+// polygon or multi polygon.
+func UnmarshalGeoJSON(b []byte) (Geometry, error) {
+	var obj geoJSONGeometry
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	switch obj.Type {
+	case "Point":
+		var c []float64
+		if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+			return nil, err
+		}
+		return fromGeoJSONPosition(c)
+	case "LineString":
+		var c [][]float64
+		if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+			return nil, err
+		}
+		points, err := fromGeoJSONPositions(c)
+		return LineString(points), err
+	case "Polygon":
+		var c [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+			return nil, err
+		}
+		return fromGeoJSONPolygon(c)
+	case "MultiPolygon":
+		var c [][][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+			return nil, err
+		}
+		mp := make(MultiPolygon, len(c))
+		for i, pc := range c {
+			pg, err := fromGeoJSONPolygon(pc)
+			if err != nil {
+				return nil, err
+			}
+			mp[i] = pg
+		}
+		return mp, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedGeometry, obj.Type)
+	}
+}
+
+func fromGeoJSONPosition(c []float64) (LatLong, error) {
+	if len(c) < 2 {
+		return LatLong{}, fmt.Errorf("geo: invalid GeoJSON position: %v", c)
+	}
+	return LatLong{Latitude: c[1], Longitude: c[0]}, nil
+}
+
+func fromGeoJSONPositions(c [][]float64) ([]LatLong, error) {
+	points := make([]LatLong, len(c))
+	for i, pc := range c {
+		p, err := fromGeoJSONPosition(pc)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = p
+	}
+	return points, nil
+}
+
+func fromGeoJSONPolygon(c [][][]float64) (Polygon, error) {
+	pg := make(Polygon, len(c))
+	for i, rc := range c {
+		ring, err := fromGeoJSONPositions(rc)
+		if err != nil {
+			return nil, err
+		}
+		pg[i] = ring
+	}
+	return pg, nil
+}
+
+// Feature is a GeoJSON feature.
+type Feature struct {
+	ID         interface{}
+	Geometry   Geometry
+	Properties map[string]interface{}
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   json.RawMessage        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (f Feature) MarshalJSON() ([]byte, error) {
+	g := json.RawMessage("null")
+	if f.Geometry != nil {
+		var err error
+		if g, err = MarshalGeoJSON(f.Geometry); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(geoJSONFeature{
+		Type:       "Feature",
+		ID:         f.ID,
+		Geometry:   g,
+		Properties: f.Properties,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *Feature) UnmarshalJSON(b []byte) error {
+	var obj geoJSONFeature
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	if obj.Type != "Feature" {
+		return fmt.Errorf("geo: invalid GeoJSON feature type: %q", obj.Type)
+	}
+	f.ID = obj.ID
+	f.Properties = obj.Properties
+	f.Geometry = nil
+	if len(obj.Geometry) > 0 && string(obj.Geometry) != "null" {
+		g, err := UnmarshalGeoJSON(obj.Geometry)
+		if err != nil {
+			return err
+		}
+		f.Geometry = g
+	}
+	return nil
+}
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Features []Feature
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (fc FeatureCollection) MarshalJSON() ([]byte, error) {
+	features := fc.Features
+	if features == nil {
+		features = []Feature{}
+	}
+	return json.Marshal(geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (fc *FeatureCollection) UnmarshalJSON(b []byte) error {
+	var obj geoJSONFeatureCollection
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	if obj.Type != "FeatureCollection" {
+		return fmt.Errorf("geo: invalid GeoJSON feature collection type: %q", obj.Type)
+	}
+	fc.Features = obj.Features
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalGeoJSON(t *testing.T) {
+	for _, tt := range geometryTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := MarshalGeoJSON(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalGeoJSON(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.in) {
+				t.Errorf("want = %v, got = %v", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestFeatureCollection(t *testing.T) {
+	fc := FeatureCollection{
+		Features: []Feature{
+			{
+				ID:         "131030002003",
+				Geometry:   LatLong{Latitude: 35.659943, Longitude: 139.747207},
+				Properties: map[string]interface{}{"area_name": "芝公園三丁目"},
+			},
+			{Properties: map[string]interface{}{}},
+		},
+	}
+	b, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"FeatureCollection","features":[` +
+		`{"type":"Feature","id":"131030002003","geometry":{"type":"Point","coordinates":[139.747207,35.659943]},"properties":{"area_name":"芝公園三丁目"}},` +
+		`{"type":"Feature","geometry":null,"properties":{}}]}`
+	if string(b) != want {
+		t.Errorf("\nwant = %v\ngot  = %v", want, string(b))
+	}
+
+	var got FeatureCollection
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, fc) {
+		t.Errorf("want = %v, got = %v", fc, got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"type":"Feature"}`), &got); err == nil {
+		t.Error("want error, got nil")
+	}
+}
@@ -0,0 +1,436 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+)
+
+// Geometry is a geometric object: a LatLong, BBox, LineString, Polygon or
+// MultiPolygon.
+type Geometry interface {
+	// Bounds returns the smallest bounding box that contains the geometry.
+	Bounds() BBox
+}
+
+// LineString represents a sequence of connected positions.
+type LineString []LatLong
+
+// Polygon represents a polygon by its rings. The first ring is the exterior
+// and the rest are holes. Each ring is closed: the first and last positions
+// are the same.
+type Polygon [][]LatLong
+
+// MultiPolygon represents a set of polygons.
+type MultiPolygon []Polygon
+
+// Meters per degree of latitude on the sphere used by LatLong.Distance.
+const metersPerDegree = 6371000 * math.Pi / 180
+
+// Bounds returns the bounding box of the point.
+func (p LatLong) Bounds() BBox {
+	return BBox{Min: p, Max: p}
+}
+
+// Bounds returns the bounding box of the line string.
+func (l LineString) Bounds() BBox {
+	return NewBBox(l...)
+}
+
+// Length returns the geodesic length of the line string on the GRS80
+// ellipsoid in meters.
+func (l LineString) Length() float64 {
+	var length float64
+	for i := 1; i < len(l); i++ {
+		length += l[i-1].GeodesicDistance(l[i])
+	}
+	return length
+}
+
+// Centroid returns the length-weighted centroid of the line string.
+func (l LineString) Centroid() LatLong {
+	if len(l) == 0 {
+		return LatLong{}
+	}
+	var sumLat, sumLong, sumLength float64
+	for i := 1; i < len(l); i++ {
+		d := math.Hypot(l[i].Latitude-l[i-1].Latitude, l[i].Longitude-l[i-1].Longitude)
+		sumLat += d * (l[i].Latitude + l[i-1].Latitude) / 2
+		sumLong += d * (l[i].Longitude + l[i-1].Longitude) / 2
+		sumLength += d
+	}
+	if sumLength == 0 {
+		return l[0]
+	}
+	return LatLong{Latitude: sumLat / sumLength, Longitude: sumLong / sumLength}
+}
+
+// DistanceTo returns the approximate shortest distance in meters from the
+// specified point to the line string.
+func (l LineString) DistanceTo(p LatLong) float64 {
+	if len(l) == 1 {
+		return p.Distance(l[0])
+	}
+	minDistance := math.Inf(1)
+	for i := 1; i < len(l); i++ {
+		d := segmentDistance(p, l[i-1], l[i])
+		minDistance = math.Min(minDistance, d)
+	}
+	return minDistance
+}
+
+// Bounds returns the bounding box of the polygon.
+func (pg Polygon) Bounds() BBox {
+	if len(pg) == 0 {
+		return EmptyBBox()
+	}
+	return NewBBox(pg[0]...)
+}
+
+// Area returns the area of the polygon in square meters.
+func (pg Polygon) Area() float64 {
+	if len(pg) == 0 {
+		return 0
+	}
+	area := math.Abs(ringArea(pg[0]))
+	for _, hole := range pg[1:] {
+		area -= math.Abs(ringArea(hole))
+	}
+	return area
+}
+
+// Length returns the geodesic length of the rings of the polygon in meters.
+func (pg Polygon) Length() float64 {
+	var length float64
+	for _, ring := range pg {
+		length += LineString(ring).Length()
+	}
+	return length
+}
+
+// Centroid returns the area-weighted centroid of the polygon.
+func (pg Polygon) Centroid() LatLong {
+	var sumArea, sumLat, sumLong float64
+	for i, ring := range pg {
+		a, lat, long := ringCentroid(ring)
+		// The exterior adds to the area and holes subtract from it,
+		// regardless of the orientation of the rings.
+		sign := 1.0
+		if (i == 0) != (a > 0) {
+			sign = -1
+		}
+		sumArea += sign * a
+		sumLat += sign * a * lat
+		sumLong += sign * a * long
+	}
+	if sumArea == 0 {
+		if len(pg) == 0 {
+			return LatLong{}
+		}
+		return LineString(pg[0]).Centroid()
+	}
+	return LatLong{Latitude: sumLat / sumArea, Longitude: sumLong / sumArea}
+}
+
+// Contains reports whether the polygon contains the specified point.
+// Points on the boundary may be reported either way.
+func (pg Polygon) Contains(p LatLong) bool {
+	if len(pg) == 0 || !pg.Bounds().Contains(p) {
+		return false
+	}
+	if !ringContains(pg[0], p) {
+		return false
+	}
+	for _, hole := range pg[1:] {
+		if ringContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// DistanceTo returns the approximate shortest distance in meters from the
+// specified point to the polygon. It returns zero if the polygon contains the
+// point.
+func (pg Polygon) DistanceTo(p LatLong) float64 {
+	if pg.Contains(p) {
+		return 0
+	}
+	minDistance := math.Inf(1)
+	for _, ring := range pg {
+		minDistance = math.Min(minDistance, LineString(ring).DistanceTo(p))
+	}
+	return minDistance
+}
+
+// Bounds returns the bounding box of the multi polygon.
+func (mp MultiPolygon) Bounds() BBox {
+	b := EmptyBBox()
+	for _, pg := range mp {
+		b = b.Union(pg.Bounds())
+	}
+	return b
+}
+
+// Area returns the area of the multi polygon in square meters.
+func (mp MultiPolygon) Area() float64 {
+	var area float64
+	for _, pg := range mp {
+		area += pg.Area()
+	}
+	return area
+}
+
+// Length returns the geodesic length of the rings of the multi polygon in
+// meters.
+func (mp MultiPolygon) Length() float64 {
+	var length float64
+	for _, pg := range mp {
+		length += pg.Length()
+	}
+	return length
+}
+
+// Centroid returns the area-weighted centroid of the multi polygon.
+func (mp MultiPolygon) Centroid() LatLong {
+	var sumArea, sumLat, sumLong float64
+	for _, pg := range mp {
+		a := pg.Area()
+		c := pg.Centroid()
+		sumArea += a
+		sumLat += a * c.Latitude
+		sumLong += a * c.Longitude
+	}
+	if sumArea == 0 {
+		if len(mp) == 0 {
+			return LatLong{}
+		}
+		return mp[0].Centroid()
+	}
+	return LatLong{Latitude: sumLat / sumArea, Longitude: sumLong / sumArea}
+}
+
+// Contains reports whether any of the polygons contains the specified point.
+func (mp MultiPolygon) Contains(p LatLong) bool {
+	for _, pg := range mp {
+		if pg.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// DistanceTo returns the approximate shortest distance in meters from the
+// specified point to the multi polygon.
+func (mp MultiPolygon) DistanceTo(p LatLong) float64 {
+	minDistance := math.Inf(1)
+	for _, pg := range mp {
+		minDistance = math.Min(minDistance, pg.DistanceTo(p))
+	}
+	return minDistance
+}
+
+// Circle returns a polygon approximating a circle with the specified radius in
+// meters by the specified number of segments.
+func Circle(center LatLong, radius float64, segments int) Polygon {
+	if segments < 3 {
+		segments = 3
+	}
+	ring := make([]LatLong, segments+1)
+	for i := 0; i < segments; i++ {
+		ring[i] = center.Destination(360*float64(i)/float64(segments), radius)
+	}
+	ring[segments] = ring[0]
+	return Polygon{ring}
+}
+
+// Intersects reports whether the geometries share any point.
+func Intersects(a, b Geometry) bool {
+	if !a.Bounds().Intersects(b.Bounds()) {
+		return false
+	}
+	pa, sa, va := decompose(a)
+	pb, sb, vb := decompose(b)
+	for _, s1 := range sa {
+		for _, s2 := range sb {
+			if segmentsIntersect(s1[0], s1[1], s2[0], s2[1]) {
+				return true
+			}
+		}
+	}
+	for _, v := range va {
+		if containedBy(v, pb, sb, vb) {
+			return true
+		}
+	}
+	for _, v := range vb {
+		if containedBy(v, pa, sa, va) {
+			return true
+		}
+	}
+	return false
+}
+
+// decompose returns the polygons, segments and vertices of a geometry.
+func decompose(g Geometry) (polygons []Polygon, segments [][2]LatLong, vertices []LatLong) {
+	addLine := func(l []LatLong) {
+		for i := 1; i < len(l); i++ {
+			segments = append(segments, [2]LatLong{l[i-1], l[i]})
+		}
+		vertices = append(vertices, l...)
+	}
+	addPolygon := func(pg Polygon) {
+		polygons = append(polygons, pg)
+		for _, ring := range pg {
+			addLine(ring)
+		}
+	}
+	switch g := g.(type) {
+	case LatLong:
+		vertices = append(vertices, g)
+	case *LatLong:
+		vertices = append(vertices, *g)
+	case BBox:
+		addPolygon(g.Polygon())
+	case LineString:
+		addLine(g)
+	case Polygon:
+		addPolygon(g)
+	case MultiPolygon:
+		for _, pg := range g {
+			addPolygon(pg)
+		}
+	}
+	return polygons, segments, vertices
+}
+
+func containedBy(p LatLong, polygons []Polygon, segments [][2]LatLong, vertices []LatLong) bool {
+	for _, pg := range polygons {
+		if pg.Contains(p) {
+			return true
+		}
+	}
+	for _, s := range segments {
+		if orientation(s[0], s[1], p) == 0 && onSegment(s[0], s[1], p) {
+			return true
+		}
+	}
+	if len(segments) == 0 {
+		for _, v := range vertices {
+			if v == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ringArea returns the signed area of a ring on the sphere in square meters.
+// It is positive for clockwise rings.
+//
+// Robert. G. Chamberlain and William H. Duquette, "Some Algorithms for
+// Polygons on a Sphere", JPL Publication 07-03.
+func ringArea(ring []LatLong) float64 {
+	const r = 6378137
+	n := len(ring)
+	if n < 3 {
+		return 0
+	}
+	rad := math.Pi / 180
+	var total float64
+	for i := 0; i < n; i++ {
+		lower := ring[i]
+		middle := ring[(i+1)%n]
+		upper := ring[(i+2)%n]
+		total += (upper.Longitude - lower.Longitude) * rad * math.Sin(middle.Latitude*rad)
+	}
+	return total * r * r / 2
+}
+
+// ringCentroid returns the signed planar area and centroid of a ring in
+// degrees. The area is positive for counterclockwise rings.
+func ringCentroid(ring []LatLong) (area, lat, long float64) {
+	for i := 1; i < len(ring); i++ {
+		x0, y0 := ring[i-1].Longitude, ring[i-1].Latitude
+		x1, y1 := ring[i].Longitude, ring[i].Latitude
+		cross := x0*y1 - x1*y0
+		area += cross
+		long += (x0 + x1) * cross
+		lat += (y0 + y1) * cross
+	}
+	area /= 2
+	if area == 0 {
+		return 0, 0, 0
+	}
+	return area, lat / (6 * area), long / (6 * area)
+}
+
+// ringContains reports whether a ring contains a point by ray casting.
+func ringContains(ring []LatLong, p LatLong) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) {
+			x := (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude) + a.Longitude
+			if p.Longitude < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+func orientation(a, b, c LatLong) int {
+	v := (b.Longitude-a.Longitude)*(c.Latitude-a.Latitude) -
+		(b.Latitude-a.Latitude)*(c.Longitude-a.Longitude)
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func onSegment(a, b, p LatLong) bool {
+	return math.Min(a.Longitude, b.Longitude) <= p.Longitude &&
+		p.Longitude <= math.Max(a.Longitude, b.Longitude) &&
+		math.Min(a.Latitude, b.Latitude) <= p.Latitude &&
+		p.Latitude <= math.Max(a.Latitude, b.Latitude)
+}
+
+func segmentsIntersect(a, b, c, d LatLong) bool {
+	o1 := orientation(a, b, c)
+	o2 := orientation(a, b, d)
+	o3 := orientation(c, d, a)
+	o4 := orientation(c, d, b)
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+	return o1 == 0 && onSegment(a, b, c) ||
+		o2 == 0 && onSegment(a, b, d) ||
+		o3 == 0 && onSegment(c, d, a) ||
+		o4 == 0 && onSegment(c, d, b)
+}
+
+// segmentDistance returns the distance in meters from p to the segment ab on
+// an equirectangular projection centered at p.
+func segmentDistance(p, a, b LatLong) float64 {
+	k := math.Cos(p.Latitude * math.Pi / 180)
+	ax := (a.Longitude - p.Longitude) * k
+	ay := a.Latitude - p.Latitude
+	bx := (b.Longitude - p.Longitude) * k
+	by := b.Latitude - p.Latitude
+	dx := bx - ax
+	dy := by - ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = clip(-(ax*dx+ay*dy)/l, 0, 1)
+	}
+	x := ax + t*dx
+	y := ay + t*dy
+	return math.Hypot(x, y) * metersPerDegree
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+// A square of 1 degree with a hole of 0.5 degrees at the equator.
+var squareWithHole = Polygon{
+	{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}},
+	{{0.25, 0.25}, {0.75, 0.25}, {0.75, 0.75}, {0.25, 0.75}, {0.25, 0.25}},
+}
+
+func TestPolygon_Area(t *testing.T) {
+	// The area between two meridians and two parallels on a sphere.
+	area := func(dLong, lat1, lat2 float64) float64 {
+		const r = 6378137
+		rad := math.Pi / 180
+		return r * r * dLong * rad * (math.Sin(lat2*rad) - math.Sin(lat1*rad))
+	}
+	tests := []struct {
+		name string
+		in   Polygon
+		want float64
+	}{
+		{"square", squareWithHole[:1], area(1, 0, 1)},
+		{"hole", squareWithHole, area(1, 0, 1) - area(0.5, 0.25, 0.75)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.in.Area(); math.Abs(got-tt.want)/tt.want > 1e-9 {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+	mp := MultiPolygon{squareWithHole[:1], squareWithHole[:1]}
+	if got, want := mp.Area(), 2*squareWithHole[:1].Area(); got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+}
+
+func TestPolygon_Centroid(t *testing.T) {
+	offCenter := Polygon{
+		squareWithHole[0],
+		{{0.5, 0.5}, {0.5, 1}, {1, 1}, {1, 0.5}, {0.5, 0.5}},
+	}
+	tests := []struct {
+		name string
+		in   Polygon
+		want LatLong
+	}{
+		{"symmetric", squareWithHole, LatLong{Latitude: 0.5, Longitude: 0.5}},
+		{"hole", offCenter, LatLong{Latitude: 5.0 / 12, Longitude: 5.0 / 12}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.in.Centroid()
+			if math.Abs(got.Latitude-tt.want.Latitude) > 1e-9 ||
+				math.Abs(got.Longitude-tt.want.Longitude) > 1e-9 {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPolygon_Contains(t *testing.T) {
+	tests := []struct {
+		name string
+		in   LatLong
+		want bool
+	}{
+		{"inside", LatLong{Latitude: 0.1, Longitude: 0.1}, true},
+		{"hole", LatLong{Latitude: 0.5, Longitude: 0.5}, false},
+		{"outside", LatLong{Latitude: 1.5, Longitude: 0.5}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := squareWithHole.Contains(tt.in); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			mp := MultiPolygon{squareWithHole}
+			if got := mp.Contains(tt.in); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLineString_Length(t *testing.T) {
+	l := LineString{flindersPeak, buninyong, flindersPeak}
+	if got, want := l.Length(), 2*54972.271; math.Abs(got-want) > 1e-2 {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	c := LineString{{0, 0}, {0, 2}}.Centroid()
+	if want := (LatLong{Latitude: 0, Longitude: 1}); c != want {
+		t.Errorf("want = %v, got = %v", want, c)
+	}
+}
+
+func TestGeometry_DistanceTo(t *testing.T) {
+	l := LineString{{0, 0}, {0, 1}}
+	p := LatLong{Latitude: 0.01, Longitude: 0.5}
+	want := p.Distance(LatLong{Latitude: 0, Longitude: 0.5})
+	if got := l.DistanceTo(p); math.Abs(got-want) > 1e-3 {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := squareWithHole.DistanceTo(LatLong{Latitude: 0.1, Longitude: 0.1}); got != 0 {
+		t.Errorf("want = %v, got = %v", 0, got)
+	}
+	if got := squareWithHole.DistanceTo(LatLong{Latitude: 0.5, Longitude: 0.5}); math.Abs(got-want*25) > 2 {
+		t.Errorf("want = %v, got = %v", want*25, got)
+	}
+}
+
+func TestCircle(t *testing.T) {
+	center := LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	c := Circle(center, 500, 64)
+	if !c.Contains(center) {
+		t.Errorf("want %v to contain the center", c)
+	}
+	want := math.Pi * 500 * 500
+	if got := c.Area(); math.Abs(got-want)/want > 0.01 {
+		t.Errorf("want = about %v, got = %v", want, got)
+	}
+	if got := c.DistanceTo(center.Destination(45, 600)); math.Abs(got-100) > 1 {
+		t.Errorf("want = about %v, got = %v", 100, got)
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Geometry
+		b    Geometry
+		want bool
+	}{
+		{"point in polygon", LatLong{Latitude: 0.1, Longitude: 0.1}, squareWithHole, true},
+		{"point in hole", LatLong{Latitude: 0.5, Longitude: 0.5}, squareWithHole, false},
+		{"point on line", LatLong{Latitude: 0, Longitude: 0.5}, LineString{{0, 0}, {0, 1}}, true},
+		{"same points", LatLong{Latitude: 1, Longitude: 1}, LatLong{Latitude: 1, Longitude: 1}, true},
+		{"crossing lines", LineString{{0, 0}, {1, 1}}, LineString{{0, 1}, {1, 0}}, true},
+		{"parallel lines", LineString{{0, 0}, {0, 1}}, LineString{{1, 0}, {1, 1}}, false},
+		{"line crosses polygon", LineString{{0.5, -1}, {0.5, 2}}, squareWithHole, true},
+		{"line in hole", LineString{{0.4, 0.4}, {0.6, 0.6}}, squareWithHole, false},
+		{"polygon in polygon", squareWithHole, BBox{Min: LatLong{Latitude: -1, Longitude: -1}, Max: LatLong{Latitude: 2, Longitude: 2}}, true},
+		{"apart", squareWithHole, BBox{Min: LatLong{Latitude: 2, Longitude: 2}, Max: LatLong{Latitude: 3, Longitude: 3}}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Intersects(tt.a, tt.b); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			if got := Intersects(tt.b, tt.a); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"sort"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// ChangeKind is a kind of change between two datasets.
+type ChangeKind string
+
+// Kinds of changes.
+const (
+	// ChangeAdded is an area that only exists in the new dataset.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is an area that only exists in the old dataset.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeRenamed is an area whose prefecture, city or area name changed.
+	ChangeRenamed ChangeKind = "renamed"
+	// ChangeRecoded is an area whose code changed. It is detected by the
+	// names of a removed and an added area.
+	ChangeRecoded ChangeKind = "recoded"
+	// ChangeMoved is an area whose position changed.
+	ChangeMoved ChangeKind = "moved"
+)
+
+// Change is a change of an area between two datasets. An area that was both
+// renamed and moved has one change of each kind.
+type Change struct {
+	Kind ChangeKind
+	// Old is the area in the old dataset, or nil if it was added.
+	Old *AddressPosition
+	// New is the area in the new dataset, or nil if it was removed.
+	New *AddressPosition
+	// Distance is the distance in meters the position moved.
+	Distance float64
+}
+
+// AreaCode returns the area code of the change, preferring the new one.
+func (c Change) AreaCode() string {
+	if c.New != nil {
+		return c.New.AreaCode
+	}
+	return c.Old.AreaCode
+}
+
+// Diff compares an old and a new dataset by area code and returns the
+// changes sorted by area code.
+func Diff(prev, next AddressPositions) []Change {
+	oldByCode := map[string]*AddressPosition{}
+	for i := range prev {
+		oldByCode[prev[i].AreaCode] = &prev[i]
+	}
+	newByCode := map[string]*AddressPosition{}
+	for i := range next {
+		newByCode[next[i].AreaCode] = &next[i]
+	}
+
+	var changes []Change
+	var removed, added []*AddressPosition
+	for i := range prev {
+		o := &prev[i]
+		n, ok := newByCode[o.AreaCode]
+		if !ok {
+			removed = append(removed, o)
+			continue
+		}
+		if o.PrefName != n.PrefName || o.CityName != n.CityName || o.AreaName != n.AreaName {
+			changes = append(changes, Change{Kind: ChangeRenamed, Old: o, New: n})
+		}
+		if d := distance(o, n); d > 0 {
+			changes = append(changes, Change{Kind: ChangeMoved, Old: o, New: n, Distance: d})
+		}
+	}
+	for i := range next {
+		if _, ok := oldByCode[next[i].AreaCode]; !ok {
+			added = append(added, &next[i])
+		}
+	}
+
+	// Pair removed and added areas that have the same unique full name.
+	addedByName := map[string][]*AddressPosition{}
+	for _, n := range added {
+		addedByName[fullName(n)] = append(addedByName[fullName(n)], n)
+	}
+	removedByName := map[string]int{}
+	for _, o := range removed {
+		removedByName[fullName(o)]++
+	}
+	recoded := map[*AddressPosition]bool{}
+	for _, o := range removed {
+		name := fullName(o)
+		candidates := addedByName[name]
+		if len(candidates) != 1 || removedByName[name] != 1 {
+			changes = append(changes, Change{Kind: ChangeRemoved, Old: o})
+			continue
+		}
+		n := candidates[0]
+		recoded[n] = true
+		changes = append(changes, Change{Kind: ChangeRecoded, Old: o, New: n})
+		if d := distance(o, n); d > 0 {
+			changes = append(changes, Change{Kind: ChangeMoved, Old: o, New: n, Distance: d})
+		}
+	}
+	for _, n := range added {
+		if !recoded[n] {
+			changes = append(changes, Change{Kind: ChangeAdded, New: n})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].AreaCode() < changes[j].AreaCode()
+	})
+	return changes
+}
+
+func fullName(ap *AddressPosition) string {
+	return ap.PrefName + "\x00" + ap.CityName + "\x00" + ap.AreaName
+}
+
+func distance(a, b *AddressPosition) float64 {
+	if a.Latitude == b.Latitude && a.Longitude == b.Longitude {
+		return 0
+	}
+	p := geo.LatLong{Latitude: a.Latitude, Longitude: a.Longitude}
+	return p.Distance(geo.LatLong{Latitude: b.Latitude, Longitude: b.Longitude})
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	prev := AddressPositions{
+		newTestAP("13", "東京都", "13103", "港区", "131030002003", "芝公園三丁目", 35.659943, 139.747207),
+		newTestAP("13", "東京都", "13103", "港区", "131030002001", "芝公園一丁目", 35.658930, 139.751417),
+		newTestAP("13", "東京都", "13103", "港区", "131030011001", "芝大門一丁目", 35.658587, 139.753717),
+		newTestAP("13", "東京都", "13103", "港区", "131030012001", "浜松町一丁目", 35.658570, 139.756545),
+		newTestAP("13", "東京都", "13103", "港区", "131030001002", "虎ノ門二丁目", 35.668490, 139.746192),
+	}
+	next := AddressPositions{
+		newTestAP("13", "東京都", "13103", "港区", "131030002003", "芝公園三丁目", 35.659943, 139.747207),
+		newTestAP("13", "東京都", "13103", "港区", "131030002001", "芝公園1丁目", 35.659930, 139.751417),
+		newTestAP("13", "東京都", "13103", "港区", "131030011999", "芝大門一丁目", 35.658587, 139.753717),
+		newTestAP("13", "東京都", "13103", "港区", "131030001003", "虎ノ門三丁目", 35.664316, 139.747076),
+	}
+	got := Diff(prev, next)
+	type change struct {
+		kind     ChangeKind
+		oldCode  string
+		newCode  string
+		distance float64
+	}
+	want := []change{
+		{ChangeRemoved, "131030001002", "", 0},
+		{ChangeAdded, "", "131030001003", 0},
+		{ChangeRenamed, "131030002001", "131030002001", 0},
+		{ChangeMoved, "131030002001", "131030002001", 111.19492664455873},
+		{ChangeRecoded, "131030011001", "131030011999", 0},
+		{ChangeRemoved, "131030012001", "", 0},
+	}
+	var gotChanges []change
+	for _, c := range got {
+		gc := change{kind: c.Kind, distance: c.Distance}
+		if c.Old != nil {
+			gc.oldCode = c.Old.AreaCode
+		}
+		if c.New != nil {
+			gc.newCode = c.New.AreaCode
+		}
+		gotChanges = append(gotChanges, gc)
+	}
+	if len(gotChanges) != len(want) {
+		t.Fatalf("want = %v, got = %v", want, gotChanges)
+	}
+	for i := range want {
+		if math.Abs(gotChanges[i].distance-want[i].distance) > 1e-6 {
+			t.Errorf("want = %v, got = %v", want[i], gotChanges[i])
+		}
+		gotChanges[i].distance = want[i].distance
+	}
+	if !reflect.DeepEqual(gotChanges, want) {
+		t.Errorf("\nwant = %v\ngot  = %v", want, gotChanges)
+	}
+
+	if got := Diff(prev, prev); len(got) != 0 {
+		t.Errorf("want no changes, got = %v", got)
+	}
+}
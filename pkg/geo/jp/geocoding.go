@@ -7,14 +7,22 @@ package jp
 
 import (
 	"encoding/csv"
+	"fmt"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/pointindex"
 )
 
+// Attribution credits the japanese-addresses dataset read by ReadAPsFromFile,
+// which is licensed under CC BY 4.0, as HTML.
+const Attribution = `<a href="https://geolonia.github.io/japanese-addresses/">japanese-addresses</a> ` +
+	`by <a href="https://github.com/geolonia">geolonia</a> is licensed under ` +
+	`<a href="https://creativecommons.org/licenses/by/4.0/">CC BY 4.0</a>`
+
 // AddressPosition is a Japanese address and its position.
 type AddressPosition struct {
 	PrefCode     string
@@ -35,9 +43,14 @@ type AddressPosition struct {
 // AddressPositions is a slice of AddressPosition.
 type AddressPositions []AddressPosition
 
-// IndexedAPs is a map of AddressPosition keyed by the quadkey.
+// IndexedAPs is a map of AddressPosition keyed by the quadkey. It measures
+// distances with DistanceModel, and APIndex with a model of its own.
 type IndexedAPs map[string]AddressPositions
 
+// DistanceModel is the model used to measure distances in address queries
+// that take no model. It must be set before any query is run.
+var DistanceModel = geo.Spherical
+
 // NearbyAP is a nearby AddressPosition.
 type NearbyAP struct {
 	AddressPosition
@@ -65,38 +78,58 @@ func ReadAPsFromFile(path string) (AddressPositions, error) {
 			continue
 		}
 
-		lat, err := strconv.ParseFloat(record[10], 64)
-		if err != nil {
-			return nil, err
-		}
-		long, err := strconv.ParseFloat(record[11], 64)
+		ap, err := parseAP(record)
 		if err != nil {
 			return nil, err
 		}
-		quadkey := geo.LatLongToQuadkey(lat, long, 23)
-
-		ap := AddressPosition{
-			record[0],
-			record[1],
-			record[2],
-			record[3],
-			record[4],
-			record[5],
-			record[6],
-			record[7],
-			record[8],
-			record[9],
-			lat,
-			long,
-			quadkey,
-		}
 		aps = append(aps, ap)
 	}
 	return aps, nil
 }
 
+// apFields is the number of fields of a record of the dataset.
+const apFields = 12
+
+// parseAP parses a record of the dataset.
+func parseAP(record []string) (AddressPosition, error) {
+	if len(record) < apFields {
+		return AddressPosition{}, fmt.Errorf("want %d fields, got %d", apFields, len(record))
+	}
+	lat, err := strconv.ParseFloat(record[10], 64)
+	if err != nil {
+		return AddressPosition{}, fmt.Errorf("invalid latitude %q", record[10])
+	}
+	long, err := strconv.ParseFloat(record[11], 64)
+	if err != nil {
+		return AddressPosition{}, fmt.Errorf("invalid longitude %q", record[11])
+	}
+	quadkey := geo.LatLongToQuadkey(lat, long, 23)
+
+	return AddressPosition{
+		record[0],
+		record[1],
+		record[2],
+		record[3],
+		record[4],
+		record[5],
+		record[6],
+		record[7],
+		record[8],
+		record[9],
+		lat,
+		long,
+		quadkey,
+	}, nil
+}
+
 // FindByAreaName returns address positions containing the specified name.
 func (aps AddressPositions) FindByAreaName(n string, base geo.LatLong) []NearbyAP {
+	return aps.FindByAreaNameWithModel(n, base, DistanceModel)
+}
+
+// FindByAreaNameWithModel is FindByAreaName that measures distances with the
+// model.
+func (aps AddressPositions) FindByAreaNameWithModel(n string, base geo.LatLong, model geo.DistanceModel) []NearbyAP {
 	var unordered AddressPositions
 	for _, ap := range aps {
 		if strings.Contains(ap.AreaName, n) {
@@ -105,7 +138,7 @@ func (aps AddressPositions) FindByAreaName(n string, base geo.LatLong) []NearbyA
 	}
 	result := make([]NearbyAP, len(unordered))
 	for i, ap := range unordered {
-		d := base.Distance(geo.LatLong{
+		d := model.Distance(base, geo.LatLong{
 			Latitude:  ap.Latitude,
 			Longitude: ap.Longitude,
 		})
@@ -124,7 +157,7 @@ func (aps AddressPositions) Nearest(p geo.LatLong) NearbyAP {
 		po := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
 		points[i] = po
 	}
-	i, d := p.Nearest(points)
+	i, d := DistanceModel.Nearest(p, points)
 	return NearbyAP{aps[i], d}
 }
 
@@ -138,7 +171,7 @@ func (aps AddressPositions) Near(p geo.LatLong, zoom int) []NearbyAP {
 		for _, qk := range quadkeys {
 			if q == qk {
 				t := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
-				a := NearbyAP{ap, p.Distance(t)}
+				a := NearbyAP{ap, DistanceModel.Distance(p, t)}
 				near = append(near, a)
 			}
 		}
@@ -202,7 +235,7 @@ func (idx IndexedAPs) Nearest(p geo.LatLong) NearbyAP {
 		}
 
 		if len(aps) > minHits {
-			i, d := p.Nearest(points)
+			i, d := DistanceModel.Nearest(p, points)
 			nearest := NearbyAP{aps[i], d}
 			return nearest
 		}
@@ -217,7 +250,7 @@ func (idx IndexedAPs) Nearest(p geo.LatLong) NearbyAP {
 			allAPs = append(allAPs, ap)
 		}
 	}
-	minIdx, minDist := p.Nearest(allPoints)
+	minIdx, minDist := DistanceModel.Nearest(p, allPoints)
 	return NearbyAP{allAPs[minIdx], minDist}
 }
 
@@ -236,7 +269,7 @@ func (idx IndexedAPs) Near(p geo.LatLong, zoom int) []NearbyAP {
 				Latitude:  ap.Latitude,
 				Longitude: ap.Longitude,
 			}
-			d := p.Distance(t)
+			d := DistanceModel.Distance(p, t)
 			newAP := NearbyAP{ap, d}
 			aps = append(aps, newAP)
 		}
@@ -246,3 +279,174 @@ func (idx IndexedAPs) Near(p geo.LatLong, zoom int) []NearbyAP {
 	})
 	return aps
 }
+
+// Within returns address positions inside the specified region. It looks up
+// only the index cells that cover the region.
+func (idx IndexedAPs) Within(region geo.Region) AddressPositions {
+	const (
+		maxZoomLevel = 23
+		minZoomLevel = 4
+		maxCells     = 64
+	)
+	coverer := geo.RegionCoverer{
+		MinLevel: minZoomLevel,
+		MaxLevel: maxZoomLevel,
+		MaxCells: maxCells,
+	}
+	var aps AddressPositions
+	for _, q := range coverer.Quadkeys(region) {
+		for _, ap := range idx[q] {
+			p := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+			if region.Contains(p) {
+				aps = append(aps, ap)
+			}
+		}
+	}
+	return aps
+}
+
+// MaxNearRadius is the maximum radius in meters that the APIs accept for the
+// address positions within a radius.
+const MaxNearRadius = 50000
+
+// WithinRadius returns address positions within the specified distance in
+// meters from the specified position, closest first.
+func (idx IndexedAPs) WithinRadius(p geo.LatLong, radius float64) []NearbyAP {
+	// Enlarge the cap slightly so that the distance model decides the
+	// boundary.
+	region := geo.Cap{Center: p, Radius: radius * 1.01}
+	var aps []NearbyAP
+	for _, ap := range idx.Within(region) {
+		t := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+		d := DistanceModel.Distance(p, t)
+		if d <= radius {
+			aps = append(aps, NearbyAP{ap, d})
+		}
+	}
+	sort.Slice(aps, func(i, j int) bool {
+		return aps[i].Distance < aps[j].Distance
+	})
+	return aps
+}
+
+// KNearest returns up to k address positions closest to the specified
+// position, closest first.
+func (idx IndexedAPs) KNearest(p geo.LatLong, k int) []NearbyAP {
+	const (
+		minZoomLevel  = 4
+		initialRadius = 500
+		maxRadius     = 4000000
+	)
+	if k <= 0 {
+		return nil
+	}
+	// Widen the search until it holds k positions. Everything within the
+	// radius is found, so the first k are the k nearest.
+	for radius := float64(initialRadius); radius <= maxRadius; radius *= 2 {
+		aps := idx.WithinRadius(p, radius)
+		if len(aps) >= k {
+			return aps[:k]
+		}
+	}
+
+	// Every position is stored once per zoom level, so the cells of the
+	// coarsest level hold all positions exactly once.
+	var aps []NearbyAP
+	for q, s := range idx {
+		if len(q) != minZoomLevel {
+			continue
+		}
+		for _, ap := range s {
+			t := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+			aps = append(aps, NearbyAP{ap, DistanceModel.Distance(p, t)})
+		}
+	}
+	sort.Slice(aps, func(i, j int) bool {
+		return aps[i].Distance < aps[j].Distance
+	})
+	if len(aps) > k {
+		aps = aps[:k]
+	}
+	return aps
+}
+
+// APIndex is AddressPositions with a spatial index that measures distances
+// with its own model. It answers the queries of IndexedAPs, and shares its
+// index with the layers of points of interest.
+type APIndex struct {
+	aps   AddressPositions
+	index *pointindex.Index
+}
+
+// CreateAPIndex creates APIndex from the specified data, which measures
+// distances with the model.
+func CreateAPIndex(aps AddressPositions, model geo.DistanceModel) APIndex {
+	return APIndex{aps, pointindex.New(aps.positions(), model)}
+}
+
+func (ap *AddressPosition) position() geo.LatLong {
+	return geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+}
+
+func (aps AddressPositions) positions() []geo.LatLong {
+	points := make([]geo.LatLong, len(aps))
+	for i := range aps {
+		points[i] = aps[i].position()
+	}
+	return points
+}
+
+// Len returns the number of address positions.
+func (idx APIndex) Len() int {
+	return len(idx.aps)
+}
+
+// Model returns the distance model of the index.
+func (idx APIndex) Model() geo.DistanceModel {
+	return idx.index.Model()
+}
+
+func (idx APIndex) nearby(hits []pointindex.Hit) []NearbyAP {
+	if hits == nil {
+		return nil
+	}
+	aps := make([]NearbyAP, len(hits))
+	for i, h := range hits {
+		aps[i] = NearbyAP{idx.aps[h.Pos], h.Distance}
+	}
+	return aps
+}
+
+// Nearest returns an address position closest to the specified position.
+// The index must not be empty.
+func (idx APIndex) Nearest(p geo.LatLong) NearbyAP {
+	h, _ := idx.index.Nearest(p)
+	return NearbyAP{idx.aps[h.Pos], h.Distance}
+}
+
+// Near returns address positions close to the specified position.
+func (idx APIndex) Near(p geo.LatLong, zoom int) []NearbyAP {
+	return idx.nearby(idx.index.Near(p, zoom))
+}
+
+// Within returns address positions inside the specified region. It looks up
+// only the index cells that cover the region.
+func (idx APIndex) Within(region geo.Region) AddressPositions {
+	var aps AddressPositions
+	for _, i := range idx.index.Within(region) {
+		aps = append(aps, idx.aps[i])
+	}
+	return aps
+}
+
+// WithinRadius returns address positions within the specified distance in
+// meters from the specified position, closest first.
+func (idx APIndex) WithinRadius(p geo.LatLong, radius float64) []NearbyAP {
+	return idx.nearby(idx.index.WithinRadius(p, radius))
+}
+
+// KNearest returns up to k address positions closest to the specified
+// position, closest first.
+func (idx APIndex) KNearest(p geo.LatLong, k int) []NearbyAP {
+	return idx.nearby(idx.index.KNearest(p, k))
+}
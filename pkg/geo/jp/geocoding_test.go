@@ -143,3 +143,148 @@ func TestIndexedAPs_Near(t *testing.T) {
 		})
 	}
 }
+
+func TestIndexedAPs_Within(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps := CreateIndexedAPs(aps)
+	center := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	tests := []struct {
+		name string
+		in   geo.Region
+	}{
+		{"bbox", center.Bounds().Buffer(1000)},
+		{"cap", geo.Cap{Center: center, Radius: 800}},
+		{"polygon", geo.Circle(center, 1500, 12)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			want := map[string]bool{}
+			for _, ap := range aps {
+				p := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+				if tt.in.Contains(p) {
+					want[ap.AreaCode] = true
+				}
+			}
+			got := iaps.Within(tt.in)
+			if len(got) != len(want) {
+				t.Errorf("want = %v, got = %v", len(want), len(got))
+			}
+			for _, ap := range got {
+				if !want[ap.AreaCode] {
+					t.Errorf("want %v not to be within the region", ap.AreaCode)
+				}
+			}
+		})
+	}
+}
+
+func TestIndexedAPs_WithinRadius(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps := CreateIndexedAPs(aps)
+	center := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	got := iaps.WithinRadius(center, 500)
+	var want []string
+	for _, ap := range aps.Near(center, 14) {
+		if ap.Distance <= 500 {
+			want = append(want, ap.AreaCode)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want = %v, got = %v", len(want), len(got))
+	}
+	for i := range got {
+		if got[i].AreaCode != want[i] {
+			t.Errorf("want = %v, got = %v", want[i], got[i].AreaCode)
+		}
+	}
+	if got[0].AreaCode != "131030002003" {
+		t.Errorf("want = %v, got = %v", "131030002003", got[0].AreaCode)
+	}
+}
+
+func TestIndexedAPs_KNearest(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps := CreateIndexedAPs(aps)
+	tests := []struct {
+		name    string
+		in      geo.LatLong
+		inK     int
+		wantLen int
+	}{
+		{"normal", geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}, 3, 3},
+		{"all", geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}, 1000, len(aps)},
+		{"far", geo.LatLong{Latitude: 0, Longitude: 0}, 2, 2},
+		{"zero", geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}, 0, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := iaps.KNearest(tt.in, tt.inK)
+			if len(got) != tt.wantLen {
+				t.Fatalf("want = %v, got = %v", tt.wantLen, len(got))
+			}
+			if len(got) == 0 {
+				return
+			}
+			want := aps.Nearest(tt.in)
+			if got[0].AreaCode != want.AreaCode || got[0].Distance != want.Distance {
+				t.Errorf("want = %v, got = %v", want, got[0])
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i-1].Distance > got[i].Distance {
+					t.Errorf("want sorted by distance, got = %v", got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAPIndex(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps := CreateIndexedAPs(aps)
+	p := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	for _, model := range []geo.DistanceModel{geo.Spherical, geo.Ellipsoidal} {
+		idx := CreateAPIndex(aps, model)
+		if idx.Len() != len(aps) || idx.Model() != model {
+			t.Fatalf("want = %v %v, got = %v %v", len(aps), model, idx.Len(), idx.Model())
+		}
+
+		// Distances are measured with the model of the index, and the
+		// results match IndexedAPs otherwise.
+		got := idx.Nearest(p)
+		if want := iaps.Nearest(p); got.AreaCode != want.AreaCode {
+			t.Errorf("%v: want = %v, got = %v", model, want.AreaCode, got.AreaCode)
+		}
+		want := aps.FindByAreaNameWithModel(got.AreaName, p, model)
+		if len(want) == 0 || got.Distance != want[0].Distance {
+			t.Errorf("%v: want = %v, got = %v", model, want, got.Distance)
+		}
+		if got, want := len(idx.Near(p, 18)), len(iaps.Near(p, 18)); got != want {
+			t.Errorf("%v: want = %v, got = %v", model, want, got)
+		}
+		if got, want := len(idx.Within(geo.Cap{Center: p, Radius: 500})), len(iaps.Within(geo.Cap{Center: p, Radius: 500})); got != want {
+			t.Errorf("%v: want = %v, got = %v", model, want, got)
+		}
+		for _, a := range idx.KNearest(p, 5) {
+			if d := model.Distance(p, a.position()); d != a.Distance {
+				t.Errorf("%v: want = %v, got = %v", model, d, a.Distance)
+			}
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// Filter restricts address positions to prefectures and cities. An address
+// position matches if its prefecture is one of PrefCodes or PrefNames, when
+// either is given, and its city is one of CityCodes or CityNames, when
+// either is given. The zero value matches every address position.
+type Filter struct {
+	PrefCodes []string
+	PrefNames []string
+	CityCodes []string
+	CityNames []string
+}
+
+// IsZero reports whether the filter matches every address position.
+func (f Filter) IsZero() bool {
+	return !f.hasPref() && !f.hasCity()
+}
+
+func (f Filter) hasPref() bool {
+	return len(f.PrefCodes) > 0 || len(f.PrefNames) > 0
+}
+
+func (f Filter) hasCity() bool {
+	return len(f.CityCodes) > 0 || len(f.CityNames) > 0
+}
+
+// Match reports whether the address position matches the filter.
+func (f Filter) Match(ap *AddressPosition) bool {
+	if f.hasPref() && !contains(f.PrefCodes, ap.PrefCode) && !contains(f.PrefNames, ap.PrefName) {
+		return false
+	}
+	if f.hasCity() && !contains(f.CityCodes, ap.CityCode) && !contains(f.CityNames, ap.CityName) {
+		return false
+	}
+	return true
+}
+
+// key returns a string that identifies the filter regardless of the order of
+// the values.
+func (f Filter) key() string {
+	var b strings.Builder
+	for _, values := range [][]string{f.PrefCodes, f.PrefNames, f.CityCodes, f.CityNames} {
+		sorted := append([]string(nil), values...)
+		sort.Strings(sorted)
+		b.WriteString(strings.Join(sorted, "\x00"))
+		b.WriteByte('\x01')
+	}
+	return b.String()
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RegionalIndex is a spatial index that can be restricted to prefectures
+// and cities. It indexes every prefecture and city when it is created, and
+// answers a filter with the indexes of the regions that match it.
+type RegionalIndex struct {
+	aps   AddressPositions
+	index APIndex
+	// prefs and cities are the regions keyed by both code and name. A city
+	// name may be shared by cities of several prefectures.
+	prefs  map[string][]*region
+	cities map[string][]*region
+}
+
+// region is a prefecture or a city.
+type region struct {
+	prefCode string
+	prefName string
+	// ids are the positions of the address positions in the dataset.
+	ids   []int
+	aps   AddressPositions
+	index APIndex
+}
+
+// NewRegionalIndex returns a regional index of the address positions and
+// their index. The regions measure distances with the model of the index.
+func NewRegionalIndex(aps AddressPositions, index APIndex) *RegionalIndex {
+	r := &RegionalIndex{
+		aps:    aps,
+		index:  index,
+		prefs:  map[string][]*region{},
+		cities: map[string][]*region{},
+	}
+	prefs := map[string]*region{}
+	cities := map[string]*region{}
+	var all []*region
+	group := func(regions map[string]*region, code string, i int) *region {
+		g, ok := regions[code]
+		if !ok {
+			g = &region{prefCode: aps[i].PrefCode, prefName: aps[i].PrefName}
+			regions[code] = g
+			all = append(all, g)
+		}
+		g.ids = append(g.ids, i)
+		return g
+	}
+	for i, ap := range aps {
+		p := group(prefs, ap.PrefCode, i)
+		r.prefs[ap.PrefCode] = appendRegion(r.prefs[ap.PrefCode], p)
+		r.prefs[ap.PrefName] = appendRegion(r.prefs[ap.PrefName], p)
+		c := group(cities, ap.CityCode, i)
+		r.cities[ap.CityCode] = appendRegion(r.cities[ap.CityCode], c)
+		r.cities[ap.CityName] = appendRegion(r.cities[ap.CityName], c)
+	}
+	for _, g := range all {
+		g.aps = make(AddressPositions, len(g.ids))
+		for j, i := range g.ids {
+			g.aps[j] = aps[i]
+		}
+		g.index = CreateAPIndex(g.aps, index.Model())
+	}
+	return r
+}
+
+// appendRegion appends the region unless it is already in s.
+func appendRegion(s []*region, g *region) []*region {
+	for _, v := range s {
+		if v == g {
+			return s
+		}
+	}
+	return append(s, g)
+}
+
+// Index returns the index of the address positions matching the filter.
+// The index is empty if nothing matches.
+func (r *RegionalIndex) Index(f Filter) MergedIndex {
+	if f.IsZero() {
+		return MergedIndex{r.index}
+	}
+	regions := r.regions(f)
+	m := make(MergedIndex, len(regions))
+	for i, g := range regions {
+		m[i] = g.index
+	}
+	return m
+}
+
+// Positions returns the address positions matching the filter in the order
+// of the dataset.
+func (r *RegionalIndex) Positions(f Filter) AddressPositions {
+	if f.IsZero() {
+		return r.aps
+	}
+	regions := r.regions(f)
+	switch len(regions) {
+	case 0:
+		return nil
+	case 1:
+		return regions[0].aps
+	}
+	var ids []int
+	for _, g := range regions {
+		ids = append(ids, g.ids...)
+	}
+	sort.Ints(ids)
+	aps := make(AddressPositions, len(ids))
+	for j, i := range ids {
+		aps[j] = r.aps[i]
+	}
+	return aps
+}
+
+// Search is AddressPositions.Search that only looks at the address
+// positions matching the filter of the options, and measures distances with
+// the model of the index.
+func (r *RegionalIndex) Search(name string, opts SearchOptions) (SearchResult, error) {
+	model := r.index.Model()
+	opts.Model = &model
+	return r.Positions(opts.Filter).Search(name, opts)
+}
+
+// regions returns the cities matching the filter, or the prefectures if it
+// has no cities, in the order of the dataset.
+func (r *RegionalIndex) regions(f Filter) []*region {
+	groups, keys := r.prefs, [][]string{f.PrefCodes, f.PrefNames}
+	if f.hasCity() {
+		groups, keys = r.cities, [][]string{f.CityCodes, f.CityNames}
+	}
+	var regions []*region
+	for _, values := range keys {
+		for _, k := range values {
+			for _, g := range groups[k] {
+				// A city matches only in the prefectures of the filter.
+				if f.hasPref() && !contains(f.PrefCodes, g.prefCode) && !contains(f.PrefNames, g.prefName) {
+					continue
+				}
+				regions = appendRegion(regions, g)
+			}
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return regions[i].ids[0] < regions[j].ids[0]
+	})
+	return regions
+}
+
+// MergedIndex answers the queries of APIndex over the union of the address
+// positions of several indexes, which must not overlap.
+type MergedIndex []APIndex
+
+// Nearest returns the address position closest to the position. The index
+// must not be empty.
+func (m MergedIndex) Nearest(p geo.LatLong) NearbyAP {
+	nearest := m[0].Nearest(p)
+	for _, idx := range m[1:] {
+		if a := idx.Nearest(p); a.Distance < nearest.Distance {
+			nearest = a
+		}
+	}
+	return nearest
+}
+
+// Model returns the distance model of the indexes. The index must not be
+// empty.
+func (m MergedIndex) Model() geo.DistanceModel {
+	return m[0].Model()
+}
+
+// Near is APIndex.Near over all the indexes.
+func (m MergedIndex) Near(p geo.LatLong, zoom int) []NearbyAP {
+	if len(m) == 1 {
+		return m[0].Near(p, zoom)
+	}
+	var aps []NearbyAP
+	for _, idx := range m {
+		aps = append(aps, idx.Near(p, zoom)...)
+	}
+	sortByDistance(aps)
+	return aps
+}
+
+// WithinRadius is APIndex.WithinRadius over all the indexes.
+func (m MergedIndex) WithinRadius(p geo.LatLong, radius float64) []NearbyAP {
+	if len(m) == 1 {
+		return m[0].WithinRadius(p, radius)
+	}
+	var aps []NearbyAP
+	for _, idx := range m {
+		aps = append(aps, idx.WithinRadius(p, radius)...)
+	}
+	sortByDistance(aps)
+	return aps
+}
+
+func sortByDistance(aps []NearbyAP) {
+	sort.SliceStable(aps, func(i, j int) bool {
+		return aps[i].Distance < aps[j].Distance
+	})
+}
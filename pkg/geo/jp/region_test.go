@@ -0,0 +1,165 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+func TestFilter_Match(t *testing.T) {
+	ap := &AddressPosition{PrefCode: "13", PrefName: "東京都", CityCode: "13103", CityName: "港区"}
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"zero", Filter{}, true},
+		{"pref code", Filter{PrefCodes: []string{"27", "13"}}, true},
+		{"pref name", Filter{PrefNames: []string{"東京都"}}, true},
+		{"other pref", Filter{PrefCodes: []string{"27"}}, false},
+		{"pref code or name", Filter{PrefCodes: []string{"27"}, PrefNames: []string{"東京都"}}, true},
+		{"city name", Filter{CityNames: []string{"港区"}}, true},
+		{"pref and city", Filter{PrefCodes: []string{"13"}, CityCodes: []string{"13103"}}, true},
+		{"pref and other city", Filter{PrefCodes: []string{"13"}, CityCodes: []string{"13101"}}, false},
+		{"other pref and city", Filter{PrefCodes: []string{"27"}, CityNames: []string{"港区"}}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.filter.Match(ap); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRegionalIndex(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Move the area closest to the position into another city to see that
+	// the filter is not crossed.
+	p := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	closest := CreateAPIndex(aps, geo.Spherical).Nearest(p)
+	for i := range aps {
+		if aps[i].AreaCode == closest.AreaCode {
+			aps[i].CityCode = "13101"
+			aps[i].CityName = "千代田区"
+		}
+	}
+	r := NewRegionalIndex(aps, CreateAPIndex(aps, geo.Spherical))
+
+	tests := []struct {
+		name        string
+		filter      Filter
+		wantClosest bool
+		wantCount   int
+	}{
+		{"zero", Filter{}, true, len(aps)},
+		{"other city", Filter{CityCodes: []string{"13101"}}, true, 1},
+		{"city", Filter{CityCodes: []string{"13103"}}, false, len(aps) - 1},
+		{"city name", Filter{PrefNames: []string{"東京都"}, CityNames: []string{"港区"}}, false, len(aps) - 1},
+		{"pref", Filter{PrefCodes: []string{"13"}}, true, len(aps)},
+		{"cities", Filter{CityNames: []string{"港区", "千代田区"}}, true, len(aps)},
+		{"no match", Filter{PrefCodes: []string{"27"}, CityCodes: []string{"13103"}}, false, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			positions := r.Positions(tt.filter)
+			if got := len(positions); got != tt.wantCount {
+				t.Errorf("want = %v, got = %v", tt.wantCount, got)
+			}
+			idx := r.Index(tt.filter)
+			if tt.wantCount == 0 {
+				if len(idx) != 0 {
+					t.Errorf("want = %v, got = %v", 0, len(idx))
+				}
+				return
+			}
+			got := idx.Nearest(p)
+			if want := positions.Nearest(p); got.AreaCode != want.AreaCode {
+				t.Errorf("want = %v, got = %v", want.AreaName, got.AreaName)
+			}
+			if (got.AreaCode == closest.AreaCode) != tt.wantClosest {
+				t.Errorf("want = %v, got = %v", tt.wantClosest, got.AreaName)
+			}
+			for _, a := range idx.WithinRadius(p, 1000) {
+				if !tt.filter.Match(&a.AddressPosition) {
+					t.Errorf("want = %v, got = %v", "a match", a.AddressPosition)
+				}
+			}
+		})
+	}
+}
+
+func TestRegionalIndex_Regions(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aps[0].CityCode = "13101"
+	aps[0].CityName = "千代田区"
+	r := NewRegionalIndex(aps, CreateAPIndex(aps, geo.Spherical))
+
+	// Filters share the indexes of their regions.
+	city := r.Index(Filter{CityCodes: []string{"13103"}})
+	cities := r.Index(Filter{CityCodes: []string{"13101"}, CityNames: []string{"港区"}})
+	if len(city) != 1 || len(cities) != 2 {
+		t.Fatalf("want = %v, got = %v", "1 and 2 indexes", []int{len(city), len(cities)})
+	}
+	if cities[1].index != city[0].index {
+		t.Errorf("want = %v, got = %v", "a shared index", "a new index")
+	}
+	if got := r.Positions(Filter{CityNames: []string{"港区", "千代田区"}}); !reflect.DeepEqual(got, aps) {
+		t.Errorf("want = %v, got = %v", aps, got)
+	}
+}
+
+func TestMergedIndex(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var even, odd AddressPositions
+	for i, ap := range aps {
+		if i%2 == 0 {
+			even = append(even, ap)
+		} else {
+			odd = append(odd, ap)
+		}
+	}
+	want := CreateAPIndex(aps, geo.Spherical)
+	m := MergedIndex{CreateAPIndex(even, geo.Spherical), CreateAPIndex(odd, geo.Spherical)}
+
+	codes := func(aps []NearbyAP) []string {
+		var codes []string
+		for _, ap := range aps {
+			codes = append(codes, ap.AreaCode)
+		}
+		return codes
+	}
+	for _, p := range []geo.LatLong{
+		{Latitude: 35.658584, Longitude: 139.7454316},
+		{Latitude: 35.681236, Longitude: 139.767125},
+		{Latitude: 43.068661, Longitude: 141.350755},
+	} {
+		if got, want := m.Nearest(p), want.Nearest(p); got != want {
+			t.Errorf("%v: want = %v, got = %v", p, want, got)
+		}
+		if got, want := codes(m.Near(p, 15)), codes(want.Near(p, 15)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: want = %v, got = %v", p, want, got)
+		}
+		if got, want := codes(m.WithinRadius(p, 2000)), codes(want.WithinRadius(p, 2000)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: want = %v, got = %v", p, want, got)
+		}
+	}
+}
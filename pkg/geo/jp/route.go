@@ -0,0 +1,131 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"math"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+const (
+	// MaxRouteSamples is the maximum number of positions sampled between the
+	// vertices of a route. Longer routes are sampled at a wider interval.
+	MaxRouteSamples = 10000
+	// MaxRouteVertices is the maximum number of vertices of a route. Every
+	// vertex is sampled in addition to the positions between them, so callers
+	// should reject longer routes.
+	MaxRouteVertices = 10000
+)
+
+// RouteArea is an area traversed by a route.
+type RouteArea struct {
+	AddressPosition
+	// Entry is the position where the route enters the area.
+	Entry geo.LatLong
+	// Exit is the position where the route leaves the area.
+	Exit geo.LatLong
+	// Distance is the distance travelled in the area in meters.
+	Distance float64
+}
+
+type routeSample struct {
+	position geo.LatLong
+	// offset is the distance from the start of the route in meters.
+	offset float64
+	ap     AddressPosition
+}
+
+// Route returns the areas traversed by the route in order. The route is
+// sampled at the specified interval in meters on the GRS80 ellipsoid and at
+// every vertex, and each sample is assigned to the nearest area. The
+// boundary between two areas is placed halfway between the samples.
+func (idx IndexedAPs) Route(route geo.LineString, interval float64) []RouteArea {
+	if len(idx) == 0 {
+		return nil
+	}
+	return routeAreas(idx, route, interval)
+}
+
+// Route is IndexedAPs.Route.
+func (idx APIndex) Route(route geo.LineString, interval float64) []RouteArea {
+	if idx.Len() == 0 {
+		return nil
+	}
+	return routeAreas(idx, route, interval)
+}
+
+// routeAreas returns the areas traversed by the route, which are found in
+// the index. The index must not be empty.
+func routeAreas(idx NearestFinder, route geo.LineString, interval float64) []RouteArea {
+	if len(route) == 0 {
+		return nil
+	}
+	length := route.Length()
+	if interval <= 0 || length/interval > MaxRouteSamples {
+		interval = length / MaxRouteSamples
+	}
+	if interval == 0 {
+		interval = 1
+	}
+
+	samples := sampleRoute(idx, route, interval)
+	var areas []RouteArea
+	var current *RouteArea
+	for i, s := range samples {
+		if current != nil && current.AreaCode == s.ap.AreaCode {
+			continue
+		}
+		entry := s.position
+		entryOffset := s.offset
+		if current != nil {
+			prev := samples[i-1]
+			gap := s.offset - prev.offset
+			entry = prev.position.Destination(prev.position.Bearing(s.position), gap/2)
+			entryOffset = prev.offset + gap/2
+			current.Exit = entry
+			current.Distance = entryOffset - current.Distance
+		}
+		areas = append(areas, RouteArea{
+			AddressPosition: s.ap,
+			Entry:           entry,
+			// Hold the entry offset until the exit is known.
+			Distance: entryOffset,
+		})
+		current = &areas[len(areas)-1]
+	}
+	last := samples[len(samples)-1]
+	current.Exit = last.position
+	current.Distance = last.offset - current.Distance
+	return areas
+}
+
+func sampleRoute(idx NearestFinder, route geo.LineString, interval float64) []routeSample {
+	samples := []routeSample{{
+		position: route[0],
+		ap:       idx.Nearest(route[0]).AddressPosition,
+	}}
+	var offset float64
+	for i := 1; i < len(route); i++ {
+		start := route[i-1]
+		d, bearing, _ := geo.GRS80.Inverse(start, route[i])
+		n := int(math.Ceil(d / interval))
+		for j := 1; j <= n; j++ {
+			p := route[i]
+			step := d
+			if j < n {
+				step = interval * float64(j)
+				p, _ = geo.GRS80.Direct(start, bearing, step)
+			}
+			samples = append(samples, routeSample{
+				position: p,
+				offset:   offset + step,
+				ap:       idx.Nearest(p).AddressPosition,
+			})
+		}
+		offset += d
+	}
+	return samples
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+func TestIndexedAPs_Route(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps := CreateIndexedAPs(aps)
+	route := geo.LineString{
+		{Latitude: 35.659943, Longitude: 139.747207},
+		{Latitude: 35.658930, Longitude: 139.751417},
+		{Latitude: 35.658570, Longitude: 139.756545},
+	}
+	tests := []struct {
+		name          string
+		inInterval    float64
+		wantAreaCodes []string
+	}{
+		{
+			"normal",
+			50,
+			[]string{"131030002003", "131030002001", "131030011001", "131030012001"},
+		},
+		{
+			"default interval",
+			0,
+			[]string{"131030002003", "131030002001", "131030011001", "131030012001"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := iaps.Route(route, tt.inInterval)
+			var gotCodes []string
+			var total float64
+			for i, a := range got {
+				gotCodes = append(gotCodes, a.AreaCode)
+				total += a.Distance
+				if i > 0 && a.Entry != got[i-1].Exit {
+					t.Errorf("want = %v, got = %v", got[i-1].Exit, a.Entry)
+				}
+			}
+			if len(gotCodes) != len(tt.wantAreaCodes) {
+				t.Fatalf("want = %v, got = %v", tt.wantAreaCodes, gotCodes)
+			}
+			for i := range gotCodes {
+				if gotCodes[i] != tt.wantAreaCodes[i] {
+					t.Errorf("want = %v, got = %v", tt.wantAreaCodes, gotCodes)
+					break
+				}
+			}
+			if got[0].Entry != route[0] {
+				t.Errorf("want = %v, got = %v", route[0], got[0].Entry)
+			}
+			if last := got[len(got)-1]; last.Exit != route[2] {
+				t.Errorf("want = %v, got = %v", route[2], last.Exit)
+			}
+			if want := route.Length(); math.Abs(total-want) > 1e-6 {
+				t.Errorf("want = %v, got = %v", want, total)
+			}
+		})
+	}
+}
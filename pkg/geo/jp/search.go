@@ -0,0 +1,146 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/pointindex"
+)
+
+// SortOrder is the order of search results.
+type SortOrder string
+
+// Sort orders.
+const (
+	// SortByDistance sorts by distance from the origin.
+	SortByDistance SortOrder = "distance"
+	// SortByName sorts by area name.
+	SortByName SortOrder = "name"
+	// SortByCode sorts by area code.
+	SortByCode SortOrder = "code"
+	// SortByRelevance sorts exact matches first, then names starting with
+	// the query, then the others, and shorter names first within each.
+	SortByRelevance SortOrder = "relevance"
+)
+
+// ParseSortOrder returns the sort order with the name.
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch o := SortOrder(s); o {
+	case SortByDistance, SortByName, SortByCode, SortByRelevance:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown sort order: %q", s)
+}
+
+const (
+	// DefaultSearchLimit is the number of results of a page if the client
+	// gives no limit.
+	DefaultSearchLimit = pointindex.DefaultSearchLimit
+	// MaxSearchLimit is the maximum number of results of a page.
+	MaxSearchLimit = pointindex.MaxSearchLimit
+)
+
+// SearchOptions are the options of Search.
+type SearchOptions struct {
+	// Origin is the position distances are measured from. Without it, the
+	// results have no distance.
+	Origin *geo.LatLong
+	// Model is the model the distances are measured with. Nil means
+	// DistanceModel.
+	Model *geo.DistanceModel
+	// Filter restricts the results to prefectures and cities.
+	Filter Filter
+	// Sort is the order of the results. It defaults to SortByDistance with
+	// an origin and SortByRelevance without.
+	Sort SortOrder
+	// Offset is the number of results to skip.
+	Offset int
+	// Limit is the maximum number of results, up to MaxSearchLimit. Zero
+	// means DefaultSearchLimit.
+	Limit int
+}
+
+// SearchResult is a page of search results.
+type SearchResult struct {
+	Areas []NearbyAP
+	// Total is the number of results of all pages.
+	Total int
+}
+
+// Search returns the address positions whose area names contain the name.
+// Results with equal keys are ordered by area code, so pages are stable.
+func (aps AddressPositions) Search(name string, opts SearchOptions) (SearchResult, error) {
+	order := opts.Sort
+	if order == "" {
+		order = SortByRelevance
+		if opts.Origin != nil {
+			order = SortByDistance
+		}
+	}
+	if order == SortByDistance && opts.Origin == nil {
+		return SearchResult{}, fmt.Errorf("sort order %q requires an origin", order)
+	}
+	limit, err := pointindex.PageLimit(opts.Offset, opts.Limit)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	model := DistanceModel
+	if opts.Model != nil {
+		model = *opts.Model
+	}
+
+	var found []NearbyAP
+	for i := range aps {
+		ap := aps[i]
+		if !strings.Contains(ap.AreaName, name) || !opts.Filter.Match(&ap) {
+			continue
+		}
+		a := NearbyAP{AddressPosition: ap}
+		if opts.Origin != nil {
+			a.Distance = model.Distance(*opts.Origin, ap.position())
+		}
+		found = append(found, a)
+	}
+
+	var less func(a, b *NearbyAP) bool
+	switch order {
+	case SortByDistance:
+		less = func(a, b *NearbyAP) bool {
+			return a.Distance < b.Distance
+		}
+	case SortByName:
+		less = func(a, b *NearbyAP) bool {
+			return a.AreaName < b.AreaName
+		}
+	case SortByCode:
+		less = func(a, b *NearbyAP) bool {
+			return false
+		}
+	case SortByRelevance:
+		less = func(a, b *NearbyAP) bool {
+			return pointindex.MoreRelevant(name, a.AreaName, a.Distance, b.AreaName, b.Distance)
+		}
+	default:
+		return SearchResult{}, fmt.Errorf("unknown sort order: %q", order)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := &found[i], &found[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.AreaCode < b.AreaCode
+	})
+
+	start, end := pointindex.PageBounds(len(found), opts.Offset, limit)
+	return SearchResult{Areas: found[start:end], Total: len(found)}, nil
+}
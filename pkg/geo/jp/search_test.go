@@ -0,0 +1,150 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+func TestAPs_Search(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shibakoen := &geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	tests := []struct {
+		name      string
+		query     string
+		opts      SearchOptions
+		want      []string
+		wantTotal int
+		wantErr   bool
+	}{
+		{
+			"relevance",
+			"芝",
+			SearchOptions{Limit: 7},
+			[]string{"芝一丁目", "芝二丁目", "芝三丁目", "芝四丁目", "芝五丁目", "芝浦一丁目", "芝浦二丁目"},
+			15,
+			false,
+		},
+		{
+			"exact match first",
+			"芝公園一丁目",
+			SearchOptions{},
+			[]string{"芝公園一丁目"},
+			1,
+			false,
+		},
+		{
+			"distance by default with origin",
+			"芝公園",
+			SearchOptions{Origin: shibakoen},
+			[]string{"芝公園三丁目", "芝公園四丁目", "芝公園一丁目", "芝公園二丁目"},
+			4,
+			false,
+		},
+		{
+			"code",
+			"芝公園",
+			SearchOptions{Sort: SortByCode, Origin: shibakoen},
+			[]string{"芝公園一丁目", "芝公園二丁目", "芝公園三丁目", "芝公園四丁目"},
+			4,
+			false,
+		},
+		{
+			"name",
+			"芝浦",
+			SearchOptions{Sort: SortByName},
+			[]string{"芝浦一丁目", "芝浦三丁目", "芝浦二丁目", "芝浦四丁目"},
+			4,
+			false,
+		},
+		{
+			"offset",
+			"芝公園",
+			SearchOptions{Sort: SortByCode, Offset: 1, Limit: 2},
+			[]string{"芝公園二丁目", "芝公園三丁目"},
+			4,
+			false,
+		},
+		{
+			"offset beyond total",
+			"芝公園",
+			SearchOptions{Offset: 10},
+			[]string{},
+			4,
+			false,
+		},
+		{
+			"pref filter",
+			"芝公園",
+			SearchOptions{Filter: Filter{PrefCodes: []string{"27"}}},
+			[]string{},
+			0,
+			false,
+		},
+		{
+			"city filter",
+			"芝公園",
+			SearchOptions{Filter: Filter{PrefCodes: []string{"13"}, CityCodes: []string{"13101", "13103"}}, Sort: SortByCode, Limit: 1},
+			[]string{"芝公園一丁目"},
+			4,
+			false,
+		},
+		{
+			"name filter",
+			"芝公園",
+			SearchOptions{Filter: Filter{PrefNames: []string{"東京都"}, CityNames: []string{"港区"}}, Sort: SortByCode, Limit: 1},
+			[]string{"芝公園一丁目"},
+			4,
+			false,
+		},
+		{"distance without origin", "芝", SearchOptions{Sort: SortByDistance}, nil, 0, true},
+		{"unknown order", "芝", SearchOptions{Sort: "random"}, nil, 0, true},
+		{"negative limit", "芝", SearchOptions{Limit: -1}, nil, 0, true},
+		{"limit over max", "芝", SearchOptions{Limit: MaxSearchLimit + 1}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := aps.Search(tt.query, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want = %v, got = %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			names := []string{}
+			for _, a := range got.Areas {
+				names = append(names, a.AreaName)
+				if (a.Distance > 0) != (tt.opts.Origin != nil) {
+					t.Errorf("want = %v, got = %v", tt.opts.Origin != nil, a.Distance)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, names)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("want = %v, got = %v", tt.wantTotal, got.Total)
+			}
+		})
+	}
+}
+
+func TestParseSortOrder(t *testing.T) {
+	for _, s := range []string{"distance", "name", "code", "relevance"} {
+		if got, err := ParseSortOrder(s); err != nil || string(got) != s {
+			t.Errorf("want = %v, got = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseSortOrder("Distance"); err == nil {
+		t.Errorf("want = %v, got = %v", "error", err)
+	}
+}
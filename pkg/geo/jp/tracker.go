@@ -0,0 +1,86 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"errors"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// ErrTooManyDevices is returned when a tracker has reached its maximum
+// number of devices.
+var ErrTooManyDevices = errors.New("too many devices")
+
+// NearestFinder finds the address position nearest to a position, like
+// IndexedAPs, APIndex and MergedIndex.
+type NearestFinder interface {
+	Nearest(p geo.LatLong) NearbyAP
+}
+
+// modelOf returns the distance model of the index if it has one, like
+// APIndex, and DistanceModel otherwise.
+func modelOf(index NearestFinder) geo.DistanceModel {
+	if m, ok := index.(interface{ Model() geo.DistanceModel }); ok {
+		return m.Model()
+	}
+	return DistanceModel
+}
+
+// AreaTracker keeps the current area of moving devices. A device switches
+// to another area only when the area is nearer than the current one by more
+// than a margin, so that a device near the boundary of two areas does not
+// flap between them. It is not safe for concurrent use.
+type AreaTracker struct {
+	index      NearestFinder
+	margin     float64
+	maxDevices int
+	areas      map[string]AddressPosition
+}
+
+// NewAreaTracker returns a tracker of the areas of the index with the
+// margin in meters. It tracks at most maxDevices devices, or any number if
+// maxDevices is zero.
+func NewAreaTracker(index NearestFinder, margin float64, maxDevices int) *AreaTracker {
+	return &AreaTracker{
+		index:      index,
+		margin:     margin,
+		maxDevices: maxDevices,
+		areas:      map[string]AddressPosition{},
+	}
+}
+
+// Update records the position of the device and returns its current area
+// and whether the area changed. The first position of a device is a change.
+func (t *AreaTracker) Update(deviceID string, p geo.LatLong) (NearbyAP, bool, error) {
+	current, ok := t.areas[deviceID]
+	if !ok && t.maxDevices > 0 && len(t.areas) >= t.maxDevices {
+		return NearbyAP{}, false, ErrTooManyDevices
+	}
+	nearest := t.index.Nearest(p)
+	if !ok {
+		t.areas[deviceID] = nearest.AddressPosition
+		return nearest, true, nil
+	}
+	if fullName(&current) == fullName(&nearest.AddressPosition) {
+		return nearest, false, nil
+	}
+	d := modelOf(t.index).Distance(p, current.position())
+	if d-nearest.Distance > t.margin {
+		t.areas[deviceID] = nearest.AddressPosition
+		return nearest, true, nil
+	}
+	return NearbyAP{current, d}, false, nil
+}
+
+// Forget removes the device, so its next position is a change.
+func (t *AreaTracker) Forget(deviceID string) {
+	delete(t.areas, deviceID)
+}
+
+// Len returns the number of devices.
+func (t *AreaTracker) Len() int {
+	return len(t.areas)
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+func TestAreaTracker_Update(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := CreateIndexedAPs(aps)
+	// A device moves back and forth between two neighboring areas about
+	// 470 meters apart.
+	a := geo.LatLong{Latitude: 35.659943, Longitude: 139.747207}
+	b := geo.LatLong{Latitude: 35.656698, Longitude: 139.743777}
+	at := func(f float64) geo.LatLong {
+		return geo.LatLong{
+			Latitude:  a.Latitude + (b.Latitude-a.Latitude)*f,
+			Longitude: a.Longitude + (b.Longitude-a.Longitude)*f,
+		}
+	}
+	tr := NewAreaTracker(idx, 100, 2)
+	steps := []struct {
+		f           float64
+		wantNearest string
+		want        string
+		wantChanged bool
+	}{
+		{0.1, "芝公園三丁目", "芝公園三丁目", true},
+		{0.2, "芝公園三丁目", "芝公園三丁目", false},
+		{0.55, "東麻布一丁目", "芝公園三丁目", false},
+		{0.45, "芝公園三丁目", "芝公園三丁目", false},
+		{0.7, "東麻布一丁目", "東麻布一丁目", true},
+		{0.45, "芝公園三丁目", "東麻布一丁目", false},
+		{0.2, "芝公園三丁目", "芝公園三丁目", true},
+	}
+	for i, s := range steps {
+		p := at(s.f)
+		if got := idx.Nearest(p).AreaName; got != s.wantNearest {
+			t.Fatalf("%d: want = %v, got = %v", i, s.wantNearest, got)
+		}
+		got, changed, err := tr.Update("d1", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.AreaName != s.want || changed != s.wantChanged {
+			t.Errorf("%d: want = %v %v, got = %v %v", i, s.want, s.wantChanged, got.AreaName, changed)
+		}
+		ap := geo.LatLong{Latitude: got.Latitude, Longitude: got.Longitude}
+		if d := DistanceModel.Distance(p, ap); d != got.Distance {
+			t.Errorf("%d: want = %v, got = %v", i, d, got.Distance)
+		}
+	}
+
+	if _, changed, err := tr.Update("d2", b); err != nil || !changed {
+		t.Errorf("want = %v, got = %v %v", true, changed, err)
+	}
+	if _, _, err := tr.Update("d3", b); err != ErrTooManyDevices {
+		t.Errorf("want = %v, got = %v", ErrTooManyDevices, err)
+	}
+	tr.Forget("d1")
+	if tr.Len() != 1 {
+		t.Errorf("want = %v, got = %v", 1, tr.Len())
+	}
+	if _, changed, err := tr.Update("d3", b); err != nil || !changed {
+		t.Errorf("want = %v, got = %v %v", true, changed, err)
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// IssueKind is a kind of problem found in a dataset.
+type IssueKind string
+
+// Kinds of issues.
+const (
+	// IssueDuplicateCode is an area code that appears more than once.
+	IssueDuplicateCode IssueKind = "duplicate_code"
+	// IssueOutOfBounds is a position outside Japan.
+	IssueOutOfBounds IssueKind = "out_of_bounds"
+	// IssueOutlier is a position far from the other positions of its
+	// municipality.
+	IssueOutlier IssueKind = "outlier"
+	// IssueNameMismatch is a name that differs from the other names with the
+	// same code.
+	IssueNameMismatch IssueKind = "name_mismatch"
+	// IssueCodeMismatch is a code that is malformed or does not start with
+	// the code of its prefecture or municipality.
+	IssueCodeMismatch IssueKind = "code_mismatch"
+	// IssueMalformed is a row of a file that cannot be parsed.
+	IssueMalformed IssueKind = "malformed"
+)
+
+// JapanBBox is a bounding box that contains all of Japan.
+var JapanBBox = geo.BBox{
+	Min: geo.LatLong{Latitude: 20, Longitude: 122},
+	Max: geo.LatLong{Latitude: 46, Longitude: 154},
+}
+
+const (
+	// outlierMinDistance is the distance in meters from the center of a
+	// municipality within which a position is never an outlier.
+	outlierMinDistance = 20000
+	// outlierFactor is how many times farther than the median distance from
+	// the center of a municipality a position must be to be an outlier.
+	outlierFactor = 5
+	// outlierMinPositions is the number of positions a municipality needs for
+	// its outliers to be detected.
+	outlierMinPositions = 3
+)
+
+// Issue is a problem found in a dataset.
+type Issue struct {
+	Kind IssueKind `json:"kind"`
+	// Index is the index of the address position in the dataset, or -1 for
+	// a malformed row.
+	Index    int    `json:"index"`
+	AreaCode string `json:"area_code"`
+	Message  string `json:"message"`
+}
+
+// String returns the issue in a human readable form.
+func (i Issue) String() string {
+	return fmt.Sprintf("#%d %s: %s: %s", i.Index, i.AreaCode, i.Kind, i.Message)
+}
+
+// Validate checks the dataset for duplicate codes, positions outside Japan,
+// positions far from their municipality, names inconsistent with their codes
+// and codes inconsistent with each other. The issues are sorted by index.
+func Validate(aps AddressPositions) []Issue {
+	var issues []Issue
+	issues = append(issues, validateCodes(aps)...)
+	issues = append(issues, validateDuplicates(aps)...)
+	issues = append(issues, validateNames(aps)...)
+	issues = append(issues, validateBounds(aps)...)
+	issues = append(issues, validateOutliers(aps)...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Index < issues[j].Index
+	})
+	return issues
+}
+
+// FileIssue is an issue found in a file with the line of its row.
+type FileIssue struct {
+	Issue
+	Line int `json:"line"`
+}
+
+// ValidateFile reads the dataset from a file and checks it like Validate.
+// Unlike ReadAPsFromFile, it reports rows that cannot be parsed as malformed
+// and skips them instead of failing. It returns the rows that could be parsed
+// and the issues sorted by line.
+func ValidateFile(path string) (AddressPositions, []FileIssue, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	return validateCSV(file)
+}
+
+func validateCSV(r io.Reader) (AddressPositions, []FileIssue, error) {
+	lr := &lineReader{r: bufio.NewReader(r)}
+	reader := csv.NewReader(lr)
+	reader.FieldsPerRecord = -1
+	var (
+		aps    AddressPositions
+		lines  []int
+		issues []FileIssue
+	)
+	for header := true; ; header = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if perr, ok := err.(*csv.ParseError); ok {
+			issues = append(issues, FileIssue{Issue{IssueMalformed, -1, "", perr.Err.Error()}, perr.StartLine})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if header {
+			continue
+		}
+		// The record ends at the last line read, and quoted fields may
+		// span lines.
+		line := lr.line()
+		for _, field := range record {
+			line -= strings.Count(field, "\n")
+		}
+		ap, err := parseAP(record)
+		if err != nil {
+			var code string
+			if len(record) > 8 {
+				code = record[8]
+			}
+			issues = append(issues, FileIssue{Issue{IssueMalformed, -1, code, err.Error()}, line})
+			continue
+		}
+		aps = append(aps, ap)
+		lines = append(lines, line)
+	}
+	for _, i := range Validate(aps) {
+		issues = append(issues, FileIssue{i, lines[i.Index]})
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+	return aps, issues, nil
+}
+
+// lineReader reads at most a line at a time and counts the lines, so that
+// a csv.Reader reading from it has read up to the end of its last record.
+type lineReader struct {
+	r     *bufio.Reader
+	lines int
+	// partial is whether the last line read has no newline yet.
+	partial bool
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := l.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		p[n] = b
+		n++
+		if b == '\n' {
+			l.lines++
+			l.partial = false
+			return n, nil
+		}
+		l.partial = true
+	}
+	return n, nil
+}
+
+// line returns the number of the last line read, counting from 1.
+func (l *lineReader) line() int {
+	if l.partial {
+		return l.lines + 1
+	}
+	return l.lines
+}
+
+func validateCodes(aps AddressPositions) []Issue {
+	var issues []Issue
+	for i, ap := range aps {
+		var msg string
+		switch {
+		case !isDigits(ap.PrefCode, 2):
+			msg = fmt.Sprintf("invalid prefecture code %q", ap.PrefCode)
+		case !isDigits(ap.CityCode, 5):
+			msg = fmt.Sprintf("invalid city code %q", ap.CityCode)
+		case !isDigits(ap.AreaCode, 12):
+			msg = fmt.Sprintf("invalid area code %q", ap.AreaCode)
+		case !strings.HasPrefix(ap.CityCode, ap.PrefCode):
+			msg = fmt.Sprintf("city code %q is not in prefecture %q", ap.CityCode, ap.PrefCode)
+		case !strings.HasPrefix(ap.AreaCode, ap.CityCode):
+			msg = fmt.Sprintf("area code is not in city %q", ap.CityCode)
+		default:
+			continue
+		}
+		issues = append(issues, Issue{IssueCodeMismatch, i, ap.AreaCode, msg})
+	}
+	return issues
+}
+
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func validateDuplicates(aps AddressPositions) []Issue {
+	var issues []Issue
+	first := map[string]int{}
+	for i, ap := range aps {
+		if j, ok := first[ap.AreaCode]; ok {
+			msg := fmt.Sprintf("duplicate of #%d", j)
+			issues = append(issues, Issue{IssueDuplicateCode, i, ap.AreaCode, msg})
+			continue
+		}
+		first[ap.AreaCode] = i
+	}
+	return issues
+}
+
+func validateNames(aps AddressPositions) []Issue {
+	var issues []Issue
+	prefNames := map[string]string{}
+	cityNames := map[string]string{}
+	for i, ap := range aps {
+		if ap.AreaName == "" {
+			issues = append(issues, Issue{IssueNameMismatch, i, ap.AreaCode, "empty area name"})
+		}
+		if n, ok := prefNames[ap.PrefCode]; !ok {
+			prefNames[ap.PrefCode] = ap.PrefName
+		} else if n != ap.PrefName {
+			msg := fmt.Sprintf("prefecture %q is named %q, want %q", ap.PrefCode, ap.PrefName, n)
+			issues = append(issues, Issue{IssueNameMismatch, i, ap.AreaCode, msg})
+		}
+		if n, ok := cityNames[ap.CityCode]; !ok {
+			cityNames[ap.CityCode] = ap.CityName
+		} else if n != ap.CityName {
+			msg := fmt.Sprintf("city %q is named %q, want %q", ap.CityCode, ap.CityName, n)
+			issues = append(issues, Issue{IssueNameMismatch, i, ap.AreaCode, msg})
+		}
+	}
+	return issues
+}
+
+func validateBounds(aps AddressPositions) []Issue {
+	var issues []Issue
+	for i, ap := range aps {
+		p := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+		if !JapanBBox.Contains(p) {
+			msg := fmt.Sprintf("position (%v, %v) is outside Japan", ap.Latitude, ap.Longitude)
+			issues = append(issues, Issue{IssueOutOfBounds, i, ap.AreaCode, msg})
+		}
+	}
+	return issues
+}
+
+func validateOutliers(aps AddressPositions) []Issue {
+	cities := map[string][]int{}
+	for i, ap := range aps {
+		p := geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude}
+		// Positions outside Japan are reported separately and would distort
+		// the center.
+		if JapanBBox.Contains(p) {
+			cities[ap.CityCode] = append(cities[ap.CityCode], i)
+		}
+	}
+
+	var issues []Issue
+	for _, indexes := range cities {
+		if len(indexes) < outlierMinPositions {
+			continue
+		}
+		lats := make([]float64, len(indexes))
+		longs := make([]float64, len(indexes))
+		for j, i := range indexes {
+			lats[j] = aps[i].Latitude
+			longs[j] = aps[i].Longitude
+		}
+		center := geo.LatLong{Latitude: median(lats), Longitude: median(longs)}
+		distances := make([]float64, len(indexes))
+		for j, i := range indexes {
+			p := geo.LatLong{Latitude: aps[i].Latitude, Longitude: aps[i].Longitude}
+			distances[j] = center.Distance(p)
+		}
+		threshold := math.Max(outlierMinDistance, outlierFactor*median(distances))
+		for j, i := range indexes {
+			if distances[j] > threshold {
+				msg := fmt.Sprintf("%.0f m from the center of city %q", distances[j], aps[i].CityCode)
+				issues = append(issues, Issue{IssueOutlier, i, aps[i].AreaCode, msg})
+			}
+		}
+	}
+	return issues
+}
+
+func median(values []float64) float64 {
+	s := make([]float64, len(values))
+	copy(s, values)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package jp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestAP(prefCode, prefName, cityCode, cityName, areaCode, areaName string, lat, long float64) AddressPosition {
+	return AddressPosition{
+		PrefCode:  prefCode,
+		PrefName:  prefName,
+		CityCode:  cityCode,
+		CityName:  cityName,
+		AreaCode:  areaCode,
+		AreaName:  areaName,
+		Latitude:  lat,
+		Longitude: long,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := AddressPositions{
+		newTestAP("13", "東京都", "13103", "港区", "131030002003", "芝公園三丁目", 35.659943, 139.747207),
+		newTestAP("13", "東京都", "13103", "港区", "131030002001", "芝公園一丁目", 35.658930, 139.751417),
+		newTestAP("13", "東京都", "13103", "港区", "131030011001", "芝大門一丁目", 35.658587, 139.753717),
+	}
+	with := func(aps ...AddressPosition) AddressPositions {
+		return append(append(AddressPositions{}, valid...), aps...)
+	}
+	tests := []struct {
+		name string
+		in   AddressPositions
+		want []IssueKind
+	}{
+		{"valid", valid, nil},
+		{
+			"duplicate",
+			with(newTestAP("13", "東京都", "13103", "港区", "131030002003", "芝公園三丁目", 35.659943, 139.747207)),
+			[]IssueKind{IssueDuplicateCode},
+		},
+		{
+			"out of bounds",
+			with(newTestAP("13", "東京都", "13103", "港区", "131030012001", "浜松町一丁目", 139.756545, 35.658570)),
+			[]IssueKind{IssueOutOfBounds},
+		},
+		{
+			"outlier",
+			with(newTestAP("13", "東京都", "13103", "港区", "131030012001", "浜松町一丁目", 34.658570, 135.756545)),
+			[]IssueKind{IssueOutlier},
+		},
+		{
+			"city name",
+			with(newTestAP("13", "東京都", "13103", "湊区", "131030012001", "浜松町一丁目", 35.658570, 139.756545)),
+			[]IssueKind{IssueNameMismatch},
+		},
+		{
+			"pref name",
+			with(newTestAP("13", "東京府", "13103", "港区", "131030012001", "浜松町一丁目", 35.658570, 139.756545)),
+			[]IssueKind{IssueNameMismatch},
+		},
+		{
+			"pref code",
+			with(newTestAP("14", "神奈川県", "13103", "港区", "131030012001", "浜松町一丁目", 35.658570, 139.756545)),
+			[]IssueKind{IssueCodeMismatch},
+		},
+		{
+			"area code",
+			with(newTestAP("13", "東京都", "13103", "港区", "131040012001", "浜松町一丁目", 35.658570, 139.756545)),
+			[]IssueKind{IssueCodeMismatch},
+		},
+		{
+			"malformed code",
+			with(newTestAP("13", "東京都", "13103", "港区", "13103001200", "浜松町一丁目", 35.658570, 139.756545)),
+			[]IssueKind{IssueCodeMismatch},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			issues := Validate(tt.in)
+			var got []IssueKind
+			for _, i := range issues {
+				got = append(got, i.Kind)
+				if i.Index != len(valid) {
+					t.Errorf("want = %v, got = %v", len(valid), i.Index)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v (%v)", tt.want, got, issues)
+			}
+		})
+	}
+}
+
+func TestValidate_file(t *testing.T) {
+	aps, err := ReadAPsFromFile("../../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Validate(aps); len(got) != 0 {
+		t.Errorf("want no issues, got = %v", got)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	aps, issues, err := ValidateFile("../../../testdata/invalid-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aps) != 2 {
+		t.Errorf("want = %v, got = %v", 2, len(aps))
+	}
+	want := []struct {
+		kind IssueKind
+		line int
+	}{
+		{IssueDuplicateCode, 3},
+		{IssueMalformed, 4},
+		{IssueMalformed, 5},
+		{IssueMalformed, 6},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("want = %v, got = %v", want, issues)
+	}
+	for i, w := range want {
+		if issues[i].Kind != w.kind || issues[i].Line != w.line {
+			t.Errorf("want = %v, got = %v", w, issues[i])
+		}
+	}
+
+	if _, _, err := ValidateFile("../../../testdata/none.csv"); err == nil {
+		t.Errorf("want = %v, got = %v", "error", err)
+	}
+
+	// Blank lines and quoted fields spanning lines count as lines.
+	row := func(code, name string) string {
+		return `"13","東京都","トウキョウト","TOKYO TO","13103","港区","ミナトク","MINATO KU","` +
+			code + `","` + name + `","35.668490","139.746192"` + "\n"
+	}
+	data := "header\n" +
+		"\n" +
+		row("131030001002", "虎ノ門\n二丁目") +
+		"\n" +
+		row("131030001002", "虎ノ門二丁目") +
+		`"13","東京都"` + "\n" +
+		strings.TrimSuffix(row("131030001003", "虎ノ門三丁目"), "\n")
+	aps, issues, err = validateCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aps) != 3 {
+		t.Errorf("want = %v, got = %v", 3, len(aps))
+	}
+	want = []struct {
+		kind IssueKind
+		line int
+	}{
+		{IssueDuplicateCode, 6},
+		{IssueMalformed, 7},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("want = %v, got = %v", want, issues)
+	}
+	for i, w := range want {
+		if issues[i].Kind != w.kind || issues[i].Line != w.line {
+			t.Errorf("want = %v, got = %v", w, issues[i])
+		}
+	}
+}
@@ -8,23 +8,47 @@ import (
 	"math"
 )
 
+// meanRadius is the mean radius of the earth in meters.
+const meanRadius = 6371000
+
 // LatLong represents a position on the earth.
 type LatLong struct {
 	Latitude  float64
 	Longitude float64
 }
 
-// Distance returns the distance to the specified point.
+// Distance returns the great-circle distance to the specified point in meters.
 func (p *LatLong) Distance(target LatLong) float64 {
-	const r = 6371000
-	rad := math.Pi / 180
-	lat1 := p.Latitude * rad
-	lat2 := target.Latitude * rad
-	sinDLat := math.Sin((target.Latitude - p.Latitude) * rad / 2)
-	sinDLong := math.Sin((target.Longitude - p.Longitude) * rad / 2)
-	a := sinDLat*sinDLat + math.Cos(lat1)*math.Cos(lat2)*sinDLong*sinDLong
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return r * c
+	return haversine(*p, target, meanRadius)
+}
+
+// GeodesicDistance returns the geodesic distance to the specified point on the
+// GRS80 ellipsoid in meters.
+func (p *LatLong) GeodesicDistance(target LatLong) float64 {
+	d, _, _ := GRS80.Inverse(*p, target)
+	return d
+}
+
+// Bearing returns the initial bearing to the specified point on the GRS80
+// ellipsoid in degrees clockwise from north.
+func (p *LatLong) Bearing(target LatLong) float64 {
+	_, b, _ := GRS80.Inverse(*p, target)
+	return b
+}
+
+// FinalBearing returns the bearing on arrival at the specified point on the
+// GRS80 ellipsoid in degrees clockwise from north.
+func (p *LatLong) FinalBearing(target LatLong) float64 {
+	_, _, b := GRS80.Inverse(*p, target)
+	return b
+}
+
+// Destination returns the position reached by travelling the specified
+// distance in meters along the initial bearing in degrees on the GRS80
+// ellipsoid.
+func (p *LatLong) Destination(bearing, distance float64) LatLong {
+	dest, _ := GRS80.Direct(*p, bearing, distance)
+	return dest
 }
 
 // Nearest returns the closest point among the specified points.
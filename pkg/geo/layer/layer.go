@@ -0,0 +1,196 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package layer handles layers of points of interest.
+package layer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/pointindex"
+)
+
+// Feature is a point of interest.
+type Feature struct {
+	ID         string
+	Name       string
+	Latitude   float64
+	Longitude  float64
+	Properties map[string]string
+}
+
+// NearbyFeature is a feature and its distance from a position.
+type NearbyFeature struct {
+	Feature
+	Distance float64
+}
+
+// Layer is a named set of features keyed by ID with a spatial index.
+type Layer struct {
+	name     string
+	features []Feature
+	ids      map[string]int
+	index    *pointindex.Index
+}
+
+// New returns a layer of the features, which measures distances with the
+// model. The IDs must be unique and not empty.
+func New(name string, features []Feature, model geo.DistanceModel) (*Layer, error) {
+	l := &Layer{
+		name:     name,
+		features: features,
+		ids:      make(map[string]int, len(features)),
+	}
+	points := make([]geo.LatLong, len(features))
+	for i, f := range features {
+		if f.ID == "" {
+			return nil, fmt.Errorf("feature %d has no ID", i)
+		}
+		if _, ok := l.ids[f.ID]; ok {
+			return nil, fmt.Errorf("duplicate ID: %s", f.ID)
+		}
+		if f.Latitude < -90 || f.Latitude > 90 || f.Longitude < -180 || f.Longitude > 180 {
+			return nil, fmt.Errorf("feature %s is out of range: %v, %v", f.ID, f.Latitude, f.Longitude)
+		}
+		l.ids[f.ID] = i
+		points[i] = geo.LatLong{Latitude: f.Latitude, Longitude: f.Longitude}
+	}
+	l.index = pointindex.New(points, model)
+	return l, nil
+}
+
+// ReadFile reads a layer from a CSV file. The header must have the id,
+// name, latitude and longitude columns, and the other columns are the
+// properties of the features. The layer measures distances with the model.
+func ReadFile(name, path string, model geo.DistanceModel) (*Layer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	l, err := Read(name, file, model)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Read reads a layer from CSV in the format of ReadFile.
+func Read(name string, r io.Reader, model geo.DistanceModel) (*Layer, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("no header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.TrimSpace(h)] = i
+	}
+	for _, c := range []string{"id", "name", "latitude", "longitude"} {
+		if _, ok := columns[c]; !ok {
+			return nil, fmt.Errorf("no %s column", c)
+		}
+	}
+
+	var features []Feature
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		lat, err := strconv.ParseFloat(record[columns["latitude"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude: %w", line, err)
+		}
+		long, err := strconv.ParseFloat(record[columns["longitude"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude: %w", line, err)
+		}
+		f := Feature{
+			ID:         record[columns["id"]],
+			Name:       record[columns["name"]],
+			Latitude:   lat,
+			Longitude:  long,
+			Properties: map[string]string{},
+		}
+		for c, i := range columns {
+			switch c {
+			case "id", "name", "latitude", "longitude":
+			default:
+				f.Properties[c] = record[i]
+			}
+		}
+		features = append(features, f)
+	}
+	return New(name, features, model)
+}
+
+// Name returns the name of the layer.
+func (l *Layer) Name() string {
+	return l.name
+}
+
+// Len returns the number of features.
+func (l *Layer) Len() int {
+	return len(l.features)
+}
+
+// Features returns the features in the order they were added.
+func (l *Layer) Features() []Feature {
+	return l.features
+}
+
+// Get returns the feature with the ID.
+func (l *Layer) Get(id string) (Feature, bool) {
+	i, ok := l.ids[id]
+	if !ok {
+		return Feature{}, false
+	}
+	return l.features[i], true
+}
+
+func (l *Layer) nearby(hits []pointindex.Hit) []NearbyFeature {
+	if hits == nil {
+		return nil
+	}
+	fs := make([]NearbyFeature, len(hits))
+	for i, h := range hits {
+		fs[i] = NearbyFeature{l.features[h.Pos], h.Distance}
+	}
+	return fs
+}
+
+// Nearest returns the feature closest to the position, or false if the
+// layer is empty.
+func (l *Layer) Nearest(p geo.LatLong) (NearbyFeature, bool) {
+	h, ok := l.index.Nearest(p)
+	if !ok {
+		return NearbyFeature{}, false
+	}
+	return NearbyFeature{l.features[h.Pos], h.Distance}, true
+}
+
+// Near returns the features in the tile of the position at the zoom level
+// and its neighbors, closest first.
+func (l *Layer) Near(p geo.LatLong, zoom int) []NearbyFeature {
+	return l.nearby(l.index.Near(p, zoom))
+}
+
+// WithinRadius returns the features within the distance in meters from the
+// position, closest first.
+func (l *Layer) WithinRadius(p geo.LatLong, radius float64) []NearbyFeature {
+	return l.nearby(l.index.WithinRadius(p, radius))
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package layer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/pointindex"
+)
+
+func readStores(t *testing.T) *Layer {
+	t.Helper()
+	l, err := ReadFile("stores", "../../../testdata/stores.csv", geo.Spherical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func ids(fs []NearbyFeature) []string {
+	ids := []string{}
+	for _, f := range fs {
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+func TestReadFile(t *testing.T) {
+	l := readStores(t)
+	if l.Name() != "stores" || l.Len() != 5 {
+		t.Fatalf("want = %v %v, got = %v %v", "stores", 5, l.Name(), l.Len())
+	}
+	got, ok := l.Get("d001")
+	want := Feature{
+		ID:         "d001",
+		Name:       "芝浦倉庫",
+		Latitude:   35.641346,
+		Longitude:  139.752312,
+		Properties: map[string]string{"category": "depot", "phone": ""},
+	}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if _, ok := l.Get("x"); ok {
+		t.Errorf("want = %v, got = %v", false, ok)
+	}
+}
+
+func TestRead_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"empty", ""},
+		{"no id", "name,latitude,longitude\na,35,139\n"},
+		{"invalid latitude", "id,name,latitude,longitude\n1,a,north,139\n"},
+		{"out of range", "id,name,latitude,longitude\n1,a,91,139\n"},
+		{"no ID", "id,name,latitude,longitude\n,a,35,139\n"},
+		{"duplicate", "id,name,latitude,longitude\n1,a,35,139\n1,b,35,139\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := Read("test", strings.NewReader(tt.csv), geo.Spherical); err == nil {
+				t.Errorf("want = %v, got = %v", "error", err)
+			}
+		})
+	}
+}
+
+func TestLayer_Queries(t *testing.T) {
+	l := readStores(t)
+	p := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+
+	got, ok := l.Nearest(p)
+	if !ok || got.ID != "s001" {
+		t.Errorf("want = %v, got = %v", "s001", got.ID)
+	}
+	if want := []string{"s001", "s002", "s004", "s003", "d001"}; !reflect.DeepEqual(ids(l.Near(p, 13)), want) {
+		t.Errorf("want = %v, got = %v", want, ids(l.Near(p, 13)))
+	}
+	if want := []string{"s001", "s002"}; !reflect.DeepEqual(ids(l.WithinRadius(p, 1200)), want) {
+		t.Errorf("want = %v, got = %v", want, ids(l.WithinRadius(p, 1200)))
+	}
+
+	empty, err := New("empty", nil, geo.Spherical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := empty.Nearest(p); ok {
+		t.Errorf("want = %v, got = %v", false, ok)
+	}
+}
+
+func TestLayer_Search(t *testing.T) {
+	l := readStores(t)
+	origin := &geo.LatLong{Latitude: 35.641346, Longitude: 139.752312}
+	tests := []struct {
+		name      string
+		query     string
+		opts      SearchOptions
+		want      []string
+		wantTotal int
+		wantErr   bool
+	}{
+		{"relevance", "店", SearchOptions{}, []string{"s001", "s002", "s004", "s003"}, 4, false},
+		{"distance", "店", SearchOptions{Origin: origin, Limit: 2}, []string{"s003", "s002"}, 4, false},
+		{"id", "", SearchOptions{Sort: SortByID, Offset: 3}, []string{"s003", "s004"}, 5, false},
+		{"exact", "芝浦倉庫", SearchOptions{}, []string{"d001"}, 1, false},
+		{"distance without origin", "店", SearchOptions{Sort: SortByDistance}, nil, 0, true},
+		{"limit over max", "店", SearchOptions{Limit: pointindex.MaxSearchLimit + 1}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := l.Search(tt.query, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want = %v, got = %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(ids(got.Features), tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, ids(got.Features))
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("want = %v, got = %v", tt.wantTotal, got.Total)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package layer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/pointindex"
+)
+
+// SortOrder is the order of search results.
+type SortOrder string
+
+// Sort orders.
+const (
+	// SortByDistance sorts by distance from the origin.
+	SortByDistance SortOrder = "distance"
+	// SortByName sorts by name.
+	SortByName SortOrder = "name"
+	// SortByID sorts by ID.
+	SortByID SortOrder = "id"
+	// SortByRelevance sorts exact matches first, then names starting with
+	// the query, then the others, and shorter names first within each.
+	SortByRelevance SortOrder = "relevance"
+)
+
+// ParseSortOrder returns the sort order with the name.
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch o := SortOrder(s); o {
+	case SortByDistance, SortByName, SortByID, SortByRelevance:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown sort order: %q", s)
+}
+
+// SearchOptions are the options of Search.
+type SearchOptions struct {
+	// Origin is the position distances are measured from. Without it, the
+	// results have no distance.
+	Origin *geo.LatLong
+	// Sort is the order of the results. It defaults to SortByDistance with
+	// an origin and SortByRelevance without.
+	Sort SortOrder
+	// Offset is the number of results to skip.
+	Offset int
+	// Limit is the maximum number of results, up to
+	// pointindex.MaxSearchLimit. Zero means pointindex.DefaultSearchLimit.
+	Limit int
+}
+
+// SearchResult is a page of search results.
+type SearchResult struct {
+	Features []NearbyFeature
+	// Total is the number of results of all pages.
+	Total int
+}
+
+// Search returns the features whose names contain the name. Results with
+// equal keys are ordered by ID, so pages are stable.
+func (l *Layer) Search(name string, opts SearchOptions) (SearchResult, error) {
+	order := opts.Sort
+	if order == "" {
+		order = SortByRelevance
+		if opts.Origin != nil {
+			order = SortByDistance
+		}
+	}
+	if order == SortByDistance && opts.Origin == nil {
+		return SearchResult{}, fmt.Errorf("sort order %q requires an origin", order)
+	}
+	limit, err := pointindex.PageLimit(opts.Offset, opts.Limit)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	var found []NearbyFeature
+	for _, f := range l.features {
+		if !strings.Contains(f.Name, name) {
+			continue
+		}
+		nf := NearbyFeature{Feature: f}
+		if opts.Origin != nil {
+			t := geo.LatLong{Latitude: f.Latitude, Longitude: f.Longitude}
+			nf.Distance = l.index.Model().Distance(*opts.Origin, t)
+		}
+		found = append(found, nf)
+	}
+
+	var less func(a, b *NearbyFeature) bool
+	switch order {
+	case SortByDistance:
+		less = func(a, b *NearbyFeature) bool {
+			return a.Distance < b.Distance
+		}
+	case SortByName:
+		less = func(a, b *NearbyFeature) bool {
+			return a.Name < b.Name
+		}
+	case SortByID:
+		less = func(a, b *NearbyFeature) bool {
+			return false
+		}
+	case SortByRelevance:
+		less = func(a, b *NearbyFeature) bool {
+			return pointindex.MoreRelevant(name, a.Name, a.Distance, b.Name, b.Distance)
+		}
+	default:
+		return SearchResult{}, fmt.Errorf("unknown sort order: %q", order)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := &found[i], &found[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return a.ID < b.ID
+	})
+
+	start, end := pointindex.PageBounds(len(found), opts.Offset, limit)
+	return SearchResult{Features: found[start:end], Total: len(found)}, nil
+}
@@ -0,0 +1,379 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package mvt encodes and decodes point features of Mapbox Vector Tiles.
+//
+// See https://github.com/mapbox/vector-tile-spec/tree/master/2.1 for the
+// format.
+package mvt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DefaultExtent is the number of units across a tile.
+const DefaultExtent = 4096
+
+// Field numbers of the messages.
+const (
+	tileLayers = 3
+
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+	layerVersion  = 15
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueFloat  = 2
+	valueDouble = 3
+	valueInt    = 4
+	valueUint   = 5
+	valueSint   = 6
+	valueBool   = 7
+)
+
+const (
+	geomTypePoint = 1
+	cmdMoveTo     = 1
+)
+
+var errMalformed = errors.New("mvt: malformed tile")
+
+// Layer is a layer of point features.
+type Layer struct {
+	Name string
+	// Extent is the number of units across the tile. Zero means
+	// DefaultExtent.
+	Extent   uint32
+	Features []Feature
+}
+
+// Feature is a point feature. X and Y are the position in the tile in units
+// of the extent from the top left corner. The values of the properties are
+// strings, int64, uint64, float64 or bools.
+type Feature struct {
+	ID         uint64
+	X          int
+	Y          int
+	Properties map[string]interface{}
+}
+
+// Point returns the position of the point in a tile at the extent.
+func Point(p geo.LatLong, t geo.Tile, extent uint32) (int, int) {
+	// The extent is a power of two, so the pixels at a higher zoom level
+	// are the units of the tile.
+	zoom := t.Z
+	for e := uint32(256); e < extent; e <<= 1 {
+		zoom++
+	}
+	x, y := geo.LatLongToPixel(p.Latitude, p.Longitude, zoom)
+	return int(int64(x) - int64(t.X)*int64(extent)), int(int64(y) - int64(t.Y)*int64(extent))
+}
+
+// Encode encodes the layers as a tile.
+func Encode(layers ...Layer) ([]byte, error) {
+	var b []byte
+	for _, l := range layers {
+		lb, err := encodeLayer(l)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, tileLayers, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+	return b, nil
+}
+
+func encodeLayer(l Layer) ([]byte, error) {
+	var b []byte
+	b = protowire.AppendTag(b, layerVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, 2)
+	b = protowire.AppendTag(b, layerName, protowire.BytesType)
+	b = protowire.AppendString(b, l.Name)
+
+	keys := map[string]uint64{}
+	var keyList []string
+	values := map[interface{}]uint64{}
+	var valueList []interface{}
+	for _, f := range l.Features {
+		names := make([]string, 0, len(f.Properties))
+		for k := range f.Properties {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		var tags []uint64
+		for _, k := range names {
+			v := f.Properties[k]
+			switch v.(type) {
+			case string, int64, uint64, float64, bool:
+			default:
+				return nil, fmt.Errorf("mvt: unsupported value of %s: %T", k, v)
+			}
+			ki, ok := keys[k]
+			if !ok {
+				ki = uint64(len(keyList))
+				keys[k] = ki
+				keyList = append(keyList, k)
+			}
+			vi, ok := values[v]
+			if !ok {
+				vi = uint64(len(valueList))
+				values[v] = vi
+				valueList = append(valueList, v)
+			}
+			tags = append(tags, ki, vi)
+		}
+
+		var fb []byte
+		if f.ID != 0 {
+			fb = protowire.AppendTag(fb, featureID, protowire.VarintType)
+			fb = protowire.AppendVarint(fb, f.ID)
+		}
+		if len(tags) > 0 {
+			fb = protowire.AppendTag(fb, featureTags, protowire.BytesType)
+			fb = protowire.AppendBytes(fb, packed(tags...))
+		}
+		fb = protowire.AppendTag(fb, featureType, protowire.VarintType)
+		fb = protowire.AppendVarint(fb, geomTypePoint)
+		fb = protowire.AppendTag(fb, featureGeometry, protowire.BytesType)
+		fb = protowire.AppendBytes(fb, packed(
+			command(cmdMoveTo, 1),
+			protowire.EncodeZigZag(int64(f.X)),
+			protowire.EncodeZigZag(int64(f.Y)),
+		))
+		b = protowire.AppendTag(b, layerFeatures, protowire.BytesType)
+		b = protowire.AppendBytes(b, fb)
+	}
+	for _, k := range keyList {
+		b = protowire.AppendTag(b, layerKeys, protowire.BytesType)
+		b = protowire.AppendString(b, k)
+	}
+	for _, v := range valueList {
+		b = protowire.AppendTag(b, layerValues, protowire.BytesType)
+		b = protowire.AppendBytes(b, encodeValue(v))
+	}
+	if l.Extent != 0 && l.Extent != DefaultExtent {
+		b = protowire.AppendTag(b, layerExtent, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(l.Extent))
+	}
+	return b, nil
+}
+
+func encodeValue(v interface{}) []byte {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = protowire.AppendTag(b, valueString, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	case int64:
+		b = protowire.AppendTag(b, valueSint, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(v))
+	case uint64:
+		b = protowire.AppendTag(b, valueUint, protowire.VarintType)
+		b = protowire.AppendVarint(b, v)
+	case float64:
+		b = protowire.AppendTag(b, valueDouble, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(v))
+	case bool:
+		b = protowire.AppendTag(b, valueBool, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	}
+	return b
+}
+
+func command(id, count uint64) uint64 {
+	return id&0x7 | count<<3
+}
+
+func packed(vs ...uint64) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = protowire.AppendVarint(b, v)
+	}
+	return b
+}
+
+// Decode decodes the layers of a tile. Features other than single points
+// are skipped.
+func Decode(b []byte) ([]Layer, error) {
+	var layers []Layer
+	err := fields(b, func(num protowire.Number, v uint64, data []byte) error {
+		if num != tileLayers {
+			return nil
+		}
+		l, err := decodeLayer(data)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, l)
+		return nil
+	})
+	return layers, err
+}
+
+func decodeLayer(b []byte) (Layer, error) {
+	l := Layer{Extent: DefaultExtent}
+	var keys []string
+	var values []interface{}
+	var features [][]byte
+	err := fields(b, func(num protowire.Number, v uint64, data []byte) error {
+		switch num {
+		case layerName:
+			l.Name = string(data)
+		case layerExtent:
+			l.Extent = uint32(v)
+		case layerKeys:
+			keys = append(keys, string(data))
+		case layerValues:
+			value, err := decodeValue(data)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		case layerFeatures:
+			features = append(features, data)
+		}
+		return nil
+	})
+	if err != nil {
+		return Layer{}, err
+	}
+	for _, fb := range features {
+		f, ok, err := decodeFeature(fb, keys, values)
+		if err != nil {
+			return Layer{}, err
+		}
+		if ok {
+			l.Features = append(l.Features, f)
+		}
+	}
+	return l, nil
+}
+
+func decodeFeature(b []byte, keys []string, values []interface{}) (Feature, bool, error) {
+	var f Feature
+	var typ uint64
+	var tags, geometry []uint64
+	err := fields(b, func(num protowire.Number, v uint64, data []byte) error {
+		var err error
+		switch num {
+		case featureID:
+			f.ID = v
+		case featureType:
+			typ = v
+		case featureTags:
+			tags, err = unpack(data)
+		case featureGeometry:
+			geometry, err = unpack(data)
+		}
+		return err
+	})
+	if err != nil {
+		return Feature{}, false, err
+	}
+	if typ != geomTypePoint || len(geometry) != 3 || geometry[0] != command(cmdMoveTo, 1) {
+		return Feature{}, false, nil
+	}
+	f.X = int(protowire.DecodeZigZag(geometry[1]))
+	f.Y = int(protowire.DecodeZigZag(geometry[2]))
+	if len(tags)%2 != 0 {
+		return Feature{}, false, errMalformed
+	}
+	if len(tags) > 0 {
+		f.Properties = make(map[string]interface{}, len(tags)/2)
+	}
+	for i := 0; i < len(tags); i += 2 {
+		if tags[i] >= uint64(len(keys)) || tags[i+1] >= uint64(len(values)) {
+			return Feature{}, false, errMalformed
+		}
+		f.Properties[keys[tags[i]]] = values[tags[i+1]]
+	}
+	return f, true, nil
+}
+
+func decodeValue(b []byte) (interface{}, error) {
+	var value interface{}
+	err := fields(b, func(num protowire.Number, v uint64, data []byte) error {
+		switch num {
+		case valueString:
+			value = string(data)
+		case valueFloat:
+			value = float64(math.Float32frombits(uint32(v)))
+		case valueDouble:
+			value = math.Float64frombits(v)
+		case valueInt:
+			value = int64(v)
+		case valueUint:
+			value = v
+		case valueSint:
+			value = protowire.DecodeZigZag(v)
+		case valueBool:
+			value = protowire.DecodeBool(v)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// fields calls fn for the fields of a message with the value of a numeric
+// field or the data of a length-delimited field.
+func fields(b []byte, fn func(num protowire.Number, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		var v uint64
+		var data []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(b)
+			v = uint64(v32)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			data, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		if err := fn(num, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpack(b []byte) ([]uint64, error) {
+	var vs []uint64
+	for len(b) > 0 {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return nil, errMalformed
+		}
+		vs = append(vs, v)
+		b = b[n:]
+	}
+	return vs, nil
+}
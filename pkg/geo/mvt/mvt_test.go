@@ -0,0 +1,113 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package mvt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Layer
+		want []Layer
+	}{
+		{
+			"points",
+			[]Layer{{
+				Name: "addresses",
+				Features: []Feature{
+					{ID: 131030002003, X: 2048, Y: 100, Properties: map[string]interface{}{
+						"area_name": "芝公園三丁目",
+						"city_name": "港区",
+					}},
+					{ID: 131030023001, X: -5, Y: 4100, Properties: map[string]interface{}{
+						"area_name": "東麻布一丁目",
+						"city_name": "港区",
+					}},
+				},
+			}},
+			[]Layer{{
+				Name:   "addresses",
+				Extent: DefaultExtent,
+				Features: []Feature{
+					{ID: 131030002003, X: 2048, Y: 100, Properties: map[string]interface{}{
+						"area_name": "芝公園三丁目",
+						"city_name": "港区",
+					}},
+					{ID: 131030023001, X: -5, Y: 4100, Properties: map[string]interface{}{
+						"area_name": "東麻布一丁目",
+						"city_name": "港区",
+					}},
+				},
+			}},
+		},
+		{
+			"values and layers",
+			[]Layer{
+				{Name: "a", Extent: 512, Features: []Feature{{X: 1, Y: 2, Properties: map[string]interface{}{
+					"i": int64(-3), "u": uint64(4), "f": 1.5, "b": true,
+				}}}},
+				{Name: "b"},
+			},
+			[]Layer{
+				{Name: "a", Extent: 512, Features: []Feature{{X: 1, Y: 2, Properties: map[string]interface{}{
+					"i": int64(-3), "u": uint64(4), "f": 1.5, "b": true,
+				}}}},
+				{Name: "b", Extent: DefaultExtent},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := Encode(tt.in...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decode(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := Encode(Layer{Features: []Feature{{Properties: map[string]interface{}{"x": 1}}}}); err == nil {
+		t.Errorf("want = %v, got = %v", "error", err)
+	}
+	if _, err := Decode([]byte{0x1a, 0x05, 0x0a}); err == nil {
+		t.Errorf("want = %v, got = %v", "error", err)
+	}
+}
+
+func TestPoint(t *testing.T) {
+	// The center of the tile 15/29103/12905.
+	lat, long := geo.PixelToLatLong(29103*256+128, 12905*256+128, 15)
+	p := geo.LatLong{Latitude: lat, Longitude: long}
+	tests := []struct {
+		tile   geo.Tile
+		extent uint32
+		wantX  int
+		wantY  int
+	}{
+		{geo.Tile{X: 29103, Y: 12905, Z: 15}, 4096, 2048, 2048},
+		{geo.Tile{X: 29103, Y: 12905, Z: 15}, 256, 128, 128},
+		{geo.Tile{X: 29104, Y: 12905, Z: 15}, 4096, -2048, 2048},
+		{geo.Tile{X: 14551, Y: 6452, Z: 14}, 4096, 3072, 3072},
+	}
+	for _, tt := range tests {
+		x, y := Point(p, tt.tile, tt.extent)
+		if x != tt.wantX || y != tt.wantY {
+			t.Errorf("%v: want = %v %v, got = %v %v", tt.tile, tt.wantX, tt.wantY, x, y)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package pointindex is a spatial index of points, which the address
+// positions and the layers of points of interest share.
+package pointindex
+
+import (
+	"sort"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+const (
+	// MinZoomLevel and MaxZoomLevel are the zoom levels of the quadkeys
+	// the points are indexed by.
+	MinZoomLevel = 4
+	MaxZoomLevel = 23
+)
+
+// Index is a spatial index of points that measures distances with a
+// distance model.
+type Index struct {
+	model  geo.DistanceModel
+	points []geo.LatLong
+	// cells is the positions in points keyed by the quadkeys of every zoom
+	// level.
+	cells map[string][]int
+}
+
+// Hit is a point found in an index.
+type Hit struct {
+	// Pos is the position of the point in the points of the index.
+	Pos      int
+	Distance float64
+}
+
+// New returns an index of the points, which must be in range.
+func New(points []geo.LatLong, model geo.DistanceModel) *Index {
+	idx := &Index{
+		model:  model,
+		points: points,
+		cells:  map[string][]int{},
+	}
+	for i, p := range points {
+		quadkey := geo.LatLongToQuadkey(p.Latitude, p.Longitude, MaxZoomLevel)
+		for zoom := MinZoomLevel; zoom <= MaxZoomLevel; zoom++ {
+			key := quadkey[0:zoom]
+			idx.cells[key] = append(idx.cells[key], i)
+		}
+	}
+	return idx
+}
+
+// Len returns the number of points.
+func (idx *Index) Len() int {
+	return len(idx.points)
+}
+
+// Model returns the distance model of the index.
+func (idx *Index) Model() geo.DistanceModel {
+	return idx.model
+}
+
+func (idx *Index) hit(i int, p geo.LatLong) Hit {
+	return Hit{i, idx.model.Distance(p, idx.points[i])}
+}
+
+// Nearest returns the point closest to the position, or false if the index
+// is empty.
+func (idx *Index) Nearest(p geo.LatLong) (Hit, bool) {
+	const minHits = 10
+	if len(idx.points) == 0 {
+		return Hit{}, false
+	}
+
+	// Look at the tiles around the position from the highest zoom level
+	// down until they hold enough points, and at every point otherwise.
+	candidates := func() []int {
+		for zoom := MaxZoomLevel; zoom >= MinZoomLevel; zoom-- {
+			quadkey := geo.LatLongToQuadkey(p.Latitude, p.Longitude, zoom)
+			var found []int
+			for _, q := range geo.Neighbors(quadkey, 1) {
+				found = append(found, idx.cells[q[0:zoom]]...)
+			}
+			if len(found) > minHits {
+				return found
+			}
+		}
+		all := make([]int, len(idx.points))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}()
+	points := make([]geo.LatLong, len(candidates))
+	for j, i := range candidates {
+		points[j] = idx.points[i]
+	}
+	j, d := idx.model.Nearest(p, points)
+	return Hit{candidates[j], d}, true
+}
+
+// Near returns the points in the tile of the position at the zoom level and
+// its neighbors, closest first.
+func (idx *Index) Near(p geo.LatLong, zoom int) []Hit {
+	quadkey := geo.LatLongToQuadkey(p.Latitude, p.Longitude, zoom)
+	var near []Hit
+	for _, q := range geo.Neighbors(quadkey, 1) {
+		for _, i := range idx.cells[q[0:zoom]] {
+			near = append(near, idx.hit(i, p))
+		}
+	}
+	SortByDistance(near)
+	return near
+}
+
+// Within returns the positions of the points inside the region. It looks up
+// only the cells that cover the region.
+func (idx *Index) Within(region geo.Region) []int {
+	const maxCells = 64
+	coverer := geo.RegionCoverer{
+		MinLevel: MinZoomLevel,
+		MaxLevel: MaxZoomLevel,
+		MaxCells: maxCells,
+	}
+	var within []int
+	for _, q := range coverer.Quadkeys(region) {
+		for _, i := range idx.cells[q] {
+			if region.Contains(idx.points[i]) {
+				within = append(within, i)
+			}
+		}
+	}
+	return within
+}
+
+// WithinRadius returns the points within the distance in meters from the
+// position, closest first.
+func (idx *Index) WithinRadius(p geo.LatLong, radius float64) []Hit {
+	// Enlarge the cap slightly so that the distance model decides the
+	// boundary.
+	region := geo.Cap{Center: p, Radius: radius * 1.01}
+	var within []Hit
+	for _, i := range idx.Within(region) {
+		if h := idx.hit(i, p); h.Distance <= radius {
+			within = append(within, h)
+		}
+	}
+	SortByDistance(within)
+	return within
+}
+
+// KNearest returns up to k points closest to the position, closest first.
+func (idx *Index) KNearest(p geo.LatLong, k int) []Hit {
+	const (
+		initialRadius = 500
+		maxRadius     = 4000000
+	)
+	if k <= 0 {
+		return nil
+	}
+	// Widen the search until it holds k points. Everything within the
+	// radius is found, so the first k are the k nearest.
+	for radius := float64(initialRadius); radius <= maxRadius; radius *= 2 {
+		hits := idx.WithinRadius(p, radius)
+		if len(hits) >= k {
+			return hits[:k]
+		}
+	}
+
+	hits := make([]Hit, len(idx.points))
+	for i := range hits {
+		hits[i] = idx.hit(i, p)
+	}
+	SortByDistance(hits)
+	if len(hits) > k {
+		hits = hits[:k]
+	}
+	return hits
+}
+
+// SortByDistance sorts the hits closest first, and hits at the same
+// distance by position.
+func SortByDistance(hits []Hit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Distance != hits[j].Distance {
+			return hits[i].Distance < hits[j].Distance
+		}
+		return hits[i].Pos < hits[j].Pos
+	})
+}
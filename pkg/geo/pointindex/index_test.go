@@ -0,0 +1,134 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package pointindex
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// testPoints are spread around Shiba Park with one far away in Osaka.
+var testPoints = []geo.LatLong{
+	{Latitude: 35.658584, Longitude: 139.7454316},
+	{Latitude: 35.6543, Longitude: 139.7489},
+	{Latitude: 35.6614, Longitude: 139.7407},
+	{Latitude: 35.6495, Longitude: 139.7521},
+	{Latitude: 35.6812, Longitude: 139.7671},
+	{Latitude: 34.6937, Longitude: 135.5023},
+}
+
+func positions(hits []Hit) []int {
+	ps := []int{}
+	for _, h := range hits {
+		ps = append(ps, h.Pos)
+	}
+	return ps
+}
+
+func TestIndex(t *testing.T) {
+	p := geo.LatLong{Latitude: 35.6555, Longitude: 139.7470}
+	for _, model := range []geo.DistanceModel{geo.Spherical, geo.Ellipsoidal} {
+		idx := New(testPoints, model)
+		if idx.Len() != len(testPoints) || idx.Model() != model {
+			t.Fatalf("want = %v %v, got = %v %v", len(testPoints), model, idx.Len(), idx.Model())
+		}
+
+		want, wantDist := model.Nearest(p, testPoints)
+		got, ok := idx.Nearest(p)
+		if !ok || got.Pos != want || got.Distance != wantDist {
+			t.Errorf("%v: want = %v %v, got = %v %v", model, want, wantDist, got.Pos, got.Distance)
+		}
+		var within []int
+		for i, t := range testPoints {
+			if model.Distance(p, t) <= 1000 {
+				within = append(within, i)
+			}
+		}
+		if got := positions(idx.WithinRadius(p, 1000)); len(got) != len(within) {
+			t.Errorf("%v: want = %v, got = %v", model, within, got)
+		}
+		if got := positions(idx.KNearest(p, 5)); !reflect.DeepEqual(got, []int{1, 0, 3, 2, 4}) {
+			t.Errorf("%v: want = %v, got = %v", model, []int{1, 0, 3, 2, 4}, got)
+		}
+		if got := positions(idx.KNearest(p, 10)); len(got) != len(testPoints) || got[5] != 5 {
+			t.Errorf("%v: want = %v, got = %v", model, "every point, Osaka last", got)
+		}
+		for _, h := range idx.Near(p, 14) {
+			if h.Distance != model.Distance(p, testPoints[h.Pos]) {
+				t.Errorf("%v: want = %v, got = %v", model, model.Distance(p, testPoints[h.Pos]), h.Distance)
+			}
+		}
+	}
+
+	empty := New(nil, geo.Spherical)
+	if _, ok := empty.Nearest(p); ok {
+		t.Errorf("want = %v, got = %v", false, ok)
+	}
+	if got := empty.KNearest(p, 3); len(got) != 0 {
+		t.Errorf("want = %v, got = %v", 0, len(got))
+	}
+}
+
+func TestSortByDistance(t *testing.T) {
+	hits := []Hit{{3, 20}, {2, 10}, {0, 20}, {1, 5}}
+	SortByDistance(hits)
+	if want := []int{1, 2, 0, 3}; !reflect.DeepEqual(positions(hits), want) {
+		t.Errorf("want = %v, got = %v", want, positions(hits))
+	}
+}
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		name                 string
+		total, offset, limit int
+		wantLimit            int
+		wantStart, wantEnd   int
+		wantErr              bool
+	}{
+		{"default", 150, 0, 0, DefaultSearchLimit, 0, 100, false},
+		{"middle", 150, 10, 20, 20, 10, 30, false},
+		{"last", 150, 140, 20, 20, 140, 150, false},
+		{"beyond", 150, 200, 20, 20, 150, 150, false},
+		{"negative offset", 150, -1, 20, 0, 0, 0, true},
+		{"negative limit", 150, 0, -1, 0, 0, 0, true},
+		{"limit over max", 150, 0, MaxSearchLimit + 1, 0, 0, 0, true},
+	}
+	for _, tt := range tests {
+		limit, err := PageLimit(tt.offset, tt.limit)
+		if (err != nil) != tt.wantErr || limit != tt.wantLimit {
+			t.Errorf("%s: want = %v %v, got = %v %v", tt.name, tt.wantLimit, tt.wantErr, limit, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if start, end := PageBounds(tt.total, tt.offset, limit); start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("%s: want = %v-%v, got = %v-%v", tt.name, tt.wantStart, tt.wantEnd, start, end)
+		}
+	}
+}
+
+func TestMoreRelevant(t *testing.T) {
+	tests := []struct {
+		a    string
+		da   float64
+		b    string
+		db   float64
+		want bool
+	}{
+		{"芝", 10, "芝公園", 0, true},
+		{"芝公園", 10, "西芝", 0, true},
+		{"芝浦", 10, "芝公園", 20, true},
+		{"芝大門", 10, "芝公園", 5, false},
+		{"港芝", 0, "芝", 0, false},
+	}
+	for _, tt := range tests {
+		if got := MoreRelevant("芝", tt.a, tt.da, tt.b, tt.db); got != tt.want {
+			t.Errorf("%s, %s: want = %v, got = %v", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package pointindex
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultSearchLimit is the number of results of a page if the client
+	// gives no limit.
+	DefaultSearchLimit = 100
+	// MaxSearchLimit is the maximum number of results of a page.
+	MaxSearchLimit = 1000
+)
+
+// PageLimit checks the offset and the limit of a page of search results
+// and returns the limit, which is DefaultSearchLimit if it is zero.
+func PageLimit(offset, limit int) (int, error) {
+	if offset < 0 || limit < 0 || limit > MaxSearchLimit {
+		return 0, fmt.Errorf("invalid offset or limit: %d, %d", offset, limit)
+	}
+	if limit == 0 {
+		limit = DefaultSearchLimit
+	}
+	return limit, nil
+}
+
+// PageBounds returns the bounds of the page at the offset with up to limit
+// results out of total.
+func PageBounds(total, offset, limit int) (start, end int) {
+	start = offset
+	if start > total {
+		start = total
+	}
+	end = total
+	if start+limit < end {
+		end = start + limit
+	}
+	return start, end
+}
+
+// MoreRelevant reports whether the name a at distance da matches the query
+// better than the name b at distance db. Exact matches come first, then
+// names starting with the query, then the others, and shorter and then
+// closer names first within each.
+func MoreRelevant(query, a string, da float64, b string, db float64) bool {
+	ra, rb := relevance(a, query), relevance(b, query)
+	if ra != rb {
+		return ra < rb
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return da < db
+}
+
+// relevance ranks how well a name matches the query. Lower is better.
+func relevance(name, query string) int {
+	switch {
+	case name == query:
+		return 0
+	case strings.HasPrefix(name, query):
+		return 1
+	default:
+		return 2
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// errInvalidPolyline is returned when an encoded polyline is malformed.
+var errInvalidPolyline = errors.New("geo: invalid encoded polyline")
+
+// EncodePolyline encodes positions with Google's encoded polyline algorithm
+// at a precision of five decimal places.
+func EncodePolyline(points []LatLong) string {
+	var b strings.Builder
+	var prevLat, prevLong int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Latitude * 1e5))
+		long := int64(math.Round(p.Longitude * 1e5))
+		writePolylineValue(&b, lat-prevLat)
+		writePolylineValue(&b, long-prevLong)
+		prevLat, prevLong = lat, long
+	}
+	return b.String()
+}
+
+func writePolylineValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte(0x20|u&0x1f) + 63)
+		u >>= 5
+	}
+	b.WriteByte(byte(u) + 63)
+}
+
+// DecodePolyline decodes positions encoded with Google's encoded polyline
+// algorithm at a precision of five decimal places.
+func DecodePolyline(s string) (LineString, error) {
+	var points LineString
+	var lat, long int64
+	for i := 0; i < len(s); {
+		dLat, n, err := readPolylineValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		dLong, n, err := readPolylineValue(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		lat += dLat
+		long += dLong
+		points = append(points, LatLong{
+			Latitude:  float64(lat) / 1e5,
+			Longitude: float64(long) / 1e5,
+		})
+	}
+	return points, nil
+}
+
+func readPolylineValue(s string) (v int64, n int, err error) {
+	var u uint64
+	var shift uint
+	for {
+		if n >= len(s) || shift > 60 {
+			return 0, 0, errInvalidPolyline
+		}
+		c := s[n]
+		if c < 63 || c > 126 {
+			return 0, 0, errInvalidPolyline
+		}
+		n++
+		chunk := uint64(c - 63)
+		u |= (chunk & 0x1f) << shift
+		shift += 5
+		if chunk < 0x20 {
+			break
+		}
+	}
+	v = int64(u >> 1)
+	if u&1 != 0 {
+		v = ^v
+	}
+	return v, n, nil
+}
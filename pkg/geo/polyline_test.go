@@ -0,0 +1,74 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodePolyline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []LatLong
+		want string
+	}{
+		{
+			"normal",
+			[]LatLong{
+				{Latitude: 38.5, Longitude: -120.2},
+				{Latitude: 40.7, Longitude: -120.95},
+				{Latitude: 43.252, Longitude: -126.453},
+			},
+			"_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+		},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := EncodePolyline(tt.in)
+			if got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodePolyline(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    LineString
+		wantErr bool
+	}{
+		{
+			"normal",
+			"_p~iF~ps|U_ulLnnqC_mqNvxq`@",
+			LineString{
+				{Latitude: 38.5, Longitude: -120.2},
+				{Latitude: 40.7, Longitude: -120.95},
+				{Latitude: 43.252, Longitude: -126.453},
+			},
+			false,
+		},
+		{"truncated", "_p~iF~ps|", nil, true},
+		{"invalid character", "_p~iF ps|U", nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := DecodePolyline(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error = %v, got = %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
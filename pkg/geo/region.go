@@ -0,0 +1,243 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"sort"
+)
+
+// Region is an area that can be covered by cells.
+type Region interface {
+	Bounds() BBox
+	// Contains reports whether the region contains the point.
+	Contains(p LatLong) bool
+	// ContainsBBox reports whether the region contains the whole bounding
+	// box. It may return false for boxes that are barely contained.
+	ContainsBBox(b BBox) bool
+	// IntersectsBBox reports whether the region may share any point with
+	// the bounding box. It may return true for boxes that barely miss.
+	IntersectsBBox(b BBox) bool
+}
+
+// IntersectsBBox reports whether the bounding boxes share any point.
+func (b BBox) IntersectsBBox(other BBox) bool {
+	return b.Intersects(other)
+}
+
+// ContainsBBox reports whether the polygon contains the whole bounding box.
+func (pg Polygon) ContainsBBox(b BBox) bool {
+	if b.IsEmpty() {
+		return true
+	}
+	box := b.Polygon()
+	for _, p := range box[0][:4] {
+		if !pg.Contains(p) {
+			return false
+		}
+	}
+	// A hole or a notch of the polygon may lie within the box even if the
+	// corners are inside.
+	_, segments, _ := decompose(pg)
+	for i := 1; i < len(box[0]); i++ {
+		for _, s := range segments {
+			if segmentsIntersect(box[0][i-1], box[0][i], s[0], s[1]) {
+				return false
+			}
+		}
+	}
+	for _, ring := range pg {
+		for _, p := range ring {
+			if b.Contains(p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// IntersectsBBox reports whether the polygon shares any point with the
+// bounding box.
+func (pg Polygon) IntersectsBBox(b BBox) bool {
+	return Intersects(pg, b)
+}
+
+// ContainsBBox reports whether any of the polygons contains the whole
+// bounding box.
+func (mp MultiPolygon) ContainsBBox(b BBox) bool {
+	for _, pg := range mp {
+		if pg.ContainsBBox(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// IntersectsBBox reports whether any of the polygons shares any point with
+// the bounding box.
+func (mp MultiPolygon) IntersectsBBox(b BBox) bool {
+	for _, pg := range mp {
+		if pg.IntersectsBBox(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cap is a circular region on the earth.
+type Cap struct {
+	Center LatLong
+	// Radius is the radius in meters.
+	Radius float64
+}
+
+// Bounds returns the bounding box of the cap.
+func (c Cap) Bounds() BBox {
+	return c.Center.Bounds().Buffer(c.Radius)
+}
+
+// Contains reports whether the cap contains the point.
+func (c Cap) Contains(p LatLong) bool {
+	return c.Center.Distance(p) <= c.Radius
+}
+
+// ContainsBBox reports whether the cap contains the whole bounding box.
+func (c Cap) ContainsBBox(b BBox) bool {
+	if b.IsEmpty() {
+		return true
+	}
+	for _, p := range b.Polygon()[0][:4] {
+		if !c.Contains(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsBBox reports whether the cap shares any point with the bounding
+// box.
+func (c Cap) IntersectsBBox(b BBox) bool {
+	if b.Contains(c.Center) {
+		return true
+	}
+	return b.Polygon().DistanceTo(c.Center) <= c.Radius
+}
+
+// Bounds returns the bounding box of the cell.
+func (c CellID) Bounds() BBox {
+	tileX, tileY, zoom := c.Tile()
+	return Tile{X: tileX, Y: tileY, Z: zoom}.Bounds()
+}
+
+// RegionCoverer approximates regions by sets of cells.
+type RegionCoverer struct {
+	// MinLevel is the coarsest level of the cells.
+	MinLevel int
+	// MaxLevel is the finest level of the cells.
+	MaxLevel int
+	// MaxCells is the desired maximum number of cells. The covering may
+	// exceed it if the region needs more cells at MinLevel. Zero means no
+	// limit.
+	MaxCells int
+}
+
+// Covering returns a set of cells of mixed levels that covers the region,
+// sorted by cell ID. Cells that are entirely inside the region are kept as
+// coarse as possible, and only cells on the boundary are subdivided.
+func (rc RegionCoverer) Covering(region Region) []CellID {
+	minLevel := int(clip(float64(rc.MinLevel), 0, MaxCellLevel))
+	maxLevel := int(clip(float64(rc.MaxLevel), float64(minLevel), MaxCellLevel))
+	maxCells := rc.MaxCells
+	if maxCells <= 0 {
+		maxCells = math.MaxInt32
+	}
+
+	queue := initialCells(region, minLevel)
+	var result []CellID
+	for len(queue) > 0 {
+		// Subdivide the coarsest cells first.
+		c := queue[0]
+		queue = queue[1:]
+		level := c.Level()
+		if level >= maxLevel || region.ContainsBBox(c.Bounds()) {
+			result = append(result, c)
+			continue
+		}
+		var children []CellID
+		cells, _ := c.Children()
+		for _, child := range cells {
+			if region.IntersectsBBox(child.Bounds()) {
+				children = append(children, child)
+			}
+		}
+		if len(result)+len(queue)+len(children) > maxCells {
+			result = append(result, c)
+			continue
+		}
+		queue = append(queue, children...)
+	}
+	return normalizeCells(result, minLevel)
+}
+
+// Quadkeys returns the quadkeys of the covering of the region.
+func (rc RegionCoverer) Quadkeys(region Region) []string {
+	cells := rc.Covering(region)
+	quadkeys := make([]string, len(cells))
+	for i, c := range cells {
+		quadkeys[i] = c.Quadkey()
+	}
+	return quadkeys
+}
+
+// initialCells returns the cells at the level that intersect the region.
+func initialCells(region Region, level int) []CellID {
+	b := region.Bounds()
+	if b.IsEmpty() {
+		return nil
+	}
+	min := LatLongToTile(b.Max.Latitude, b.Min.Longitude, level)
+	max := LatLongToTile(b.Min.Latitude, b.Max.Longitude, level)
+	var cells []CellID
+	for y := min.Y; y <= max.Y; y++ {
+		for x := min.X; x <= max.X; x++ {
+			c := TileToCellID(x, y, level)
+			if region.IntersectsBBox(c.Bounds()) {
+				cells = append(cells, c)
+			}
+		}
+	}
+	return cells
+}
+
+// normalizeCells sorts the cells and replaces every four siblings with their
+// parent as long as the parent is not coarser than the minimum level.
+func normalizeCells(cells []CellID, minLevel int) []CellID {
+	sort.Slice(cells, func(i, j int) bool { return cells[i] < cells[j] })
+	var out []CellID
+	for _, c := range cells {
+		if n := len(out); n > 0 && out[n-1].Contains(c) {
+			continue
+		}
+		out = append(out, c)
+		for {
+			n := len(out)
+			if n < 4 {
+				break
+			}
+			last := out[n-1]
+			if last.Level() <= minLevel {
+				break
+			}
+			parent := last.Parent()
+			siblings, _ := parent.Children()
+			if out[n-4] != siblings[0] || out[n-3] != siblings[1] ||
+				out[n-2] != siblings[2] || last != siblings[3] {
+				break
+			}
+			out = append(out[:n-4], parent)
+		}
+	}
+	return out
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var tokyoTower = LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+
+func TestRegionCoverer_Covering(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     Region
+		inRC   RegionCoverer
+		inside []LatLong
+	}{
+		{
+			"bbox",
+			tokyoTower.Bounds().Buffer(1000),
+			RegionCoverer{MinLevel: 4, MaxLevel: 23, MaxCells: 16},
+			[]LatLong{tokyoTower, tokyoTower.Destination(45, 1300)},
+		},
+		{
+			"cap",
+			Cap{Center: tokyoTower, Radius: 500},
+			RegionCoverer{MinLevel: 10, MaxLevel: 18, MaxCells: 8},
+			[]LatLong{tokyoTower, tokyoTower.Destination(200, 499)},
+		},
+		{
+			"polygon",
+			Circle(tokyoTower, 2000, 16),
+			RegionCoverer{MinLevel: 4, MaxLevel: 20},
+			[]LatLong{tokyoTower, tokyoTower.Destination(0, 1800)},
+		},
+		{
+			"multi polygon",
+			MultiPolygon{Circle(tokyoTower, 100, 8), Circle(tokyoTower.Destination(90, 5000), 100, 8)},
+			RegionCoverer{MinLevel: 8, MaxLevel: 18, MaxCells: 20},
+			[]LatLong{tokyoTower, tokyoTower.Destination(90, 5000)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.inRC.Covering(tt.in)
+			if len(got) == 0 {
+				t.Fatal("want cells, got none")
+			}
+			if tt.inRC.MaxCells > 0 && len(got) > tt.inRC.MaxCells {
+				t.Errorf("want <= %v cells, got = %v", tt.inRC.MaxCells, len(got))
+			}
+			for i, c := range got {
+				if l := c.Level(); l < tt.inRC.MinLevel || l > tt.inRC.MaxLevel {
+					t.Errorf("want level in [%v, %v], got = %v", tt.inRC.MinLevel, tt.inRC.MaxLevel, l)
+				}
+				if i > 0 && got[i-1].RangeMax() >= c.RangeMin() {
+					t.Errorf("want sorted disjoint cells, got = %v, %v", got[i-1], c)
+				}
+				if !tt.in.IntersectsBBox(c.Bounds()) {
+					t.Errorf("want cell %v to intersect the region", c.Quadkey())
+				}
+			}
+			for _, p := range tt.inside {
+				leaf := LatLongToCellID(p.Latitude, p.Longitude, MaxCellLevel)
+				covered := false
+				for _, c := range got {
+					if c.Contains(leaf) {
+						covered = true
+						break
+					}
+				}
+				if !covered {
+					t.Errorf("want %v to be covered", p)
+				}
+			}
+		})
+	}
+}
+
+func TestRegionCoverer_Quadkeys(t *testing.T) {
+	tile := LatLongToTile(tokyoTower.Latitude, tokyoTower.Longitude, 12)
+	inner := tile.Bounds()
+	// Shrink the box a little so that it does not touch the neighbors.
+	inner.Min.Latitude += 1e-9
+	inner.Min.Longitude += 1e-9
+	inner.Max.Latitude -= 1e-9
+	inner.Max.Longitude -= 1e-9
+	tests := []struct {
+		name string
+		in   Region
+		inRC RegionCoverer
+		want []string
+	}{
+		{
+			"single cell",
+			inner,
+			RegionCoverer{MinLevel: 4, MaxLevel: 16},
+			[]string{tile.Quadkey()},
+		},
+		{
+			"min level",
+			inner,
+			RegionCoverer{MinLevel: 13, MaxLevel: 16},
+			[]string{
+				tile.Quadkey() + "0",
+				tile.Quadkey() + "1",
+				tile.Quadkey() + "2",
+				tile.Quadkey() + "3",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.inRC.Quadkeys(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCap_IntersectsBBox(t *testing.T) {
+	c := Cap{Center: tokyoTower, Radius: 1000}
+	tests := []struct {
+		name         string
+		in           BBox
+		wantIntersec bool
+		wantContains bool
+	}{
+		{"center", tokyoTower.Bounds(), true, true},
+		{"near", tokyoTower.Destination(90, 1200).Bounds().Buffer(300), true, false},
+		{"far", tokyoTower.Destination(90, 2000).Bounds().Buffer(300), false, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := c.IntersectsBBox(tt.in); got != tt.wantIntersec {
+				t.Errorf("want = %v, got = %v", tt.wantIntersec, got)
+			}
+			if got := c.ContainsBBox(tt.in); got != tt.wantContains {
+				t.Errorf("want = %v, got = %v", tt.wantContains, got)
+			}
+		})
+	}
+}
+
+func TestCap_Bounds(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Cap
+	}{
+		{"tokyo tower", Cap{Center: tokyoTower, Radius: 1000}},
+		{"near the north pole", Cap{Center: LatLong{Latitude: 89.9, Longitude: 0}, Radius: 50000}},
+		{"near the south pole", Cap{Center: LatLong{Latitude: -89, Longitude: 179}, Radius: 500000}},
+		{"whole earth", Cap{Center: tokyoTower, Radius: 1e9}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.in.Bounds()
+			if got.IsEmpty() || got.Min.Latitude < -90 || got.Max.Latitude > 90 {
+				t.Fatalf("want = %v, got = %v", "a box within the poles", got)
+			}
+			if !got.Contains(tt.in.Center) {
+				t.Errorf("want %v to contain %v", got, tt.in.Center)
+			}
+			for bearing := 0.0; bearing < 360; bearing += 45 {
+				p := tt.in.Center.Destination(bearing, math.Min(tt.in.Radius, 1e7)*0.99)
+				if !got.Contains(p) {
+					t.Errorf("want %v to contain %v", got, p)
+				}
+			}
+		})
+	}
+}
+
+func TestPolygon_ContainsBBox(t *testing.T) {
+	tests := []struct {
+		name string
+		in   BBox
+		want bool
+	}{
+		{"inside", BBox{Min: LatLong{Latitude: 0.05, Longitude: 0.05}, Max: LatLong{Latitude: 0.2, Longitude: 0.2}}, true},
+		{"over hole", BBox{Min: LatLong{Latitude: 0.1, Longitude: 0.1}, Max: LatLong{Latitude: 0.9, Longitude: 0.9}}, false},
+		{"in hole", BBox{Min: LatLong{Latitude: 0.4, Longitude: 0.4}, Max: LatLong{Latitude: 0.6, Longitude: 0.6}}, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := squareWithHole.ContainsBBox(tt.in); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
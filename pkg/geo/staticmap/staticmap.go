@@ -0,0 +1,141 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package staticmap renders map images in the Web Mercator projection.
+package staticmap
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	// The tiles are PNG or JPEG images.
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/tiles"
+)
+
+const tileSize = 256
+
+// Background is the color of the map where there is no tile.
+var Background = color.RGBA{R: 0xf2, G: 0xef, B: 0xe9, A: 0xff}
+
+// Map is an image of the map centered on a position.
+type Map struct {
+	img  *image.RGBA
+	zoom int
+	// originX and originY are the pixel coordinates of the top left corner
+	// of the image at the zoom level.
+	originX int64
+	originY int64
+}
+
+// New returns a map of the size centered on the position at the zoom level.
+func New(center geo.LatLong, zoom, width, height int) *Map {
+	x, y := geo.LatLongToPixel(center.Latitude, center.Longitude, zoom)
+	m := &Map{
+		img:     image.NewRGBA(image.Rect(0, 0, width, height)),
+		zoom:    zoom,
+		originX: int64(x) - int64(width/2),
+		originY: int64(y) - int64(height/2),
+	}
+	draw.Draw(m.img, m.img.Bounds(), &image.Uniform{C: Background}, image.Point{}, draw.Src)
+	return m
+}
+
+// Image returns the image of the map.
+func (m *Map) Image() *image.RGBA {
+	return m.img
+}
+
+// Point returns the position in the image of the position on the map. The
+// point may be outside the image.
+func (m *Map) Point(p geo.LatLong) image.Point {
+	x, y := geo.LatLongToPixel(p.Latitude, p.Longitude, m.zoom)
+	return image.Point{X: int(int64(x) - m.originX), Y: int(int64(y) - m.originY)}
+}
+
+// DrawTiles draws the tiles of the source that the image covers. The map
+// wraps around the antimeridian, and the tiles that the source does not have
+// are left blank.
+func (m *Map) DrawTiles(src tiles.Source) error {
+	n := int64(geo.MapSize(uint(m.zoom)) / tileSize)
+	b := m.img.Bounds()
+	minX, minY := floorDiv(m.originX, tileSize), floorDiv(m.originY, tileSize)
+	maxX := floorDiv(m.originX+int64(b.Dx())-1, tileSize)
+	maxY := floorDiv(m.originY+int64(b.Dy())-1, tileSize)
+	for ty := minY; ty <= maxY; ty++ {
+		if ty < 0 || ty >= n {
+			continue
+		}
+		for tx := minX; tx <= maxX; tx++ {
+			t := geo.Tile{X: uint((tx%n + n) % n), Y: uint(ty), Z: m.zoom}
+			data, err := src.Tile(t)
+			if err == tiles.ErrNotExist {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			tile, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("staticmap: tile %v: %w", t, err)
+			}
+			min := image.Point{X: int(tx*tileSize - m.originX), Y: int(ty*tileSize - m.originY)}
+			r := image.Rectangle{Min: min, Max: min.Add(image.Point{X: tileSize, Y: tileSize})}
+			draw.Draw(m.img, r, tile, tile.Bounds().Min, draw.Over)
+		}
+	}
+	return nil
+}
+
+// DrawPoint draws a dot of the radius at the position, outlined with the
+// stroke color unless it is nil.
+func (m *Map) DrawPoint(p geo.LatLong, radius int, fill, stroke color.Color) {
+	c := m.Point(p)
+	if stroke != nil {
+		m.drawCircle(c, radius+1, stroke)
+	}
+	m.drawCircle(c, radius, fill)
+}
+
+func (m *Map) drawCircle(c image.Point, r int, col color.Color) {
+	mask := &circle{c: c, r: r}
+	draw.DrawMask(m.img, mask.Bounds(), &image.Uniform{C: col}, image.Point{},
+		mask, mask.Bounds().Min, draw.Over)
+}
+
+// circle is a mask of a disk.
+type circle struct {
+	c image.Point
+	r int
+}
+
+func (c *circle) ColorModel() color.Model {
+	return color.AlphaModel
+}
+
+func (c *circle) Bounds() image.Rectangle {
+	return image.Rect(c.c.X-c.r, c.c.Y-c.r, c.c.X+c.r+1, c.c.Y+c.r+1)
+}
+
+func (c *circle) At(x, y int) color.Color {
+	dx, dy := x-c.c.X, y-c.c.Y
+	if dx*dx+dy*dy <= c.r*c.r {
+		return color.Alpha{A: 0xff}
+	}
+	return color.Alpha{}
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package staticmap
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/tiles"
+)
+
+func readTile(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestMap(t *testing.T) {
+	// The center of the tile 15/29103/12905.
+	lat, long := geo.PixelToLatLong(29103*256+128, 12905*256+128, 15)
+	center := geo.LatLong{Latitude: lat, Longitude: long}
+	src := tiles.Dir("../../../testdata/tiles")
+
+	m := New(center, 15, 600, 400)
+	if got, want := m.Point(center), (image.Point{X: 300, Y: 200}); got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if err := m.DrawTiles(src); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		x, y int
+		path string
+		// tileX and tileY are the position in the tile.
+		tileX, tileY int
+	}{
+		{300, 200, "../../../testdata/tiles/15/29103/12905.png", 128, 128},
+		{10, 10, "../../../testdata/tiles/15/29102/12904.png", 94, 194},
+		{590, 390, "../../../testdata/tiles/15/29104/12906.png", 162, 62},
+	}
+	for _, tt := range tests {
+		want := color.RGBAModel.Convert(readTile(t, tt.path).At(tt.tileX, tt.tileY))
+		if got := m.Image().At(tt.x, tt.y); got != want {
+			t.Errorf("(%d, %d): want = %v, got = %v", tt.x, tt.y, want, got)
+		}
+	}
+
+	red := color.RGBA{R: 0xff, A: 0xff}
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	m.DrawPoint(center, 5, red, white)
+	if got := m.Image().At(300, 200); got != red {
+		t.Errorf("want = %v, got = %v", red, got)
+	}
+	if got := m.Image().At(306, 200); got != white {
+		t.Errorf("want = %v, got = %v", white, got)
+	}
+
+	// The source has no tiles at zoom 14.
+	m = New(center, 14, 100, 100)
+	if err := m.DrawTiles(src); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Image().At(50, 50); got != Background {
+		t.Errorf("want = %v, got = %v", Background, got)
+	}
+
+	// The map wraps around the antimeridian and is blank beyond the poles.
+	m = New(geo.LatLong{Latitude: 85, Longitude: 180}, 0, 100, 100)
+	if got, want := m.Point(geo.LatLong{Latitude: 85, Longitude: 180}), (image.Point{X: 50, Y: 50}); got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if err := m.DrawTiles(src); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFloorDiv(t *testing.T) {
+	tests := []struct {
+		a, b, want int64
+	}{
+		{0, 256, 0},
+		{255, 256, 0},
+		{256, 256, 1},
+		{-1, 256, -1},
+		{-256, 256, -1},
+		{-257, 256, -2},
+	}
+	for _, tt := range tests {
+		if got := floorDiv(tt.a, tt.b); got != tt.want {
+			t.Errorf("%d/%d: want = %v, got = %v", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
@@ -38,24 +38,30 @@ func QuadkeyToLatLong(quadkey string) (lat, long float64) {
 // Neighbors returns quadkeys that are adjacent to the specified quadkey.
 func Neighbors(quadkey string, tile int) []string {
 	tileX, tileY, zoom := QuadkeyToTile(quadkey)
-	var ds [][]int64
-	for dy := -tile; dy <= tile; dy++ {
-		for dx := -tile; dx <= tile; dx++ {
-			ds = append(ds, []int64{int64(dx), int64(dy)})
+	if tile < 0 {
+		return []string{}
+	}
+	// Only the tiles on the map are generated, so a large range costs no
+	// more than the map.
+	max := int64(1)<<uint(zoom) - 1
+	span := func(n uint) (int64, int64) {
+		lo, hi := int64(n)-int64(tile), max
+		if lo < 0 {
+			lo = 0
+		}
+		if int64(tile) < max-int64(n) {
+			hi = int64(n) + int64(tile)
 		}
+		return lo, hi
 	}
-	min := int64(0)
-	max := int64(math.Pow(4, float64(zoom)) - 1)
-
-	neighbors := make([]string, 0, len(ds))
-	for _, d := range ds {
-		x := int64(tileX) + d[0]
-		y := int64(tileY) + d[1]
-		if x < min || x > max || y < min || y > max {
-			continue
+	minX, maxX := span(tileX)
+	minY, maxY := span(tileY)
+
+	neighbors := make([]string, 0, (maxX-minX+1)*(maxY-minY+1))
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			neighbors = append(neighbors, TileToQuadkey(uint(x), uint(y), zoom))
 		}
-		q := TileToQuadkey(uint(x), uint(y), zoom)
-		neighbors = append(neighbors, q)
 	}
 	return neighbors
 }
@@ -87,6 +87,16 @@ func TestNeighbors(t *testing.T) {
 				"13300211230311333132201",
 			},
 		},
+		{
+			"east edge",
+			"1",
+			[]string{"0", "1", "2", "3"},
+		},
+		{
+			"south edge",
+			"3",
+			[]string{"0", "1", "2", "3"},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -99,3 +109,33 @@ func TestNeighbors(t *testing.T) {
 		})
 	}
 }
+
+func TestNeighbors_Range(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		inTile  int
+		wantLen int
+	}{
+		{"whole map", "1", 1 << 30, 4},
+		{"max int", "0123", int(^uint(0) >> 1), 256},
+		{"corner", "000", 2, 9},
+		{"zero", "0123", 0, 1},
+		{"negative", "0123", -1, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Neighbors(tt.in, tt.inTile)
+			if len(got) != tt.wantLen {
+				t.Errorf("want = %v, got = %v", tt.wantLen, len(got))
+			}
+			for _, q := range got {
+				if len(q) != len(tt.in) {
+					t.Errorf("want = %v, got = %v", "a tile of the same level", q)
+				}
+			}
+		})
+	}
+}
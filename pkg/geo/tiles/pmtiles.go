@@ -0,0 +1,589 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// This file reads and writes PMTiles archives of version 3, which keep the
+// tiles in a single file that is read by HTTP range requests. See
+// https://github.com/protomaps/PMTiles/blob/main/spec/v3/spec.md.
+
+const (
+	pmtilesMagic      = "PMTiles"
+	pmtilesVersion    = 3
+	pmtilesHeaderSize = 127
+	// pmtilesMaxRootSize is the maximum size of the header and the root
+	// directory, which clients fetch in the first request.
+	pmtilesMaxRootSize = 16384
+	// pmtilesMaxDirSize limits the size of the directories and the tiles
+	// read from malformed archives.
+	pmtilesMaxDirSize = 1 << 26
+	// pmtilesMaxDepth is the maximum depth of the directories.
+	pmtilesMaxDepth = 4
+	// pmtilesLeafCacheSize is the number of leaf directories kept in memory.
+	pmtilesLeafCacheSize = 64
+)
+
+// Compression types of PMTiles.
+const (
+	pmtilesCompressionUnknown = 0
+	pmtilesCompressionNone    = 1
+	pmtilesCompressionGzip    = 2
+)
+
+// pmtilesTileTypes are the tile types of PMTiles keyed by format.
+var pmtilesTileTypes = map[string]uint8{
+	"pbf":  1,
+	"png":  2,
+	"jpg":  3,
+	"jpeg": 3,
+	"webp": 4,
+}
+
+var errPMTilesCorrupt = errors.New("tiles: malformed PMTiles archive")
+
+// pmtilesHeader is the header of an archive.
+type pmtilesHeader struct {
+	rootOffset, rootLength         uint64
+	metadataOffset, metadataLength uint64
+	leafOffset, leafLength         uint64
+	dataOffset, dataLength         uint64
+	addressedTiles                 uint64
+	tileEntries                    uint64
+	tileContents                   uint64
+	clustered                      bool
+	internalCompression            uint8
+	tileCompression                uint8
+	tileType                       uint8
+	minZoom, maxZoom               uint8
+	// The bounds and the center are in degrees times 10^7.
+	minLong, minLat int32
+	maxLong, maxLat int32
+	centerZoom      uint8
+	centerLong      int32
+	centerLat       int32
+}
+
+func (h pmtilesHeader) encode() []byte {
+	b := make([]byte, pmtilesHeaderSize)
+	copy(b, pmtilesMagic)
+	b[7] = pmtilesVersion
+	le := binary.LittleEndian
+	for i, v := range []uint64{
+		h.rootOffset, h.rootLength, h.metadataOffset, h.metadataLength,
+		h.leafOffset, h.leafLength, h.dataOffset, h.dataLength,
+		h.addressedTiles, h.tileEntries, h.tileContents,
+	} {
+		le.PutUint64(b[8+8*i:], v)
+	}
+	if h.clustered {
+		b[96] = 1
+	}
+	b[97], b[98], b[99] = h.internalCompression, h.tileCompression, h.tileType
+	b[100], b[101] = h.minZoom, h.maxZoom
+	for i, v := range []int32{h.minLong, h.minLat, h.maxLong, h.maxLat} {
+		le.PutUint32(b[102+4*i:], uint32(v))
+	}
+	b[118] = h.centerZoom
+	le.PutUint32(b[119:], uint32(h.centerLong))
+	le.PutUint32(b[123:], uint32(h.centerLat))
+	return b
+}
+
+func decodePMTilesHeader(b []byte) (pmtilesHeader, error) {
+	if len(b) < pmtilesHeaderSize || string(b[:7]) != pmtilesMagic {
+		return pmtilesHeader{}, errors.New("tiles: not a PMTiles archive")
+	}
+	if b[7] != pmtilesVersion {
+		return pmtilesHeader{}, fmt.Errorf("tiles: unsupported PMTiles version %d", b[7])
+	}
+	le := binary.LittleEndian
+	u := func(i int) uint64 { return le.Uint64(b[8+8*i:]) }
+	i32 := func(off int) int32 { return int32(le.Uint32(b[off:])) }
+	return pmtilesHeader{
+		rootOffset: u(0), rootLength: u(1),
+		metadataOffset: u(2), metadataLength: u(3),
+		leafOffset: u(4), leafLength: u(5),
+		dataOffset: u(6), dataLength: u(7),
+		addressedTiles: u(8), tileEntries: u(9), tileContents: u(10),
+		clustered:           b[96] == 1,
+		internalCompression: b[97],
+		tileCompression:     b[98],
+		tileType:            b[99],
+		minZoom:             b[100],
+		maxZoom:             b[101],
+		minLong:             i32(102),
+		minLat:              i32(106),
+		maxLong:             i32(110),
+		maxLat:              i32(114),
+		centerZoom:          b[118],
+		centerLong:          i32(119),
+		centerLat:           i32(123),
+	}, nil
+}
+
+// pmtilesEntry is an entry of a directory. An entry with a run length of
+// zero points to a leaf directory, and others to the content of the tiles
+// from the tile ID up to the run length.
+type pmtilesEntry struct {
+	tileID    uint64
+	offset    uint64
+	length    uint32
+	runLength uint32
+}
+
+func encodeDirectory(entries []pmtilesEntry) []byte {
+	b := appendUvarint(nil, uint64(len(entries)))
+	last := uint64(0)
+	for _, e := range entries {
+		b = appendUvarint(b, e.tileID-last)
+		last = e.tileID
+	}
+	for _, e := range entries {
+		b = appendUvarint(b, uint64(e.runLength))
+	}
+	for _, e := range entries {
+		b = appendUvarint(b, uint64(e.length))
+	}
+	for i, e := range entries {
+		// Zero means that the content follows the previous one.
+		if i > 0 && e.offset == entries[i-1].offset+uint64(entries[i-1].length) {
+			b = appendUvarint(b, 0)
+		} else {
+			b = appendUvarint(b, e.offset+1)
+		}
+	}
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func decodeDirectory(b []byte) ([]pmtilesEntry, error) {
+	r := bytes.NewReader(b)
+	n, err := binary.ReadUvarint(r)
+	// Every entry takes at least four bytes.
+	if err != nil || n > uint64(len(b))/4 {
+		return nil, errPMTilesCorrupt
+	}
+	entries := make([]pmtilesEntry, n)
+	last := uint64(0)
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errPMTilesCorrupt
+		}
+		last += v
+		entries[i].tileID = last
+	}
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil || v > math.MaxUint32 {
+			return nil, errPMTilesCorrupt
+		}
+		entries[i].runLength = uint32(v)
+	}
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil || v > math.MaxUint32 {
+			return nil, errPMTilesCorrupt
+		}
+		entries[i].length = uint32(v)
+	}
+	for i := range entries {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errPMTilesCorrupt
+		}
+		switch {
+		case v > 0:
+			entries[i].offset = v - 1
+		case i > 0:
+			entries[i].offset = entries[i-1].offset + uint64(entries[i-1].length)
+		default:
+			return nil, errPMTilesCorrupt
+		}
+	}
+	return entries, nil
+}
+
+// findEntry returns the entry of the directory that holds the tile ID.
+func findEntry(entries []pmtilesEntry, id uint64) (pmtilesEntry, bool) {
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].tileID > id }) - 1
+	if i < 0 {
+		return pmtilesEntry{}, false
+	}
+	e := entries[i]
+	if e.runLength == 0 || id-e.tileID < uint64(e.runLength) {
+		return e, true
+	}
+	return pmtilesEntry{}, false
+}
+
+// tileID returns the ID of the tile, which numbers the tiles by zoom level
+// and along a Hilbert curve within a level.
+func tileID(t geo.Tile) uint64 {
+	id := (uint64(1)<<(2*uint(t.Z)) - 1) / 3
+	x, y := uint64(t.X), uint64(t.Y)
+	for s := uint64(1) << uint(t.Z) >> 1; s > 0; s >>= 1 {
+		rx, ry := x&s, y&s
+		id += s * ((3 * rx) ^ ry)
+		// Rotate the quadrant.
+		if ry == 0 {
+			if rx != 0 {
+				x, y = s-1-x, s-1-y
+			}
+			x, y = y, x
+		}
+	}
+	return id
+}
+
+func compress(b []byte, compression uint8) ([]byte, error) {
+	if compression == pmtilesCompressionNone {
+		return b, nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(b []byte, compression uint8) ([]byte, error) {
+	switch compression {
+	case pmtilesCompressionNone:
+		return b, nil
+	case pmtilesCompressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, errPMTilesCorrupt
+		}
+		defer zr.Close()
+		d, err := ioutil.ReadAll(io.LimitReader(zr, pmtilesMaxDirSize+1))
+		if err != nil || len(d) > pmtilesMaxDirSize {
+			return nil, errPMTilesCorrupt
+		}
+		return d, nil
+	}
+	return nil, fmt.Errorf("tiles: unsupported PMTiles compression %d", compression)
+}
+
+// PMTiles is a PMTiles archive. It is safe for concurrent use.
+type PMTiles struct {
+	f        *os.File
+	header   pmtilesHeader
+	root     []pmtilesEntry
+	metadata map[string]string
+
+	mu sync.Mutex
+	// leaves are the leaf directories read recently keyed by offset.
+	leaves map[uint64][]pmtilesEntry
+}
+
+// OpenPMTiles opens a PMTiles archive. The root directory is read into
+// memory, and the leaf directories are read as needed.
+func OpenPMTiles(path string) (*PMTiles, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPMTiles(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+func newPMTiles(f *os.File) (*PMTiles, error) {
+	b := make([]byte, pmtilesHeaderSize)
+	if _, err := f.ReadAt(b, 0); err != nil {
+		return nil, errors.New("tiles: not a PMTiles archive")
+	}
+	h, err := decodePMTilesHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	p := &PMTiles{f: f, header: h, metadata: map[string]string{}, leaves: map[uint64][]pmtilesEntry{}}
+	if p.root, err = p.readDirectory(h.rootOffset, h.rootLength); err != nil {
+		return nil, err
+	}
+	if h.metadataLength > 0 {
+		b, err := p.read(h.metadataOffset, h.metadataLength)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = decompress(b, h.internalCompression); err != nil {
+			return nil, err
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("tiles: metadata: %w", err)
+		}
+		for k, v := range values {
+			var s string
+			if err := json.Unmarshal(v, &s); err == nil {
+				p.metadata[k] = s
+			} else {
+				p.metadata[k] = string(v)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *PMTiles) read(offset, length uint64) ([]byte, error) {
+	if length > pmtilesMaxDirSize {
+		return nil, errPMTilesCorrupt
+	}
+	b := make([]byte, length)
+	if _, err := p.f.ReadAt(b, int64(offset)); err != nil {
+		return nil, fmt.Errorf("tiles: reading PMTiles archive: %w", err)
+	}
+	return b, nil
+}
+
+func (p *PMTiles) readDirectory(offset, length uint64) ([]pmtilesEntry, error) {
+	b, err := p.read(offset, length)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = decompress(b, p.header.internalCompression); err != nil {
+		return nil, err
+	}
+	return decodeDirectory(b)
+}
+
+// Tile returns the image of the tile, compressed as the archive records.
+func (p *PMTiles) Tile(t geo.Tile) ([]byte, error) {
+	if !t.Valid() || t.Z < int(p.header.minZoom) || t.Z > int(p.header.maxZoom) {
+		return nil, ErrNotExist
+	}
+	id := tileID(t)
+	dir := p.root
+	for depth := 0; depth < pmtilesMaxDepth; depth++ {
+		e, ok := findEntry(dir, id)
+		if !ok {
+			return nil, ErrNotExist
+		}
+		if e.runLength > 0 {
+			return p.read(p.header.dataOffset+e.offset, uint64(e.length))
+		}
+		var err error
+		if dir, err = p.leaf(e); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errPMTilesCorrupt
+}
+
+// leaf returns the leaf directory of the entry.
+func (p *PMTiles) leaf(e pmtilesEntry) ([]pmtilesEntry, error) {
+	p.mu.Lock()
+	dir, ok := p.leaves[e.offset]
+	p.mu.Unlock()
+	if ok {
+		return dir, nil
+	}
+	dir, err := p.readDirectory(p.header.leafOffset+e.offset, uint64(e.length))
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.leaves) >= pmtilesLeafCacheSize {
+		p.leaves = map[uint64][]pmtilesEntry{}
+	}
+	p.leaves[e.offset] = dir
+	return dir, nil
+}
+
+// Metadata returns the metadata of the archive. Values that are not strings
+// are given as JSON.
+func (p *PMTiles) Metadata() map[string]string {
+	md := make(map[string]string, len(p.metadata))
+	for k, v := range p.metadata {
+		md[k] = v
+	}
+	return md
+}
+
+// Len returns the number of tiles.
+func (p *PMTiles) Len() int {
+	return int(p.header.addressedTiles)
+}
+
+// Close closes the file.
+func (p *PMTiles) Close() error {
+	return p.f.Close()
+}
+
+// WritePMTiles writes the tiles to w as a PMTiles archive.
+func WritePMTiles(w io.Writer, md Metadata, tiles map[geo.Tile][]byte) error {
+	return writePMTiles(w, md, tiles, pmtilesMaxRootSize)
+}
+
+// writePMTiles writes the archive, moving the entries to leaf directories
+// unless the header and the root directory fit in maxRootSize bytes.
+func writePMTiles(w io.Writer, md Metadata, tiles map[geo.Tile][]byte, maxRootSize int) error {
+	type tile struct {
+		id   uint64
+		data []byte
+	}
+	sorted := make([]tile, 0, len(tiles))
+	for t, data := range tiles {
+		if !t.Valid() {
+			return fmt.Errorf("tiles: invalid tile %v", t)
+		}
+		sorted = append(sorted, tile{tileID(t), data})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+	entries := make([]pmtilesEntry, len(sorted))
+	var dataLength uint64
+	for i, t := range sorted {
+		if len(t.data) > math.MaxUint32 {
+			return errors.New("tiles: tile too large")
+		}
+		entries[i] = pmtilesEntry{tileID: t.id, offset: dataLength, length: uint32(len(t.data)), runLength: 1}
+		dataLength += uint64(len(t.data))
+	}
+
+	const internal = pmtilesCompressionGzip
+	root, leaves, err := buildDirectories(entries, internal, maxRootSize-pmtilesHeaderSize)
+	if err != nil {
+		return err
+	}
+	meta := map[string]interface{}{"type": "overlay"}
+	for k, v := range map[string]string{
+		"name":        md.Name,
+		"description": md.Description,
+		"attribution": md.Attribution,
+		"format":      md.Format,
+	} {
+		if v != "" {
+			meta[k] = v
+		}
+	}
+	if len(md.VectorLayers) > 0 {
+		meta["vector_layers"] = md.VectorLayers
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if metaJSON, err = compress(metaJSON, internal); err != nil {
+		return err
+	}
+
+	tileCompression := uint8(pmtilesCompressionNone)
+	switch md.Compression {
+	case "":
+	case "gzip":
+		tileCompression = pmtilesCompressionGzip
+	default:
+		tileCompression = pmtilesCompressionUnknown
+	}
+	b, c := md.bounds(), md.center()
+	e7 := func(v float64) int32 {
+		return int32(math.Round(v * 1e7))
+	}
+	h := pmtilesHeader{
+		rootOffset:          pmtilesHeaderSize,
+		rootLength:          uint64(len(root)),
+		metadataOffset:      pmtilesHeaderSize + uint64(len(root)),
+		metadataLength:      uint64(len(metaJSON)),
+		leafLength:          uint64(len(leaves)),
+		dataLength:          dataLength,
+		addressedTiles:      uint64(len(entries)),
+		tileEntries:         uint64(len(entries)),
+		tileContents:        uint64(len(entries)),
+		clustered:           true,
+		internalCompression: internal,
+		tileCompression:     tileCompression,
+		tileType:            pmtilesTileTypes[md.Format],
+		minZoom:             uint8(md.MinZoom),
+		maxZoom:             uint8(md.MaxZoom),
+		minLong:             e7(b.Min.Longitude),
+		minLat:              e7(b.Min.Latitude),
+		maxLong:             e7(b.Max.Longitude),
+		maxLat:              e7(b.Max.Latitude),
+		centerZoom:          uint8(md.MinZoom),
+		centerLong:          e7(c.Longitude),
+		centerLat:           e7(c.Latitude),
+	}
+	h.leafOffset = h.metadataOffset + h.metadataLength
+	h.dataOffset = h.leafOffset + h.leafLength
+
+	for _, part := range [][]byte{h.encode(), root, metaJSON, leaves} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	for _, t := range sorted {
+		if _, err := w.Write(t.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildDirectories returns the root directory and the leaf directories of
+// the entries. The entries are split into leaves of growing size until the
+// root fits in maxRootSize bytes.
+func buildDirectories(entries []pmtilesEntry, compression uint8, maxRootSize int) ([]byte, []byte, error) {
+	root, err := compress(encodeDirectory(entries), compression)
+	if err != nil || len(root) <= maxRootSize {
+		return root, nil, err
+	}
+	for leafSize := 4096; ; leafSize *= 2 {
+		var rootEntries []pmtilesEntry
+		var leaves []byte
+		for start := 0; start < len(entries); start += leafSize {
+			end := start + leafSize
+			if end > len(entries) {
+				end = len(entries)
+			}
+			leaf, err := compress(encodeDirectory(entries[start:end]), compression)
+			if err != nil {
+				return nil, nil, err
+			}
+			rootEntries = append(rootEntries, pmtilesEntry{
+				tileID: entries[start].tileID,
+				offset: uint64(len(leaves)),
+				length: uint32(len(leaf)),
+			})
+			leaves = append(leaves, leaf...)
+		}
+		if root, err = compress(encodeDirectory(rootEntries), compression); err != nil {
+			return nil, nil, err
+		}
+		if len(root) <= maxRootSize || len(rootEntries) == 1 {
+			return root, leaves, nil
+		}
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package tiles
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// This file reads the SQLite file format as far as MBTiles needs: the
+// schema and the rows of tables. It does not run SQL, so views, indexes and
+// uncommitted transactions in a write-ahead log are ignored.
+
+const (
+	sqliteMagic = "SQLite format 3\x00"
+	// sqliteHeaderSize is the size of the database header on the first page.
+	sqliteHeaderSize = 100
+
+	pageInteriorTable = 0x05
+	pageLeafTable     = 0x0d
+)
+
+var errCorrupt = errors.New("tiles: malformed database")
+
+// sqliteDB is a read-only SQLite database.
+type sqliteDB struct {
+	r        io.ReaderAt
+	pageSize int
+	// usable is the page size without the reserved bytes.
+	usable int
+	// pages is the number of pages, or zero if unknown.
+	pages uint32
+}
+
+// sqliteObject is an entry of the schema table.
+type sqliteObject struct {
+	typ      string
+	name     string
+	rootPage uint32
+	sql      string
+}
+
+func openSQLite(r io.ReaderAt) (*sqliteDB, error) {
+	h := make([]byte, sqliteHeaderSize)
+	if _, err := r.ReadAt(h, 0); err != nil {
+		return nil, fmt.Errorf("tiles: not a SQLite database: %w", err)
+	}
+	if string(h[:16]) != sqliteMagic {
+		return nil, errors.New("tiles: not a SQLite database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(h[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, errCorrupt
+	}
+	if enc := binary.BigEndian.Uint32(h[56:60]); enc != 0 && enc != 1 {
+		return nil, errors.New("tiles: database text encoding is not UTF-8")
+	}
+	db := &sqliteDB{
+		r:        r,
+		pageSize: pageSize,
+		usable:   pageSize - int(h[20]),
+	}
+	// The page count is valid only if it was written by the version that
+	// last changed the file.
+	if string(h[24:28]) == string(h[92:96]) {
+		db.pages = binary.BigEndian.Uint32(h[28:32])
+	}
+	if db.usable < 480 {
+		return nil, errCorrupt
+	}
+	return db, nil
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	if n == 0 || db.pages > 0 && n > db.pages {
+		return nil, errCorrupt
+	}
+	p := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(p, int64(n-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("tiles: reading page %d: %w", n, err)
+	}
+	return p, nil
+}
+
+// schema returns the objects of the database.
+func (db *sqliteDB) schema() ([]sqliteObject, error) {
+	var objects []sqliteObject
+	err := db.scan(1, func(rowid int64, c cell) error {
+		values, err := db.record(c, 5)
+		if err != nil {
+			return err
+		}
+		var o sqliteObject
+		o.typ, _ = values[0].(string)
+		o.name, _ = values[1].(string)
+		root, _ := values[3].(int64)
+		o.rootPage = uint32(root)
+		o.sql, _ = values[4].(string)
+		objects = append(objects, o)
+		return nil
+	})
+	return objects, err
+}
+
+// cell is the payload of a row. The payload beyond the local bytes is
+// stored in a chain of overflow pages.
+type cell struct {
+	size     int
+	local    []byte
+	overflow uint32
+}
+
+// scan calls fn for the rows of the table with the root page in the order
+// of their rowids.
+func (db *sqliteDB) scan(root uint32, fn func(rowid int64, c cell) error) error {
+	return db.scanPage(root, fn, 0)
+}
+
+func (db *sqliteDB) scanPage(n uint32, fn func(rowid int64, c cell) error, depth int) error {
+	// A b-tree deeper than this is a cycle in a malformed file.
+	if depth > 64 {
+		return errCorrupt
+	}
+	p, err := db.page(n)
+	if err != nil {
+		return err
+	}
+	hdr := 0
+	if n == 1 {
+		hdr = sqliteHeaderSize
+	}
+	if len(p) < hdr+8 {
+		return errCorrupt
+	}
+	typ := p[hdr]
+	count := int(binary.BigEndian.Uint16(p[hdr+3 : hdr+5]))
+	switch typ {
+	case pageLeafTable:
+		ptrs := hdr + 8
+		if ptrs+2*count > len(p) {
+			return errCorrupt
+		}
+		for i := 0; i < count; i++ {
+			off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+			rowid, c, err := db.leafCell(p, off)
+			if err != nil {
+				return err
+			}
+			if err := fn(rowid, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case pageInteriorTable:
+		ptrs := hdr + 12
+		if ptrs+2*count > len(p) {
+			return errCorrupt
+		}
+		for i := 0; i < count; i++ {
+			off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+			if off+4 > len(p) {
+				return errCorrupt
+			}
+			if err := db.scanPage(binary.BigEndian.Uint32(p[off:]), fn, depth+1); err != nil {
+				return err
+			}
+		}
+		return db.scanPage(binary.BigEndian.Uint32(p[hdr+8:]), fn, depth+1)
+	default:
+		return errCorrupt
+	}
+}
+
+// find returns the row of the table with the rowid.
+func (db *sqliteDB) find(root uint32, rowid int64) (cell, bool, error) {
+	n := root
+	for depth := 0; depth <= 64; depth++ {
+		p, err := db.page(n)
+		if err != nil {
+			return cell{}, false, err
+		}
+		hdr := 0
+		if n == 1 {
+			hdr = sqliteHeaderSize
+		}
+		typ := p[hdr]
+		count := int(binary.BigEndian.Uint16(p[hdr+3 : hdr+5]))
+		switch typ {
+		case pageLeafTable:
+			ptrs := hdr + 8
+			if ptrs+2*count > len(p) {
+				return cell{}, false, errCorrupt
+			}
+			for i := 0; i < count; i++ {
+				off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+				id, c, err := db.leafCell(p, off)
+				if err != nil {
+					return cell{}, false, err
+				}
+				if id == rowid {
+					return c, true, nil
+				}
+			}
+			return cell{}, false, nil
+		case pageInteriorTable:
+			ptrs := hdr + 12
+			if ptrs+2*count > len(p) {
+				return cell{}, false, errCorrupt
+			}
+			// The left child of a cell holds the rowids up to its key.
+			next := binary.BigEndian.Uint32(p[hdr+8:])
+			for i := 0; i < count; i++ {
+				off := int(binary.BigEndian.Uint16(p[ptrs+2*i:]))
+				if off+4 > len(p) {
+					return cell{}, false, errCorrupt
+				}
+				key, _, err := readVarint(p[off+4:])
+				if err != nil {
+					return cell{}, false, err
+				}
+				if rowid <= key {
+					next = binary.BigEndian.Uint32(p[off:])
+					break
+				}
+			}
+			n = next
+		default:
+			return cell{}, false, errCorrupt
+		}
+	}
+	return cell{}, false, errCorrupt
+}
+
+// leafCell decodes the cell of a table leaf page at the offset.
+func (db *sqliteDB) leafCell(p []byte, off int) (int64, cell, error) {
+	if off >= len(p) {
+		return 0, cell{}, errCorrupt
+	}
+	size, n, err := readVarint(p[off:])
+	if err != nil {
+		return 0, cell{}, err
+	}
+	off += n
+	rowid, n, err := readVarint(p[off:])
+	if err != nil {
+		return 0, cell{}, err
+	}
+	off += n
+	if size < 0 || size > math.MaxInt32 {
+		return 0, cell{}, errCorrupt
+	}
+	c := cell{size: int(size)}
+	local := db.localSize(c.size)
+	if off+local > len(p) {
+		return 0, cell{}, errCorrupt
+	}
+	c.local = p[off : off+local]
+	if local < c.size {
+		if off+local+4 > len(p) {
+			return 0, cell{}, errCorrupt
+		}
+		c.overflow = binary.BigEndian.Uint32(p[off+local:])
+	}
+	return rowid, c, nil
+}
+
+// localSize returns the number of payload bytes stored on a table leaf page.
+func (db *sqliteDB) localSize(size int) int {
+	u := db.usable
+	maxLocal := u - 35
+	if size <= maxLocal {
+		return size
+	}
+	minLocal := (u-12)*32/255 - 23
+	k := minLocal + (size-minLocal)%(u-4)
+	if k <= maxLocal {
+		return k
+	}
+	return minLocal
+}
+
+// payload returns the whole payload of the cell.
+func (db *sqliteDB) payload(c cell) ([]byte, error) {
+	if len(c.local) == c.size {
+		return c.local, nil
+	}
+	b := make([]byte, 0, c.size)
+	b = append(b, c.local...)
+	next := c.overflow
+	for len(b) < c.size {
+		if next == 0 {
+			return nil, errCorrupt
+		}
+		p, err := db.page(next)
+		if err != nil {
+			return nil, err
+		}
+		next = binary.BigEndian.Uint32(p)
+		n := c.size - len(b)
+		if n > db.usable-4 {
+			n = db.usable - 4
+		}
+		b = append(b, p[4:4+n]...)
+	}
+	return b, nil
+}
+
+// record decodes the first n columns of the record in the cell. Missing
+// columns are nil. The overflow pages are read only if the columns are not
+// in the local bytes.
+func (db *sqliteDB) record(c cell, n int) ([]interface{}, error) {
+	if values, err := decodeRecord(c.local, n); err == nil || len(c.local) == c.size {
+		return values, err
+	}
+	b, err := db.payload(c)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecord(b, n)
+}
+
+// decodeRecord decodes the first n columns of a record.
+func decodeRecord(b []byte, n int) ([]interface{}, error) {
+	hsize, off, err := readVarint(b)
+	if err != nil || hsize < int64(off) || hsize > int64(len(b)) {
+		return nil, errCorrupt
+	}
+	values := make([]interface{}, n)
+	body := int(hsize)
+	for i := 0; off < int(hsize) && i < n; i++ {
+		st, m, err := readVarint(b[off:hsize])
+		if err != nil {
+			return nil, err
+		}
+		off += m
+		size := serialSize(st)
+		if size < 0 || body+size > len(b) {
+			return nil, errCorrupt
+		}
+		v := b[body : body+size]
+		body += size
+		switch {
+		case st == 0:
+		case st >= 1 && st <= 6:
+			x := int64(int8(v[0]))
+			for _, c := range v[1:] {
+				x = x<<8 | int64(c)
+			}
+			values[i] = x
+		case st == 7:
+			values[i] = math.Float64frombits(binary.BigEndian.Uint64(v))
+		case st == 8:
+			values[i] = int64(0)
+		case st == 9:
+			values[i] = int64(1)
+		case st >= 12 && st%2 == 0:
+			values[i] = v
+		case st >= 13:
+			values[i] = string(v)
+		default:
+			return nil, errCorrupt
+		}
+	}
+	return values, nil
+}
+
+// serialSize returns the size of a value of the serial type, or -1 for a
+// reserved type.
+func serialSize(st int64) int {
+	switch {
+	case st >= 0 && st <= 4:
+		return []int{0, 1, 2, 3, 4}[st]
+	case st == 5:
+		return 6
+	case st == 6 || st == 7:
+		return 8
+	case st == 8 || st == 9:
+		return 0
+	case st >= 12 && st <= math.MaxInt32:
+		return int(st-12) / 2
+	}
+	return -1
+}
+
+// readVarint reads a SQLite varint, which is big-endian with 7 bits per
+// byte except for the ninth byte, which has 8 bits.
+func readVarint(b []byte) (int64, int, error) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(b) {
+			return 0, 0, errCorrupt
+		}
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9, nil
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return int64(v), i + 1, nil
+		}
+	}
+	return 0, 0, errCorrupt
+}
+
+// columns returns the lower-case names of the columns of a CREATE TABLE
+// statement and the index of the column that is an alias of the rowid, or
+// -1.
+func columns(sql string) ([]string, int) {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil, -1
+	}
+	var defs []string
+	depth, last := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[last:end])
+
+	var names []string
+	alias := -1
+	for _, d := range defs {
+		fields := strings.Fields(strings.ToLower(d))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "constraint", "primary", "unique", "check", "foreign":
+			continue
+		}
+		if strings.Join(fields[1:], " ") == "integer primary key" ||
+			strings.HasPrefix(strings.Join(fields[1:], " "), "integer primary key ") {
+			alias = len(names)
+		}
+		names = append(names, strings.Trim(fields[0], "\"`[]'"))
+	}
+	return names, alias
+}
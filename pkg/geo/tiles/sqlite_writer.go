@@ -0,0 +1,403 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package tiles
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// This file writes a SQLite database whose tables and indexes are given
+// whole, as MBTiles needs. The b-trees are built bottom-up from rows in the
+// order of their keys, so the file has no free pages.
+
+const (
+	pageInteriorIndex = 0x02
+	pageLeafIndex     = 0x0a
+
+	// sqliteWriterPageSize is the page size of the written databases.
+	sqliteWriterPageSize = 4096
+	// sqliteVersion is the version of SQLite the files are compatible with.
+	sqliteVersion = 3031001
+)
+
+// sqliteBuilder builds the pages of a database. The first page is the
+// schema table, which is built last.
+type sqliteBuilder struct {
+	pageSize int
+	pages    [][]byte
+	// schema are the rows of the schema table.
+	schema [][]interface{}
+}
+
+func newSQLiteBuilder() *sqliteBuilder {
+	return &sqliteBuilder{
+		pageSize: sqliteWriterPageSize,
+		pages:    [][]byte{nil},
+	}
+}
+
+func (b *sqliteBuilder) alloc() uint32 {
+	b.pages = append(b.pages, make([]byte, b.pageSize))
+	return uint32(len(b.pages))
+}
+
+// addTable adds a table with the rows, whose rowids are their positions
+// from 1.
+func (b *sqliteBuilder) addTable(name, sql string, rows [][]interface{}) error {
+	cells := make([][]byte, len(rows))
+	for i, row := range rows {
+		record, err := encodeRecord(row)
+		if err != nil {
+			return err
+		}
+		cells[i] = b.tableLeafCell(int64(i+1), record)
+	}
+	root := b.buildTable(cells)
+	b.schema = append(b.schema, []interface{}{"table", name, name, int64(root), sql})
+	return nil
+}
+
+// addIndex adds an index of the table with the keys, which are the indexed
+// columns followed by the rowid and must be sorted.
+func (b *sqliteBuilder) addIndex(name, table, sql string, keys [][]interface{}) error {
+	entries := make([][]byte, len(keys))
+	maxLocal := (b.pageSize-12)*64/255 - 23
+	for i, key := range keys {
+		record, err := encodeRecord(key)
+		if err != nil {
+			return err
+		}
+		// Index keys of MBTiles are a few integers, so they never need
+		// overflow pages.
+		if len(record) > maxLocal {
+			return errors.New("tiles: index key too large")
+		}
+		entries[i] = record
+	}
+	root := b.buildIndex(entries)
+	b.schema = append(b.schema, []interface{}{"index", name, table, int64(root), sql})
+	return nil
+}
+
+// tableLeafCell returns the cell of a row, moving the payload that does not
+// fit in the page to overflow pages.
+func (b *sqliteBuilder) tableLeafCell(rowid int64, payload []byte) []byte {
+	c := appendVarint(nil, int64(len(payload)))
+	c = appendVarint(c, rowid)
+	local := localSize(b.pageSize, len(payload))
+	c = append(c, payload[:local]...)
+	if local == len(payload) {
+		return c
+	}
+	rest := payload[local:]
+	first := b.alloc()
+	c = appendUint32(c, first)
+	for n := first; ; {
+		p := b.pages[n-1]
+		m := copy(p[4:], rest)
+		rest = rest[m:]
+		if len(rest) == 0 {
+			break
+		}
+		next := b.alloc()
+		binary.BigEndian.PutUint32(p, next)
+		n = next
+	}
+	return c
+}
+
+// buildTable builds a table b-tree from the leaf cells and returns its root
+// page.
+func (b *sqliteBuilder) buildTable(cells [][]byte) uint32 {
+	type child struct {
+		page uint32
+		// key is the largest rowid in the subtree.
+		key int64
+	}
+	var children []child
+	rowid := int64(0)
+	for start := 0; start < len(cells) || len(children) == 0; {
+		end := start
+		for end < len(cells) && fits(b.pageSize, 8, cells[start:end+1]) {
+			end++
+		}
+		n := b.alloc()
+		b.writePage(n, pageLeafTable, cells[start:end], 0)
+		rowid += int64(end - start)
+		children = append(children, child{n, rowid})
+		start = end
+	}
+	for len(children) > 1 {
+		// Interior cells are at most 13 bytes, so the children are spread
+		// evenly to leave no page with a single child.
+		perPage := (b.pageSize - 12) / (13 + 2)
+		pages := (len(children) + perPage - 1) / perPage
+		var parents []child
+		for i := 0; i < pages; i++ {
+			group := children[i*len(children)/pages : (i+1)*len(children)/pages]
+			var cells [][]byte
+			for _, c := range group[:len(group)-1] {
+				cell := appendUint32(nil, c.page)
+				cells = append(cells, appendVarint(cell, c.key))
+			}
+			last := group[len(group)-1]
+			n := b.alloc()
+			b.writePage(n, pageInteriorTable, cells, last.page)
+			parents = append(parents, child{n, last.key})
+		}
+		children = parents
+	}
+	return children[0].page
+}
+
+// buildIndex builds an index b-tree from the sorted entries and returns its
+// root page. Unlike tables, every entry is stored once, so the entries that
+// divide the children are moved up to the parents.
+func (b *sqliteBuilder) buildIndex(entries [][]byte) uint32 {
+	cells := make([][]byte, len(entries))
+	for i, e := range entries {
+		cells[i] = append(appendVarint(nil, int64(len(e))), e...)
+	}
+	var pages []uint32
+	var dividers [][]byte
+	for start := 0; start < len(cells) || len(pages) == 0; {
+		end := start
+		for end < len(cells) && fits(b.pageSize, 8, cells[start:end+1]) {
+			end++
+		}
+		// The next entry divides this page from the next one, which must
+		// not be empty.
+		if end == len(cells)-1 {
+			end--
+		}
+		n := b.alloc()
+		b.writePage(n, pageLeafIndex, cells[start:end], 0)
+		pages = append(pages, n)
+		if end < len(cells) {
+			dividers = append(dividers, entries[end])
+		}
+		start = end + 1
+	}
+	for len(pages) > 1 {
+		var parents []uint32
+		var promoted [][]byte
+		for start := 0; start < len(pages); {
+			// The page holds the children from start to end with the
+			// dividers between them, and the last child is the right
+			// pointer.
+			end := start
+			var cells [][]byte
+			for end < len(dividers) {
+				cell := appendUint32(nil, pages[end])
+				cell = appendVarint(cell, int64(len(dividers[end])))
+				cell = append(cell, dividers[end]...)
+				if !fits(b.pageSize, 12, append(cells, cell)) {
+					break
+				}
+				cells = append(cells, cell)
+				end++
+			}
+			if end == len(pages)-2 {
+				cells = cells[:len(cells)-1]
+				end--
+			}
+			n := b.alloc()
+			b.writePage(n, pageInteriorIndex, cells, pages[end])
+			parents = append(parents, n)
+			if end < len(dividers) {
+				promoted = append(promoted, dividers[end])
+			}
+			start = end + 1
+		}
+		pages, dividers = parents, promoted
+	}
+	return pages[0]
+}
+
+// fits reports whether the cells fit in a page with the header size.
+func fits(pageSize, header int, cells [][]byte) bool {
+	size := header
+	for _, c := range cells {
+		size += 2 + len(c)
+	}
+	return size <= pageSize
+}
+
+// writePage writes the cells to the page from its end. The header of the
+// first page follows the database header.
+func (b *sqliteBuilder) writePage(n uint32, typ byte, cells [][]byte, right uint32) {
+	p := b.pages[n-1]
+	hdr := 0
+	if n == 1 {
+		hdr = sqliteHeaderSize
+	}
+	ptrs := hdr + 8
+	if typ == pageInteriorTable || typ == pageInteriorIndex {
+		binary.BigEndian.PutUint32(p[hdr+8:], right)
+		ptrs = hdr + 12
+	}
+	p[hdr] = typ
+	binary.BigEndian.PutUint16(p[hdr+3:], uint16(len(cells)))
+	off := len(p)
+	for i, c := range cells {
+		off -= len(c)
+		copy(p[off:], c)
+		binary.BigEndian.PutUint16(p[ptrs+2*i:], uint16(off))
+	}
+	// A content area starting at 65536 is written as 0.
+	binary.BigEndian.PutUint16(p[hdr+5:], uint16(off))
+}
+
+// writeTo writes the database with the application ID.
+func (b *sqliteBuilder) writeTo(w io.Writer, appID uint32) error {
+	var cells [][]byte
+	for i, row := range b.schema {
+		record, err := encodeRecord(row)
+		if err != nil {
+			return err
+		}
+		cells = append(cells, b.tableLeafCell(int64(i+1), record))
+	}
+	if !fits(b.pageSize, sqliteHeaderSize+8, cells) {
+		return errors.New("tiles: schema too large")
+	}
+	b.pages[0] = make([]byte, b.pageSize)
+	b.writePage(1, pageLeafTable, cells, 0)
+
+	h := b.pages[0][:sqliteHeaderSize]
+	copy(h, sqliteMagic)
+	binary.BigEndian.PutUint16(h[16:], uint16(b.pageSize))
+	h[18], h[19] = 1, 1 // Legacy journal mode.
+	h[21], h[22], h[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(h[24:], 1) // File change counter.
+	binary.BigEndian.PutUint32(h[28:], uint32(len(b.pages)))
+	binary.BigEndian.PutUint32(h[40:], 1) // Schema cookie.
+	binary.BigEndian.PutUint32(h[44:], 4) // Schema format.
+	binary.BigEndian.PutUint32(h[56:], 1) // UTF-8.
+	binary.BigEndian.PutUint32(h[68:], appID)
+	binary.BigEndian.PutUint32(h[92:], 1)
+	binary.BigEndian.PutUint32(h[96:], sqliteVersion)
+
+	for _, p := range b.pages {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localSize returns the number of payload bytes stored on a table leaf page
+// of the page size.
+func localSize(pageSize, size int) int {
+	db := sqliteDB{pageSize: pageSize, usable: pageSize}
+	return db.localSize(size)
+}
+
+// encodeRecord encodes the values, which are nil, int64, float64, string or
+// []byte, as a record.
+func encodeRecord(values []interface{}) ([]byte, error) {
+	var types []int64
+	var body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int64:
+			st, b := encodeInt(v)
+			types = append(types, st)
+			body = append(body, b...)
+		case float64:
+			types = append(types, 7)
+			body = appendUint64(body, math.Float64bits(v))
+		case string:
+			types = append(types, int64(len(v))*2+13)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, int64(len(v))*2+12)
+			body = append(body, v...)
+		default:
+			return nil, fmt.Errorf("tiles: unsupported value %T", v)
+		}
+	}
+	var header []byte
+	for _, st := range types {
+		header = appendVarint(header, st)
+	}
+	// The size of the header includes the varint of the size.
+	size := len(header) + 1
+	for size != len(header)+varintLen(int64(size)) {
+		size = len(header) + varintLen(int64(size))
+	}
+	record := appendVarint(nil, int64(size))
+	record = append(record, header...)
+	return append(record, body...), nil
+}
+
+// encodeInt returns the smallest serial type of the integer and its bytes.
+func encodeInt(v int64) (int64, []byte) {
+	switch {
+	case v == 0:
+		return 8, nil
+	case v == 1:
+		return 9, nil
+	}
+	sizes := []struct {
+		st   int64
+		size uint
+	}{{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 6}, {6, 8}}
+	for _, s := range sizes {
+		if s.size == 8 || v >= -1<<(8*s.size-1) && v < 1<<(8*s.size-1) {
+			b := make([]byte, s.size)
+			for i := range b {
+				b[len(b)-1-i] = byte(v >> (8 * uint(i)))
+			}
+			return s.st, b
+		}
+	}
+	return 0, nil
+}
+
+// appendVarint appends a SQLite varint.
+func appendVarint(b []byte, v int64) []byte {
+	u := uint64(v)
+	if u>>56 != 0 {
+		var buf [9]byte
+		buf[8] = byte(u)
+		u >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(u&0x7f) | 0x80
+			u >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	var buf [8]byte
+	i := len(buf) - 1
+	buf[i] = byte(u & 0x7f)
+	for u >>= 7; u != 0; u >>= 7 {
+		i--
+		buf[i] = byte(u&0x7f) | 0x80
+	}
+	return append(b, buf[i:]...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func varintLen(v int64) int {
+	return len(appendVarint(nil, v))
+}
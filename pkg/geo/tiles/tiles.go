@@ -0,0 +1,479 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package tiles reads and writes map tiles in local files.
+package tiles
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// ErrNotExist is returned for a tile that the source does not have.
+var ErrNotExist = errors.New("tiles: tile does not exist")
+
+// Source is a source of map tiles in the z/x/y scheme.
+type Source interface {
+	// Tile returns the image of the tile.
+	Tile(t geo.Tile) ([]byte, error)
+	// Close releases the resources of the source.
+	Close() error
+}
+
+// Metadata describes a set of tiles.
+type Metadata struct {
+	Name        string
+	Description string
+	// Attribution is an HTML string crediting the sources of the data.
+	Attribution string
+	// Format is the format of the tiles: pbf for vector tiles, png or jpg.
+	Format string
+	// Compression is the compression of the tiles, gzip or empty for none.
+	// Vector tiles in MBTiles files are compressed with gzip by convention.
+	Compression string
+	MinZoom     int
+	MaxZoom     int
+	Bounds      geo.BBox
+	// VectorLayers describe the layers of vector tiles.
+	VectorLayers []VectorLayer
+}
+
+// VectorLayer describes a layer of vector tiles.
+type VectorLayer struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	MinZoom     int    `json:"minzoom"`
+	MaxZoom     int    `json:"maxzoom"`
+	// Fields are the types of the properties of the features, such as
+	// String or Number, keyed by name.
+	Fields map[string]string `json:"fields"`
+}
+
+// bounds returns the bounds, or the whole world if they are empty or not
+// given.
+func (md Metadata) bounds() geo.BBox {
+	if md.Bounds.IsEmpty() || md.Bounds == (geo.BBox{}) {
+		return geo.BBox{
+			Min: geo.LatLong{Latitude: geo.MinLatitude, Longitude: geo.MinLongitude},
+			Max: geo.LatLong{Latitude: geo.MaxLatitude, Longitude: geo.MaxLongitude},
+		}
+	}
+	return md.Bounds
+}
+
+// center returns the center of the bounds.
+func (md Metadata) center() geo.LatLong {
+	b := md.bounds()
+	return geo.LatLong{
+		Latitude:  (b.Min.Latitude + b.Max.Latitude) / 2,
+		Longitude: (b.Min.Longitude + b.Max.Longitude) / 2,
+	}
+}
+
+// values returns the metadata as name and value pairs of MBTiles.
+func (md Metadata) values() (map[string]string, error) {
+	b, c := md.bounds(), md.center()
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	values := map[string]string{
+		"name":    md.Name,
+		"format":  md.Format,
+		"type":    "overlay",
+		"minzoom": strconv.Itoa(md.MinZoom),
+		"maxzoom": strconv.Itoa(md.MaxZoom),
+		"bounds": strings.Join([]string{
+			f(b.Min.Longitude), f(b.Min.Latitude), f(b.Max.Longitude), f(b.Max.Latitude),
+		}, ","),
+		"center": strings.Join([]string{f(c.Longitude), f(c.Latitude), strconv.Itoa(md.MinZoom)}, ","),
+	}
+	if md.Description != "" {
+		values["description"] = md.Description
+	}
+	if md.Attribution != "" {
+		values["attribution"] = md.Attribution
+	}
+	if len(md.VectorLayers) > 0 {
+		j, err := json.Marshal(map[string]interface{}{"vector_layers": md.VectorLayers})
+		if err != nil {
+			return nil, err
+		}
+		values["json"] = string(j)
+	}
+	return values, nil
+}
+
+// Open opens a tile directory, an MBTiles file or a PMTiles archive.
+func Open(path string) (Source, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return Dir(path), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(pmtilesMagic))
+	_, err = io.ReadFull(f, magic)
+	f.Close()
+	if err == nil && string(magic) == pmtilesMagic {
+		return OpenPMTiles(path)
+	}
+	return OpenMBTiles(path)
+}
+
+// Dir is a directory of tiles in {z}/{x}/{y}.png files. JPEG tiles with the
+// .jpg or .jpeg extension are also read.
+type Dir string
+
+var dirExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// Tile returns the image of the tile.
+func (d Dir) Tile(t geo.Tile) ([]byte, error) {
+	if !t.Valid() {
+		return nil, ErrNotExist
+	}
+	base := filepath.Join(string(d), strconv.Itoa(t.Z), strconv.FormatUint(uint64(t.X), 10),
+		strconv.FormatUint(uint64(t.Y), 10))
+	for _, ext := range dirExtensions {
+		b, err := ioutil.ReadFile(base + ext)
+		if os.IsNotExist(err) {
+			continue
+		}
+		return b, err
+	}
+	return nil, ErrNotExist
+}
+
+// Close does nothing.
+func (d Dir) Close() error {
+	return nil
+}
+
+// MBTiles is an MBTiles file, which stores the tiles in a SQLite database.
+// Both the flat tiles table and the map and images tables of deduplicated
+// files are supported. It is safe for concurrent use.
+type MBTiles struct {
+	f        *os.File
+	db       *sqliteDB
+	metadata map[string]string
+	// root is the root page of the table with the tile data in the column.
+	root   uint32
+	column int
+	// rows are the rowids of the tiles in the table keyed by the tile.
+	rows map[geo.Tile]int64
+}
+
+// OpenMBTiles opens an MBTiles file. The locations of all tiles are read
+// into memory.
+func OpenMBTiles(path string) (*MBTiles, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newMBTiles(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func newMBTiles(f *os.File) (*MBTiles, error) {
+	db, err := openSQLite(f)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := db.schema()
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string]sqliteObject{}
+	for _, o := range objects {
+		if o.typ == "table" {
+			tables[strings.ToLower(o.name)] = o
+		}
+	}
+	m := &MBTiles{f: f, db: db, metadata: map[string]string{}, rows: map[geo.Tile]int64{}}
+	if err := m.readMetadata(tables["metadata"]); err != nil {
+		return nil, err
+	}
+	if t, ok := tables["tiles"]; ok {
+		err = m.readTiles(t)
+	} else {
+		err = m.readMap(tables["map"], tables["images"])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *MBTiles) readMetadata(t sqliteObject) error {
+	if t.rootPage == 0 {
+		return nil
+	}
+	cols, err := columnIndexes(t, "name", "value")
+	if err != nil {
+		return err
+	}
+	return m.scan(t, cols, func(values []interface{}) {
+		name, _ := values[0].(string)
+		value, _ := values[1].(string)
+		m.metadata[name] = value
+	})
+}
+
+// readTiles reads the locations of the tiles of the flat schema.
+func (m *MBTiles) readTiles(t sqliteObject) error {
+	cols, err := columnIndexes(t, "zoom_level", "tile_column", "tile_row", "tile_data")
+	if err != nil {
+		return err
+	}
+	m.root, m.column = t.rootPage, cols[3]
+	return m.db.scan(t.rootPage, func(rowid int64, c cell) error {
+		values, err := m.db.record(c, maxIndex(cols[:3])+1)
+		if err != nil {
+			return err
+		}
+		if tile, ok := tmsTile(values[cols[0]], values[cols[1]], values[cols[2]]); ok {
+			m.rows[tile] = rowid
+		}
+		return nil
+	})
+}
+
+// readMap reads the locations of the tiles of the deduplicated schema, in
+// which the map table refers to the images table by tile_id.
+func (m *MBTiles) readMap(mapTable, images sqliteObject) error {
+	if mapTable.rootPage == 0 || images.rootPage == 0 {
+		return errors.New("tiles: no tiles table")
+	}
+	imageCols, err := columnIndexes(images, "tile_id", "tile_data")
+	if err != nil {
+		return err
+	}
+	_, alias := columns(images.sql)
+	ids := map[interface{}]int64{}
+	err = m.db.scan(images.rootPage, func(rowid int64, c cell) error {
+		values, err := m.db.record(c, imageCols[0]+1)
+		if err != nil {
+			return err
+		}
+		id := values[imageCols[0]]
+		if imageCols[0] == alias {
+			id = rowid
+		}
+		if b, ok := id.([]byte); ok {
+			id = string(b)
+		}
+		ids[id] = rowid
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.root, m.column = images.rootPage, imageCols[1]
+
+	cols, err := columnIndexes(mapTable, "zoom_level", "tile_column", "tile_row", "tile_id")
+	if err != nil {
+		return err
+	}
+	return m.scan(mapTable, cols, func(values []interface{}) {
+		tile, ok := tmsTile(values[0], values[1], values[2])
+		id := values[3]
+		if b, isBlob := id.([]byte); isBlob {
+			id = string(b)
+		}
+		if rowid, found := ids[id]; ok && found {
+			m.rows[tile] = rowid
+		}
+	})
+}
+
+// scan calls fn with the values of the columns of each row of the table.
+func (m *MBTiles) scan(t sqliteObject, cols []int, fn func(values []interface{})) error {
+	_, alias := columns(t.sql)
+	return m.db.scan(t.rootPage, func(rowid int64, c cell) error {
+		values, err := m.db.record(c, maxIndex(cols)+1)
+		if err != nil {
+			return err
+		}
+		selected := make([]interface{}, len(cols))
+		for i, col := range cols {
+			selected[i] = values[col]
+			if col == alias {
+				selected[i] = rowid
+			}
+		}
+		fn(selected)
+		return nil
+	})
+}
+
+// columnIndexes returns the indexes of the columns of the table.
+func columnIndexes(t sqliteObject, names ...string) ([]int, error) {
+	cols, _ := columns(t.sql)
+	indexes := make([]int, len(names))
+	for i, name := range names {
+		indexes[i] = -1
+		for j, c := range cols {
+			if c == name {
+				indexes[i] = j
+			}
+		}
+		if indexes[i] < 0 {
+			return nil, fmt.Errorf("tiles: table %s has no column %s", t.name, name)
+		}
+	}
+	return indexes, nil
+}
+
+func maxIndex(indexes []int) int {
+	max := 0
+	for _, i := range indexes {
+		if i > max {
+			max = i
+		}
+	}
+	return max
+}
+
+// tmsTile returns the tile of the MBTiles coordinates, whose rows are
+// numbered from the south as in TMS.
+func tmsTile(z, x, y interface{}) (geo.Tile, bool) {
+	zoom, ok1 := z.(int64)
+	col, ok2 := x.(int64)
+	row, ok3 := y.(int64)
+	if !ok1 || !ok2 || !ok3 || zoom < 0 || zoom > geo.MaxCellLevel || col < 0 || row < 0 {
+		return geo.Tile{}, false
+	}
+	t := geo.Tile{X: uint(col), Y: uint(int64(1)<<uint(zoom) - 1 - row), Z: int(zoom)}
+	return t, t.Valid()
+}
+
+// Tile returns the image of the tile.
+func (m *MBTiles) Tile(t geo.Tile) ([]byte, error) {
+	rowid, ok := m.rows[t]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	c, ok, err := m.db.find(m.root, rowid)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotExist
+	}
+	b, err := m.db.payload(c)
+	if err != nil {
+		return nil, err
+	}
+	values, err := decodeRecord(b, m.column+1)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := values[m.column].([]byte)
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return data, nil
+}
+
+// Metadata returns the metadata of the file, such as its name, format and
+// attribution.
+func (m *MBTiles) Metadata() map[string]string {
+	md := make(map[string]string, len(m.metadata))
+	for k, v := range m.metadata {
+		md[k] = v
+	}
+	return md
+}
+
+// Len returns the number of tiles.
+func (m *MBTiles) Len() int {
+	return len(m.rows)
+}
+
+// Close closes the file.
+func (m *MBTiles) Close() error {
+	return m.f.Close()
+}
+
+// WriteMBTiles writes the tiles to w as an MBTiles file with the flat
+// schema and the index of the tiles.
+func WriteMBTiles(w io.Writer, md Metadata, tiles map[geo.Tile][]byte) error {
+	values, err := md.values()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	metadata := make([][]interface{}, len(names))
+	for i, name := range names {
+		metadata[i] = []interface{}{name, values[name]}
+	}
+
+	// The rows are sorted as in the index.
+	keys := make([]geo.Tile, 0, len(tiles))
+	for t := range tiles {
+		if !t.Valid() {
+			return fmt.Errorf("tiles: invalid tile %v", t)
+		}
+		keys = append(keys, t)
+	}
+	tmsRow := func(t geo.Tile) int64 {
+		return int64(1)<<uint(t.Z) - 1 - int64(t.Y)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return tmsRow(a) < tmsRow(b)
+	})
+	rows := make([][]interface{}, len(keys))
+	index := make([][]interface{}, len(keys))
+	for i, t := range keys {
+		z, x, y := int64(t.Z), int64(t.X), tmsRow(t)
+		rows[i] = []interface{}{z, x, y, tiles[t]}
+		index[i] = []interface{}{z, x, y, int64(i + 1)}
+	}
+
+	b := newSQLiteBuilder()
+	if err := b.addTable("metadata", "CREATE TABLE metadata (name text, value text)", metadata); err != nil {
+		return err
+	}
+	if err := b.addTable("tiles", "CREATE TABLE tiles "+
+		"(zoom_level integer, tile_column integer, tile_row integer, tile_data blob)", rows); err != nil {
+		return err
+	}
+	if err := b.addIndex("tile_index", "tiles", "CREATE UNIQUE INDEX tile_index "+
+		"ON tiles (zoom_level, tile_column, tile_row)", index); err != nil {
+		return err
+	}
+	return b.writeTo(w, mbtilesApplicationID)
+}
+
+// mbtilesApplicationID is the application ID of MBTiles files, "MPBX".
+const mbtilesApplicationID = 0x4d504258
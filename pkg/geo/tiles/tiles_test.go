@@ -0,0 +1,393 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package tiles
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+)
+
+// testTiles are the tiles of the test data, the 3x3 tiles around Shiba Park
+// at zoom levels 15 and 16.
+func testTiles() []geo.Tile {
+	var tiles []geo.Tile
+	for _, c := range []geo.Tile{{X: 29103, Y: 12905, Z: 15}, {X: 58207, Y: 25811, Z: 16}} {
+		tiles = append(tiles, c.Neighbors()...)
+	}
+	return tiles
+}
+
+func readTestTile(t *testing.T, tile geo.Tile) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("../../../testdata/tiles", strconv.Itoa(tile.Z),
+		strconv.Itoa(int(tile.X)), strconv.Itoa(int(tile.Y))+".png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"directory", "../../../testdata/tiles"},
+		{"mbtiles", "../../../testdata/tiles.mbtiles"},
+		{"deduplicated mbtiles", "../../../testdata/tiles-dedup.mbtiles"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := Open(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			for _, tile := range testTiles() {
+				got, err := s.Tile(tile)
+				if err != nil {
+					t.Fatalf("%v: %v", tile, err)
+				}
+				if want := readTestTile(t, tile); !bytes.Equal(got, want) {
+					t.Errorf("%v: want = %d bytes, got = %d bytes", tile, len(want), len(got))
+				}
+			}
+			for _, tile := range []geo.Tile{{X: 29103, Y: 12905, Z: 14}, {X: 29105, Y: 12905, Z: 15}, {X: 2, Y: 0, Z: 1}} {
+				if _, err := s.Tile(tile); err != ErrNotExist {
+					t.Errorf("%v: want = %v, got = %v", tile, ErrNotExist, err)
+				}
+			}
+			if m, ok := s.(*MBTiles); ok {
+				if m.Len() != 18 {
+					t.Errorf("want = %v, got = %v", 18, m.Len())
+				}
+				want := map[string]string{
+					"name":        "Minato test tiles",
+					"format":      "png",
+					"minzoom":     "15",
+					"maxzoom":     "16",
+					"attribution": "test",
+				}
+				if got := m.Metadata(); !reflect.DeepEqual(got, want) {
+					t.Errorf("want = %v, got = %v", want, got)
+				}
+			}
+		})
+	}
+
+	for _, path := range []string{"../../../testdata/stores.csv", "../../../testdata/none.mbtiles"} {
+		if _, err := Open(path); err == nil {
+			t.Errorf("%s: want = %v, got = %v", path, "error", err)
+		}
+	}
+}
+
+func TestReadVarint(t *testing.T) {
+	tests := []struct {
+		in    []byte
+		want  int64
+		wantN int
+	}{
+		{[]byte{0x00}, 0, 1},
+		{[]byte{0x7f}, 127, 1},
+		{[]byte{0x81, 0x00}, 128, 2},
+		{[]byte{0x82, 0x80, 0x01}, 1<<15 | 1, 3},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, -1, 9},
+	}
+	for _, tt := range tests {
+		got, n, err := readVarint(tt.in)
+		if err != nil || got != tt.want || n != tt.wantN {
+			t.Errorf("%x: want = %v %v, got = %v %v %v", tt.in, tt.want, tt.wantN, got, n, err)
+		}
+	}
+	if _, _, err := readVarint([]byte{0x81}); err == nil {
+		t.Errorf("want = %v, got = %v", "error", err)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      []string
+		wantAlias int
+	}{
+		{"CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+			[]string{"zoom_level", "tile_column", "tile_row", "tile_data"}, -1},
+		{`CREATE TABLE "images" ("tile_id" INTEGER PRIMARY KEY, tile_data BLOB, CONSTRAINT c UNIQUE (tile_data))`,
+			[]string{"tile_id", "tile_data"}, 0},
+		{"CREATE TABLE m (name text, value decimal(10, 2), PRIMARY KEY (name))",
+			[]string{"name", "value"}, -1},
+	}
+	for _, tt := range tests {
+		got, alias := columns(tt.sql)
+		if !reflect.DeepEqual(got, tt.want) || alias != tt.wantAlias {
+			t.Errorf("%s: want = %v %v, got = %v %v", tt.sql, tt.want, tt.wantAlias, got, alias)
+		}
+	}
+}
+
+// testTileset returns the tiles up to zoom level 8, which need several
+// levels of b-tree pages and directories. Some of them need overflow pages.
+func testTileset() map[geo.Tile][]byte {
+	tiles := map[geo.Tile][]byte{}
+	for z := 0; z <= 8; z++ {
+		for x := uint(0); x < 1<<uint(z); x++ {
+			for y := uint(0); y < 1<<uint(z); y++ {
+				t := geo.Tile{X: x, Y: y, Z: z}
+				n := 1 + int(x+y)%4
+				if x == y {
+					n = 2000
+				}
+				tiles[t] = []byte(strings.Repeat(t.String(), n))
+			}
+		}
+	}
+	return tiles
+}
+
+func TestWrite(t *testing.T) {
+	tileset := testTileset()
+	md := Metadata{
+		Name:        "test",
+		Attribution: "test attribution",
+		Format:      "pbf",
+		Compression: "gzip",
+		MinZoom:     0,
+		MaxZoom:     8,
+		Bounds: geo.BBox{
+			Min: geo.LatLong{Latitude: 35, Longitude: 139},
+			Max: geo.LatLong{Latitude: 36, Longitude: 140},
+		},
+		VectorLayers: []VectorLayer{{ID: "points", MaxZoom: 8, Fields: map[string]string{"name": "String"}}},
+	}
+	tests := []struct {
+		name  string
+		write func(w io.Writer) error
+		want  map[string]string
+	}{
+		{
+			"mbtiles",
+			func(w io.Writer) error { return WriteMBTiles(w, md, tileset) },
+			map[string]string{
+				"name":        "test",
+				"attribution": "test attribution",
+				"format":      "pbf",
+				"type":        "overlay",
+				"minzoom":     "0",
+				"maxzoom":     "8",
+				"bounds":      "139,35,140,36",
+				"center":      "139.5,35.5,0",
+				"json":        `{"vector_layers":[{"id":"points","minzoom":0,"maxzoom":8,"fields":{"name":"String"}}]}`,
+			},
+		},
+		{
+			"pmtiles",
+			func(w io.Writer) error { return writePMTiles(w, md, tileset, 1024) },
+			map[string]string{
+				"name":          "test",
+				"attribution":   "test attribution",
+				"format":        "pbf",
+				"type":          "overlay",
+				"vector_layers": `[{"id":"points","minzoom":0,"maxzoom":8,"fields":{"name":"String"}}]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			f, err := ioutil.TempFile("", "tiles")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			err = tt.write(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s, err := Open(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			for tile, want := range tileset {
+				got, err := s.Tile(tile)
+				if err != nil {
+					t.Fatalf("%v: %v", tile, err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Fatalf("%v: want = %d bytes, got = %d bytes", tile, len(want), len(got))
+				}
+			}
+			for _, tile := range []geo.Tile{{X: 0, Y: 0, Z: 9}, {X: 1, Y: 0, Z: 0}} {
+				if _, err := s.Tile(tile); err != ErrNotExist {
+					t.Errorf("%v: want = %v, got = %v", tile, ErrNotExist, err)
+				}
+			}
+			m := s.(interface {
+				Metadata() map[string]string
+				Len() int
+			})
+			if got := m.Metadata(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			if got := m.Len(); got != len(tileset) {
+				t.Errorf("want = %v, got = %v", len(tileset), got)
+			}
+		})
+	}
+}
+
+func TestTileID(t *testing.T) {
+	tests := []struct {
+		tile geo.Tile
+		want uint64
+	}{
+		{geo.Tile{X: 0, Y: 0, Z: 0}, 0},
+		{geo.Tile{X: 0, Y: 0, Z: 1}, 1},
+		{geo.Tile{X: 0, Y: 1, Z: 1}, 2},
+		{geo.Tile{X: 1, Y: 1, Z: 1}, 3},
+		{geo.Tile{X: 1, Y: 0, Z: 1}, 4},
+		{geo.Tile{X: 0, Y: 0, Z: 2}, 5},
+		{geo.Tile{X: 3, Y: 0, Z: 2}, 20},
+		{geo.Tile{X: 0, Y: 0, Z: 3}, 21},
+		// The test vector of the specification.
+		{geo.Tile{X: 3423, Y: 1763, Z: 12}, 19078479},
+	}
+	for _, tt := range tests {
+		if got := tileID(tt.tile); got != tt.want {
+			t.Errorf("%v: want = %v, got = %v", tt.tile, tt.want, got)
+		}
+	}
+}
+
+// TestPMTilesHeader checks the header against its layout in the
+// specification.
+func TestPMTilesHeader(t *testing.T) {
+	h := pmtilesHeader{
+		rootOffset: 127, rootLength: 25,
+		metadataOffset: 152, metadataLength: 247,
+		leafOffset: 399, leafLength: 0,
+		dataOffset: 399, dataLength: 1000,
+		addressedTiles: 10, tileEntries: 8, tileContents: 5,
+		clustered:           true,
+		internalCompression: pmtilesCompressionGzip,
+		tileCompression:     pmtilesCompressionGzip,
+		tileType:            pmtilesTileTypes["pbf"],
+		minZoom:             0,
+		maxZoom:             14,
+		minLong:             1220000000,
+		minLat:              200000000,
+		maxLong:             1540000000,
+		maxLat:              460000000,
+		centerZoom:          0,
+		centerLong:          1380000000,
+		centerLat:           330000000,
+	}
+	want := []byte{
+		// Magic number and version.
+		0x50, 0x4d, 0x54, 0x69, 0x6c, 0x65, 0x73, 0x03,
+		// Offsets and lengths of the root directory, the metadata, the
+		// leaf directories and the tile data.
+		0x7f, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x19, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x98, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xf7, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x8f, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x8f, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xe8, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// Numbers of the addressed tiles, the tile entries and the tile
+		// contents.
+		0x0a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		// Clustered, the internal and the tile compression, the tile type
+		// and the zoom levels.
+		0x01, 0x02, 0x02, 0x01, 0x00, 0x0e,
+		// Bounds.
+		0x00, 0xb9, 0xb7, 0x48,
+		0x00, 0xc2, 0xeb, 0x0b,
+		0x00, 0x89, 0xca, 0x5b,
+		0x00, 0x0b, 0x6b, 0x1b,
+		// Center.
+		0x00,
+		0x00, 0x21, 0x41, 0x52,
+		0x80, 0x66, 0xab, 0x13,
+	}
+	if got := h.encode(); !bytes.Equal(got, want) {
+		t.Errorf("want = %x, got = %x", want, got)
+	}
+	got, err := decodePMTilesHeader(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != h {
+		t.Errorf("want = %+v, got = %+v", h, got)
+	}
+}
+
+// TestPMTilesDirectory checks the directory against its encoding in the
+// specification: the number of entries, then the deltas of the tile IDs,
+// the run lengths, the lengths and the offsets plus one, which are zero for
+// contents following the previous one, as varints.
+func TestPMTilesDirectory(t *testing.T) {
+	entries := []pmtilesEntry{
+		{tileID: 0, offset: 0, length: 100, runLength: 1},
+		{tileID: 1, offset: 100, length: 200, runLength: 1},
+		{tileID: 5, offset: 1000, length: 50, runLength: 3},
+		{tileID: 300, offset: 2000, length: 30, runLength: 0},
+	}
+	want := []byte{
+		0x04,
+		0x00, 0x01, 0x04, 0xa7, 0x02,
+		0x01, 0x01, 0x03, 0x00,
+		0x64, 0xc8, 0x01, 0x32, 0x1e,
+		0x01, 0x00, 0xe9, 0x07, 0xd1, 0x0f,
+	}
+	if got := encodeDirectory(entries); !bytes.Equal(got, want) {
+		t.Errorf("want = %x, got = %x", want, got)
+	}
+	got, err := decodeDirectory(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("want = %v, got = %v", entries, got)
+	}
+}
+
+func TestEncodeRecord(t *testing.T) {
+	values := []interface{}{
+		nil, int64(0), int64(1), int64(-1), int64(300), int64(-1 << 20), int64(1 << 40), int64(-1 << 62),
+		1.5, "芝公園", []byte{1, 2}, strings.Repeat("x", 200),
+	}
+	b, err := encodeRecord(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeRecord(b, len(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("want = %v, got = %v", values, got)
+	}
+}
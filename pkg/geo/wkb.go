@@ -0,0 +1,208 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	wkbPoint        = 1
+	wkbLineString   = 2
+	wkbPolygon      = 3
+	wkbMultiPolygon = 6
+)
+
+// errInvalidWKB is returned when a well-known binary is malformed.
+var errInvalidWKB = errors.New("geo: invalid WKB")
+
+// MarshalWKB returns the well-known binary representation of a geometry in
+// little-endian byte order. A BBox is encoded as a polygon.
+func MarshalWKB(g Geometry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeWKB(&buf, g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeWKB(buf *bytes.Buffer, g Geometry) error {
+	le := binary.LittleEndian
+	writeUint32 := func(n uint32) {
+		var b [4]byte
+		le.PutUint32(b[:], n)
+		buf.Write(b[:])
+	}
+	writePoint := func(p LatLong) {
+		var b [16]byte
+		le.PutUint64(b[0:], math.Float64bits(p.Longitude))
+		le.PutUint64(b[8:], math.Float64bits(p.Latitude))
+		buf.Write(b[:])
+	}
+	writePoints := func(points []LatLong) {
+		writeUint32(uint32(len(points)))
+		for _, p := range points {
+			writePoint(p)
+		}
+	}
+	writePolygon := func(pg Polygon) {
+		writeUint32(uint32(len(pg)))
+		for _, ring := range pg {
+			writePoints(ring)
+		}
+	}
+
+	buf.WriteByte(1)
+	switch g := g.(type) {
+	case LatLong:
+		writeUint32(wkbPoint)
+		writePoint(g)
+	case *LatLong:
+		writeUint32(wkbPoint)
+		writePoint(*g)
+	case LineString:
+		writeUint32(wkbLineString)
+		writePoints(g)
+	case BBox:
+		writeUint32(wkbPolygon)
+		writePolygon(g.Polygon())
+	case Polygon:
+		writeUint32(wkbPolygon)
+		writePolygon(g)
+	case MultiPolygon:
+		writeUint32(wkbMultiPolygon)
+		writeUint32(uint32(len(g)))
+		for _, pg := range g {
+			if err := writeWKB(buf, pg); err != nil {
+				return err
+			}
+		}
+	default:
+		return ErrUnsupportedGeometry
+	}
+	return nil
+}
+
+// UnmarshalWKB parses the well-known binary representation of a point, line
+// string, polygon or multi polygon in either byte order.
+func UnmarshalWKB(b []byte) (Geometry, error) {
+	r := bytes.NewReader(b)
+	g, err := readWKB(r)
+	if err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: unexpected end of data", errInvalidWKB)
+		}
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: unexpected trailing data", errInvalidWKB)
+	}
+	return g, nil
+}
+
+func readWKB(r *bytes.Reader) (Geometry, error) {
+	order, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var bo binary.ByteOrder
+	switch order {
+	case 0:
+		bo = binary.BigEndian
+	case 1:
+		bo = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("%w: byte order %d", errInvalidWKB, order)
+	}
+
+	readUint32 := func() (uint32, error) {
+		var n uint32
+		err := binary.Read(r, bo, &n)
+		return n, err
+	}
+	readPoint := func() (LatLong, error) {
+		var xy [2]float64
+		if err := binary.Read(r, bo, &xy); err != nil {
+			return LatLong{}, err
+		}
+		return LatLong{Latitude: xy[1], Longitude: xy[0]}, nil
+	}
+	readCount := func(size int) (int, error) {
+		n, err := readUint32()
+		if err != nil {
+			return 0, err
+		}
+		// Guard against huge allocations from corrupt counts.
+		if int64(n)*int64(size) > int64(r.Len()) {
+			return 0, fmt.Errorf("%w: count %d exceeds data", errInvalidWKB, n)
+		}
+		return int(n), nil
+	}
+	readPoints := func() ([]LatLong, error) {
+		n, err := readCount(16)
+		if err != nil {
+			return nil, err
+		}
+		points := make([]LatLong, n)
+		for i := range points {
+			if points[i], err = readPoint(); err != nil {
+				return nil, err
+			}
+		}
+		return points, nil
+	}
+	readPolygon := func() (Polygon, error) {
+		n, err := readCount(4)
+		if err != nil {
+			return nil, err
+		}
+		pg := make(Polygon, n)
+		for i := range pg {
+			if pg[i], err = readPoints(); err != nil {
+				return nil, err
+			}
+		}
+		return pg, nil
+	}
+
+	typ, err := readUint32()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case wkbPoint:
+		return readPoint()
+	case wkbLineString:
+		points, err := readPoints()
+		return LineString(points), err
+	case wkbPolygon:
+		return readPolygon()
+	case wkbMultiPolygon:
+		n, err := readCount(9)
+		if err != nil {
+			return nil, err
+		}
+		mp := make(MultiPolygon, n)
+		for i := range mp {
+			g, err := readWKB(r)
+			if err != nil {
+				return nil, err
+			}
+			pg, ok := g.(Polygon)
+			if !ok {
+				return nil, fmt.Errorf("%w: multi polygon contains %T", errInvalidWKB, g)
+			}
+			mp[i] = pg
+		}
+		return mp, nil
+	default:
+		return nil, fmt.Errorf("%w: type %d", ErrUnsupportedGeometry, typ)
+	}
+}
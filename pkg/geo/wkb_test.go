@@ -0,0 +1,73 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalWKB(t *testing.T) {
+	for _, tt := range geometryTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := MarshalWKB(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalWKB(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.in) {
+				t.Errorf("want = %v, got = %v", tt.in, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWKB(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Geometry
+		wantErr bool
+	}{
+		{
+			"little endian",
+			"0101000000000000000000f03f0000000000000040",
+			LatLong{Latitude: 2, Longitude: 1},
+			false,
+		},
+		{
+			"big endian",
+			"00000000013ff00000000000004000000000000000",
+			LatLong{Latitude: 2, Longitude: 1},
+			false,
+		},
+		{"short", "0101000000000000000000f03f", nil, true},
+		{"unsupported", "010400000000000000", nil, true},
+		{"huge count", "0102000000ffffffff", nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := hex.DecodeString(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalWKB(b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error = %v, got = %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
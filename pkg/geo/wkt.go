@@ -0,0 +1,197 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedGeometry is returned when a geometry cannot be encoded or
+// decoded.
+var ErrUnsupportedGeometry = errors.New("geo: unsupported geometry")
+
+// MarshalWKT returns the well-known text representation of a geometry.
+// A BBox is encoded as a polygon.
+func MarshalWKT(g Geometry) (string, error) {
+	var b strings.Builder
+	switch g := g.(type) {
+	case LatLong:
+		b.WriteString("POINT ")
+		writeWKTPoints(&b, []LatLong{g})
+	case *LatLong:
+		b.WriteString("POINT ")
+		writeWKTPoints(&b, []LatLong{*g})
+	case LineString:
+		b.WriteString("LINESTRING ")
+		writeWKTPoints(&b, g)
+	case BBox:
+		b.WriteString("POLYGON ")
+		writeWKTPolygon(&b, g.Polygon())
+	case Polygon:
+		b.WriteString("POLYGON ")
+		writeWKTPolygon(&b, g)
+	case MultiPolygon:
+		b.WriteString("MULTIPOLYGON (")
+		for i, pg := range g {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeWKTPolygon(&b, pg)
+		}
+		b.WriteString(")")
+	default:
+		return "", ErrUnsupportedGeometry
+	}
+	return b.String(), nil
+}
+
+func writeWKTPoints(b *strings.Builder, points []LatLong) {
+	b.WriteString("(")
+	for i, p := range points {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.FormatFloat(p.Longitude, 'f', -1, 64))
+		b.WriteString(" ")
+		b.WriteString(strconv.FormatFloat(p.Latitude, 'f', -1, 64))
+	}
+	b.WriteString(")")
+}
+
+func writeWKTPolygon(b *strings.Builder, pg Polygon) {
+	b.WriteString("(")
+	for i, ring := range pg {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeWKTPoints(b, ring)
+	}
+	b.WriteString(")")
+}
+
+// UnmarshalWKT parses the well-known text representation of a point, line
+// string, polygon or multi polygon.
+func UnmarshalWKT(s string) (Geometry, error) {
+	p := &wktParser{s: s}
+	tag := strings.ToUpper(p.word())
+	var g Geometry
+	var err error
+	switch tag {
+	case "POINT":
+		var points []LatLong
+		points, err = p.points()
+		if err == nil && len(points) != 1 {
+			err = p.errorf("point must have one position")
+		}
+		if err == nil {
+			g = points[0]
+		}
+	case "LINESTRING":
+		var points []LatLong
+		points, err = p.points()
+		g = LineString(points)
+	case "POLYGON":
+		g, err = p.polygon()
+	case "MULTIPOLYGON":
+		var mp MultiPolygon
+		err = p.list(func() error {
+			pg, err := p.polygon()
+			mp = append(mp, pg)
+			return err
+		})
+		g = mp
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedGeometry, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected trailing text")
+	}
+	return g, nil
+}
+
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("geo: invalid WKT at offset %d: %s", p.pos, fmt.Sprintf(format, a...))
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n(),", p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *wktParser) expect(c byte) error {
+	p.skipSpace()
+	if p.pos >= len(p.s) || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// list parses a parenthesized, comma-separated list of items.
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.pos < len(p.s) && p.s[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		return p.expect(')')
+	}
+}
+
+func (p *wktParser) points() ([]LatLong, error) {
+	var points []LatLong
+	err := p.list(func() error {
+		x, err := strconv.ParseFloat(p.word(), 64)
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		y, err := strconv.ParseFloat(p.word(), 64)
+		if err != nil {
+			return p.errorf("%v", err)
+		}
+		points = append(points, LatLong{Latitude: y, Longitude: x})
+		return nil
+	})
+	return points, err
+}
+
+func (p *wktParser) polygon() (Polygon, error) {
+	var pg Polygon
+	err := p.list(func() error {
+		ring, err := p.points()
+		pg = append(pg, ring)
+		return err
+	})
+	return pg, err
+}
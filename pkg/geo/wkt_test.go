@@ -0,0 +1,89 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"reflect"
+	"testing"
+)
+
+var geometryTests = []struct {
+	name    string
+	in      Geometry
+	wantWKT string
+}{
+	{
+		"point",
+		LatLong{Latitude: 35.658584, Longitude: 139.7454316},
+		"POINT (139.7454316 35.658584)",
+	},
+	{
+		"line string",
+		LineString{{Latitude: 1, Longitude: 2}, {Latitude: 3, Longitude: 4}},
+		"LINESTRING (2 1, 4 3)",
+	},
+	{
+		"polygon",
+		squareWithHole,
+		"POLYGON ((0 0, 1 0, 1 1, 0 1, 0 0), (0.25 0.25, 0.25 0.75, 0.75 0.75, 0.75 0.25, 0.25 0.25))",
+	},
+	{
+		"multi polygon",
+		MultiPolygon{squareWithHole[:1], squareWithHole[1:]},
+		"MULTIPOLYGON (((0 0, 1 0, 1 1, 0 1, 0 0)), ((0.25 0.25, 0.25 0.75, 0.75 0.75, 0.75 0.25, 0.25 0.25)))",
+	},
+}
+
+func TestMarshalWKT(t *testing.T) {
+	for _, tt := range geometryTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := MarshalWKT(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.wantWKT {
+				t.Errorf("\nwant = %v\ngot  = %v", tt.wantWKT, got)
+			}
+			g, err := UnmarshalWKT(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g, tt.in) {
+				t.Errorf("want = %v, got = %v", tt.in, g)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWKT(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Geometry
+		wantErr bool
+	}{
+		{"compact", "point(1 2)", LatLong{Latitude: 2, Longitude: 1}, false},
+		{"bbox", "POLYGON((0 0,1 0,1 1,0 1,0 0))", BBox{Max: LatLong{Latitude: 1, Longitude: 1}}.Polygon(), false},
+		{"unsupported", "MULTIPOINT ((1 2))", nil, true},
+		{"unclosed", "LINESTRING (1 2, 3 4", nil, true},
+		{"trailing", "POINT (1 2) x", nil, true},
+		{"not a number", "POINT (a 2)", nil, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := UnmarshalWKT(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error = %v, got = %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+// Tile represents a slippy map tile in the z/x/y scheme used by OSM.
+type Tile struct {
+	X uint
+	Y uint
+	Z int
+}
+
+// LatLongToTile converts latitude and longitude to a tile.
+func LatLongToTile(lat, long float64, zoom int) Tile {
+	pixelX, pixelY := LatLongToPixel(lat, long, zoom)
+	tileX, tileY := PixelToTile(pixelX, pixelY)
+	return Tile{X: tileX, Y: tileY, Z: zoom}
+}
+
+// QuadkeyToXYZ converts a quadkey to a tile.
+func QuadkeyToXYZ(quadkey string) Tile {
+	tileX, tileY, zoom := QuadkeyToTile(quadkey)
+	return Tile{X: tileX, Y: tileY, Z: zoom}
+}
+
+// ParseTile parses a tile in the "z/x/y" form.
+func ParseTile(s string) (Tile, error) {
+	var t Tile
+	if _, err := fmt.Sscanf(s, "%d/%d/%d", &t.Z, &t.X, &t.Y); err != nil {
+		return Tile{}, fmt.Errorf("geo: invalid tile %q: %w", s, err)
+	}
+	if !t.Valid() {
+		return Tile{}, fmt.Errorf("geo: tile out of range: %q", s)
+	}
+	return t, nil
+}
+
+// String returns the tile in the "z/x/y" form.
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Valid reports whether the tile coordinates are within the zoom level.
+func (t Tile) Valid() bool {
+	if t.Z < 0 || t.Z > MaxCellLevel {
+		return false
+	}
+	max := uint(1)<<uint(t.Z) - 1
+	return t.X <= max && t.Y <= max
+}
+
+// Quadkey returns the quadkey of the tile.
+func (t Tile) Quadkey() string {
+	return TileToQuadkey(t.X, t.Y, t.Z)
+}
+
+// CellID returns the cell ID of the tile.
+func (t Tile) CellID() CellID {
+	return TileToCellID(t.X, t.Y, t.Z)
+}
+
+// Bounds returns the bounding box of the tile.
+func (t Tile) Bounds() BBox {
+	var b BBox
+	b.Max.Latitude, b.Min.Longitude = tileCorner(t.X, t.Y, t.Z)
+	b.Min.Latitude, b.Max.Longitude = tileCorner(t.X+1, t.Y+1, t.Z)
+	return b
+}
+
+// tileCorner returns the north-west corner of a tile. Unlike PixelToLatLong,
+// it accepts the coordinates one past the last tile so that the south-east
+// edge of the map can be computed.
+func tileCorner(tileX, tileY uint, zoom int) (lat, long float64) {
+	n := float64(uint(1) << uint(zoom))
+	x := float64(tileX)/n - 0.5
+	y := 0.5 - float64(tileY)/n
+	lat = 90 - 360*math.Atan(math.Exp(-y*2*math.Pi))/math.Pi
+	long = 360 * x
+	return lat, long
+}
+
+// Parent returns the tile one level above.
+func (t Tile) Parent() Tile {
+	if t.Z == 0 {
+		return t
+	}
+	return Tile{X: t.X >> 1, Y: t.Y >> 1, Z: t.Z - 1}
+}
+
+// Children returns the four tiles one level below in quadkey digit order.
+func (t Tile) Children() [4]Tile {
+	x := t.X << 1
+	y := t.Y << 1
+	z := t.Z + 1
+	return [4]Tile{
+		{X: x, Y: y, Z: z},
+		{X: x + 1, Y: y, Z: z},
+		{X: x, Y: y + 1, Z: z},
+		{X: x + 1, Y: y + 1, Z: z},
+	}
+}
+
+// Neighbors returns tiles that are adjacent to the tile, including itself,
+// from north-west to south-east. Tiles beyond the poles are omitted and tiles
+// beyond the antimeridian wrap around.
+func (t Tile) Neighbors() []Tile {
+	n := int64(1) << uint(t.Z)
+	neighbors := make([]Tile, 0, 9)
+	for dy := int64(-1); dy <= 1; dy++ {
+		y := int64(t.Y) + dy
+		if y < 0 || y >= n {
+			continue
+		}
+		for dx := int64(-1); dx <= 1; dx++ {
+			x := ((int64(t.X)+dx)%n + n) % n
+			if dx != 0 && x == int64(t.X) || dx == 1 && n == 2 {
+				// The map is too small to have distinct neighbors.
+				continue
+			}
+			neighbors = append(neighbors, Tile{X: uint(x), Y: uint(y), Z: t.Z})
+		}
+	}
+	return neighbors
+}
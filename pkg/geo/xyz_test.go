@@ -0,0 +1,132 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package geo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLatLongToTile(t *testing.T) {
+	got := LatLongToTile(35.658584, 139.7454316, 23)
+	want := QuadkeyToXYZ("13300211230311333132022")
+	if got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := got.Quadkey(); got != "13300211230311333132022" {
+		t.Errorf("want = %v, got = %v", "13300211230311333132022", got)
+	}
+}
+
+func TestParseTile(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Tile
+		wantErr bool
+	}{
+		{"normal", "3/7/2", Tile{X: 7, Y: 2, Z: 3}, false},
+		{"out of range", "3/8/0", Tile{}, true},
+		{"invalid", "3-7-2", Tile{}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseTile(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error = %v, got = %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+			if err == nil && got.String() != tt.in {
+				t.Errorf("want = %v, got = %v", tt.in, got.String())
+			}
+		})
+	}
+}
+
+func TestTile_Bounds(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Tile
+		want BBox
+	}{
+		{
+			"world",
+			Tile{},
+			BBox{
+				Min: LatLong{Latitude: MinLatitude, Longitude: MinLongitude},
+				Max: LatLong{Latitude: MaxLatitude, Longitude: MaxLongitude},
+			},
+		},
+		{
+			"quarter",
+			Tile{X: 1, Y: 0, Z: 1},
+			BBox{
+				Min: LatLong{Latitude: 0, Longitude: 0},
+				Max: LatLong{Latitude: MaxLatitude, Longitude: MaxLongitude},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.in.Bounds()
+			if math.Abs(got.Min.Latitude-tt.want.Min.Latitude) > 1e-8 ||
+				math.Abs(got.Min.Longitude-tt.want.Min.Longitude) > 1e-8 ||
+				math.Abs(got.Max.Latitude-tt.want.Max.Latitude) > 1e-8 ||
+				math.Abs(got.Max.Longitude-tt.want.Max.Longitude) > 1e-8 {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTile_ParentChildren(t *testing.T) {
+	tile := LatLongToTile(35.658584, 139.7454316, 18)
+	for _, c := range tile.Children() {
+		if got := c.Parent(); got != tile {
+			t.Errorf("want = %v, got = %v", tile, got)
+		}
+	}
+	if got := (Tile{}).Parent(); got != (Tile{}) {
+		t.Errorf("want = %v, got = %v", Tile{}, got)
+	}
+}
+
+func TestTile_Neighbors(t *testing.T) {
+	center := QuadkeyToXYZ("13300211230311333132022")
+	var want []Tile
+	for _, q := range Neighbors("13300211230311333132022", 1) {
+		want = append(want, QuadkeyToXYZ(q))
+	}
+	tests := []struct {
+		name string
+		in   Tile
+		want []Tile
+	}{
+		{"normal", center, want},
+		{"world", Tile{}, []Tile{{}}},
+		{
+			"antimeridian",
+			Tile{X: 0, Y: 0, Z: 1},
+			[]Tile{{X: 1, Y: 0, Z: 1}, {X: 0, Y: 0, Z: 1}, {X: 1, Y: 1, Z: 1}, {X: 0, Y: 1, Z: 1}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := tt.in.Neighbors()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
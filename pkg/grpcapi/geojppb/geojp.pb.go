@@ -0,0 +1,1145 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: geojppb/geojp.proto
+
+package geojppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// LatLong is a position in degrees.
+type LatLong struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Latitude  float64 `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+}
+
+func (x *LatLong) Reset() {
+	*x = LatLong{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LatLong) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LatLong) ProtoMessage() {}
+
+func (x *LatLong) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LatLong.ProtoReflect.Descriptor instead.
+func (*LatLong) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LatLong) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *LatLong) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+// Area is an area of an address.
+type Area struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PrefCode string `protobuf:"bytes,1,opt,name=pref_code,json=prefCode,proto3" json:"pref_code,omitempty"`
+	PrefName string `protobuf:"bytes,2,opt,name=pref_name,json=prefName,proto3" json:"pref_name,omitempty"`
+	CityCode string `protobuf:"bytes,3,opt,name=city_code,json=cityCode,proto3" json:"city_code,omitempty"`
+	CityName string `protobuf:"bytes,4,opt,name=city_name,json=cityName,proto3" json:"city_name,omitempty"`
+	AreaCode string `protobuf:"bytes,5,opt,name=area_code,json=areaCode,proto3" json:"area_code,omitempty"`
+	AreaName string `protobuf:"bytes,6,opt,name=area_name,json=areaName,proto3" json:"area_name,omitempty"`
+	// Position is the representative point of the area.
+	Position *LatLong `protobuf:"bytes,7,opt,name=position,proto3" json:"position,omitempty"`
+	// Distance is the distance in meters from the position of the query, or
+	// zero if the query has no position.
+	Distance float64 `protobuf:"fixed64,8,opt,name=distance,proto3" json:"distance,omitempty"`
+}
+
+func (x *Area) Reset() {
+	*x = Area{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Area) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Area) ProtoMessage() {}
+
+func (x *Area) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Area.ProtoReflect.Descriptor instead.
+func (*Area) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Area) GetPrefCode() string {
+	if x != nil {
+		return x.PrefCode
+	}
+	return ""
+}
+
+func (x *Area) GetPrefName() string {
+	if x != nil {
+		return x.PrefName
+	}
+	return ""
+}
+
+func (x *Area) GetCityCode() string {
+	if x != nil {
+		return x.CityCode
+	}
+	return ""
+}
+
+func (x *Area) GetCityName() string {
+	if x != nil {
+		return x.CityName
+	}
+	return ""
+}
+
+func (x *Area) GetAreaCode() string {
+	if x != nil {
+		return x.AreaCode
+	}
+	return ""
+}
+
+func (x *Area) GetAreaName() string {
+	if x != nil {
+		return x.AreaName
+	}
+	return ""
+}
+
+func (x *Area) GetPosition() *LatLong {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+func (x *Area) GetDistance() float64 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+type GeocodeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Area name to search for. Areas containing the name match.
+	AreaName string `protobuf:"bytes,1,opt,name=area_name,json=areaName,proto3" json:"area_name,omitempty"`
+	// Origin sorts the areas by distance from it if given. Without it, exact
+	// matches come first, then names starting with area_name.
+	Origin *LatLong `protobuf:"bytes,2,opt,name=origin,proto3" json:"origin,omitempty"`
+	// Limit is the maximum number of areas, up to 1000. Zero means 100.
+	Limit int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	// Offset is the number of areas to skip.
+	Offset int32 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+}
+
+func (x *GeocodeRequest) Reset() {
+	*x = GeocodeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GeocodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeocodeRequest) ProtoMessage() {}
+
+func (x *GeocodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeocodeRequest.ProtoReflect.Descriptor instead.
+func (*GeocodeRequest) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GeocodeRequest) GetAreaName() string {
+	if x != nil {
+		return x.AreaName
+	}
+	return ""
+}
+
+func (x *GeocodeRequest) GetOrigin() *LatLong {
+	if x != nil {
+		return x.Origin
+	}
+	return nil
+}
+
+func (x *GeocodeRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GeocodeRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type GeocodeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Areas []*Area `protobuf:"bytes,1,rep,name=areas,proto3" json:"areas,omitempty"`
+	// Total is the number of matching areas of all pages.
+	Total int32 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+}
+
+func (x *GeocodeResponse) Reset() {
+	*x = GeocodeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GeocodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GeocodeResponse) ProtoMessage() {}
+
+func (x *GeocodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GeocodeResponse.ProtoReflect.Descriptor instead.
+func (*GeocodeResponse) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GeocodeResponse) GetAreas() []*Area {
+	if x != nil {
+		return x.Areas
+	}
+	return nil
+}
+
+func (x *GeocodeResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+type ReverseGeocodeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Position *LatLong `protobuf:"bytes,1,opt,name=position,proto3" json:"position,omitempty"`
+}
+
+func (x *ReverseGeocodeRequest) Reset() {
+	*x = ReverseGeocodeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReverseGeocodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReverseGeocodeRequest) ProtoMessage() {}
+
+func (x *ReverseGeocodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReverseGeocodeRequest.ProtoReflect.Descriptor instead.
+func (*ReverseGeocodeRequest) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ReverseGeocodeRequest) GetPosition() *LatLong {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+type ReverseGeocodeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Area *Area `protobuf:"bytes,1,opt,name=area,proto3" json:"area,omitempty"`
+}
+
+func (x *ReverseGeocodeResponse) Reset() {
+	*x = ReverseGeocodeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReverseGeocodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReverseGeocodeResponse) ProtoMessage() {}
+
+func (x *ReverseGeocodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReverseGeocodeResponse.ProtoReflect.Descriptor instead.
+func (*ReverseGeocodeResponse) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ReverseGeocodeResponse) GetArea() *Area {
+	if x != nil {
+		return x.Area
+	}
+	return nil
+}
+
+type NearRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Position *LatLong `protobuf:"bytes,1,opt,name=position,proto3" json:"position,omitempty"`
+	// Zoom is a zoom level from 4 to 23. The areas in the tile at the zoom
+	// level and its neighbors are returned. Either zoom or radius is required.
+	Zoom int32 `protobuf:"varint,2,opt,name=zoom,proto3" json:"zoom,omitempty"`
+	// Radius is a distance in meters. The areas within it are returned.
+	Radius float64 `protobuf:"fixed64,3,opt,name=radius,proto3" json:"radius,omitempty"`
+}
+
+func (x *NearRequest) Reset() {
+	*x = NearRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NearRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearRequest) ProtoMessage() {}
+
+func (x *NearRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearRequest.ProtoReflect.Descriptor instead.
+func (*NearRequest) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NearRequest) GetPosition() *LatLong {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+func (x *NearRequest) GetZoom() int32 {
+	if x != nil {
+		return x.Zoom
+	}
+	return 0
+}
+
+func (x *NearRequest) GetRadius() float64 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+type NearResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Areas sorted by distance.
+	Areas []*Area `protobuf:"bytes,1,rep,name=areas,proto3" json:"areas,omitempty"`
+}
+
+func (x *NearResponse) Reset() {
+	*x = NearResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NearResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearResponse) ProtoMessage() {}
+
+func (x *NearResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearResponse.ProtoReflect.Descriptor instead.
+func (*NearResponse) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *NearResponse) GetAreas() []*Area {
+	if x != nil {
+		return x.Areas
+	}
+	return nil
+}
+
+type BatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// ID is echoed in the response to identify it.
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are assignable to Query:
+	//	*BatchRequest_Geocode
+	//	*BatchRequest_ReverseGeocode
+	//	*BatchRequest_Near
+	Query isBatchRequest_Query `protobuf_oneof:"query"`
+}
+
+func (x *BatchRequest) Reset() {
+	*x = BatchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchRequest) ProtoMessage() {}
+
+func (x *BatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchRequest.ProtoReflect.Descriptor instead.
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BatchRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (m *BatchRequest) GetQuery() isBatchRequest_Query {
+	if m != nil {
+		return m.Query
+	}
+	return nil
+}
+
+func (x *BatchRequest) GetGeocode() *GeocodeRequest {
+	if x, ok := x.GetQuery().(*BatchRequest_Geocode); ok {
+		return x.Geocode
+	}
+	return nil
+}
+
+func (x *BatchRequest) GetReverseGeocode() *ReverseGeocodeRequest {
+	if x, ok := x.GetQuery().(*BatchRequest_ReverseGeocode); ok {
+		return x.ReverseGeocode
+	}
+	return nil
+}
+
+func (x *BatchRequest) GetNear() *NearRequest {
+	if x, ok := x.GetQuery().(*BatchRequest_Near); ok {
+		return x.Near
+	}
+	return nil
+}
+
+type isBatchRequest_Query interface {
+	isBatchRequest_Query()
+}
+
+type BatchRequest_Geocode struct {
+	Geocode *GeocodeRequest `protobuf:"bytes,2,opt,name=geocode,proto3,oneof"`
+}
+
+type BatchRequest_ReverseGeocode struct {
+	ReverseGeocode *ReverseGeocodeRequest `protobuf:"bytes,3,opt,name=reverse_geocode,json=reverseGeocode,proto3,oneof"`
+}
+
+type BatchRequest_Near struct {
+	Near *NearRequest `protobuf:"bytes,4,opt,name=near,proto3,oneof"`
+}
+
+func (*BatchRequest_Geocode) isBatchRequest_Query() {}
+
+func (*BatchRequest_ReverseGeocode) isBatchRequest_Query() {}
+
+func (*BatchRequest_Near) isBatchRequest_Query() {}
+
+type BatchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Types that are assignable to Result:
+	//	*BatchResponse_Geocode
+	//	*BatchResponse_ReverseGeocode
+	//	*BatchResponse_Near
+	//	*BatchResponse_Error
+	Result isBatchResponse_Result `protobuf_oneof:"result"`
+}
+
+func (x *BatchResponse) Reset() {
+	*x = BatchResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchResponse) ProtoMessage() {}
+
+func (x *BatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchResponse.ProtoReflect.Descriptor instead.
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *BatchResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (m *BatchResponse) GetResult() isBatchResponse_Result {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetGeocode() *GeocodeResponse {
+	if x, ok := x.GetResult().(*BatchResponse_Geocode); ok {
+		return x.Geocode
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetReverseGeocode() *ReverseGeocodeResponse {
+	if x, ok := x.GetResult().(*BatchResponse_ReverseGeocode); ok {
+		return x.ReverseGeocode
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetNear() *NearResponse {
+	if x, ok := x.GetResult().(*BatchResponse_Near); ok {
+		return x.Near
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetError() *Error {
+	if x, ok := x.GetResult().(*BatchResponse_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+type isBatchResponse_Result interface {
+	isBatchResponse_Result()
+}
+
+type BatchResponse_Geocode struct {
+	Geocode *GeocodeResponse `protobuf:"bytes,2,opt,name=geocode,proto3,oneof"`
+}
+
+type BatchResponse_ReverseGeocode struct {
+	ReverseGeocode *ReverseGeocodeResponse `protobuf:"bytes,3,opt,name=reverse_geocode,json=reverseGeocode,proto3,oneof"`
+}
+
+type BatchResponse_Near struct {
+	Near *NearResponse `protobuf:"bytes,4,opt,name=near,proto3,oneof"`
+}
+
+type BatchResponse_Error struct {
+	// Error is set if the query failed.
+	Error *Error `protobuf:"bytes,5,opt,name=error,proto3,oneof"`
+}
+
+func (*BatchResponse_Geocode) isBatchResponse_Result() {}
+
+func (*BatchResponse_ReverseGeocode) isBatchResponse_Result() {}
+
+func (*BatchResponse_Near) isBatchResponse_Result() {}
+
+func (*BatchResponse_Error) isBatchResponse_Result() {}
+
+// Error is the error of a query in a batch.
+type Error struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Code is a gRPC status code.
+	Code    int32  `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_geojppb_geojp_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_geojppb_geojp_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_geojppb_geojp_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Error) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_geojppb_geojp_proto protoreflect.FileDescriptor
+
+var file_geojppb_geojp_proto_rawDesc = []byte{
+	0x0a, 0x13, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x70, 0x62, 0x2f, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x22,
+	0x43, 0x0a, 0x07, 0x4c, 0x61, 0x74, 0x4c, 0x6f, 0x6e, 0x67, 0x12, 0x1a, 0x0a, 0x08, 0x6c, 0x61,
+	0x74, 0x69, 0x74, 0x75, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x6c, 0x61,
+	0x74, 0x69, 0x74, 0x75, 0x64, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6c, 0x6f, 0x6e, 0x67, 0x69, 0x74,
+	0x75, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x6c, 0x6f, 0x6e, 0x67, 0x69,
+	0x74, 0x75, 0x64, 0x65, 0x22, 0xff, 0x01, 0x0a, 0x04, 0x41, 0x72, 0x65, 0x61, 0x12, 0x1b, 0x0a,
+	0x09, 0x70, 0x72, 0x65, 0x66, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x08, 0x70, 0x72, 0x65, 0x66, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x72,
+	0x65, 0x66, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70,
+	0x72, 0x65, 0x66, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x69, 0x74, 0x79, 0x5f,
+	0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x69, 0x74, 0x79,
+	0x43, 0x6f, 0x64, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x63, 0x69, 0x74, 0x79, 0x5f, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x63, 0x69, 0x74, 0x79, 0x4e, 0x61, 0x6d,
+	0x65, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x72, 0x65, 0x61, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x72, 0x65, 0x61, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x1b,
+	0x0a, 0x09, 0x61, 0x72, 0x65, 0x61, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x61, 0x72, 0x65, 0x61, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x2d, 0x0a, 0x08, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e,
+	0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x61, 0x74, 0x4c, 0x6f, 0x6e, 0x67,
+	0x52, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x64, 0x69,
+	0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x64, 0x69,
+	0x73, 0x74, 0x61, 0x6e, 0x63, 0x65, 0x22, 0x86, 0x01, 0x0a, 0x0e, 0x47, 0x65, 0x6f, 0x63, 0x6f,
+	0x64, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x72, 0x65,
+	0x61, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x72,
+	0x65, 0x61, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x29, 0x0a, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76,
+	0x31, 0x2e, 0x4c, 0x61, 0x74, 0x4c, 0x6f, 0x6e, 0x67, 0x52, 0x06, 0x6f, 0x72, 0x69, 0x67, 0x69,
+	0x6e, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65,
+	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x22,
+	0x4d, 0x0a, 0x0f, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x24, 0x0a, 0x05, 0x61, 0x72, 0x65, 0x61, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x0e, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x65,
+	0x61, 0x52, 0x05, 0x61, 0x72, 0x65, 0x61, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61,
+	0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x22, 0x46,
+	0x0a, 0x15, 0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2d, 0x0a, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x67, 0x65, 0x6f, 0x6a,
+	0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x61, 0x74, 0x4c, 0x6f, 0x6e, 0x67, 0x52, 0x08, 0x70, 0x6f,
+	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x3c, 0x0a, 0x16, 0x52, 0x65, 0x76, 0x65, 0x72, 0x73,
+	0x65, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x22, 0x0a, 0x04, 0x61, 0x72, 0x65, 0x61, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e,
+	0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x65, 0x61, 0x52, 0x04,
+	0x61, 0x72, 0x65, 0x61, 0x22, 0x68, 0x0a, 0x0b, 0x4e, 0x65, 0x61, 0x72, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x2d, 0x0a, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31,
+	0x2e, 0x4c, 0x61, 0x74, 0x4c, 0x6f, 0x6e, 0x67, 0x52, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x7a, 0x6f, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x04, 0x7a, 0x6f, 0x6f, 0x6d, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x61, 0x64, 0x69, 0x75, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x72, 0x61, 0x64, 0x69, 0x75, 0x73, 0x22, 0x34,
+	0x0a, 0x0c, 0x4e, 0x65, 0x61, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24,
+	0x0a, 0x05, 0x61, 0x72, 0x65, 0x61, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e,
+	0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x72, 0x65, 0x61, 0x52, 0x05, 0x61,
+	0x72, 0x65, 0x61, 0x73, 0x22, 0xd6, 0x01, 0x0a, 0x0c, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x34, 0x0a, 0x07, 0x67, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76,
+	0x31, 0x2e, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x48, 0x00, 0x52, 0x07, 0x67, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x4a, 0x0a, 0x0f, 0x72,
+	0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x5f, 0x67, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e,
+	0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x0e, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65,
+	0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x2b, 0x0a, 0x04, 0x6e, 0x65, 0x61, 0x72, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31,
+	0x2e, 0x4e, 0x65, 0x61, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x48, 0x00, 0x52, 0x04,
+	0x6e, 0x65, 0x61, 0x72, 0x42, 0x07, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x22, 0x84, 0x02,
+	0x0a, 0x0d, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x35, 0x0a, 0x07, 0x67, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x19, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x6f, 0x63,
+	0x6f, 0x64, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x07, 0x67,
+	0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x4b, 0x0a, 0x0f, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73,
+	0x65, 0x5f, 0x67, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x20, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x76, 0x65, 0x72,
+	0x73, 0x65, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x48, 0x00, 0x52, 0x0e, 0x72, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x47, 0x65, 0x6f, 0x63,
+	0x6f, 0x64, 0x65, 0x12, 0x2c, 0x0a, 0x04, 0x6e, 0x65, 0x61, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x16, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65, 0x61,
+	0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x04, 0x6e, 0x65, 0x61,
+	0x72, 0x12, 0x27, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0f, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x72, 0x72, 0x6f,
+	0x72, 0x48, 0x00, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x22, 0x35, 0x0a, 0x05, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x12, 0x0a,
+	0x04, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x63, 0x6f, 0x64,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x32, 0x91, 0x02, 0x0a, 0x05,
+	0x47, 0x65, 0x6f, 0x4a, 0x50, 0x12, 0x3e, 0x0a, 0x07, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65,
+	0x12, 0x18, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x6f, 0x63,
+	0x6f, 0x64, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x67, 0x65, 0x6f,
+	0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x53, 0x0a, 0x0e, 0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65,
+	0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x1f, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x47, 0x65, 0x6f, 0x63, 0x6f, 0x64,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x76, 0x65, 0x72, 0x73, 0x65, 0x47, 0x65, 0x6f, 0x63, 0x6f,
+	0x64, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x35, 0x0a, 0x04, 0x4e, 0x65,
+	0x61, 0x72, 0x12, 0x15, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65,
+	0x61, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x67, 0x65, 0x6f, 0x6a,
+	0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4e, 0x65, 0x61, 0x72, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x3c, 0x0a, 0x05, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x16, 0x2e, 0x67, 0x65, 0x6f,
+	0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x17, 0x2e, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42,
+	0x31, 0x5a, 0x2f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x74, 0x77,
+	0x69, 0x68, 0x69, 0x6b, 0x65, 0x2f, 0x67, 0x6f, 0x2d, 0x67, 0x65, 0x6f, 0x6a, 0x70, 0x2f, 0x70,
+	0x6b, 0x67, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x65, 0x6f, 0x6a, 0x70,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_geojppb_geojp_proto_rawDescOnce sync.Once
+	file_geojppb_geojp_proto_rawDescData = file_geojppb_geojp_proto_rawDesc
+)
+
+func file_geojppb_geojp_proto_rawDescGZIP() []byte {
+	file_geojppb_geojp_proto_rawDescOnce.Do(func() {
+		file_geojppb_geojp_proto_rawDescData = protoimpl.X.CompressGZIP(file_geojppb_geojp_proto_rawDescData)
+	})
+	return file_geojppb_geojp_proto_rawDescData
+}
+
+var file_geojppb_geojp_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_geojppb_geojp_proto_goTypes = []interface{}{
+	(*LatLong)(nil),                // 0: geojp.v1.LatLong
+	(*Area)(nil),                   // 1: geojp.v1.Area
+	(*GeocodeRequest)(nil),         // 2: geojp.v1.GeocodeRequest
+	(*GeocodeResponse)(nil),        // 3: geojp.v1.GeocodeResponse
+	(*ReverseGeocodeRequest)(nil),  // 4: geojp.v1.ReverseGeocodeRequest
+	(*ReverseGeocodeResponse)(nil), // 5: geojp.v1.ReverseGeocodeResponse
+	(*NearRequest)(nil),            // 6: geojp.v1.NearRequest
+	(*NearResponse)(nil),           // 7: geojp.v1.NearResponse
+	(*BatchRequest)(nil),           // 8: geojp.v1.BatchRequest
+	(*BatchResponse)(nil),          // 9: geojp.v1.BatchResponse
+	(*Error)(nil),                  // 10: geojp.v1.Error
+}
+var file_geojppb_geojp_proto_depIdxs = []int32{
+	0,  // 0: geojp.v1.Area.position:type_name -> geojp.v1.LatLong
+	0,  // 1: geojp.v1.GeocodeRequest.origin:type_name -> geojp.v1.LatLong
+	1,  // 2: geojp.v1.GeocodeResponse.areas:type_name -> geojp.v1.Area
+	0,  // 3: geojp.v1.ReverseGeocodeRequest.position:type_name -> geojp.v1.LatLong
+	1,  // 4: geojp.v1.ReverseGeocodeResponse.area:type_name -> geojp.v1.Area
+	0,  // 5: geojp.v1.NearRequest.position:type_name -> geojp.v1.LatLong
+	1,  // 6: geojp.v1.NearResponse.areas:type_name -> geojp.v1.Area
+	2,  // 7: geojp.v1.BatchRequest.geocode:type_name -> geojp.v1.GeocodeRequest
+	4,  // 8: geojp.v1.BatchRequest.reverse_geocode:type_name -> geojp.v1.ReverseGeocodeRequest
+	6,  // 9: geojp.v1.BatchRequest.near:type_name -> geojp.v1.NearRequest
+	3,  // 10: geojp.v1.BatchResponse.geocode:type_name -> geojp.v1.GeocodeResponse
+	5,  // 11: geojp.v1.BatchResponse.reverse_geocode:type_name -> geojp.v1.ReverseGeocodeResponse
+	7,  // 12: geojp.v1.BatchResponse.near:type_name -> geojp.v1.NearResponse
+	10, // 13: geojp.v1.BatchResponse.error:type_name -> geojp.v1.Error
+	2,  // 14: geojp.v1.GeoJP.Geocode:input_type -> geojp.v1.GeocodeRequest
+	4,  // 15: geojp.v1.GeoJP.ReverseGeocode:input_type -> geojp.v1.ReverseGeocodeRequest
+	6,  // 16: geojp.v1.GeoJP.Near:input_type -> geojp.v1.NearRequest
+	8,  // 17: geojp.v1.GeoJP.Batch:input_type -> geojp.v1.BatchRequest
+	3,  // 18: geojp.v1.GeoJP.Geocode:output_type -> geojp.v1.GeocodeResponse
+	5,  // 19: geojp.v1.GeoJP.ReverseGeocode:output_type -> geojp.v1.ReverseGeocodeResponse
+	7,  // 20: geojp.v1.GeoJP.Near:output_type -> geojp.v1.NearResponse
+	9,  // 21: geojp.v1.GeoJP.Batch:output_type -> geojp.v1.BatchResponse
+	18, // [18:22] is the sub-list for method output_type
+	14, // [14:18] is the sub-list for method input_type
+	14, // [14:14] is the sub-list for extension type_name
+	14, // [14:14] is the sub-list for extension extendee
+	0,  // [0:14] is the sub-list for field type_name
+}
+
+func init() { file_geojppb_geojp_proto_init() }
+func file_geojppb_geojp_proto_init() {
+	if File_geojppb_geojp_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_geojppb_geojp_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LatLong); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Area); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GeocodeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GeocodeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReverseGeocodeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReverseGeocodeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NearRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NearResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_geojppb_geojp_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Error); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_geojppb_geojp_proto_msgTypes[8].OneofWrappers = []interface{}{
+		(*BatchRequest_Geocode)(nil),
+		(*BatchRequest_ReverseGeocode)(nil),
+		(*BatchRequest_Near)(nil),
+	}
+	file_geojppb_geojp_proto_msgTypes[9].OneofWrappers = []interface{}{
+		(*BatchResponse_Geocode)(nil),
+		(*BatchResponse_ReverseGeocode)(nil),
+		(*BatchResponse_Near)(nil),
+		(*BatchResponse_Error)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_geojppb_geojp_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_geojppb_geojp_proto_goTypes,
+		DependencyIndexes: file_geojppb_geojp_proto_depIdxs,
+		MessageInfos:      file_geojppb_geojp_proto_msgTypes,
+	}.Build()
+	File_geojppb_geojp_proto = out.File
+	file_geojppb_geojp_proto_rawDesc = nil
+	file_geojppb_geojp_proto_goTypes = nil
+	file_geojppb_geojp_proto_depIdxs = nil
+}
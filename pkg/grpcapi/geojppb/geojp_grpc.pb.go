@@ -0,0 +1,252 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package geojppb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// GeoJPClient is the client API for GeoJP service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type GeoJPClient interface {
+	// Geocode finds areas by name.
+	Geocode(ctx context.Context, in *GeocodeRequest, opts ...grpc.CallOption) (*GeocodeResponse, error)
+	// ReverseGeocode finds the area nearest to a position.
+	ReverseGeocode(ctx context.Context, in *ReverseGeocodeRequest, opts ...grpc.CallOption) (*ReverseGeocodeResponse, error)
+	// Near finds the areas around a position.
+	Near(ctx context.Context, in *NearRequest, opts ...grpc.CallOption) (*NearResponse, error)
+	// Batch answers a stream of queries in order. Each response has the ID of
+	// its request, and a failed query does not end the stream.
+	Batch(ctx context.Context, opts ...grpc.CallOption) (GeoJP_BatchClient, error)
+}
+
+type geoJPClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewGeoJPClient(cc grpc.ClientConnInterface) GeoJPClient {
+	return &geoJPClient{cc}
+}
+
+func (c *geoJPClient) Geocode(ctx context.Context, in *GeocodeRequest, opts ...grpc.CallOption) (*GeocodeResponse, error) {
+	out := new(GeocodeResponse)
+	err := c.cc.Invoke(ctx, "/geojp.v1.GeoJP/Geocode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoJPClient) ReverseGeocode(ctx context.Context, in *ReverseGeocodeRequest, opts ...grpc.CallOption) (*ReverseGeocodeResponse, error) {
+	out := new(ReverseGeocodeResponse)
+	err := c.cc.Invoke(ctx, "/geojp.v1.GeoJP/ReverseGeocode", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoJPClient) Near(ctx context.Context, in *NearRequest, opts ...grpc.CallOption) (*NearResponse, error) {
+	out := new(NearResponse)
+	err := c.cc.Invoke(ctx, "/geojp.v1.GeoJP/Near", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *geoJPClient) Batch(ctx context.Context, opts ...grpc.CallOption) (GeoJP_BatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &GeoJP_ServiceDesc.Streams[0], "/geojp.v1.GeoJP/Batch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &geoJPBatchClient{stream}
+	return x, nil
+}
+
+type GeoJP_BatchClient interface {
+	Send(*BatchRequest) error
+	Recv() (*BatchResponse, error)
+	grpc.ClientStream
+}
+
+type geoJPBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *geoJPBatchClient) Send(m *BatchRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *geoJPBatchClient) Recv() (*BatchResponse, error) {
+	m := new(BatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GeoJPServer is the server API for GeoJP service.
+// All implementations must embed UnimplementedGeoJPServer
+// for forward compatibility
+type GeoJPServer interface {
+	// Geocode finds areas by name.
+	Geocode(context.Context, *GeocodeRequest) (*GeocodeResponse, error)
+	// ReverseGeocode finds the area nearest to a position.
+	ReverseGeocode(context.Context, *ReverseGeocodeRequest) (*ReverseGeocodeResponse, error)
+	// Near finds the areas around a position.
+	Near(context.Context, *NearRequest) (*NearResponse, error)
+	// Batch answers a stream of queries in order. Each response has the ID of
+	// its request, and a failed query does not end the stream.
+	Batch(GeoJP_BatchServer) error
+	mustEmbedUnimplementedGeoJPServer()
+}
+
+// UnimplementedGeoJPServer must be embedded to have forward compatible implementations.
+type UnimplementedGeoJPServer struct {
+}
+
+func (UnimplementedGeoJPServer) Geocode(context.Context, *GeocodeRequest) (*GeocodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Geocode not implemented")
+}
+func (UnimplementedGeoJPServer) ReverseGeocode(context.Context, *ReverseGeocodeRequest) (*ReverseGeocodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReverseGeocode not implemented")
+}
+func (UnimplementedGeoJPServer) Near(context.Context, *NearRequest) (*NearResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Near not implemented")
+}
+func (UnimplementedGeoJPServer) Batch(GeoJP_BatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (UnimplementedGeoJPServer) mustEmbedUnimplementedGeoJPServer() {}
+
+// UnsafeGeoJPServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to GeoJPServer will
+// result in compilation errors.
+type UnsafeGeoJPServer interface {
+	mustEmbedUnimplementedGeoJPServer()
+}
+
+func RegisterGeoJPServer(s grpc.ServiceRegistrar, srv GeoJPServer) {
+	s.RegisterService(&GeoJP_ServiceDesc, srv)
+}
+
+func _GeoJP_Geocode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GeocodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoJPServer).Geocode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/geojp.v1.GeoJP/Geocode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoJPServer).Geocode(ctx, req.(*GeocodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoJP_ReverseGeocode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReverseGeocodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoJPServer).ReverseGeocode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/geojp.v1.GeoJP/ReverseGeocode",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoJPServer).ReverseGeocode(ctx, req.(*ReverseGeocodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoJP_Near_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GeoJPServer).Near(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/geojp.v1.GeoJP/Near",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GeoJPServer).Near(ctx, req.(*NearRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _GeoJP_Batch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GeoJPServer).Batch(&geoJPBatchServer{stream})
+}
+
+type GeoJP_BatchServer interface {
+	Send(*BatchResponse) error
+	Recv() (*BatchRequest, error)
+	grpc.ServerStream
+}
+
+type geoJPBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *geoJPBatchServer) Send(m *BatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *geoJPBatchServer) Recv() (*BatchRequest, error) {
+	m := new(BatchRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GeoJP_ServiceDesc is the grpc.ServiceDesc for GeoJP service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var GeoJP_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "geojp.v1.GeoJP",
+	HandlerType: (*GeoJPServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Geocode",
+			Handler:    _GeoJP_Geocode_Handler,
+		},
+		{
+			MethodName: "ReverseGeocode",
+			Handler:    _GeoJP_ReverseGeocode_Handler,
+		},
+		{
+			MethodName: "Near",
+			Handler:    _GeoJP_Near_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Batch",
+			Handler:       _GeoJP_Batch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "geojppb/geojp.proto",
+}
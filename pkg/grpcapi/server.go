@@ -0,0 +1,195 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+// Package grpcapi serves the geocoding API over gRPC.
+package grpcapi
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative geojppb/geojp.proto
+
+import (
+	"context"
+	"io"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/grpcapi/geojppb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	minZoomLevel = 4
+	maxZoomLevel = 23
+)
+
+// Server implements the GeoJP service.
+type Server struct {
+	geojppb.UnimplementedGeoJPServer
+	aps  jp.AddressPositions
+	iaps jp.APIndex
+}
+
+// NewServer returns a server that answers queries from the dataset and its
+// index.
+func NewServer(aps jp.AddressPositions, iaps jp.APIndex) *Server {
+	return &Server{aps: aps, iaps: iaps}
+}
+
+// Geocode finds a page of areas by name, sorted by distance from the origin
+// if given and by relevance otherwise. The page has jp.DefaultSearchLimit
+// areas unless the request has a limit.
+func (s *Server) Geocode(ctx context.Context, req *geojppb.GeocodeRequest) (*geojppb.GeocodeResponse, error) {
+	if req.GetAreaName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "area_name is required")
+	}
+	model := s.iaps.Model()
+	opts := jp.SearchOptions{
+		Model:  &model,
+		Limit:  int(req.GetLimit()),
+		Offset: int(req.GetOffset()),
+	}
+	switch {
+	case opts.Limit == 0:
+		opts.Limit = jp.DefaultSearchLimit
+	case opts.Limit < 0 || opts.Limit > jp.MaxSearchLimit:
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be from 1 to %d", jp.MaxSearchLimit)
+	}
+	if opts.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset must not be negative")
+	}
+	if req.GetOrigin() != nil {
+		origin, err := position(req.GetOrigin(), "origin")
+		if err != nil {
+			return nil, err
+		}
+		opts.Origin = &origin
+	}
+	found, err := s.aps.Search(req.GetAreaName(), opts)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	res := &geojppb.GeocodeResponse{
+		Areas: make([]*geojppb.Area, len(found.Areas)),
+		Total: int32(found.Total),
+	}
+	for i, ap := range found.Areas {
+		res.Areas[i] = newArea(ap)
+	}
+	return res, nil
+}
+
+// ReverseGeocode finds the area nearest to the position.
+func (s *Server) ReverseGeocode(ctx context.Context, req *geojppb.ReverseGeocodeRequest) (*geojppb.ReverseGeocodeResponse, error) {
+	p, err := position(req.GetPosition(), "position")
+	if err != nil {
+		return nil, err
+	}
+	if len(s.aps) == 0 {
+		return nil, status.Error(codes.NotFound, "no areas")
+	}
+	return &geojppb.ReverseGeocodeResponse{Area: newArea(s.iaps.Nearest(p))}, nil
+}
+
+// Near finds the areas in the tiles around the position or within the
+// radius, sorted by distance.
+func (s *Server) Near(ctx context.Context, req *geojppb.NearRequest) (*geojppb.NearResponse, error) {
+	p, err := position(req.GetPosition(), "position")
+	if err != nil {
+		return nil, err
+	}
+	zoom, radius := int(req.GetZoom()), req.GetRadius()
+	var aps []jp.NearbyAP
+	switch {
+	case zoom != 0 && radius != 0:
+		return nil, status.Error(codes.InvalidArgument, "either zoom or radius is allowed")
+	case zoom != 0:
+		if zoom < minZoomLevel || zoom > maxZoomLevel {
+			return nil, status.Errorf(codes.InvalidArgument, "zoom must be from %d to %d", minZoomLevel, maxZoomLevel)
+		}
+		aps = s.iaps.Near(p, zoom)
+	case radius != 0:
+		if radius < 0 || radius > jp.MaxNearRadius {
+			return nil, status.Errorf(codes.InvalidArgument, "radius must be from 0 to %d", jp.MaxNearRadius)
+		}
+		aps = s.iaps.WithinRadius(p, radius)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "zoom or radius is required")
+	}
+	res := &geojppb.NearResponse{Areas: make([]*geojppb.Area, len(aps))}
+	for i, ap := range aps {
+		res.Areas[i] = newArea(ap)
+	}
+	return res, nil
+}
+
+// Batch answers the queries of the stream in order until the client closes
+// it.
+func (s *Server) Batch(stream geojppb.GeoJP_BatchServer) error {
+	ctx := stream.Context()
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		res := &geojppb.BatchResponse{Id: req.GetId()}
+		switch q := req.GetQuery().(type) {
+		case *geojppb.BatchRequest_Geocode:
+			var r *geojppb.GeocodeResponse
+			if r, err = s.Geocode(ctx, q.Geocode); err == nil {
+				res.Result = &geojppb.BatchResponse_Geocode{Geocode: r}
+			}
+		case *geojppb.BatchRequest_ReverseGeocode:
+			var r *geojppb.ReverseGeocodeResponse
+			if r, err = s.ReverseGeocode(ctx, q.ReverseGeocode); err == nil {
+				res.Result = &geojppb.BatchResponse_ReverseGeocode{ReverseGeocode: r}
+			}
+		case *geojppb.BatchRequest_Near:
+			var r *geojppb.NearResponse
+			if r, err = s.Near(ctx, q.Near); err == nil {
+				res.Result = &geojppb.BatchResponse_Near{Near: r}
+			}
+		default:
+			err = status.Error(codes.InvalidArgument, "query is required")
+		}
+		if err != nil {
+			st := status.Convert(err)
+			res.Result = &geojppb.BatchResponse_Error{Error: &geojppb.Error{
+				Code:    int32(st.Code()),
+				Message: st.Message(),
+			}}
+		}
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+// position converts and validates a position of a request.
+func position(p *geojppb.LatLong, name string) (geo.LatLong, error) {
+	if p == nil {
+		return geo.LatLong{}, status.Errorf(codes.InvalidArgument, "%s is required", name)
+	}
+	lat, long := p.GetLatitude(), p.GetLongitude()
+	// The negated form also rejects NaN.
+	if !(lat >= -90 && lat <= 90 && long >= -180 && long <= 180) {
+		return geo.LatLong{}, status.Errorf(codes.InvalidArgument, "%s is out of range", name)
+	}
+	return geo.LatLong{Latitude: lat, Longitude: long}, nil
+}
+
+func newArea(ap jp.NearbyAP) *geojppb.Area {
+	return &geojppb.Area{
+		PrefCode: ap.PrefCode,
+		PrefName: ap.PrefName,
+		CityCode: ap.CityCode,
+		CityName: ap.CityName,
+		AreaCode: ap.AreaCode,
+		AreaName: ap.AreaName,
+		Position: &geojppb.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude},
+		Distance: ap.Distance,
+	}
+}
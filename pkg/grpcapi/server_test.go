@@ -0,0 +1,245 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/grpcapi/geojppb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var shibakoen = &geojppb.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+
+// newTestClient serves the test dataset over an in-memory connection.
+func newTestClient(t *testing.T) (geojppb.GeoJPClient, func()) {
+	t.Helper()
+	aps, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	geojppb.RegisterGeoJPServer(s, NewServer(aps, jp.CreateAPIndex(aps, geo.Spherical)))
+	go s.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return geojppb.NewGeoJPClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestGeocode(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		name     string
+		req      *geojppb.GeocodeRequest
+		wantCode codes.Code
+		want     []string
+		wantDist bool
+	}{
+		{"origin", &geojppb.GeocodeRequest{AreaName: "芝公園", Origin: shibakoen}, codes.OK,
+			[]string{"芝公園三丁目", "芝公園四丁目", "芝公園一丁目", "芝公園二丁目"}, true},
+		{"no origin", &geojppb.GeocodeRequest{AreaName: "芝公園三丁目"}, codes.OK,
+			[]string{"芝公園三丁目"}, false},
+		{"limit", &geojppb.GeocodeRequest{AreaName: "芝公園", Origin: shibakoen, Limit: 2}, codes.OK,
+			[]string{"芝公園三丁目", "芝公園四丁目"}, true},
+		{"offset", &geojppb.GeocodeRequest{AreaName: "芝公園", Origin: shibakoen, Offset: 3}, codes.OK,
+			[]string{"芝公園二丁目"}, true},
+		{"limit over max", &geojppb.GeocodeRequest{AreaName: "芝公園", Limit: jp.MaxSearchLimit + 1},
+			codes.InvalidArgument, nil, false},
+		{"negative limit", &geojppb.GeocodeRequest{AreaName: "芝公園", Limit: -1}, codes.InvalidArgument, nil, false},
+		{"negative offset", &geojppb.GeocodeRequest{AreaName: "芝公園", Offset: -1}, codes.InvalidArgument, nil, false},
+		{"no match", &geojppb.GeocodeRequest{AreaName: "存在しない"}, codes.OK, nil, false},
+		{"no name", &geojppb.GeocodeRequest{}, codes.InvalidArgument, nil, false},
+		{"invalid origin", &geojppb.GeocodeRequest{AreaName: "芝", Origin: &geojppb.LatLong{Latitude: 91}},
+			codes.InvalidArgument, nil, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := client.Geocode(context.Background(), tt.req)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("want = %v, got = %v", tt.wantCode, got)
+			}
+			if err != nil {
+				return
+			}
+			if tt.req.GetLimit() > 0 || tt.req.GetOffset() > 0 {
+				if got := res.GetTotal(); got != 4 {
+					t.Errorf("want = %v, got = %v", 4, got)
+				}
+			}
+			var got []string
+			for _, a := range res.GetAreas() {
+				got = append(got, a.GetAreaName())
+				if (a.GetDistance() > 0) != tt.wantDist {
+					t.Errorf("want = %v, got = %v", tt.wantDist, a.GetDistance())
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("want = %v, got = %v", tt.want, got)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("want = %v, got = %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestReverseGeocode(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	res, err := client.ReverseGeocode(context.Background(), &geojppb.ReverseGeocodeRequest{Position: shibakoen})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := res.GetArea()
+	if a.GetAreaCode() != "131030002003" || a.GetAreaName() != "芝公園三丁目" || a.GetCityName() != "港区" {
+		t.Errorf("want = %v, got = %v", "131030002003 芝公園三丁目", a)
+	}
+	if got, want := a.GetDistance(), 220.37123693585445; math.Abs(got-want) > 1e-6 {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+
+	for _, p := range []*geojppb.LatLong{nil, {Latitude: 0, Longitude: 181}, {Latitude: math.NaN()}} {
+		_, err := client.ReverseGeocode(context.Background(), &geojppb.ReverseGeocodeRequest{Position: p})
+		if got := status.Code(err); got != codes.InvalidArgument {
+			t.Errorf("%v: want = %v, got = %v", p, codes.InvalidArgument, got)
+		}
+	}
+}
+
+func TestNear(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		name     string
+		req      *geojppb.NearRequest
+		wantCode codes.Code
+		wantMin  int
+		maxDist  float64
+	}{
+		{"zoom", &geojppb.NearRequest{Position: shibakoen, Zoom: 15}, codes.OK, 1, math.Inf(1)},
+		{"radius", &geojppb.NearRequest{Position: shibakoen, Radius: 500}, codes.OK, 1, 500},
+		{"both", &geojppb.NearRequest{Position: shibakoen, Zoom: 15, Radius: 500}, codes.InvalidArgument, 0, 0},
+		{"neither", &geojppb.NearRequest{Position: shibakoen}, codes.InvalidArgument, 0, 0},
+		{"zoom out of range", &geojppb.NearRequest{Position: shibakoen, Zoom: 24}, codes.InvalidArgument, 0, 0},
+		{"radius too large", &geojppb.NearRequest{Position: shibakoen, Radius: jp.MaxNearRadius + 1}, codes.InvalidArgument, 0, 0},
+		{"no position", &geojppb.NearRequest{Zoom: 15}, codes.InvalidArgument, 0, 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := client.Near(context.Background(), tt.req)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Fatalf("want = %v, got = %v", tt.wantCode, got)
+			}
+			if err != nil {
+				return
+			}
+			areas := res.GetAreas()
+			if len(areas) < tt.wantMin {
+				t.Errorf("want >= %v, got = %v", tt.wantMin, len(areas))
+			}
+			for i, a := range areas {
+				if a.GetDistance() > tt.maxDist {
+					t.Errorf("want <= %v, got = %v", tt.maxDist, a.GetDistance())
+				}
+				if i > 0 && a.GetDistance() < areas[i-1].GetDistance() {
+					t.Errorf("want sorted, got = %v", areas)
+				}
+			}
+		})
+	}
+}
+
+func TestBatch(t *testing.T) {
+	client, stop := newTestClient(t)
+	defer stop()
+
+	stream, err := client.Batch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := []*geojppb.BatchRequest{
+		{Id: "a", Query: &geojppb.BatchRequest_ReverseGeocode{
+			ReverseGeocode: &geojppb.ReverseGeocodeRequest{Position: shibakoen},
+		}},
+		{Id: "b", Query: &geojppb.BatchRequest_Geocode{
+			Geocode: &geojppb.GeocodeRequest{AreaName: "芝公園三丁目"},
+		}},
+		{Id: "c", Query: &geojppb.BatchRequest_Near{
+			Near: &geojppb.NearRequest{Position: shibakoen},
+		}},
+		{Id: "d"},
+		{Id: "e", Query: &geojppb.BatchRequest_Near{
+			Near: &geojppb.NearRequest{Position: shibakoen, Radius: 300},
+		}},
+	}
+	// Interleave sends and receives to exercise both directions.
+	var got []*geojppb.BatchResponse
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, res)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("want = %v, got = %v", io.EOF, err)
+	}
+
+	for i, res := range got {
+		if res.GetId() != reqs[i].GetId() {
+			t.Errorf("want = %v, got = %v", reqs[i].GetId(), res.GetId())
+		}
+	}
+	if got := got[0].GetReverseGeocode().GetArea().GetAreaName(); got != "芝公園三丁目" {
+		t.Errorf("want = %v, got = %v", "芝公園三丁目", got)
+	}
+	if got := len(got[1].GetGeocode().GetAreas()); got != 1 {
+		t.Errorf("want = %v, got = %v", 1, got)
+	}
+	for _, i := range []int{2, 3} {
+		if got := codes.Code(got[i].GetError().GetCode()); got != codes.InvalidArgument {
+			t.Errorf("#%d: want = %v, got = %v", i, codes.InvalidArgument, got)
+		}
+	}
+	if got := len(got[4].GetNear().GetAreas()); got == 0 {
+		t.Errorf("want > %v, got = %v", 0, got)
+	}
+}
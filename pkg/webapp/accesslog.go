@@ -0,0 +1,237 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request ID accepted from a
+// client.
+const maxRequestIDLength = 128
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = map[logLevel]string{
+	levelDebug: "debug",
+	levelInfo:  "info",
+	levelWarn:  "warn",
+	levelError: "error",
+}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for l, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level: %q", s)
+}
+
+// field is a key and value of a structured log entry.
+type field struct {
+	key   string
+	value interface{}
+}
+
+// logger writes structured log entries in JSON or logfmt style text.
+type logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level logLevel
+	text  bool
+	now   func() time.Time
+}
+
+var appLogger = &logger{out: os.Stderr, level: levelInfo, now: time.Now}
+
+func newLogger(out io.Writer, level, format string) (*logger, error) {
+	l, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	var text bool
+	switch format {
+	case "json":
+	case "text":
+		text = true
+	default:
+		return nil, fmt.Errorf("invalid log format: %q", format)
+	}
+	return &logger{out: out, level: l, text: text, now: time.Now}, nil
+}
+
+func (l *logger) log(level logLevel, msg string, fields ...field) {
+	if level < l.level {
+		return
+	}
+	fields = append([]field{
+		{"time", l.now().UTC().Format(time.RFC3339Nano)},
+		{"level", level.String()},
+		{"msg", msg},
+	}, fields...)
+	var b strings.Builder
+	if l.text {
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(f.key)
+			b.WriteByte('=')
+			b.WriteString(formatTextValue(f.value))
+		}
+	} else {
+		b.WriteByte('{')
+		for i, f := range fields {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			k, _ := json.Marshal(f.key)
+			v, err := json.Marshal(f.value)
+			if err != nil {
+				v, _ = json.Marshal(err.Error())
+			}
+			b.Write(k)
+			b.WriteByte(':')
+			b.Write(v)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.out, b.String())
+}
+
+func formatTextValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+type requestInfoKey struct{}
+
+// requestInfo is the information about a request shared between the access
+// log middleware and the handlers.
+type requestInfo struct {
+	id      string
+	results int
+}
+
+// requestID returns the ID assigned to the request by the access log
+// middleware.
+func requestID(r *http.Request) string {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// setResultCount records the number of results of the request for the access
+// log.
+func setResultCount(r *http.Request, n int) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.results = n
+	}
+}
+
+// accessLog assigns a request ID to each request, unless the client sent a
+// valid one, and logs the request after it is served.
+func (l *logger) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		info := &requestInfo{id: id, results: -1}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		r.Header.Set(requestIDHeader, id)
+		w.Header().Set(requestIDHeader, id)
+
+		rec := newStatusRecorder(w)
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		latency := time.Since(start)
+
+		level := levelInfo
+		switch {
+		case rec.status >= 500:
+			level = levelError
+		case rec.status >= 400:
+			level = levelWarn
+		}
+		fields := []field{
+			{"request_id", id},
+			{"method", r.Method},
+			{"path", r.URL.Path},
+			{"status", rec.status},
+			{"latency_ms", float64(latency.Microseconds()) / 1000},
+		}
+		if info.results >= 0 {
+			fields = append(fields, field{"results", info.results})
+		}
+		fields = append(fields, field{"client_ip", clientIP(r)})
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			fields = append(fields, field{"forwarded_for", fwd})
+		}
+		l.log(level, "request", fields...)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(t *testing.T, level, format string) (*logger, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	l, err := newLogger(&buf, level, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = func() time.Time { return time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC) }
+	return l, &buf
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		status    int
+		results   int
+		wantID    bool
+		wantLevel string
+	}{
+		{"generated id", "", http.StatusOK, 3, false, "info"},
+		{"client id", "abc-123", http.StatusOK, 0, true, "info"},
+		{"invalid client id", "a b", http.StatusOK, 0, false, "info"},
+		{"bad request", "", http.StatusBadRequest, -1, false, "warn"},
+		{"server error", "", http.StatusInternalServerError, -1, false, "error"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			l, buf := newTestLogger(t, "info", "json")
+			var handlerID string
+			handler := l.accessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerID = requestID(r)
+				if tt.results >= 0 {
+					setResultCount(r, tt.results)
+				}
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/api/geocoding", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			if tt.requestID != "" {
+				req.Header.Set(requestIDHeader, tt.requestID)
+			}
+			got := httptest.NewRecorder()
+			handler.ServeHTTP(got, req)
+
+			id := got.Header().Get(requestIDHeader)
+			if id == "" || id != handlerID {
+				t.Errorf("want = %v, got = %v", handlerID, id)
+			}
+			if (id == tt.requestID) != tt.wantID {
+				t.Errorf("want = %v, got = %v", tt.wantID, id == tt.requestID)
+			}
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]interface{}{
+				"time":       "2020-09-01T12:00:00Z",
+				"level":      tt.wantLevel,
+				"msg":        "request",
+				"request_id": id,
+				"method":     "POST",
+				"path":       "/api/geocoding",
+				"status":     float64(tt.status),
+				"client_ip":  "192.0.2.1",
+			}
+			if tt.results >= 0 {
+				want["results"] = float64(tt.results)
+			}
+			if _, ok := entry["latency_ms"].(float64); !ok {
+				t.Errorf("want = latency_ms, got = %v", entry)
+			}
+			delete(entry, "latency_ms")
+			if len(entry) != len(want) {
+				t.Errorf("\nwant = %v\ngot  = %v", want, entry)
+			}
+			for k, v := range want {
+				if entry[k] != v {
+					t.Errorf("%s: want = %v, got = %v", k, v, entry[k])
+				}
+			}
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		level  string
+		format string
+		want   string
+	}{
+		{"debug", "text", "time=2020-09-01T12:00:00Z level=debug msg=hello a=1 b=\"x y\"\n" +
+			"time=2020-09-01T12:00:00Z level=warn msg=hello a=1 b=\"x y\"\n"},
+		{"warn", "text", "time=2020-09-01T12:00:00Z level=warn msg=hello a=1 b=\"x y\"\n"},
+		{"WARN", "json", `{"time":"2020-09-01T12:00:00Z","level":"warn","msg":"hello","a":1,"b":"x y"}` + "\n"},
+		{"error", "json", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.level+"/"+tt.format, func(t *testing.T) {
+			t.Parallel()
+			l, buf := newTestLogger(t, tt.level, tt.format)
+			l.log(levelDebug, "hello", field{"a", 1}, field{"b", "x y"})
+			l.log(levelWarn, "hello", field{"a", 1}, field{"b", "x y"})
+			if got := buf.String(); got != tt.want {
+				t.Errorf("\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewLoggerError(t *testing.T) {
+	tests := []struct {
+		level  string
+		format string
+	}{
+		{"verbose", "json"},
+		{"info", "xml"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.level+"/"+tt.format, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			if _, err := newLogger(&buf, tt.level, tt.format); err == nil {
+				t.Errorf("want = error, got = %v", err)
+			}
+		})
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"a b", false},
+		{"a\nb", false},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+			if got := validRequestID(tt.in); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// sweepInterval is the interval at which idle token buckets are dropped.
+const sweepInterval = time.Minute
+
+// apiKey is an API key of a client read from the API key file.
+type apiKey struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	// Rate is the number of requests per second. Zero means the default rate.
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests allowed at once. Zero means the default
+	// burst.
+	Burst int `json:"burst"`
+	// DailyQuota is the number of requests per day in UTC. Zero means no
+	// quota.
+	DailyQuota int `json:"daily_quota"`
+	// Admin allows the key to query the usage.
+	Admin bool `json:"admin"`
+}
+
+// readAPIKeys reads the API keys from a JSON file of the form
+// {"keys": [{"key": "...", "label": "...", ...}]}.
+func readAPIKeys(path string) ([]apiKey, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var v struct {
+		Keys []apiKey `json:"keys"`
+	}
+	if err := json.NewDecoder(file).Decode(&v); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	keys := map[string]bool{}
+	labels := map[string]bool{}
+	for i, k := range v.Keys {
+		switch {
+		case k.Key == "":
+			return nil, fmt.Errorf("%s: key #%d: empty key", path, i)
+		case k.Label == "":
+			return nil, fmt.Errorf("%s: key #%d: empty label", path, i)
+		case keys[k.Key]:
+			return nil, fmt.Errorf("%s: key #%d: duplicate key", path, i)
+		case labels[k.Label]:
+			return nil, fmt.Errorf("%s: key #%d: duplicate label %q", path, i, k.Label)
+		case k.Rate < 0 || k.Burst < 0 || k.DailyQuota < 0:
+			return nil, fmt.Errorf("%s: key #%d: negative limit", path, i)
+		}
+		keys[k.Key] = true
+		labels[k.Label] = true
+	}
+	if len(v.Keys) == 0 {
+		return nil, errors.New(path + ": no keys")
+	}
+	return v.Keys, nil
+}
+
+// clientUsage is the usage of an API key.
+type clientUsage struct {
+	Label         string `json:"label" doc:"Label of the API key."`
+	Requests      uint64 `json:"requests" doc:"Number of accepted requests."`
+	RateLimited   uint64 `json:"rate_limited" doc:"Number of requests rejected by the rate limit."`
+	QuotaExceeded uint64 `json:"quota_exceeded" doc:"Number of requests rejected by the daily quota."`
+	// Day is the day in UTC that QuotaUsed counts.
+	Day        string `json:"day" doc:"Day in UTC that quota_used counts."`
+	QuotaUsed  int    `json:"quota_used" doc:"Number of requests on the day."`
+	DailyQuota int    `json:"daily_quota" doc:"Number of requests allowed per day. Zero means no quota."`
+}
+
+// guard authenticates clients by API key and limits their request rate.
+// Without keys, clients are not authenticated and are limited by IP address.
+type guard struct {
+	mu sync.Mutex
+	// keys is nil when authentication is disabled.
+	keys      map[string]*apiKey
+	rate      float64
+	burst     int
+	buckets   map[string]*tokenBucket
+	usage     map[string]*clientUsage
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+var appGuard = newGuard(nil, 0, 0)
+
+// newGuard returns a guard with the API keys and the default rate in
+// requests per second. A rate of zero disables the default limit.
+func newGuard(keys []apiKey, rate float64, burst int) *guard {
+	g := &guard{
+		rate:    rate,
+		burst:   burst,
+		buckets: map[string]*tokenBucket{},
+		usage:   map[string]*clientUsage{},
+		now:     time.Now,
+	}
+	if keys != nil {
+		g.keys = map[string]*apiKey{}
+		for i := range keys {
+			k := &keys[i]
+			g.keys[k.Key] = k
+			g.usage[k.Label] = &clientUsage{Label: k.Label, DailyQuota: k.DailyQuota}
+		}
+	}
+	return g
+}
+
+func (g *guard) authEnabled() bool {
+	return g.keys != nil
+}
+
+// lookup returns the API key of the request given in the X-API-Key header or
+// as a bearer token.
+func (g *guard) lookup(r *http.Request) *apiKey {
+	return g.find(r.Header.Get(apiKeyHeader), r.Header.Get("Authorization"))
+}
+
+// find returns the API key given as is, or else as a bearer token in the
+// value of an Authorization header.
+func (g *guard) find(key, authorization string) *apiKey {
+	if key == "" && len(authorization) > 7 && strings.EqualFold(authorization[:7], "Bearer ") {
+		key = strings.TrimSpace(authorization[7:])
+	}
+	if key == "" {
+		return nil
+	}
+	return g.keys[key]
+}
+
+// middleware rejects requests without a valid API key when authentication
+// is enabled, and requests over the rate limit or the quota of the client.
+func (g *guard) middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var key *apiKey
+		if g.authEnabled() {
+			key = g.lookup(r)
+			if key == nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="geojp"`)
+				writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+				return
+			}
+		}
+		if ok, wait := g.allow(clientIP(r), key); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter(wait)))
+			writeError(w, http.StatusTooManyRequests, "rate limit or daily quota exceeded")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// retryAfter returns the time to wait in whole seconds, at least one.
+func retryAfter(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// allow reports whether a request from the IP address with the key, which is
+// nil without authentication, is within the limits of the client, and
+// otherwise the time until it is.
+func (g *guard) allow(ip string, key *apiKey) (bool, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	g.sweep(now)
+
+	client := clientID(ip, key)
+	rate, burst := g.rate, g.burst
+	var usage *clientUsage
+	if key != nil {
+		if key.Rate > 0 {
+			rate = key.Rate
+		}
+		if key.Burst > 0 {
+			burst = key.Burst
+		}
+		usage = g.usage[key.Label]
+		if day := now.UTC().Format("2006-01-02"); usage.Day != day {
+			usage.Day = day
+			usage.QuotaUsed = 0
+		}
+		if key.DailyQuota > 0 && usage.QuotaUsed >= key.DailyQuota {
+			usage.QuotaExceeded++
+			y, m, d := now.UTC().Date()
+			return false, time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Sub(now)
+		}
+	}
+
+	if rate > 0 {
+		b, ok := g.buckets[client]
+		if !ok {
+			b = newTokenBucket(rate, burst, now)
+			g.buckets[client] = b
+		}
+		if ok, wait := b.take(now); !ok {
+			if usage != nil {
+				usage.RateLimited++
+			}
+			return false, wait
+		}
+	}
+	if usage != nil {
+		usage.Requests++
+		usage.QuotaUsed++
+	}
+	return true, 0
+}
+
+// clientID identifies the client of the key, or of the IP address without
+// authentication, in the limits.
+func clientID(ip string, key *apiKey) string {
+	if key != nil {
+		return "key:" + key.Label
+	}
+	return "ip:" + ip
+}
+
+// sweep drops the buckets that have been idle long enough to be full.
+func (g *guard) sweep(now time.Time) {
+	if now.Sub(g.lastSweep) < sweepInterval {
+		return
+	}
+	g.lastSweep = now
+	for k, b := range g.buckets {
+		if b.full(now) {
+			delete(g.buckets, k)
+		}
+	}
+}
+
+// usageHandler writes the usage of every API key sorted by label. Only admin
+// keys can query it.
+func (g *guard) usageHandler(w http.ResponseWriter, r *http.Request) {
+	key := g.lookup(r)
+	if key == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="geojp"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+		return
+	}
+	if !key.Admin {
+		writeError(w, http.StatusForbidden, "not an admin key")
+		return
+	}
+
+	g.mu.Lock()
+	body := make([]clientUsage, 0, len(g.usage))
+	for _, u := range g.usage {
+		body = append(body, *u)
+	}
+	g.mu.Unlock()
+	sort.Slice(body, func(i, j int) bool { return body[i].Label < body[j].Label })
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
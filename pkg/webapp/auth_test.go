@@ -0,0 +1,267 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testAPIKeys = []apiKey{
+	{Key: "admin-key", Label: "admin", Admin: true},
+	{Key: "partner-key", Label: "partner", Rate: 1, Burst: 2, DailyQuota: 3},
+}
+
+func newTestGuard(keys []apiKey, rate float64, burst int) (*guard, *time.Time) {
+	now := time.Date(2020, 9, 1, 23, 59, 0, 0, time.UTC)
+	g := newGuard(keys, rate, burst)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func doGuardedRequest(h http.HandlerFunc, remoteAddr string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/test", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	got := httptest.NewRecorder()
+	h(got, req)
+	return got
+}
+
+func okHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestGuardAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{"no key", nil, http.StatusUnauthorized},
+		{"unknown key", map[string]string{apiKeyHeader: "unknown"}, http.StatusUnauthorized},
+		{"header", map[string]string{apiKeyHeader: "admin-key"}, http.StatusOK},
+		{"bearer", map[string]string{"Authorization": "Bearer admin-key"}, http.StatusOK},
+		{"basic", map[string]string{"Authorization": "Basic admin-key"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g, _ := newTestGuard(testAPIKeys, 0, 0)
+			got := doGuardedRequest(g.middleware(okHandler), "192.0.2.1:1234", tt.header)
+			if got.Code != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got.Code)
+			}
+			if tt.want == http.StatusUnauthorized && got.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("want = %v, got = %v", "WWW-Authenticate", got.Header())
+			}
+		})
+	}
+}
+
+func TestGuardRateLimit(t *testing.T) {
+	g, now := newTestGuard(nil, 2, 2)
+	h := g.middleware(okHandler)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if got := doGuardedRequest(h, "192.0.2.1:1234", nil); got.Code != want {
+			t.Errorf("#%d: want = %v, got = %v", i, want, got.Code)
+		}
+	}
+	got := doGuardedRequest(h, "192.0.2.1:1234", nil)
+	if got, want := got.Header().Get("Retry-After"), "1"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	// Another IP address has its own bucket.
+	if got := doGuardedRequest(h, "192.0.2.2:1234", nil); got.Code != http.StatusOK {
+		t.Errorf("want = %v, got = %v", http.StatusOK, got.Code)
+	}
+	*now = now.Add(500 * time.Millisecond)
+	if got := doGuardedRequest(h, "192.0.2.1:1234", nil); got.Code != http.StatusOK {
+		t.Errorf("want = %v, got = %v", http.StatusOK, got.Code)
+	}
+
+	// Idle buckets are dropped.
+	*now = now.Add(sweepInterval)
+	doGuardedRequest(h, "192.0.2.3:1234", nil)
+	if got := len(g.buckets); got != 1 {
+		t.Errorf("want = %v, got = %v", 1, got)
+	}
+}
+
+func TestGuardKeyLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		key  apiKey
+		// want is the number of requests accepted at once.
+		want int
+	}{
+		{"defaults", apiKey{Key: "k", Label: "l"}, 3},
+		{"rate only", apiKey{Key: "k", Label: "l", Rate: 10}, 3},
+		{"burst only", apiKey{Key: "k", Label: "l", Burst: 1}, 1},
+		{"rate and burst", apiKey{Key: "k", Label: "l", Rate: 10, Burst: 5}, 5},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			g, _ := newTestGuard([]apiKey{tt.key}, 1, 3)
+			h := g.middleware(okHandler)
+			header := map[string]string{apiKeyHeader: "k"}
+			for i := 0; i < tt.want; i++ {
+				if got := doGuardedRequest(h, "192.0.2.1:1234", header); got.Code != http.StatusOK {
+					t.Errorf("#%d: want = %v, got = %v", i, http.StatusOK, got.Code)
+				}
+			}
+			if got := doGuardedRequest(h, "192.0.2.1:1234", header); got.Code != http.StatusTooManyRequests {
+				t.Errorf("want = %v, got = %v", http.StatusTooManyRequests, got.Code)
+			}
+		})
+	}
+}
+
+func TestGuardQuota(t *testing.T) {
+	g, now := newTestGuard(testAPIKeys, 0, 0)
+	h := g.middleware(okHandler)
+	partner := map[string]string{apiKeyHeader: "partner-key"}
+
+	wants := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i, want := range wants {
+		if got := doGuardedRequest(h, "192.0.2.1:1234", partner); got.Code != want {
+			t.Errorf("#%d: want = %v, got = %v", i, want, got.Code)
+		}
+	}
+	// The key is limited regardless of the IP address, and the quota runs out.
+	*now = now.Add(time.Second)
+	wants = []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, want := range wants {
+		if got := doGuardedRequest(h, "192.0.2.2:1234", partner); got.Code != want {
+			t.Errorf("#%d: want = %v, got = %v", i, want, got.Code)
+		}
+	}
+	// The quota is used up until midnight.
+	*now = now.Add(10 * time.Second)
+	got := doGuardedRequest(h, "192.0.2.1:1234", partner)
+	if got.Code != http.StatusTooManyRequests {
+		t.Errorf("want = %v, got = %v", http.StatusTooManyRequests, got.Code)
+	}
+	if got, want := got.Header().Get("Retry-After"), "49"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	*now = now.Add(time.Minute)
+	if got := doGuardedRequest(h, "192.0.2.1:1234", partner); got.Code != http.StatusOK {
+		t.Errorf("want = %v, got = %v", http.StatusOK, got.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/admin/usage", nil)
+	req.Header.Set(apiKeyHeader, "admin-key")
+	rec := httptest.NewRecorder()
+	g.usageHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want = %v, got = %v", http.StatusOK, rec.Code)
+	}
+	var usage []clientUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &usage); err != nil {
+		t.Fatal(err)
+	}
+	want := []clientUsage{
+		{Label: "admin", DailyQuota: 0},
+		{Label: "partner", Requests: 4, RateLimited: 1, QuotaExceeded: 2, Day: "2020-09-02", QuotaUsed: 1, DailyQuota: 3},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("want = %v, got = %v", want, usage)
+	}
+	for i := range want {
+		if usage[i] != want[i] {
+			t.Errorf("want = %+v, got = %+v", want[i], usage[i])
+		}
+	}
+}
+
+func TestGuardUsageHandler(t *testing.T) {
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"partner-key", http.StatusForbidden},
+		{"admin-key", http.StatusOK},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.key, func(t *testing.T) {
+			t.Parallel()
+			g, _ := newTestGuard(testAPIKeys, 0, 0)
+			got := doGuardedRequest(g.usageHandler, "192.0.2.1:1234", map[string]string{apiKeyHeader: tt.key})
+			if got.Code != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got.Code)
+			}
+		})
+	}
+}
+
+func TestReadAPIKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geojp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{"valid", `{"keys":[{"key":"a","label":"x","rate":1.5,"daily_quota":10},{"key":"b","label":"y","admin":true}]}`, 2, false},
+		{"no keys", `{"keys":[]}`, 0, true},
+		{"empty key", `{"keys":[{"label":"x"}]}`, 0, true},
+		{"empty label", `{"keys":[{"key":"a"}]}`, 0, true},
+		{"duplicate key", `{"keys":[{"key":"a","label":"x"},{"key":"a","label":"y"}]}`, 0, true},
+		{"duplicate label", `{"keys":[{"key":"a","label":"x"},{"key":"b","label":"x"}]}`, 0, true},
+		{"negative", `{"keys":[{"key":"a","label":"x","rate":-1}]}`, 0, true},
+		{"invalid json", `{"keys":`, 0, true},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, "keys"+string(rune('a'+i))+".json")
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := readAPIKeys(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want = %v, got = %v", tt.wantErr, err)
+			}
+			if len(keys) != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, len(keys))
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+	b := newTokenBucket(0.5, 0, start)
+	if ok, _ := b.take(start); !ok {
+		t.Errorf("want = %v, got = %v", true, ok)
+	}
+	ok, wait := b.take(start)
+	if ok || wait != 2*time.Second {
+		t.Errorf("want = %v %v, got = %v %v", false, 2*time.Second, ok, wait)
+	}
+	if b.full(start.Add(time.Second)) {
+		t.Errorf("want = %v, got = %v", false, true)
+	}
+	if ok, _ := b.take(start.Add(2 * time.Second)); !ok {
+		t.Errorf("want = %v, got = %v", true, ok)
+	}
+}
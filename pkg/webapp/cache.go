@@ -0,0 +1,272 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+// maxCacheEntrySize is the maximum size of a response body kept in the cache.
+const maxCacheEntrySize = 1 << 20
+
+// roundedParams are the parameters rounded when rounding is enabled.
+var roundedParams = []string{"latitude", "longitude"}
+
+type cacheEntry struct {
+	key    string
+	header http.Header
+	body   []byte
+}
+
+// responseCache is an LRU cache of successful API responses keyed by the
+// normalized request parameters. It also serves conditional requests with
+// ETags derived from the dataset version.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// size is the maximum number of entries. Zero disables caching but not
+	// the conditional requests.
+	size int
+	// maxAge is the max-age of the Cache-Control header in seconds.
+	maxAge int
+	// roundDigits is the number of decimal places latitudes and longitudes
+	// are rounded to. A negative value disables rounding.
+	roundDigits int
+
+	version      string
+	lastModified time.Time
+}
+
+var appCache = newResponseCache(0, 0, -1)
+
+func newResponseCache(size, maxAge, roundDigits int) *responseCache {
+	return &responseCache{
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+		size:        size,
+		maxAge:      maxAge,
+		roundDigits: roundDigits,
+	}
+}
+
+// reset removes all entries and sets the version and modification time of
+// the dataset.
+func (c *responseCache) reset(version string, lastModified time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+	c.version = version
+	c.lastModified = lastModified
+}
+
+func (c *responseCache) get(key string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry), true
+}
+
+func (c *responseCache) put(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if e, ok := c.entries[entry.key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *responseCache) validators() (string, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version, c.lastModified
+}
+
+// middleware serves the responses of the handler from the cache. Requests
+// with the same parameters share an entry regardless of the method, the
+// encoding and the order of the parameters. Only GET and HEAD responses
+// carry validators and may be stored by shared caches such as CDNs.
+func (c *responseCache) middleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := parseParams(r); err != nil {
+			writeParamsError(w, err)
+			return
+		}
+		c.normalize(r.Form)
+		key := cacheKey(r.URL.Path, r.Form)
+		version, lastModified := c.validators()
+		etag := `"` + version + "-" + key[:16] + `"`
+		cacheable := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+		header := w.Header()
+		entry, ok := c.get(key)
+		if ok {
+			header.Set("X-Cache", "HIT")
+		} else {
+			rec := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+			h(rec, r)
+			if rec.status != http.StatusOK {
+				for k, v := range rec.header {
+					header[k] = v
+				}
+				w.WriteHeader(rec.status)
+				w.Write(rec.body.Bytes())
+				return
+			}
+			entry = &cacheEntry{
+				key:    key,
+				header: rec.header,
+				body:   rec.body.Bytes(),
+			}
+			if len(entry.body) <= maxCacheEntrySize {
+				// The dataset may have changed while the handler was running.
+				if v, _ := c.validators(); v == version {
+					c.put(entry)
+				}
+			}
+			header.Set("X-Cache", "MISS")
+		}
+
+		// Only successful responses are validated, so that a request the
+		// handler rejects is never answered as not modified.
+		if cacheable && matchETag(r.Header.Get("If-None-Match"), etag) {
+			c.setValidators(header, etag, lastModified)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.write(w, entry, cacheable, etag, lastModified)
+	}
+}
+
+// normalize rounds the coordinates.
+func (c *responseCache) normalize(form url.Values) {
+	if c.roundDigits < 0 {
+		return
+	}
+	for _, k := range roundedParams {
+		for i, v := range form[k] {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				continue
+			}
+			form[k][i] = strconv.FormatFloat(f, 'f', c.roundDigits, 64)
+		}
+	}
+}
+
+func (c *responseCache) write(w http.ResponseWriter, entry *cacheEntry, cacheable bool, etag string, lastModified time.Time) {
+	header := w.Header()
+	for k, v := range entry.header {
+		header[k] = append([]string(nil), v...)
+	}
+	if cacheable {
+		c.setValidators(header, etag, lastModified)
+	} else {
+		header.Set("Cache-Control", "no-store")
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.body)
+}
+
+func (c *responseCache) setValidators(header http.Header, etag string, lastModified time.Time) {
+	header.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set("Cache-Control", "public, max-age="+strconv.Itoa(c.maxAge))
+}
+
+// cacheKey returns a hash of the path and the parameters sorted by key.
+func cacheKey(path string, form url.Values) string {
+	sum := sha256.Sum256([]byte(path + "?" + form.Encode()))
+	return hex.EncodeToString(sum[:])
+}
+
+// matchETag reports whether the If-None-Match header matches the ETag using
+// the weak comparison.
+func matchETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// datasetVersion returns a hash of the address positions.
+func datasetVersion(aps jp.AddressPositions) string {
+	h := fnv.New64a()
+	for _, ap := range aps {
+		for _, s := range []string{
+			ap.PrefCode, ap.PrefName, ap.PrefKanaName, ap.PrefRomaName,
+			ap.CityCode, ap.CityName, ap.CityKanaName, ap.CityRomaName,
+			ap.AreaCode, ap.AreaName,
+			strconv.FormatFloat(ap.Latitude, 'g', -1, 64),
+			strconv.FormatFloat(ap.Longitude, 'g', -1, 64),
+		} {
+			h.Write([]byte(s))
+			h.Write([]byte{0})
+		}
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// bufferedResponse is a response writer that holds the response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.status = code
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+// newCountingHandler returns a handler that echoes the latitude and counts
+// its calls.
+func newCountingHandler(calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Form.Get("latitude") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("X-Total-Count", "1")
+		fmt.Fprintf(w, "[%s]\n", r.Form.Get("latitude"))
+	}
+}
+
+func doCachedRequest(h http.HandlerFunc, form url.Values, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/test?"+form.Encode(), nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	got := httptest.NewRecorder()
+	h(got, req)
+	return got
+}
+
+func TestResponseCache(t *testing.T) {
+	modTime := time.Date(2020, 9, 1, 12, 0, 0, 0, time.UTC)
+	c := newResponseCache(2, 60, -1)
+	c.reset("v1", modTime)
+	var calls int
+	h := c.middleware(newCountingHandler(&calls))
+
+	form := url.Values{"latitude": {"35.1"}, "longitude": {"139.1"}}
+	first := doCachedRequest(h, form, "")
+	if got, want := first.Header().Get("X-Cache"), "MISS"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	etag := first.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"v1-`) {
+		t.Errorf("want = %v, got = %v", `"v1-...`, etag)
+	}
+	if got, want := first.Header().Get("Last-Modified"), "Tue, 01 Sep 2020 12:00:00 GMT"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got, want := first.Header().Get("Cache-Control"), "public, max-age=60"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+
+	// The order of the parameters does not matter.
+	second := doCachedRequest(h, url.Values{"longitude": {"139.1"}, "latitude": {"35.1"}}, "")
+	if got, want := second.Header().Get("X-Cache"), "HIT"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got, want := second.Body.String(), first.Body.String(); got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got, want := second.Header().Get("Content-Type"), "application/json; charset=utf-8"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got, want := second.Header().Get("X-Total-Count"), "1"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if calls != 1 {
+		t.Errorf("want = %v, got = %v", 1, calls)
+	}
+
+	// POST requests share the entry but are not validated or stored by
+	// shared caches.
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/test", strings.NewReader(`{"latitude":35.1,"longitude":139.1}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-None-Match", etag)
+	post := httptest.NewRecorder()
+	h(post, req)
+	if post.Code != http.StatusOK || post.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("want = %v %v, got = %v %v", http.StatusOK, "HIT", post.Code, post.Header().Get("X-Cache"))
+	}
+	if got, want := post.Header().Get("Cache-Control"), "no-store"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := post.Header().Get("ETag"); got != "" {
+		t.Errorf("want = %v, got = %v", "", got)
+	}
+
+	notModified := doCachedRequest(h, form, etag)
+	if notModified.Code != http.StatusNotModified {
+		t.Errorf("want = %v, got = %v", http.StatusNotModified, notModified.Code)
+	}
+	if notModified.Body.Len() != 0 {
+		t.Errorf("want = %v, got = %v", "", notModified.Body.String())
+	}
+
+	// Failed responses are not cached nor validated.
+	doCachedRequest(h, url.Values{}, "")
+	if bad := doCachedRequest(h, url.Values{}, ""); bad.Code != http.StatusBadRequest {
+		t.Errorf("want = %v, got = %v", http.StatusBadRequest, bad.Code)
+	}
+	if bad := doCachedRequest(h, url.Values{}, "*"); bad.Code != http.StatusBadRequest {
+		t.Errorf("want = %v, got = %v", http.StatusBadRequest, bad.Code)
+	}
+	if calls != 4 {
+		t.Errorf("want = %v, got = %v", 4, calls)
+	}
+
+	// The least recently used entry is evicted.
+	doCachedRequest(h, url.Values{"latitude": {"1"}}, "")
+	doCachedRequest(h, url.Values{"latitude": {"2"}}, "")
+	if got := doCachedRequest(h, form, ""); got.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("want = %v, got = %v", "MISS", got.Header().Get("X-Cache"))
+	}
+	if got := doCachedRequest(h, url.Values{"latitude": {"2"}}, ""); got.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("want = %v, got = %v", "HIT", got.Header().Get("X-Cache"))
+	}
+
+	// A new dataset invalidates the entries and the ETags.
+	c.reset("v2", modTime.Add(time.Hour))
+	if c.len() != 0 {
+		t.Errorf("want = %v, got = %v", 0, c.len())
+	}
+	stale := doCachedRequest(h, form, etag)
+	if stale.Code != http.StatusOK || stale.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("want = %v %v, got = %v %v", http.StatusOK, "MISS", stale.Code, stale.Header().Get("X-Cache"))
+	}
+	if got := stale.Header().Get("ETag"); got == etag {
+		t.Errorf("want != %v, got = %v", etag, got)
+	}
+}
+
+func TestResponseCacheRounding(t *testing.T) {
+	c := newResponseCache(10, 0, 3)
+	var calls int
+	h := c.middleware(newCountingHandler(&calls))
+
+	first := doCachedRequest(h, url.Values{"latitude": {"35.65858"}}, "")
+	second := doCachedRequest(h, url.Values{"latitude": {"35.6586"}}, "")
+	if got, want := first.Body.String(), "[35.659]\n"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got, want := second.Header().Get("X-Cache"), "HIT"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if calls != 1 {
+		t.Errorf("want = %v, got = %v", 1, calls)
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"a-b"`, true},
+		{`W/"a-b"`, true},
+		{`"x", "a-b"`, true},
+		{`"x"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.ifNoneMatch, func(t *testing.T) {
+			t.Parallel()
+			if got := matchETag(tt.ifNoneMatch, `"a-b"`); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDatasetVersion(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := datasetVersion(a)
+	if v2 := datasetVersion(a); v1 != v2 {
+		t.Errorf("want = %v, got = %v", v1, v2)
+	}
+	b := append(jp.AddressPositions{}, a...)
+	b[0].Latitude += 0.001
+	if v2 := datasetVersion(b); v1 == v2 {
+		t.Errorf("want != %v, got = %v", v1, v2)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// cors answers preflight requests and sets the CORS headers of the responses
+// to requests from allowed origins.
+type cors struct {
+	// origins are the allowed origins. An origin may contain one "*" that
+	// matches any string, and "*" alone allows every origin.
+	origins          []string
+	methods          []string
+	headers          []string
+	exposedHeaders   []string
+	allowCredentials bool
+	// maxAge is how long the result of a preflight request can be cached in
+	// seconds. A negative value omits the header.
+	maxAge int
+}
+
+var appCORS = &cors{}
+
+// newCORS returns a CORS configuration from comma separated lists. With
+// credentials, an origin may have a wildcard only as the whole leading label
+// of its host, such as "https://*.example.com", since a broader pattern would
+// let any site make authenticated requests.
+func newCORS(origins, methods, headers, exposedHeaders string, allowCredentials bool, maxAge int) (*cors, error) {
+	c := &cors{
+		origins:          splitList(origins),
+		headers:          splitList(headers),
+		exposedHeaders:   splitList(exposedHeaders),
+		allowCredentials: allowCredentials,
+		maxAge:           maxAge,
+	}
+	for _, m := range splitList(methods) {
+		c.methods = append(c.methods, strings.ToUpper(m))
+	}
+	if allowCredentials {
+		for _, o := range c.origins {
+			if !subdomainPattern(o) {
+				return nil, fmt.Errorf("cors: credentials are not allowed for origin %q", o)
+			}
+		}
+	}
+	return c, nil
+}
+
+// subdomainPattern reports whether the origin has no wildcard, or has one
+// only as the leading label of a host under a domain with at least two
+// labels, such as "https://*.example.com".
+func subdomainPattern(origin string) bool {
+	i := strings.Index(origin, "*")
+	if i < 0 {
+		return true
+	}
+	scheme := strings.Index(origin, "://")
+	if scheme <= 0 || i != scheme+len("://") {
+		return false
+	}
+	domain := origin[i+1:]
+	if !strings.HasPrefix(domain, ".") {
+		return false
+	}
+	domain = domain[1:]
+	return !strings.ContainsAny(domain, "*/") && strings.Contains(domain, ".") &&
+		!strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func (c *cors) enabled() bool {
+	return len(c.origins) > 0
+}
+
+func (c *cors) allowOrigin(origin string) bool {
+	for _, o := range c.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		if i := strings.Index(o, "*"); i >= 0 {
+			prefix, suffix := strings.ToLower(o[:i]), strings.ToLower(o[i+1:])
+			lower := strings.ToLower(origin)
+			if len(lower) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *cors) allowMethod(method string) bool {
+	for _, m := range c.methods {
+		if m == "*" || m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *cors) allowHeaders(requested string) bool {
+	for _, h := range splitList(requested) {
+		ok := false
+		for _, allowed := range c.headers {
+			if allowed == "*" || strings.EqualFold(allowed, h) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// middleware handles CORS requests. Preflight requests are answered without
+// calling the next handler, so they need no API key.
+func (c *cors) middleware(next http.Handler) http.Handler {
+	if !c.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		header := w.Header()
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Origin")
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			method := r.Header.Get("Access-Control-Request-Method")
+			requested := r.Header.Get("Access-Control-Request-Headers")
+			if origin == "" || !c.allowOrigin(origin) || !c.allowMethod(method) || !c.allowHeaders(requested) {
+				writeError(w, http.StatusForbidden, "origin, method or headers not allowed")
+				return
+			}
+			c.setOrigin(header, origin)
+			header.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+			if requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			if c.maxAge >= 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(c.maxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		header.Add("Vary", "Origin")
+		if origin != "" && c.allowOrigin(origin) {
+			c.setOrigin(header, origin)
+			if len(c.exposedHeaders) > 0 {
+				header.Set("Access-Control-Expose-Headers", strings.Join(c.exposedHeaders, ", "))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *cors) setOrigin(header http.Header, origin string) {
+	// Echo the origin unless any origin is allowed, which excludes
+	// credentials.
+	if len(c.origins) == 1 && c.origins[0] == "*" {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.allowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
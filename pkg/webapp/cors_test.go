@@ -0,0 +1,194 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     string
+		credentials bool
+		origin      string
+		method      string
+		headers     string
+		wantCode    int
+		wantOrigin  string
+		wantHeaders string
+	}{
+		{"allowed", "https://a.example.com", false, "https://a.example.com", "POST", "content-type, x-api-key",
+			http.StatusNoContent, "https://a.example.com", "content-type, x-api-key"},
+		{"wildcard", "*", false, "https://b.example.com", "GET", "",
+			http.StatusNoContent, "*", ""},
+		{"subdomain with credentials", "https://*.example.com", true, "https://b.example.com", "GET", "",
+			http.StatusNoContent, "https://b.example.com", ""},
+		{"subdomain", "https://*.example.com", false, "https://c.example.com", "GET", "",
+			http.StatusNoContent, "https://c.example.com", ""},
+		{"subdomain of other domain", "https://*.example.com", false, "https://c.example.org", "GET", "",
+			http.StatusForbidden, "", ""},
+		{"origin", "https://a.example.com", false, "https://evil.example.com", "POST", "",
+			http.StatusForbidden, "", ""},
+		{"method", "https://a.example.com", false, "https://a.example.com", "DELETE", "",
+			http.StatusForbidden, "", ""},
+		{"header", "https://a.example.com", false, "https://a.example.com", "POST", "X-Unknown",
+			http.StatusForbidden, "", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := newCORS(tt.origins, "GET, post", "Content-Type, X-API-Key", "X-Request-ID", tt.credentials, 600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var called bool
+			h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+
+			req := httptest.NewRequest(http.MethodOptions, "http://example.com/api/geocoding", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			got := httptest.NewRecorder()
+			h.ServeHTTP(got, req)
+
+			if called {
+				t.Errorf("want = %v, got = %v", false, called)
+			}
+			if got.Code != tt.wantCode {
+				t.Errorf("want = %v, got = %v", tt.wantCode, got.Code)
+			}
+			header := got.Header()
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("want = %v, got = %v", tt.wantOrigin, got)
+			}
+			if got := header.Get("Access-Control-Allow-Headers"); got != tt.wantHeaders {
+				t.Errorf("want = %v, got = %v", tt.wantHeaders, got)
+			}
+			if tt.wantCode != http.StatusNoContent {
+				return
+			}
+			if got, want := header.Get("Access-Control-Allow-Methods"), "GET, POST"; got != want {
+				t.Errorf("want = %v, got = %v", want, got)
+			}
+			if got, want := header.Get("Access-Control-Max-Age"), "600"; got != want {
+				t.Errorf("want = %v, got = %v", want, got)
+			}
+			wantCredentials := ""
+			if tt.credentials {
+				wantCredentials = "true"
+			}
+			if got := header.Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("want = %v, got = %v", wantCredentials, got)
+			}
+		})
+	}
+}
+
+func TestCORSSimple(t *testing.T) {
+	tests := []struct {
+		name       string
+		origin     string
+		wantOrigin string
+		wantExpose string
+	}{
+		{"allowed", "https://a.example.com", "https://a.example.com", "ETag, X-Request-ID"},
+		{"not allowed", "https://evil.example.com", "", ""},
+		{"same origin", "", "", ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := newCORS("https://a.example.com", "GET, POST", "Content-Type", "ETag, X-Request-ID", false, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/api/geocoding", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			got := httptest.NewRecorder()
+			h.ServeHTTP(got, req)
+
+			if got.Code != http.StatusTeapot {
+				t.Errorf("want = %v, got = %v", http.StatusTeapot, got.Code)
+			}
+			header := got.Header()
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("want = %v, got = %v", tt.wantOrigin, got)
+			}
+			if got := header.Get("Access-Control-Expose-Headers"); got != tt.wantExpose {
+				t.Errorf("want = %v, got = %v", tt.wantExpose, got)
+			}
+			if got, want := header.Get("Vary"), "Origin"; got != want {
+				t.Errorf("want = %v, got = %v", want, got)
+			}
+		})
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	c, err := newCORS("", "GET", "", "", false, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var called bool
+	h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	req := httptest.NewRequest(http.MethodOptions, "http://example.com/api/geocoding", nil)
+	req.Header.Set("Origin", "https://a.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	got := httptest.NewRecorder()
+	h.ServeHTTP(got, req)
+	if !called {
+		t.Errorf("want = %v, got = %v", true, called)
+	}
+	if got := got.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("want = %v, got = %v", "", got)
+	}
+}
+
+func TestNewCORS(t *testing.T) {
+	tests := []struct {
+		origins string
+		wantErr bool
+	}{
+		{"https://a.example.com", false},
+		{"https://*.example.com", false},
+		{"https://*.example.com:8443, http://*.a.example.co.jp", false},
+		{"*", true},
+		{"https://a.example.com, *", true},
+		{"https://*", true},
+		{"http*", true},
+		{"*.example.com", true},
+		{"https://*.com", true},
+		{"https://*example.com", true},
+		{"https://a.*.example.com", true},
+		{"https://*.example.*", true},
+		{"https://*./example.com", true},
+	}
+	for _, tt := range tests {
+		if _, err := newCORS(tt.origins, "GET", "", "", true, 600); (err != nil) != tt.wantErr {
+			t.Errorf("%s: want = %v, got = %v", tt.origins, tt.wantErr, err)
+		}
+		// Any pattern is allowed without credentials.
+		if _, err := newCORS(tt.origins, "GET", "", "", false, 600); err != nil {
+			t.Errorf("%s: want = %v, got = %v", tt.origins, nil, err)
+		}
+	}
+}
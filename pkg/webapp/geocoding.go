@@ -5,79 +5,96 @@
 package webapp
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 
-	"github.com/twihike/go-geojp/pkg/geo"
-	"github.com/twihike/go-structconv/structconv"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
 )
 
 type geocodingInput struct {
-	AreaName  string  `strmap:"area_name,required"`
-	Latitude  float64 `strmap:"latitude"`
-	Longitude float64 `strmap:"longitude"`
+	AreaName  string   `strmap:"area_name,required" doc:"Area name to search for." example:"芝公園三丁目"`
+	Latitude  float64  `strmap:"latitude" doc:"Latitude of the current location. Distances are measured from it." example:"35.658584"`
+	Longitude float64  `strmap:"longitude" doc:"Longitude of the current location." example:"139.7454316"`
+	PrefCode  []string `strmap:"pref_code" doc:"Prefecture codes to restrict the results to. May be repeated."`
+	PrefName  []string `strmap:"pref_name" doc:"Prefecture names to restrict the results to. May be repeated."`
+	CityCode  []string `strmap:"city_code" doc:"City codes to restrict the results to. May be repeated."`
+	CityName  []string `strmap:"city_name" doc:"City names to restrict the results to. May be repeated."`
+	Sort      string   `strmap:"sort" doc:"Order of the results: distance, name, code or relevance. Defaults to distance with the current location and relevance without it." example:"relevance"`
+	Limit     int      `strmap:"limit" doc:"Maximum number of results, up to 1000. Defaults to 100."`
+	Offset    int      `strmap:"offset" doc:"Number of results to skip."`
+	Cursor    string   `strmap:"cursor" doc:"Cursor of the next page from the X-Next-Cursor header of the previous response. Used instead of offset."`
 }
 
 type geocodingOutput struct {
-	PrefName  string  `json:"pref_name"`
-	CityName  string  `json:"city_name"`
-	AreaName  string  `json:"area_name"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Distance  float64 `json:"distance,omitempty"`
+	PrefName  string  `json:"pref_name" doc:"Prefecture name."`
+	CityName  string  `json:"city_name" doc:"City name."`
+	AreaName  string  `json:"area_name" doc:"Area name."`
+	Latitude  float64 `json:"latitude" doc:"Latitude of the representative point of the area."`
+	Longitude float64 `json:"longitude" doc:"Longitude of the representative point of the area."`
+	Distance  float64 `json:"distance,omitempty" doc:"Distance in meters from the current location, if given."`
 }
 
 func geocoding(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var in geocodingInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
 		return
 	}
-	strMap := map[string]string{}
-	for k, v := range r.Form {
-		if len(v) > 0 {
-			strMap[k] = v[0]
+	if in.Cursor != "" {
+		offset, err := decodeCursor(in.Cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
 		}
+		in.Offset = offset
 	}
 
-	var in geocodingInput
-	if err := structconv.DecodeStringMap(strMap, &in); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	opts := jp.SearchOptions{
+		Filter: jp.Filter{
+			PrefCodes: in.PrefCode,
+			PrefNames: in.PrefName,
+			CityCodes: in.CityCode,
+			CityNames: in.CityName,
+		},
+		Offset: in.Offset,
+		Limit:  in.Limit,
+	}
+	origin, err := originParam(r, in.Latitude, in.Longitude)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	var body interface{}
-	if in.Latitude != 0 && in.Longitude != 0 {
-		target := geo.LatLong{
-			Latitude:  in.Latitude,
-			Longitude: in.Longitude,
+	opts.Origin = origin
+	if in.Sort != "" {
+		var err error
+		if opts.Sort, err = jp.ParseSortOrder(in.Sort); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid sort order")
+			return
 		}
-		filteredAPs := aps.FindByAreaName(in.AreaName, target)
-		b := []geocodingOutput{}
-		for _, ap := range filteredAPs {
-			b = append(b, geocodingOutput{
-				PrefName:  ap.PrefName,
-				CityName:  ap.CityName,
-				AreaName:  ap.AreaName,
-				Latitude:  ap.Latitude,
-				Longitude: ap.Longitude,
-				Distance:  ap.Distance,
-			})
-		}
-		body = b
-	} else {
-		filteredAPs := aps.FindByAreaName(in.AreaName, geo.LatLong{})
-		b := []geocodingOutput{}
-		for _, ap := range filteredAPs {
-			b = append(b, geocodingOutput{
-				PrefName:  ap.PrefName,
-				CityName:  ap.CityName,
-				AreaName:  ap.AreaName,
-				Latitude:  ap.Latitude,
-				Longitude: ap.Longitude,
-			})
+	}
+	res, err := regions.Search(in.AreaName, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body := make([]geocodingOutput, len(res.Areas))
+	for i, ap := range res.Areas {
+		body[i] = geocodingOutput{
+			PrefName:  ap.PrefName,
+			CityName:  ap.CityName,
+			AreaName:  ap.AreaName,
+			Latitude:  ap.Latitude,
+			Longitude: ap.Longitude,
+			Distance:  ap.Distance,
 		}
-		body = b
 	}
+	setResultCount(r, len(body))
+	setPageHeaders(w.Header(), r, in.Offset+len(body), res.Total)
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	if err := json.NewEncoder(w).Encode(body); err != nil {
@@ -85,3 +102,41 @@ func geocoding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// setPageHeaders sets the total count of the results and, if there are more
+// results after next, the cursor and the link of the next page.
+func setPageHeaders(header http.Header, r *http.Request, next, total int) {
+	header.Set("X-Total-Count", strconv.Itoa(total))
+	if next >= total {
+		return
+	}
+	cursor := encodeCursor(next)
+	header.Set("X-Next-Cursor", cursor)
+	q := url.Values{}
+	for k, v := range r.Form {
+		q[k] = v
+	}
+	q.Del("offset")
+	q.Set("cursor", cursor)
+	header.Set("Link", "<"+r.URL.Path+"?"+q.Encode()+`>; rel="next"`)
+}
+
+// encodeCursor returns an opaque cursor of the offset.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("o" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) < 2 || b[0] != 'o' {
+		return 0, errors.New("invalid cursor")
+	}
+	offset, err := strconv.Atoi(string(b[1:]))
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return offset, nil
+}
@@ -5,21 +5,25 @@
 package webapp
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/twihike/go-geojp/pkg/geo"
 	"github.com/twihike/go-geojp/pkg/geo/jp"
 )
 
 func TestGeocoding(t *testing.T) {
 	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
-	aps = a
 	if err != nil {
 		t.Fatal(err)
 	}
+	aps = a
+	regions = jp.NewRegionalIndex(a, jp.CreateAPIndex(a, geo.Spherical))
 
 	target := "http://example.com/api/geocoding"
 	body := url.Values{}
@@ -42,3 +46,106 @@ func TestGeocoding(t *testing.T) {
 		t.Errorf("\nwant = %v\ngot  = %v", want, got)
 	}
 }
+
+func TestGeocodingPagination(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aps = a
+	regions = jp.NewRegionalIndex(a, jp.CreateAPIndex(a, geo.Spherical))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/api/geocoding?"+query, nil)
+		got := httptest.NewRecorder()
+		geocoding(got, req)
+		return got
+	}
+	names := func(got *httptest.ResponseRecorder) []string {
+		var body []geocodingOutput
+		if err := json.Unmarshal(got.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, o := range body {
+			names = append(names, o.AreaName)
+		}
+		return names
+	}
+
+	// Follow the cursors through all pages.
+	var all []string
+	query := "area_name=芝公園&sort=code&limit=3&pref_code=13&pref_code=27"
+	for i := 0; ; i++ {
+		got := get(query)
+		if got.Code != http.StatusOK {
+			t.Fatalf("want = %v, got = %v", http.StatusOK, got.Code)
+		}
+		if got, want := got.Header().Get("X-Total-Count"), "4"; got != want {
+			t.Errorf("want = %v, got = %v", want, got)
+		}
+		all = append(all, names(got)...)
+		cursor := got.Header().Get("X-Next-Cursor")
+		if cursor == "" {
+			if link := got.Header().Get("Link"); link != "" {
+				t.Errorf("want = %v, got = %v", "", link)
+			}
+			break
+		}
+		link := got.Header().Get("Link")
+		if !strings.HasPrefix(link, "</api/geocoding?") || !strings.HasSuffix(link, `>; rel="next"`) {
+			t.Fatalf("want = %v, got = %v", `</api/geocoding?...>; rel="next"`, link)
+		}
+		query = strings.TrimSuffix(strings.TrimPrefix(link, "</api/geocoding?"), `>; rel="next"`)
+		if i > 2 {
+			t.Fatal("too many pages")
+		}
+	}
+	want := []string{"芝公園一丁目", "芝公園二丁目", "芝公園三丁目", "芝公園四丁目"}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("want = %v, got = %v", want, all)
+	}
+
+	tests := []struct {
+		query    string
+		wantCode int
+	}{
+		{"area_name=芝&offset=14", http.StatusOK},
+		{"area_name=芝&cursor=" + encodeCursor(14), http.StatusOK},
+		{"area_name=芝&cursor=invalid", http.StatusBadRequest},
+		{"area_name=芝&sort=random", http.StatusBadRequest},
+		{"area_name=芝&sort=distance", http.StatusBadRequest},
+		{"area_name=芝&limit=-1", http.StatusBadRequest},
+		{"area_name=芝&limit=1&limit=2", http.StatusBadRequest},
+		{"area_name=芝&limit=1001", http.StatusBadRequest},
+		{"area_name=芝&latitude=35.658584", http.StatusBadRequest},
+		{"area_name=芝&latitude=91&longitude=139.7454316", http.StatusBadRequest},
+		// A zero latitude or longitude is still a position.
+		{"area_name=芝公園三丁目&latitude=0&longitude=139.7454316&sort=distance", http.StatusOK},
+		{"area_name=芝公園&pref_name=東京都&city_name=港区&limit=1", http.StatusOK},
+		{"area_name=芝公園&city_code=13101&city_code=13103&limit=1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		got := get(tt.query)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s: want = %v, got = %v", tt.query, tt.wantCode, got.Code)
+		}
+		if tt.wantCode == http.StatusOK && len(names(got)) != 1 {
+			t.Errorf("%s: want = %v, got = %v", tt.query, 1, names(got))
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	for _, offset := range []int{0, 1, 100} {
+		got, err := decodeCursor(encodeCursor(offset))
+		if err != nil || got != offset {
+			t.Errorf("want = %v, got = %v, %v", offset, got, err)
+		}
+	}
+	for _, cursor := range []string{"", "!", "eDE", "bzE=", "by0x"} {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("%q: want = %v, got = %v", cursor, "error", err)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/fence"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+const (
+	geofencesURL = "/api/geofences"
+	positionsURL = "/api/positions"
+)
+
+var (
+	appGeofences = newGeofenceSpaces(5*time.Minute, 0, 0, 0)
+	// appWebhook receives the geofence events if configured.
+	appWebhook *webhook
+)
+
+// geofenceSpaces keeps the zones and the devices of each API key apart, so
+// that a client sees and triggers only its own zones. Without
+// authentication, all clients share one space.
+type geofenceSpaces struct {
+	dwell      time.Duration
+	idle       time.Duration
+	maxDevices int
+	maxZones   int
+
+	mu        sync.Mutex
+	spaces    map[string]*geofenceSpace
+	lastSweep time.Time
+}
+
+// geofenceSpace is the zones and the devices of an API key.
+type geofenceSpace struct {
+	fences  *fence.Index
+	tracker *fence.Tracker
+}
+
+// newGeofenceSpaces returns spaces whose trackers have the dwell time, the
+// idle time and the maximum number of devices of fence.NewTracker, and whose
+// indexes have the maximum number of zones of fence.NewIndex.
+func newGeofenceSpaces(dwell, idle time.Duration, maxDevices, maxZones int) *geofenceSpaces {
+	return &geofenceSpaces{
+		dwell:      dwell,
+		idle:       idle,
+		maxDevices: maxDevices,
+		maxZones:   maxZones,
+		spaces:     map[string]*geofenceSpace{},
+	}
+}
+
+// get returns the space of the API key with the label, which is empty
+// without authentication.
+func (s *geofenceSpaces) get(label string) *geofenceSpace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, ok := s.spaces[label]
+	if !ok {
+		fences := fence.NewIndex(s.maxZones)
+		sp = &geofenceSpace{fences, fence.NewTracker(fences, s.dwell, s.idle, s.maxDevices)}
+		s.spaces[label] = sp
+	}
+	return sp
+}
+
+// expire removes the idle devices of every space, at most once per sweep
+// interval, and returns their exits keyed by the label of the space.
+func (s *geofenceSpaces) expire(now time.Time) map[string][]fence.Event {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastSweep = now
+	spaces := make(map[string]*geofenceSpace, len(s.spaces))
+	for label, sp := range s.spaces {
+		spaces[label] = sp
+	}
+	s.mu.Unlock()
+
+	exits := map[string][]fence.Event{}
+	for label, sp := range spaces {
+		if events := sp.tracker.Expire(now); len(events) > 0 {
+			exits[label] = events
+		}
+	}
+	return exits
+}
+
+// geofenceSpaceOf returns the space of the API key of the request.
+func geofenceSpaceOf(r *http.Request) (*geofenceSpace, string) {
+	var label string
+	if key := appGuard.lookup(r); key != nil {
+		label = key.Label
+	}
+	return appGeofences.get(label), label
+}
+
+type geofenceInput struct {
+	ID        string  `strmap:"id,required" doc:"ID of the zone. A zone with the same ID is replaced." example:"shibakoen"`
+	Name      string  `strmap:"name" doc:"Name of the zone." example:"Shiba Park"`
+	GeoJSON   string  `strmap:"geojson" doc:"Zone as a GeoJSON Polygon or MultiPolygon geometry. A JSON body may give the geometry as an object. Either geojson or radius is required."`
+	Latitude  float64 `strmap:"latitude" doc:"Latitude of the center of a circular zone." example:"35.658584"`
+	Longitude float64 `strmap:"longitude" doc:"Longitude of the center of a circular zone." example:"139.7454316"`
+	Radius    float64 `strmap:"radius" doc:"Radius in meters of a circular zone." example:"500"`
+	Dwell     int     `strmap:"dwell" doc:"Seconds a device stays in the zone before a dwell event. Defaults to GEOFENCE_DWELL."`
+}
+
+type geofenceOutput struct {
+	ID        string      `json:"id" doc:"ID of the zone."`
+	Name      string      `json:"name" doc:"Name of the zone."`
+	GeoJSON   interface{} `json:"geojson,omitempty" doc:"GeoJSON geometry of a polygonal zone."`
+	Latitude  float64     `json:"latitude,omitempty" doc:"Latitude of the center of a circular zone."`
+	Longitude float64     `json:"longitude,omitempty" doc:"Longitude of the center of a circular zone."`
+	Radius    float64     `json:"radius,omitempty" doc:"Radius in meters of a circular zone."`
+	Dwell     int         `json:"dwell,omitempty" doc:"Seconds a device stays in the zone before a dwell event, if set."`
+}
+
+type positionInput struct {
+	DeviceID  string  `strmap:"device_id,required" doc:"ID of the device." example:"truck-1"`
+	Latitude  float64 `strmap:"latitude,required" doc:"Latitude of the device." example:"35.658584"`
+	Longitude float64 `strmap:"longitude,required" doc:"Longitude of the device." example:"139.7454316"`
+	Time      string  `strmap:"time" doc:"RFC 3339 time of the position, up to a minute in the future. Defaults to the time the server receives it." example:"2020-09-01T12:00:00Z"`
+}
+
+type geofenceEventOutput struct {
+	Type      string  `json:"type" doc:"enter, exit or dwell."`
+	DeviceID  string  `json:"device_id" doc:"ID of the device."`
+	ZoneID    string  `json:"zone_id" doc:"ID of the zone."`
+	ZoneName  string  `json:"zone_name" doc:"Name of the zone."`
+	Latitude  float64 `json:"latitude" doc:"Latitude of the device."`
+	Longitude float64 `json:"longitude" doc:"Longitude of the device."`
+	Time      string  `json:"time" doc:"RFC 3339 time of the position."`
+	Duration  float64 `json:"duration,omitempty" doc:"Seconds the device has been in the zone, for exit and dwell events."`
+	Expired   bool    `json:"expired,omitempty" doc:"Set on the exits of a device that has sent no position for GEOFENCE_IDLE. Their position and time are the last ones of the device."`
+	PrefName  string  `json:"pref_name,omitempty" doc:"Prefecture name of the area nearest to the device."`
+	CityName  string  `json:"city_name,omitempty" doc:"City name of the area nearest to the device."`
+	AreaName  string  `json:"area_name,omitempty" doc:"Name of the area nearest to the device."`
+}
+
+// geofenceWebhookBody is the body posted to the webhook for each position
+// that causes events, and for the devices that expire.
+type geofenceWebhookBody struct {
+	// KeyLabel is the label of the API key whose zones the events are of.
+	KeyLabel string                `json:"key_label,omitempty"`
+	Events   []geofenceEventOutput `json:"events"`
+}
+
+func newGeofenceOutput(z fence.Zone) geofenceOutput {
+	out := geofenceOutput{ID: z.ID, Name: z.Name, Dwell: int(z.Dwell / time.Second)}
+	switch r := z.Region.(type) {
+	case geo.Cap:
+		out.Latitude = r.Center.Latitude
+		out.Longitude = r.Center.Longitude
+		out.Radius = r.Radius
+	case geo.Geometry:
+		if b, err := geo.MarshalGeoJSON(r); err == nil {
+			out.GeoJSON = json.RawMessage(b)
+		}
+	}
+	return out
+}
+
+// decodeZone returns the zone of the input, or false if it is invalid.
+func decodeZone(in geofenceInput) (fence.Zone, bool) {
+	z := fence.Zone{ID: in.ID, Name: in.Name, Dwell: time.Duration(in.Dwell) * time.Second}
+	switch {
+	case in.GeoJSON != "" && in.Radius == 0:
+		g, err := geo.UnmarshalGeoJSON([]byte(in.GeoJSON))
+		if err != nil {
+			return fence.Zone{}, false
+		}
+		switch g := g.(type) {
+		case geo.Polygon:
+			z.Region = g
+		case geo.MultiPolygon:
+			z.Region = g
+		default:
+			return fence.Zone{}, false
+		}
+	case in.GeoJSON == "" && in.Radius > 0:
+		if in.Latitude < -90 || in.Latitude > 90 || in.Longitude < -180 || in.Longitude > 180 {
+			return fence.Zone{}, false
+		}
+		z.Region = geo.Cap{
+			Center: geo.LatLong{Latitude: in.Latitude, Longitude: in.Longitude},
+			Radius: in.Radius,
+		}
+	default:
+		return fence.Zone{}, false
+	}
+	return z, true
+}
+
+// setupGeofences creates the geofence spaces and the webhook of the config.
+func setupGeofences(c appConfig) error {
+	var dwell, idle, timeout, backoff time.Duration
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"geofence dwell", c.GeofenceDwell, &dwell},
+		{"geofence idle", c.GeofenceIdle, &idle},
+		{"webhook timeout", c.WebhookTimeout, &timeout},
+		{"webhook backoff", c.WebhookBackoff, &backoff},
+	}
+	for _, d := range durations {
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid %s: %q", d.name, d.value)
+		}
+		*d.dst = v
+	}
+	if c.GeofenceMaxDevices < 0 {
+		return fmt.Errorf("invalid geofence max devices: %d", c.GeofenceMaxDevices)
+	}
+	if c.GeofenceMaxZones < 0 {
+		return fmt.Errorf("invalid geofence max zones: %d", c.GeofenceMaxZones)
+	}
+	if c.WebhookMaxAttempts <= 0 {
+		return fmt.Errorf("invalid webhook max attempts: %d", c.WebhookMaxAttempts)
+	}
+	appGeofences = newGeofenceSpaces(dwell, idle, c.GeofenceMaxDevices, c.GeofenceMaxZones)
+	if c.WebhookURL != "" {
+		if !strings.HasPrefix(c.WebhookURL, "http://") && !strings.HasPrefix(c.WebhookURL, "https://") {
+			return errors.New("webhook URL must be http or https")
+		}
+		appWebhook = newWebhook(c.WebhookURL, c.WebhookSecret, timeout, backoff, c.WebhookMaxAttempts)
+	}
+	return nil
+}
+
+// geofencesHandler lists the zones of the client and adds or replaces a
+// zone.
+func geofencesHandler(w http.ResponseWriter, r *http.Request) {
+	space, _ := geofenceSpaceOf(r)
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		zones := space.fences.Zones()
+		body := make([]geofenceOutput, len(zones))
+		for i, z := range zones {
+			body[i] = newGeofenceOutput(z)
+		}
+		setResultCount(r, len(body))
+		writeJSON(w, body)
+	case http.MethodPost:
+		var in geofenceInput
+		if err := readParams(r, &in); err != nil {
+			writeParamsError(w, err)
+			return
+		}
+		z, ok := decodeZone(in)
+		if !ok {
+			writeError(w, http.StatusBadRequest, "invalid geofence")
+			return
+		}
+		added, err := space.fences.Put(z)
+		switch err {
+		case nil:
+		case fence.ErrTooManyZones:
+			writeError(w, http.StatusConflict, fmt.Sprintf("too many geofences: up to %d", appGeofences.maxZones))
+			return
+		case fence.ErrTooManyVertices:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("too many vertices: up to %d", fence.MaxVertices))
+			return
+		default:
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if added {
+			w.Header().Set("Location", geofencesURL+"/"+z.ID)
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(newGeofenceOutput(z))
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// geofenceHandler returns or deletes the zone of the client with the ID in
+// the path.
+func geofenceHandler(w http.ResponseWriter, r *http.Request) {
+	space, _ := geofenceSpaceOf(r)
+	id := strings.TrimPrefix(r.URL.Path, geofencesURL+"/")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		z, ok := space.fences.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, "unknown geofence")
+			return
+		}
+		writeJSON(w, newGeofenceOutput(z))
+	case http.MethodDelete:
+		if !space.fences.Delete(id) {
+			writeError(w, http.StatusNotFound, "unknown geofence")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, DELETE")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// positionsHandler records the position of a device in the zones of the
+// client and returns the geofence events it causes, which are also posted to
+// the webhook. It also reports the exits of the devices that have expired.
+func positionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var in positionInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	now := time.Now()
+	for label, exits := range appGeofences.expire(now) {
+		postEvents(label, annotateEvents(exits))
+	}
+	at := now
+	if in.Time != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339Nano, in.Time); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid time")
+			return
+		}
+	}
+	p := geo.LatLong{Latitude: in.Latitude, Longitude: in.Longitude}
+	if p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+		writeError(w, http.StatusBadRequest, "invalid position")
+		return
+	}
+	space, label := geofenceSpaceOf(r)
+	events, err := space.tracker.Update(in.DeviceID, p, at)
+	switch err {
+	case fence.ErrStalePosition:
+		writeError(w, http.StatusConflict, "position is older than the last position of the device")
+		return
+	case fence.ErrFuturePosition:
+		writeError(w, http.StatusBadRequest, "time is in the future")
+		return
+	case fence.ErrTooManyDevices:
+		writeError(w, http.StatusServiceUnavailable, "too many devices")
+		return
+	}
+
+	body := make([]geofenceEventOutput, len(events))
+	if len(events) > 0 {
+		body = annotateEvents(events)
+		postEvents(label, body)
+	}
+	setResultCount(r, len(body))
+	writeJSON(w, body)
+}
+
+// postEvents posts the events of the API key with the label to the webhook
+// if configured.
+func postEvents(label string, events []geofenceEventOutput) {
+	if appWebhook == nil {
+		return
+	}
+	if b, err := json.Marshal(geofenceWebhookBody{KeyLabel: label, Events: events}); err == nil {
+		appWebhook.send(b)
+	}
+}
+
+// annotateEvents returns the events with the area nearest to their
+// positions, which are mostly the same for all events.
+func annotateEvents(events []fence.Event) []geofenceEventOutput {
+	nearest := map[geo.LatLong]jp.AddressPosition{}
+	out := make([]geofenceEventOutput, len(events))
+	for i, e := range events {
+		out[i] = geofenceEventOutput{
+			Type:      string(e.Type),
+			DeviceID:  e.DeviceID,
+			ZoneID:    e.Zone.ID,
+			ZoneName:  e.Zone.Name,
+			Latitude:  e.Position.Latitude,
+			Longitude: e.Position.Longitude,
+			Time:      e.Time.UTC().Format(time.RFC3339Nano),
+			Duration:  e.Duration.Seconds(),
+			Expired:   e.Expired,
+		}
+		if iaps.Len() == 0 {
+			continue
+		}
+		a, ok := nearest[e.Position]
+		if !ok {
+			a = iaps.Nearest(e.Position).AddressPosition
+			nearest[e.Position] = a
+		}
+		out[i].PrefName, out[i].CityName, out[i].AreaName = a.PrefName, a.CityName, a.AreaName
+	}
+	return out
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/fence"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+// resetGeofences replaces the geofences with the zones and forgets the
+// devices.
+func resetGeofences(t *testing.T, zones ...fence.Zone) {
+	t.Helper()
+	appGeofences = newGeofenceSpaces(5*time.Minute, 0, 0, 0)
+	appWebhook = nil
+	for _, z := range zones {
+		if _, err := appGeofences.get("").fences.Put(z); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSetupGeofences(t *testing.T) {
+	defer resetGeofences(t)
+	valid := appConfig{
+		GeofenceDwell:      "1m",
+		GeofenceIdle:       "1h",
+		GeofenceMaxDevices: 100,
+		WebhookTimeout:     "10s",
+		WebhookBackoff:     "1s",
+		WebhookMaxAttempts: 5,
+	}
+	tests := []struct {
+		name    string
+		modify  func(c *appConfig)
+		wantErr bool
+	}{
+		{"valid", func(c *appConfig) {}, false},
+		{"webhook", func(c *appConfig) { c.WebhookURL = "https://example.com/hook" }, false},
+		{"dwell", func(c *appConfig) { c.GeofenceDwell = "1" }, true},
+		{"idle", func(c *appConfig) { c.GeofenceIdle = "-1h" }, true},
+		{"max devices", func(c *appConfig) { c.GeofenceMaxDevices = -1 }, true},
+		{"max zones", func(c *appConfig) { c.GeofenceMaxZones = -1 }, true},
+		{"backoff", func(c *appConfig) { c.WebhookBackoff = "-1s" }, true},
+		{"attempts", func(c *appConfig) { c.WebhookMaxAttempts = 0 }, true},
+		{"scheme", func(c *appConfig) { c.WebhookURL = "ftp://example.com/hook" }, true},
+	}
+	for _, tt := range tests {
+		c := valid
+		tt.modify(&c)
+		appWebhook = nil
+		err := setupGeofences(c)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: want = %v, got = %v", tt.name, tt.wantErr, err)
+		}
+		if appWebhook != nil {
+			if appWebhook.url != c.WebhookURL {
+				t.Errorf("%s: want = %v, got = %v", tt.name, c.WebhookURL, appWebhook.url)
+			}
+			appWebhook.close(context.Background())
+		}
+	}
+}
+
+func TestGeofenceHandlers(t *testing.T) {
+	resetGeofences(t)
+	handler := setupServer().Handler
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://example.com"+target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		got := httptest.NewRecorder()
+		handler.ServeHTTP(got, req)
+		return got
+	}
+	polygon := url.QueryEscape(`{"type":"Polygon","coordinates":[[[139.74,35.65],[139.75,35.65],[139.75,35.66],[139.74,35.66],[139.74,35.65]]]}`)
+
+	tests := []struct {
+		method   string
+		target   string
+		body     string
+		wantCode int
+		want     string
+	}{
+		{
+			http.MethodPost, "/api/geofences", "id=shibakoen&name=Shiba+Park&latitude=35.658584&longitude=139.7454316&radius=500",
+			http.StatusCreated, `{"id":"shibakoen","name":"Shiba Park","latitude":35.658584,"longitude":139.7454316,"radius":500}`,
+		},
+		{
+			http.MethodPost, "/api/geofences", "id=shibakoen&geojson=" + polygon + "&dwell=60",
+			http.StatusOK, `{"id":"shibakoen","name":"","geojson":{"type":"Polygon","coordinates":[[[139.74,35.65],[139.75,35.65],[139.75,35.66],[139.74,35.66],[139.74,35.65]]]},"dwell":60}`,
+		},
+		{
+			http.MethodGet, "/api/geofences/shibakoen", "",
+			http.StatusOK, `{"id":"shibakoen","name":"","geojson":{"type":"Polygon","coordinates":[[[139.74,35.65],[139.75,35.65],[139.75,35.66],[139.74,35.66],[139.74,35.65]]]},"dwell":60}`,
+		},
+		{http.MethodGet, "/api/geofences", "", http.StatusOK, `[{"id":"shibakoen","name":"","geojson":{"type":"Polygon","coordinates":[[[139.74,35.65],[139.75,35.65],[139.75,35.66],[139.74,35.66],[139.74,35.65]]]},"dwell":60}]`},
+		{http.MethodPost, "/api/geofences", "id=a&geojson=" + polygon + "&radius=500", http.StatusBadRequest, "invalid geofence"},
+		{http.MethodPost, "/api/geofences", "id=a&latitude=35.658584&longitude=139.7454316", http.StatusBadRequest, "invalid geofence"},
+		{http.MethodPost, "/api/geofences", "id=a&geojson=" + url.QueryEscape(`{"type":"Point","coordinates":[139.74,35.65]}`), http.StatusBadRequest, "invalid geofence"},
+		{http.MethodPost, "/api/geofences", "id=a&latitude=35.658584&longitude=139.7454316&radius=500&dwell=-1", http.StatusBadRequest, ""},
+		{http.MethodPost, "/api/geofences", "latitude=35.658584&longitude=139.7454316&radius=500", http.StatusBadRequest, ""},
+		{http.MethodPut, "/api/geofences", "", http.StatusMethodNotAllowed, "method not allowed"},
+		{http.MethodGet, "/api/geofences/unknown", "", http.StatusNotFound, "unknown geofence"},
+		{http.MethodPost, "/api/geofences/shibakoen", "", http.StatusMethodNotAllowed, "method not allowed"},
+		{http.MethodDelete, "/api/geofences/shibakoen", "", http.StatusNoContent, ""},
+		{http.MethodDelete, "/api/geofences/shibakoen", "", http.StatusNotFound, "unknown geofence"},
+		{http.MethodGet, "/api/geofences", "", http.StatusOK, `[]`},
+		{http.MethodGet, "/api/positions", "", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+	for _, tt := range tests {
+		got := do(tt.method, tt.target, tt.body)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s %s %s: want = %v, got = %v", tt.method, tt.target, tt.body, tt.wantCode, got.Code)
+		}
+		if tt.wantCode >= http.StatusBadRequest {
+			if got := errorMessage(t, got); got == "" || tt.want != "" && got != tt.want {
+				t.Errorf("%s %s %s: want = %v, got = %v", tt.method, tt.target, tt.body, tt.want, got)
+			}
+			continue
+		}
+		if got := strings.TrimSpace(got.Body.String()); got != tt.want {
+			t.Errorf("%s %s %s:\nwant = %v\ngot  = %v", tt.method, tt.target, tt.body, tt.want, got)
+		}
+	}
+}
+
+func TestGeofenceHandlers_Limits(t *testing.T) {
+	defer resetGeofences(t)
+	appGeofences = newGeofenceSpaces(5*time.Minute, 0, 0, 1)
+	handler := setupServer().Handler
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/api/geofences", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		got := httptest.NewRecorder()
+		handler.ServeHTTP(got, req)
+		return got
+	}
+
+	// A polygon of one vertex over fence.MaxVertices along the south edge
+	// of a triangle.
+	var coords strings.Builder
+	for i := 0; i < fence.MaxVertices-1; i++ {
+		fmt.Fprintf(&coords, "[%.7f,35.65],", 139.74+float64(i)*1e-6)
+	}
+	coords.WriteString("[139.75,35.66],[139.74,35.65]")
+	polygon := `{"type":"Polygon","coordinates":[[` + coords.String() + `]]}`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		want     string
+	}{
+		{"first zone", `{"id":"a","latitude":35.658584,"longitude":139.7454316,"radius":500}`, http.StatusCreated, ""},
+		{"too many zones", `{"id":"b","latitude":35.658584,"longitude":139.7454316,"radius":500}`, http.StatusConflict, "too many geofences: up to 1"},
+		{"replaced zone", `{"id":"a","latitude":35.658584,"longitude":139.7454316,"radius":100}`, http.StatusOK, ""},
+		{"too many vertices", `{"id":"a","geojson":` + polygon + `}`, http.StatusBadRequest, fmt.Sprintf("too many vertices: up to %d", fence.MaxVertices)},
+	}
+	for _, tt := range tests {
+		got := post(tt.body)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s: want = %v, got = %v", tt.name, tt.wantCode, got.Code)
+		}
+		if tt.want != "" {
+			if got := errorMessage(t, got); got != tt.want {
+				t.Errorf("%s: want = %v, got = %v", tt.name, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestPositionsHandler(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps = jp.CreateAPIndex(a, geo.Spherical)
+	shibakoen := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	resetGeofences(t, fence.Zone{
+		ID:     "shibakoen",
+		Name:   "Shiba Park",
+		Region: geo.Cap{Center: shibakoen, Radius: 500},
+		Dwell:  time.Minute,
+	})
+	defer resetGeofences(t)
+
+	bodies := make(chan []byte, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer hook.Close()
+	appWebhook = newWebhook(hook.URL, "", time.Second, time.Millisecond, 3)
+
+	const position = "device_id=truck-1&latitude=35.658584&longitude=139.7454316"
+	const away = "device_id=truck-1&latitude=34.7&longitude=135.5"
+	tests := []struct {
+		body     string
+		wantCode int
+		want     string
+	}{
+		{
+			position + "&time=2020-09-01T12:00:00Z", http.StatusOK,
+			`[{"type":"enter","device_id":"truck-1","zone_id":"shibakoen","zone_name":"Shiba Park","latitude":35.658584,"longitude":139.7454316,"time":"2020-09-01T12:00:00Z","pref_name":"東京都","city_name":"港区","area_name":"芝公園三丁目"}]`,
+		},
+		{position + "&time=2020-09-01T12:00:30Z", http.StatusOK, `[]`},
+		{
+			position + "&time=2020-09-01T12:01:00Z", http.StatusOK,
+			`[{"type":"dwell","device_id":"truck-1","zone_id":"shibakoen","zone_name":"Shiba Park","latitude":35.658584,"longitude":139.7454316,"time":"2020-09-01T12:01:00Z","duration":60,"pref_name":"東京都","city_name":"港区","area_name":"芝公園三丁目"}]`,
+		},
+		{position + "&time=2020-09-01T11:00:00Z", http.StatusConflict, "position is older than the last position of the device"},
+		{position + "&time=2999-09-01T11:00:00Z", http.StatusBadRequest, "time is in the future"},
+		{position + "&time=yesterday", http.StatusBadRequest, "invalid time"},
+		{"device_id=truck-1&latitude=91&longitude=139.7454316", http.StatusBadRequest, "invalid position"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/api/positions", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		got := httptest.NewRecorder()
+		positionsHandler(got, req)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s: want = %v, got = %v", tt.body, tt.wantCode, got.Code)
+		}
+		if tt.wantCode >= http.StatusBadRequest {
+			if got := errorMessage(t, got); got != tt.want {
+				t.Errorf("%s: want = %v, got = %v", tt.body, tt.want, got)
+			}
+			continue
+		}
+		if got := strings.TrimSpace(got.Body.String()); got != tt.want {
+			t.Errorf("%s:\nwant = %v\ngot  = %v", tt.body, tt.want, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api/positions", strings.NewReader(away+"&time=2020-09-01T12:02:00Z"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	positionsHandler(httptest.NewRecorder(), req)
+	appWebhook.close(context.Background())
+
+	// Only the positions with events are posted.
+	var got []string
+	var duration float64
+	for len(bodies) > 0 {
+		var body geofenceWebhookBody
+		if err := json.Unmarshal(<-bodies, &body); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range body.Events {
+			got = append(got, e.Type)
+			duration = e.Duration
+		}
+	}
+	if want := []string{"enter", "dwell", "exit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if duration != 120 {
+		t.Errorf("want = %v, got = %v", 120, duration)
+	}
+}
+
+func TestGeofenceSpaces(t *testing.T) {
+	defer func(g *guard) { appGuard = g }(appGuard)
+	appGuard, _ = newTestGuard([]apiKey{{Key: "admin-key", Label: "admin"}, {Key: "partner-key", Label: "partner"}}, 0, 0)
+	resetGeofences(t)
+	defer resetGeofences(t)
+	appGeofences = newGeofenceSpaces(0, time.Nanosecond, 1, 0)
+
+	bodies := make(chan []byte, 10)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- b
+	}))
+	defer hook.Close()
+	appWebhook = newWebhook(hook.URL, "", time.Second, time.Millisecond, 3)
+
+	handler := setupServer().Handler
+	do := func(key, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://example.com"+target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(apiKeyHeader, key)
+		got := httptest.NewRecorder()
+		handler.ServeHTTP(got, req)
+		return got
+	}
+
+	// The zones of a key are hidden from the other keys.
+	if got := do("admin-key", http.MethodPost, "/api/geofences", "id=shibakoen&latitude=35.658584&longitude=139.7454316&radius=500"); got.Code != http.StatusCreated {
+		t.Fatalf("want = %v, got = %v", http.StatusCreated, got.Code)
+	}
+	if got := do("partner-key", http.MethodGet, "/api/geofences", ""); strings.TrimSpace(got.Body.String()) != "[]" {
+		t.Errorf("want = %v, got = %v", "[]", got.Body.String())
+	}
+	if got := do("partner-key", http.MethodGet, "/api/geofences/shibakoen", ""); got.Code != http.StatusNotFound {
+		t.Errorf("want = %v, got = %v", http.StatusNotFound, got.Code)
+	}
+	const position = "device_id=truck-1&latitude=35.658584&longitude=139.7454316"
+	if got := do("partner-key", http.MethodPost, "/api/positions", position); strings.TrimSpace(got.Body.String()) != "[]" {
+		t.Errorf("want = %v, got = %v", "[]", got.Body.String())
+	}
+	if got := do("admin-key", http.MethodPost, "/api/positions", position); !strings.Contains(got.Body.String(), `"type":"enter"`) {
+		t.Errorf("want = %v, got = %v", "an enter", got.Body.String())
+	}
+
+	// Each key tracks up to the maximum number of devices.
+	got := do("admin-key", http.MethodPost, "/api/positions", "device_id=truck-2&latitude=35.658584&longitude=139.7454316")
+	if got.Code != http.StatusServiceUnavailable {
+		t.Errorf("want = %v, got = %v", http.StatusServiceUnavailable, got.Code)
+	}
+
+	// The devices that sent no position for the idle time exit their zones
+	// on the next sweep.
+	appGeofences.lastSweep = time.Time{}
+	if got := do("admin-key", http.MethodPost, "/api/positions", "device_id=truck-2&latitude=34.7&longitude=135.5"); got.Code != http.StatusOK {
+		t.Errorf("want = %v, got = %v", http.StatusOK, got.Code)
+	}
+	appWebhook.close(context.Background())
+	var labels, types []string
+	for len(bodies) > 0 {
+		var body geofenceWebhookBody
+		if err := json.Unmarshal(<-bodies, &body); err != nil {
+			t.Fatal(err)
+		}
+		labels = append(labels, body.KeyLabel)
+		for _, e := range body.Events {
+			types = append(types, fmt.Sprintf("%s %s %v", e.Type, e.DeviceID, e.Expired))
+		}
+	}
+	if want := []string{"admin", "admin"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("want = %v, got = %v", want, labels)
+	}
+	if want := []string{"enter truck-1 false", "exit truck-1 true"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("want = %v, got = %v", want, types)
+	}
+}
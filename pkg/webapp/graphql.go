@@ -0,0 +1,480 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"sync/atomic"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+const (
+	minZoomLevel = 4
+	maxZoomLevel = 23
+
+	// maxGraphQLDepth limits the nesting of a query, which can otherwise
+	// walk the hierarchy back and forth.
+	maxGraphQLDepth = 8
+	// maxGraphQLCost limits the breadth of a query, which can repeat fields
+	// under aliases. Lists cost one plus their length and searches, which
+	// scan the dataset, cost graphqlSearchCost more.
+	maxGraphQLCost    = 10000
+	graphqlSearchCost = 100
+	// maxGraphQLRequestSize is the maximum size of a request body.
+	maxGraphQLRequestSize = 1 << 20
+)
+
+// graphqlSDL is the schema of the GraphQL API.
+const graphqlSDL = `
+schema {
+	query: Query
+}
+
+type Query {
+	"Areas whose name contains areaName, nearest to the origin first if given. The limit defaults to 100 and may be up to 1000."
+	search(areaName: String!, origin: LatLongInput, limit: Int): [NearbyArea!]!
+	"The area nearest to the position."
+	reverse(position: LatLongInput!): NearbyArea
+	"Areas in the tiles around the position at the zoom level, or within the radius in meters, nearest first. The limit defaults to 100 and may be up to 1000."
+	near(position: LatLongInput!, zoom: Int, radius: Float, limit: Int): [NearbyArea!]!
+	"All prefectures ordered by code."
+	prefectures: [Prefecture!]!
+	"The prefecture with the two-digit code."
+	prefecture(code: String!): Prefecture
+	"The city with the five-digit code."
+	city(code: String!): City
+	"The area with the twelve-digit code."
+	area(code: String!): Area
+}
+
+input LatLongInput {
+	latitude: Float!
+	longitude: Float!
+}
+
+type Prefecture {
+	code: String!
+	name: String!
+	kanaName: String!
+	romaName: String!
+	"Cities of the prefecture ordered by code."
+	cities: [City!]!
+}
+
+type City {
+	code: String!
+	name: String!
+	kanaName: String!
+	romaName: String!
+	prefecture: Prefecture!
+	"Areas of the city in the order of the dataset. The limit defaults to 100 and may be up to 1000."
+	areas(limit: Int): [Area!]!
+}
+
+type Area {
+	code: String!
+	name: String!
+	"Latitude of the representative point of the area."
+	latitude: Float!
+	"Longitude of the representative point of the area."
+	longitude: Float!
+	city: City!
+	prefecture: Prefecture!
+}
+
+type NearbyArea {
+	area: Area!
+	"Distance in meters from the position, or null if there is no position."
+	distance: Float
+}
+`
+
+var appGraphQL = graphql.MustParseSchema(graphqlSDL, &graphqlQuery{},
+	graphql.UseStringDescriptions(), graphql.MaxDepth(maxGraphQLDepth))
+
+// addrTree is the prefecture, city and area hierarchy of the dataset.
+var addrTree = newAddressTree(nil)
+
+// addressTree groups the address positions by prefecture and city.
+type addressTree struct {
+	prefectures []*prefectureNode
+	prefByCode  map[string]*prefectureNode
+	cityByCode  map[string]*cityNode
+	areaByCode  map[string]*jp.AddressPosition
+}
+
+type prefectureNode struct {
+	code     string
+	name     string
+	kanaName string
+	romaName string
+	cities   []*cityNode
+}
+
+type cityNode struct {
+	code     string
+	name     string
+	kanaName string
+	romaName string
+	pref     *prefectureNode
+	areas    []*jp.AddressPosition
+}
+
+func newAddressTree(a jp.AddressPositions) *addressTree {
+	t := &addressTree{
+		prefByCode: map[string]*prefectureNode{},
+		cityByCode: map[string]*cityNode{},
+		areaByCode: map[string]*jp.AddressPosition{},
+	}
+	for i := range a {
+		ap := &a[i]
+		p, ok := t.prefByCode[ap.PrefCode]
+		if !ok {
+			p = &prefectureNode{
+				code:     ap.PrefCode,
+				name:     ap.PrefName,
+				kanaName: ap.PrefKanaName,
+				romaName: ap.PrefRomaName,
+			}
+			t.prefByCode[p.code] = p
+			t.prefectures = append(t.prefectures, p)
+		}
+		c, ok := t.cityByCode[ap.CityCode]
+		if !ok {
+			c = &cityNode{
+				code:     ap.CityCode,
+				name:     ap.CityName,
+				kanaName: ap.CityKanaName,
+				romaName: ap.CityRomaName,
+				pref:     p,
+			}
+			t.cityByCode[c.code] = c
+			p.cities = append(p.cities, c)
+		}
+		c.areas = append(c.areas, ap)
+		t.areaByCode[ap.AreaCode] = ap
+	}
+	sort.Slice(t.prefectures, func(i, j int) bool {
+		return t.prefectures[i].code < t.prefectures[j].code
+	})
+	for _, p := range t.prefectures {
+		cities := p.cities
+		sort.Slice(cities, func(i, j int) bool {
+			return cities[i].code < cities[j].code
+		})
+	}
+	return t
+}
+
+// area returns the resolver of an address position.
+func (t *addressTree) area(ap *jp.AddressPosition) *areaResolver {
+	return &areaResolver{ap: ap, city: t.cityByCode[ap.CityCode]}
+}
+
+func (t *addressTree) nearbyAreas(nearby []jp.NearbyAP, withDistance bool) []*nearbyAreaResolver {
+	res := make([]*nearbyAreaResolver, len(nearby))
+	for i := range nearby {
+		res[i] = &nearbyAreaResolver{area: t.area(&nearby[i].AddressPosition)}
+		if withDistance {
+			res[i].distance = &nearby[i].Distance
+		}
+	}
+	return res
+}
+
+// graphqlQuery resolves the fields of the Query type.
+type graphqlQuery struct{}
+
+type latLongInput struct {
+	Latitude  float64
+	Longitude float64
+}
+
+func (in latLongInput) latLong() (geo.LatLong, error) {
+	// The negated form also rejects NaN.
+	if !(in.Latitude >= -90 && in.Latitude <= 90 && in.Longitude >= -180 && in.Longitude <= 180) {
+		return geo.LatLong{}, errors.New("position is out of range")
+	}
+	return geo.LatLong{Latitude: in.Latitude, Longitude: in.Longitude}, nil
+}
+
+// limitLength returns n capped by the limit argument, which defaults to
+// jp.DefaultSearchLimit.
+func limitLength(n int, limit *int32) (int, error) {
+	l := jp.DefaultSearchLimit
+	if limit != nil {
+		if *limit < 0 || *limit > jp.MaxSearchLimit {
+			return 0, fmt.Errorf("limit must be from 0 to %d", jp.MaxSearchLimit)
+		}
+		l = int(*limit)
+	}
+	if l < n {
+		return l, nil
+	}
+	return n, nil
+}
+
+type graphqlCostKey struct{}
+
+// graphqlCost is the remaining cost that a query may spend. Resolvers run
+// in parallel, so it is updated atomically.
+type graphqlCost struct {
+	limit     int64
+	remaining int64
+}
+
+func withGraphQLCost(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, graphqlCostKey{}, &graphqlCost{limit: int64(limit), remaining: int64(limit)})
+}
+
+// chargeCost spends the cost of a field, and fails once the query exceeds
+// its limit.
+func chargeCost(ctx context.Context, cost int) error {
+	c, ok := ctx.Value(graphqlCostKey{}).(*graphqlCost)
+	if !ok {
+		return nil
+	}
+	if atomic.AddInt64(&c.remaining, -int64(cost)) < 0 {
+		return fmt.Errorf("query exceeds the maximum cost %d", c.limit)
+	}
+	return nil
+}
+
+func (*graphqlQuery) Search(ctx context.Context, args struct {
+	AreaName string
+	Origin   *latLongInput
+	Limit    *int32
+}) ([]*nearbyAreaResolver, error) {
+	if args.AreaName == "" {
+		return nil, errors.New("areaName must not be empty")
+	}
+	var origin geo.LatLong
+	if args.Origin != nil {
+		var err error
+		if origin, err = args.Origin.latLong(); err != nil {
+			return nil, err
+		}
+	}
+	if err := chargeCost(ctx, graphqlSearchCost); err != nil {
+		return nil, err
+	}
+	nearby := aps.FindByAreaNameWithModel(args.AreaName, origin, iaps.Model())
+	n, err := limitLength(len(nearby), args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := chargeCost(ctx, 1+n); err != nil {
+		return nil, err
+	}
+	return addrTree.nearbyAreas(nearby[:n], args.Origin != nil), nil
+}
+
+func (*graphqlQuery) Reverse(args struct{ Position latLongInput }) (*nearbyAreaResolver, error) {
+	p, err := args.Position.latLong()
+	if err != nil {
+		return nil, err
+	}
+	if len(aps) == 0 {
+		return nil, nil
+	}
+	nearest := iaps.Nearest(p)
+	return addrTree.nearbyAreas([]jp.NearbyAP{nearest}, true)[0], nil
+}
+
+func (*graphqlQuery) Near(ctx context.Context, args struct {
+	Position latLongInput
+	Zoom     *int32
+	Radius   *float64
+	Limit    *int32
+}) ([]*nearbyAreaResolver, error) {
+	p, err := args.Position.latLong()
+	if err != nil {
+		return nil, err
+	}
+	var nearby []jp.NearbyAP
+	switch {
+	case args.Zoom != nil && args.Radius != nil:
+		return nil, errors.New("either zoom or radius is allowed")
+	case args.Zoom != nil:
+		zoom := int(*args.Zoom)
+		if zoom < minZoomLevel || zoom > maxZoomLevel {
+			return nil, fmt.Errorf("zoom must be from %d to %d", minZoomLevel, maxZoomLevel)
+		}
+		nearby = iaps.Near(p, zoom)
+	case args.Radius != nil:
+		radius := *args.Radius
+		if !(radius > 0 && radius <= jp.MaxNearRadius) {
+			return nil, fmt.Errorf("radius must be greater than 0 and at most %d", jp.MaxNearRadius)
+		}
+		nearby = iaps.WithinRadius(p, radius)
+	default:
+		return nil, errors.New("zoom or radius is required")
+	}
+	n, err := limitLength(len(nearby), args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := chargeCost(ctx, 1+n); err != nil {
+		return nil, err
+	}
+	return addrTree.nearbyAreas(nearby[:n], true), nil
+}
+
+func (*graphqlQuery) Prefectures(ctx context.Context) ([]*prefectureResolver, error) {
+	prefs := addrTree.prefectures
+	if err := chargeCost(ctx, 1+len(prefs)); err != nil {
+		return nil, err
+	}
+	res := make([]*prefectureResolver, len(prefs))
+	for i, p := range prefs {
+		res[i] = &prefectureResolver{p}
+	}
+	return res, nil
+}
+
+func (*graphqlQuery) Prefecture(args struct{ Code string }) *prefectureResolver {
+	if p, ok := addrTree.prefByCode[args.Code]; ok {
+		return &prefectureResolver{p}
+	}
+	return nil
+}
+
+func (*graphqlQuery) City(args struct{ Code string }) *cityResolver {
+	if c, ok := addrTree.cityByCode[args.Code]; ok {
+		return &cityResolver{c}
+	}
+	return nil
+}
+
+func (*graphqlQuery) Area(args struct{ Code string }) *areaResolver {
+	t := addrTree
+	if ap, ok := t.areaByCode[args.Code]; ok {
+		return t.area(ap)
+	}
+	return nil
+}
+
+type prefectureResolver struct{ p *prefectureNode }
+
+func (r *prefectureResolver) Code() string     { return r.p.code }
+func (r *prefectureResolver) Name() string     { return r.p.name }
+func (r *prefectureResolver) KanaName() string { return r.p.kanaName }
+func (r *prefectureResolver) RomaName() string { return r.p.romaName }
+
+func (r *prefectureResolver) Cities(ctx context.Context) ([]*cityResolver, error) {
+	if err := chargeCost(ctx, 1+len(r.p.cities)); err != nil {
+		return nil, err
+	}
+	res := make([]*cityResolver, len(r.p.cities))
+	for i, c := range r.p.cities {
+		res[i] = &cityResolver{c}
+	}
+	return res, nil
+}
+
+type cityResolver struct{ c *cityNode }
+
+func (r *cityResolver) Code() string                    { return r.c.code }
+func (r *cityResolver) Name() string                    { return r.c.name }
+func (r *cityResolver) KanaName() string                { return r.c.kanaName }
+func (r *cityResolver) RomaName() string                { return r.c.romaName }
+func (r *cityResolver) Prefecture() *prefectureResolver { return &prefectureResolver{r.c.pref} }
+
+func (r *cityResolver) Areas(ctx context.Context, args struct{ Limit *int32 }) ([]*areaResolver, error) {
+	n, err := limitLength(len(r.c.areas), args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := chargeCost(ctx, 1+n); err != nil {
+		return nil, err
+	}
+	res := make([]*areaResolver, n)
+	for i, ap := range r.c.areas[:n] {
+		res[i] = &areaResolver{ap: ap, city: r.c}
+	}
+	return res, nil
+}
+
+type areaResolver struct {
+	ap   *jp.AddressPosition
+	city *cityNode
+}
+
+func (r *areaResolver) Code() string                    { return r.ap.AreaCode }
+func (r *areaResolver) Name() string                    { return r.ap.AreaName }
+func (r *areaResolver) Latitude() float64               { return r.ap.Latitude }
+func (r *areaResolver) Longitude() float64              { return r.ap.Longitude }
+func (r *areaResolver) City() *cityResolver             { return &cityResolver{r.city} }
+func (r *areaResolver) Prefecture() *prefectureResolver { return &prefectureResolver{r.city.pref} }
+
+type nearbyAreaResolver struct {
+	area     *areaResolver
+	distance *float64
+}
+
+func (r *nearbyAreaResolver) Area() *areaResolver { return r.area }
+func (r *nearbyAreaResolver) Distance() *float64  { return r.distance }
+
+type graphqlRequest struct {
+	Query         string                 `json:"query" doc:"The GraphQL document."`
+	OperationName string                 `json:"operationName,omitempty" doc:"The operation to run if the document has several."`
+	Variables     map[string]interface{} `json:"variables,omitempty" doc:"Values of the variables of the operation."`
+}
+
+// graphqlHandler runs a GraphQL query given as query parameters of a GET
+// request or as the JSON body of a POST request. The depth and the cost of
+// the query are limited.
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid variables")
+				return
+			}
+		}
+	case http.MethodPost:
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, "body must be JSON")
+			return
+		}
+		body := io.LimitReader(r.Body, maxGraphQLRequestSize)
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid body")
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, "missing query")
+		return
+	}
+
+	ctx := withGraphQLCost(r.Context(), maxGraphQLCost)
+	res := appGraphQL.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+func TestGraphQLQueries(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, nil, time.Now())
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			"prefectures",
+			`{ prefectures { code name kanaName romaName cities { code name } } }`,
+			`{"data":{"prefectures":[{"code":"13","name":"東京都","kanaName":"トウキョウト","romaName":"TOKYO TO","cities":[{"code":"13103","name":"港区"}]}]}}`,
+		},
+		{
+			"city",
+			`{ city(code: "13103") { name prefecture { name } areas(limit: 2) { code name } } }`,
+			`{"data":{"city":{"name":"港区","prefecture":{"name":"東京都"},"areas":[{"code":"131030001002","name":"虎ノ門二丁目"},{"code":"131030001003","name":"虎ノ門三丁目"}]}}}`,
+		},
+		{
+			"area",
+			`{ area(code: "131030002003") { name latitude longitude city { name } prefecture { code } } }`,
+			`{"data":{"area":{"name":"芝公園三丁目","latitude":35.659943,"longitude":139.747207,"city":{"name":"港区"},"prefecture":{"code":"13"}}}}`,
+		},
+		{
+			"unknown code",
+			`{ prefecture(code: "99") { name } }`,
+			`{"data":{"prefecture":null}}`,
+		},
+		{
+			"reverse",
+			`{ reverse(position: {latitude: 35.658584, longitude: 139.7454316}) { area { code name } distance } }`,
+			`{"data":{"reverse":{"area":{"code":"131030002003","name":"芝公園三丁目"},"distance":220.37123693585445}}}`,
+		},
+		{
+			"search",
+			`{ search(areaName: "芝公園", limit: 2) { area { name } distance } }`,
+			`{"data":{"search":[{"area":{"name":"芝公園三丁目"},"distance":null},{"area":{"name":"芝公園四丁目"},"distance":null}]}}`,
+		},
+		{
+			"search with origin",
+			`{ search(areaName: "芝公園三丁目", origin: {latitude: 35.658584, longitude: 139.7454316}) { area { name } distance } }`,
+			`{"data":{"search":[{"area":{"name":"芝公園三丁目"},"distance":220.37123693585445}]}}`,
+		},
+		{
+			"near",
+			`{ near(position: {latitude: 35.658584, longitude: 139.7454316}, radius: 300) { area { name } distance } }`,
+			`{"data":{"near":[{"area":{"name":"芝公園三丁目"},"distance":220.37123693585445},{"area":{"name":"東麻布一丁目"},"distance":257.53983715026243}]}}`,
+		},
+		{
+			"near without zoom or radius",
+			`{ near(position: {latitude: 35.658584, longitude: 139.7454316}) { distance } }`,
+			`{"errors":[{"message":"zoom or radius is required","path":["near"]}],"data":null}`,
+		},
+		{
+			"limit over max",
+			`{ search(areaName: "芝公園", limit: 1001) { distance } }`,
+			`{"errors":[{"message":"limit must be from 0 to 1000","path":["search"]}],"data":null}`,
+		},
+		{
+			"out of range",
+			`{ reverse(position: {latitude: 91, longitude: 0}) { distance } }`,
+			`{"errors":[{"message":"position is out of range","path":["reverse"]}],"data":{"reverse":null}}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			target := "http://example.com/graphql?query=" + url.QueryEscape(tt.query)
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			got := httptest.NewRecorder()
+			graphqlHandler(got, req)
+			if got.Code != http.StatusOK {
+				t.Errorf("want = %v, got = %v", http.StatusOK, got.Code)
+			}
+			if got := strings.TrimSpace(got.Body.String()); got != tt.want {
+				t.Errorf("\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGraphQLCost(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, nil, time.Now())
+
+	// The dataset has one prefecture, so each prefectures field costs two.
+	const query = `{ a: prefectures { code } b: prefectures { code } }`
+	tests := []struct {
+		limit      int
+		wantErrors int
+	}{
+		{4, 0},
+		{3, 1},
+	}
+	for _, tt := range tests {
+		res := appGraphQL.Exec(withGraphQLCost(context.Background(), tt.limit), query, "", nil)
+		if got := len(res.Errors); got != tt.wantErrors {
+			t.Errorf("%d: want = %v, got = %v", tt.limit, tt.wantErrors, res.Errors)
+		}
+		for _, e := range res.Errors {
+			if want := "query exceeds the maximum cost 3"; e.Message != want {
+				t.Errorf("want = %v, got = %v", want, e.Message)
+			}
+		}
+	}
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, nil, time.Now())
+
+	const query = `query Area($code: String!) { area(code: $code) { name } }`
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantCode    int
+		want        string
+	}{
+		{
+			"post", http.MethodPost, "/graphql", "application/json; charset=utf-8",
+			`{"query":"query Area($code: String!) { area(code: $code) { name } }","variables":{"code":"131030002003"}}`,
+			http.StatusOK, `{"data":{"area":{"name":"芝公園三丁目"}}}`,
+		},
+		{
+			"get with variables", http.MethodGet,
+			"/graphql?" + url.Values{"query": {query}, "variables": {`{"code":"131030002003"}`}}.Encode(),
+			"", "", http.StatusOK, `{"data":{"area":{"name":"芝公園三丁目"}}}`,
+		},
+		{
+			"operation name", http.MethodPost, "/graphql", "application/json",
+			`{"query":"query A { prefectures { code } } query B { city(code: \"13103\") { name } }","operationName":"B"}`,
+			http.StatusOK, `{"data":{"city":{"name":"港区"}}}`,
+		},
+		{
+			"too deep", http.MethodPost, "/graphql", "application/json",
+			`{"query":"{ area(code: \"131030002003\") { city { prefecture { cities { prefecture { cities { prefecture { cities { code } } } } } } } } }"}`,
+			http.StatusOK, `{"errors":[{"message":"Field \"code\" has depth 9 that exceeds max depth 8","locations":[{"line":1,"column":105}]}]}`,
+		},
+		{"form", http.MethodPost, "/graphql", "application/x-www-form-urlencoded", "query=%7Bprefectures%7Bcode%7D%7D",
+			http.StatusUnsupportedMediaType, "body must be JSON"},
+		{"invalid json", http.MethodPost, "/graphql", "application/json", `{"query":`, http.StatusBadRequest, "invalid body"},
+		{"no query", http.MethodPost, "/graphql", "application/json", `{}`, http.StatusBadRequest, "missing query"},
+		{"invalid variables", http.MethodGet, "/graphql?" + url.Values{"query": {query}, "variables": {"{"}}.Encode(),
+			"", "", http.StatusBadRequest, "invalid variables"},
+		{"method", http.MethodDelete, "/graphql", "", "", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			got := httptest.NewRecorder()
+			graphqlHandler(got, req)
+			if got.Code != tt.wantCode {
+				t.Errorf("want = %v, got = %v", tt.wantCode, got.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				if got := errorMessage(t, got); got != tt.want {
+					t.Errorf("want = %v, got = %v", tt.want, got)
+				}
+				return
+			}
+			if got := strings.TrimSpace(got.Body.String()); got != tt.want {
+				t.Errorf("\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// rpcHooks apply the API keys, the rate limits, the access log and the
+// metrics of the HTTP APIs to the gRPC service. The API key is given in the
+// x-api-key or authorization metadata like the headers of HTTP requests.
+type rpcHooks struct {
+	guard   *guard
+	metrics *metrics
+	logger  *logger
+}
+
+func (h rpcHooks) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(h.unary),
+		grpc.StreamInterceptor(h.stream),
+	}
+}
+
+// unary serves an RPC if the client is authenticated and within its limits.
+func (h rpcHooks) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	id := rpcRequestID(ctx)
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestIDHeader), id))
+	key, err := h.authenticate(ctx)
+	if err == nil {
+		err = h.allow(ctx, key)
+	}
+	var res interface{}
+	if err == nil {
+		res, err = handler(ctx, req)
+	}
+	h.done(ctx, id, info.FullMethod, err, time.Since(start))
+	return res, err
+}
+
+// stream serves a streaming RPC if the client is authenticated, and charges
+// each message it sends against its limits.
+func (h rpcHooks) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := ss.Context()
+	id := rpcRequestID(ctx)
+	ss.SetHeader(metadata.Pairs(strings.ToLower(requestIDHeader), id))
+	key, err := h.authenticate(ctx)
+	if err == nil {
+		err = handler(srv, &guardedStream{ServerStream: ss, hooks: h, key: key})
+	}
+	h.done(ctx, id, info.FullMethod, err, time.Since(start))
+	return err
+}
+
+// authenticate returns the API key of the RPC, which is nil when
+// authentication is disabled.
+func (h rpcHooks) authenticate(ctx context.Context) (*apiKey, error) {
+	if !h.guard.authEnabled() {
+		return nil, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := h.guard.find(firstValue(md, strings.ToLower(apiKeyHeader)), firstValue(md, "authorization"))
+	if key == nil {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid API key")
+	}
+	return key, nil
+}
+
+func (h rpcHooks) allow(ctx context.Context, key *apiKey) error {
+	if ok, wait := h.guard.allow(peerIP(ctx), key); !ok {
+		return status.Errorf(codes.ResourceExhausted,
+			"rate limit or daily quota exceeded, retry after %d seconds", retryAfter(wait))
+	}
+	return nil
+}
+
+// done measures and logs a finished RPC. Client errors are logged as
+// warnings and server errors as errors.
+func (h rpcHooks) done(ctx context.Context, id, method string, err error, latency time.Duration) {
+	code := status.Code(err)
+	h.metrics.observeRPC(method, code, latency)
+
+	level := levelWarn
+	switch code {
+	case codes.OK:
+		level = levelInfo
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		level = levelError
+	}
+	h.logger.log(level, "rpc",
+		field{"request_id", id},
+		field{"method", method},
+		field{"code", code.String()},
+		field{"latency_ms", float64(latency.Microseconds()) / 1000},
+		field{"client_ip", peerIP(ctx)},
+	)
+}
+
+// guardedStream charges each message received from the client against its
+// limits.
+type guardedStream struct {
+	grpc.ServerStream
+	hooks rpcHooks
+	key   *apiKey
+}
+
+func (s *guardedStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.hooks.allow(s.Context(), s.key)
+}
+
+// rpcRequestID returns the request ID sent by the client in the metadata if
+// it is valid, and a new ID otherwise.
+func rpcRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if id := firstValue(md, strings.ToLower(requestIDHeader)); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if v := md.Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// peerIP returns the IP address of the client of the RPC.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/grpcapi"
+	"github.com/twihike/go-geojp/pkg/grpcapi/geojppb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var testPosition = &geojppb.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+
+// newTestRPCClient serves the test dataset over an in-memory connection with
+// the hooks.
+func newTestRPCClient(t *testing.T, hooks rpcHooks) (geojppb.GeoJPClient, func()) {
+	t.Helper()
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(hooks.serverOptions()...)
+	geojppb.RegisterGeoJPServer(s, grpcapi.NewServer(a, jp.CreateAPIndex(a, geo.Spherical)))
+	go s.Serve(lis)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return geojppb.NewGeoJPClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestRPCHooksAuth(t *testing.T) {
+	g, _ := newTestGuard(testAPIKeys, 0, 0)
+	var logs bytes.Buffer
+	m := newMetrics()
+	hooks := rpcHooks{guard: g, metrics: m, logger: &logger{out: &logs, level: levelInfo, now: time.Now}}
+	client, stop := newTestRPCClient(t, hooks)
+	defer stop()
+
+	tests := []struct {
+		name string
+		md   []string
+		want codes.Code
+	}{
+		{"no key", nil, codes.Unauthenticated},
+		{"unknown key", []string{"x-api-key", "unknown"}, codes.Unauthenticated},
+		{"key", []string{"x-api-key", "admin-key"}, codes.OK},
+		{"bearer", []string{"authorization", "Bearer admin-key"}, codes.OK},
+		{"basic", []string{"authorization", "Basic admin-key"}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs(tt.md...))
+		var header metadata.MD
+		_, err := client.ReverseGeocode(ctx, &geojppb.ReverseGeocodeRequest{Position: testPosition}, grpc.Header(&header))
+		if got := status.Code(err); got != tt.want {
+			t.Errorf("%s: want = %v, got = %v", tt.name, tt.want, got)
+		}
+		if got := header.Get("x-request-id"); len(got) != 1 || got[0] == "" {
+			t.Errorf("%s: want = %v, got = %v", tt.name, "x-request-id", header)
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "unknown"))
+	stream, err := client.Batch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("want = %v, got = %v", codes.Unauthenticated, err)
+	}
+
+	var metrics bytes.Buffer
+	if err := m.write(&metrics); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`geojp_grpc_requests_total{code="OK",method="/geojp.v1.GeoJP/ReverseGeocode"} 2` + "\n",
+		`geojp_grpc_requests_total{code="Unauthenticated",method="/geojp.v1.GeoJP/ReverseGeocode"} 3` + "\n",
+		`geojp_grpc_requests_total{code="Unauthenticated",method="/geojp.v1.GeoJP/Batch"} 1` + "\n",
+		`geojp_grpc_request_duration_seconds_count{method="/geojp.v1.GeoJP/ReverseGeocode"} 5` + "\n",
+	} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("\nwant = %v\ngot  = %v", want, metrics.String())
+		}
+	}
+	if got := strings.Count(logs.String(), `"msg":"rpc"`); got != len(tests)+1 {
+		t.Errorf("want = %v, got = %v", len(tests)+1, got)
+	}
+	if want := `"level":"warn","msg":"rpc"`; !strings.Contains(logs.String(), want) {
+		t.Errorf("\nwant = %v\ngot  = %v", want, logs.String())
+	}
+}
+
+func TestRPCHooksRateLimit(t *testing.T) {
+	g, _ := newTestGuard(testAPIKeys, 0, 0)
+	hooks := rpcHooks{guard: g, metrics: newMetrics(), logger: &logger{out: &bytes.Buffer{}, level: levelError, now: time.Now}}
+	client, stop := newTestRPCClient(t, hooks)
+	defer stop()
+	// The partner key has a burst of two requests, and every message of a
+	// stream is charged as a request.
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-api-key", "partner-key"))
+	if _, err := client.Near(ctx, &geojppb.NearRequest{Position: testPosition, Zoom: 16}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.Batch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &geojppb.BatchRequest{Id: "a", Query: &geojppb.BatchRequest_ReverseGeocode{
+		ReverseGeocode: &geojppb.ReverseGeocodeRequest{Position: testPosition},
+	}}
+	for i, want := range []codes.Code{codes.OK, codes.ResourceExhausted} {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		_, err := stream.Recv()
+		if got := status.Code(err); got != want {
+			t.Errorf("#%d: want = %v, got = %v", i, want, got)
+		}
+	}
+	_, err = client.Near(ctx, &geojppb.NearRequest{Position: testPosition, Zoom: 16})
+	if got := status.Code(err); got != codes.ResourceExhausted {
+		t.Errorf("want = %v, got = %v", codes.ResourceExhausted, got)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/geo/layer"
+	"github.com/twihike/go-geojp/pkg/geo/pointindex"
+)
+
+// layersURL is the prefix of the URLs of the layer endpoints, which are
+// followed by the name of the layer and the query.
+const layersURL = "/api/layers/"
+
+// appLayers are the layers of points of interest keyed by name.
+var appLayers = map[string]*layer.Layer{}
+
+var layerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// layerPath is the path of the CSV of a layer.
+type layerPath struct {
+	name string
+	path string
+}
+
+// parseDatasetPaths splits the comma separated entries of ADDR_POS_PATH
+// into the path of the address CSV and the paths of the layers, which are
+// given as name=path.
+func parseDatasetPaths(s string) (string, []layerPath, error) {
+	var addrPath string
+	var layers []layerPath
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			if addrPath != "" {
+				return "", nil, errors.New("more than one address dataset")
+			}
+			addrPath = entry
+			continue
+		}
+		name, path := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if !layerNamePattern.MatchString(name) || path == "" {
+			return "", nil, fmt.Errorf("invalid layer: %q", entry)
+		}
+		if seen[name] {
+			return "", nil, fmt.Errorf("duplicate layer: %s", name)
+		}
+		seen[name] = true
+		layers = append(layers, layerPath{name, path})
+	}
+	if addrPath == "" {
+		return "", nil, errors.New("no address dataset")
+	}
+	return addrPath, layers, nil
+}
+
+// layersVersion returns a hash of the features of the layers.
+func layersVersion(layers map[string]*layer.Layer) string {
+	names := make([]string, 0, len(layers))
+	for name := range layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := fnv.New64a()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		for _, f := range layers[name].Features() {
+			keys := make([]string, 0, len(f.Properties))
+			for k := range f.Properties {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, s := range []string{
+				f.ID, f.Name,
+				strconv.FormatFloat(f.Latitude, 'g', -1, 64),
+				strconv.FormatFloat(f.Longitude, 'g', -1, 64),
+			} {
+				h.Write([]byte(s))
+				h.Write([]byte{0})
+			}
+			for _, k := range keys {
+				h.Write([]byte(k + "=" + f.Properties[k]))
+				h.Write([]byte{0})
+			}
+		}
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+type layerNearestInput struct {
+	Latitude  float64 `strmap:"latitude,required" doc:"Latitude of the position." example:"35.658584"`
+	Longitude float64 `strmap:"longitude,required" doc:"Longitude of the position." example:"139.7454316"`
+}
+
+type layerNearInput struct {
+	Latitude  float64 `strmap:"latitude,required" doc:"Latitude of the position." example:"35.658584"`
+	Longitude float64 `strmap:"longitude,required" doc:"Longitude of the position." example:"139.7454316"`
+	Zoom      int     `strmap:"zoom" doc:"Zoom level from 4 to 23. The features in the tile at the zoom level and its neighbors are returned. Either zoom or radius is required."`
+	Radius    float64 `strmap:"radius" doc:"Radius in meters up to 50000. The features within the radius are returned." example:"1500"`
+	Limit     int     `strmap:"limit" doc:"Maximum number of results, up to 1000. Defaults to 100."`
+}
+
+type layerSearchInput struct {
+	Name      string  `strmap:"name,required" doc:"Name to search for." example:"店"`
+	Latitude  float64 `strmap:"latitude" doc:"Latitude of the current location. Distances are measured from it."`
+	Longitude float64 `strmap:"longitude" doc:"Longitude of the current location."`
+	Sort      string  `strmap:"sort" doc:"Order of the results: distance, name, id or relevance. Defaults to distance with the current location and relevance without it."`
+	Limit     int     `strmap:"limit" doc:"Maximum number of results, up to 1000. Defaults to 100."`
+	Offset    int     `strmap:"offset" doc:"Number of results to skip."`
+	Cursor    string  `strmap:"cursor" doc:"Cursor of the next page from the X-Next-Cursor header of the previous response. Used instead of offset."`
+}
+
+type layerFeatureOutput struct {
+	ID         string            `json:"id" doc:"ID of the feature."`
+	Name       string            `json:"name" doc:"Name of the feature."`
+	Latitude   float64           `json:"latitude" doc:"Latitude of the feature."`
+	Longitude  float64           `json:"longitude" doc:"Longitude of the feature."`
+	Properties map[string]string `json:"properties" doc:"The other columns of the layer."`
+	Distance   float64           `json:"distance,omitempty" doc:"Distance in meters from the position, if given."`
+}
+
+func newLayerFeatureOutput(f layer.NearbyFeature) layerFeatureOutput {
+	return layerFeatureOutput{
+		ID:         f.ID,
+		Name:       f.Name,
+		Latitude:   f.Latitude,
+		Longitude:  f.Longitude,
+		Properties: f.Properties,
+		Distance:   f.Distance,
+	}
+}
+
+var layerQueries = map[string]func(w http.ResponseWriter, r *http.Request, l *layer.Layer){
+	"nearest": layerNearest,
+	"near":    layerNear,
+	"search":  layerSearch,
+}
+
+// layerHandler routes the requests of the layer endpoints by the name of
+// the layer and the query.
+func layerHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, layersURL), "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, "unknown layer")
+		return
+	}
+	l, ok := appLayers[parts[0]]
+	query, ok2 := layerQueries[parts[1]]
+	if !ok || !ok2 {
+		writeError(w, http.StatusNotFound, "unknown layer")
+		return
+	}
+	query(w, r, l)
+}
+
+func layerNearest(w http.ResponseWriter, r *http.Request, l *layer.Layer) {
+	var in layerNearestInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	f, ok := l.Nearest(geo.LatLong{Latitude: in.Latitude, Longitude: in.Longitude})
+	if !ok {
+		writeError(w, http.StatusNotFound, "layer has no features")
+		return
+	}
+	setResultCount(r, 1)
+	writeJSON(w, newLayerFeatureOutput(f))
+}
+
+func layerNear(w http.ResponseWriter, r *http.Request, l *layer.Layer) {
+	var in layerNearInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	p := geo.LatLong{Latitude: in.Latitude, Longitude: in.Longitude}
+	var near []layer.NearbyFeature
+	switch {
+	case in.Limit < 0 || in.Limit > pointindex.MaxSearchLimit:
+		writeError(w, http.StatusBadRequest, "invalid limit")
+		return
+	case in.Zoom != 0 && in.Radius != 0:
+		writeError(w, http.StatusBadRequest, "either zoom or radius is allowed")
+		return
+	case in.Zoom != 0:
+		if in.Zoom < minZoomLevel || in.Zoom > maxZoomLevel {
+			writeError(w, http.StatusBadRequest, "invalid zoom")
+			return
+		}
+		near = l.Near(p, in.Zoom)
+	case in.Radius != 0:
+		if !(in.Radius > 0 && in.Radius <= jp.MaxNearRadius) {
+			writeError(w, http.StatusBadRequest, "invalid radius")
+			return
+		}
+		near = l.WithinRadius(p, in.Radius)
+	default:
+		writeError(w, http.StatusBadRequest, "either zoom or radius is required")
+		return
+	}
+	limit := in.Limit
+	if limit == 0 {
+		limit = pointindex.DefaultSearchLimit
+	}
+	if len(near) > limit {
+		near = near[:limit]
+	}
+
+	body := make([]layerFeatureOutput, len(near))
+	for i, f := range near {
+		body[i] = newLayerFeatureOutput(f)
+	}
+	setResultCount(r, len(body))
+	writeJSON(w, body)
+}
+
+func layerSearch(w http.ResponseWriter, r *http.Request, l *layer.Layer) {
+	var in layerSearchInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	if in.Cursor != "" {
+		offset, err := decodeCursor(in.Cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+		in.Offset = offset
+	}
+
+	opts := layer.SearchOptions{Offset: in.Offset, Limit: in.Limit}
+	origin, err := originParam(r, in.Latitude, in.Longitude)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Origin = origin
+	if in.Sort != "" {
+		var err error
+		if opts.Sort, err = layer.ParseSortOrder(in.Sort); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid sort order")
+			return
+		}
+	}
+	res, err := l.Search(in.Name, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body := make([]layerFeatureOutput, len(res.Features))
+	for i, f := range res.Features {
+		body[i] = newLayerFeatureOutput(f)
+	}
+	setResultCount(r, len(body))
+	setPageHeaders(w.Header(), r, in.Offset+len(body), res.Total)
+	writeJSON(w, body)
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/layer"
+)
+
+func TestParseDatasetPaths(t *testing.T) {
+	tests := []struct {
+		in         string
+		wantAddr   string
+		wantLayers []layerPath
+		wantErr    bool
+	}{
+		{"latest.csv", "latest.csv", nil, false},
+		{"stores=stores.csv, latest.csv ,depots=/data/depots.csv", "latest.csv",
+			[]layerPath{{"stores", "stores.csv"}, {"depots", "/data/depots.csv"}}, false},
+		{"stores=stores.csv", "", nil, true},
+		{"a.csv,b.csv", "", nil, true},
+		{"latest.csv,s t=stores.csv", "", nil, true},
+		{"latest.csv,stores=", "", nil, true},
+		{"latest.csv,stores=a.csv,stores=b.csv", "", nil, true},
+	}
+	for _, tt := range tests {
+		addr, layers, err := parseDatasetPaths(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: want = %v, got = %v", tt.in, tt.wantErr, err)
+			continue
+		}
+		if addr != tt.wantAddr || !reflect.DeepEqual(layers, tt.wantLayers) {
+			t.Errorf("%s: want = %v %v, got = %v %v", tt.in, tt.wantAddr, tt.wantLayers, addr, layers)
+		}
+	}
+}
+
+func TestLayerHandler(t *testing.T) {
+	stores, err := layer.ReadFile("stores", "../../testdata/stores.csv", geo.Spherical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := layer.New("empty", nil, geo.Spherical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appLayers = map[string]*layer.Layer{"stores": stores, "empty": empty}
+
+	const position = "latitude=35.658584&longitude=139.7454316"
+	tests := []struct {
+		target   string
+		wantCode int
+		want     string
+	}{
+		{
+			"/api/layers/stores/nearest?" + position, http.StatusOK,
+			`{"id":"s001","name":"芝公園店","latitude":35.656459,"longitude":139.74764,"properties":{"category":"store","phone":"03-0000-0001"},"distance":309.2609283463855}`,
+		},
+		{
+			"/api/layers/stores/near?radius=1200&limit=1&" + position, http.StatusOK,
+			`[{"id":"s001","name":"芝公園店","latitude":35.656459,"longitude":139.74764,"properties":{"category":"store","phone":"03-0000-0001"},"distance":309.2609283463855}]`,
+		},
+		{
+			"/api/layers/stores/search?name=倉庫", http.StatusOK,
+			`[{"id":"d001","name":"芝浦倉庫","latitude":35.641346,"longitude":139.752312,"properties":{"category":"depot","phone":""}}]`,
+		},
+		{"/api/layers/stores/near?" + position, http.StatusBadRequest, "either zoom or radius is required"},
+		{"/api/layers/stores/near?zoom=3&" + position, http.StatusBadRequest, "invalid zoom"},
+		{"/api/layers/stores/near?zoom=13&radius=100&" + position, http.StatusBadRequest, "either zoom or radius is allowed"},
+		{"/api/layers/stores/near?radius=50001&" + position, http.StatusBadRequest, "invalid radius"},
+		{"/api/layers/stores/near?radius=1200&limit=1001&" + position, http.StatusBadRequest, "invalid limit"},
+		{"/api/layers/stores/search?name=店&latitude=35.658584", http.StatusBadRequest, "latitude and longitude must be given together"},
+		{"/api/layers/stores/search?name=店&sort=distance", http.StatusBadRequest, `sort order "distance" requires an origin`},
+		{"/api/layers/stores/nearest", http.StatusBadRequest, ""},
+		{"/api/layers/empty/nearest?" + position, http.StatusNotFound, "layer has no features"},
+		{"/api/layers/depots/nearest?" + position, http.StatusNotFound, "unknown layer"},
+		{"/api/layers/stores/farthest?" + position, http.StatusNotFound, "unknown layer"},
+		{"/api/layers/stores?" + position, http.StatusNotFound, "unknown layer"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.target, nil)
+		got := httptest.NewRecorder()
+		layerHandler(got, req)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s: want = %v, got = %v", tt.target, tt.wantCode, got.Code)
+		}
+		if tt.wantCode != http.StatusOK {
+			// The message of a parameter error is not checked.
+			if got := errorMessage(t, got); got == "" || tt.want != "" && got != tt.want {
+				t.Errorf("%s: want = %v, got = %v", tt.target, tt.want, got)
+			}
+			continue
+		}
+		if got := strings.TrimSpace(got.Body.String()); got != tt.want {
+			t.Errorf("%s:\nwant = %v\ngot  = %v", tt.target, tt.want, got)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/layers/stores/search?name=店&sort=id&limit=2", nil)
+	got := httptest.NewRecorder()
+	layerHandler(got, req)
+	if got, want := got.Header().Get("X-Total-Count"), "4"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := got.Header().Get("X-Next-Cursor"); got != encodeCursor(2) {
+		t.Errorf("want = %v, got = %v", encodeCursor(2), got)
+	}
+}
@@ -0,0 +1,312 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// durationBuckets are the upper bounds of the request latency histogram in
+// seconds.
+var durationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type requestKey struct {
+	handler string
+	method  string
+	code    int
+}
+
+type rpcKey struct {
+	method string
+	code   codes.Code
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// metrics collects the metrics of the server and writes them in the
+// Prometheus text exposition format.
+type metrics struct {
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+	// rpcs and rpcDurations are the metrics of the gRPC service keyed by
+	// the full method name.
+	rpcs         map[rpcKey]uint64
+	rpcDurations map[string]*histogram
+
+	records            int
+	indexBuildDuration time.Duration
+	loadTime           time.Time
+}
+
+var appMetrics = newMetrics()
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests:     map[requestKey]uint64{},
+		durations:    map[string]*histogram{},
+		rpcs:         map[rpcKey]uint64{},
+		rpcDurations: map[string]*histogram{},
+	}
+}
+
+// setDataset records the statistics of the loaded dataset.
+func (m *metrics) setDataset(records int, indexBuildDuration time.Duration, loadTime time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = records
+	m.indexBuildDuration = indexBuildDuration
+	m.loadTime = loadTime
+}
+
+func (m *metrics) observe(handler, method string, code int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{handler, method, code}]++
+	observeDuration(m.durations, handler, d)
+}
+
+// observeRPC counts an RPC by method and status code and measures its
+// latency.
+func (m *metrics) observeRPC(method string, code codes.Code, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rpcs[rpcKey{method, code}]++
+	observeDuration(m.rpcDurations, method, d)
+}
+
+func observeDuration(durations map[string]*histogram, name string, d time.Duration) {
+	h, ok := durations[name]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		durations[name] = h
+	}
+	s := d.Seconds()
+	for i, b := range durationBuckets {
+		if s <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += s
+}
+
+// middleware counts the requests and measures their latency by the pattern
+// of the handler that serves them.
+func (m *metrics) middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		rec := newStatusRecorder(w)
+		start := time.Now()
+		mux.ServeHTTP(rec, r)
+		m.observe(pattern, methodLabel(r.Method), rec.status, time.Since(start))
+	})
+}
+
+// methodLabel returns the method as a label value. Other methods than the
+// standard ones are counted together so that clients cannot create an
+// unbounded number of series.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+func (m *metrics) handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (m *metrics) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	m.mu.Lock()
+	m.writeRequests(bw)
+	writeDurations(bw, "geojp_http_request_duration_seconds", "Latency of HTTP requests.", "handler", m.durations)
+	m.writeRPCs(bw)
+	writeDurations(bw, "geojp_grpc_request_duration_seconds", "Latency of gRPC requests.", "method", m.rpcDurations)
+	writeMetric(bw, "geojp_dataset_records", "gauge",
+		"Number of address positions in the dataset.", "", float64(m.records))
+	writeMetric(bw, "geojp_index_build_duration_seconds", "gauge",
+		"Time taken to build the spatial index.", "", m.indexBuildDuration.Seconds())
+	var loaded float64
+	if !m.loadTime.IsZero() {
+		loaded = float64(m.loadTime.UnixNano()) / 1e9
+	}
+	writeMetric(bw, "geojp_dataset_load_timestamp_seconds", "gauge",
+		"Unix time when the dataset was loaded.", "", loaded)
+	m.mu.Unlock()
+	writeRuntimeMetrics(bw)
+	return bw.Flush()
+}
+
+func (m *metrics) writeRequests(w io.Writer) {
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.handler != b.handler {
+			return a.handler < b.handler
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	writeHeader(w, "geojp_http_requests_total", "counter", "Total number of HTTP requests.")
+	for _, k := range keys {
+		labels := formatLabels("code", strconv.Itoa(k.code), "handler", k.handler, "method", k.method)
+		writeSample(w, "geojp_http_requests_total", labels, float64(m.requests[k]))
+	}
+}
+
+func (m *metrics) writeRPCs(w io.Writer) {
+	keys := make([]rpcKey, 0, len(m.rpcs))
+	for k := range m.rpcs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	writeHeader(w, "geojp_grpc_requests_total", "counter", "Total number of gRPC requests.")
+	for _, k := range keys {
+		labels := formatLabels("code", k.code.String(), "method", k.method)
+		writeSample(w, "geojp_grpc_requests_total", labels, float64(m.rpcs[k]))
+	}
+}
+
+// writeDurations writes the latency histograms keyed by the value of the
+// label.
+func writeDurations(w io.Writer, name, help, label string, durations map[string]*histogram) {
+	values := make([]string, 0, len(durations))
+	for v := range durations {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	writeHeader(w, name, "histogram", help)
+	for _, v := range values {
+		h := durations[v]
+		for i, b := range durationBuckets {
+			labels := formatLabels(label, v, "le", formatFloat(b))
+			writeSample(w, name+"_bucket", labels, float64(h.counts[i]))
+		}
+		writeSample(w, name+"_bucket", formatLabels(label, v, "le", "+Inf"), float64(h.count))
+		writeSample(w, name+"_sum", formatLabels(label, v), h.sum)
+		writeSample(w, name+"_count", formatLabels(label, v), float64(h.count))
+	}
+}
+
+func writeRuntimeMetrics(w io.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeMetric(w, "go_goroutines", "gauge",
+		"Number of goroutines that currently exist.", "", float64(runtime.NumGoroutine()))
+	writeMetric(w, "go_info", "gauge",
+		"Information about the Go environment.", formatLabels("version", runtime.Version()), 1)
+	writeMetric(w, "go_memstats_alloc_bytes", "gauge",
+		"Number of bytes allocated and still in use.", "", float64(ms.Alloc))
+	writeMetric(w, "go_memstats_sys_bytes", "gauge",
+		"Number of bytes obtained from the system.", "", float64(ms.Sys))
+	writeMetric(w, "go_memstats_heap_objects", "gauge",
+		"Number of allocated objects.", "", float64(ms.HeapObjects))
+	writeMetric(w, "go_memstats_gc_cycles_total", "counter",
+		"Number of completed GC cycles.", "", float64(ms.NumGC))
+	writeMetric(w, "go_memstats_gc_pause_seconds_total", "counter",
+		"Total time spent in GC stop-the-world pauses.", "", float64(ms.PauseTotalNs)/1e9)
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeSample(w io.Writer, name, labels string, v float64) {
+	fmt.Fprintf(w, "%s%s %s\n", name, labels, formatFloat(v))
+}
+
+func writeMetric(w io.Writer, name, typ, help, labels string, v float64) {
+	writeHeader(w, name, typ, help)
+	writeSample(w, name, labels, v)
+}
+
+// formatLabels formats label name and value pairs.
+func formatLabels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(pairs[i+1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// statusRecorder records the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack lets WebSocket handlers take over the connection, which is recorded
+// as switching protocols.
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
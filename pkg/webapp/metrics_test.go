@@ -0,0 +1,95 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	m.setDataset(42, 1500*time.Millisecond, time.Unix(1600000000, 0))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/api/bad", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	mux.HandleFunc("/metrics", m.handler)
+	handler := m.middleware(mux)
+
+	requests := []struct {
+		method string
+		target string
+	}{
+		{http.MethodGet, "/api/ok"},
+		{http.MethodGet, "/api/ok"},
+		{http.MethodPost, "/api/ok"},
+		{http.MethodPost, "/api/bad"},
+		{"FOO", "/api/ok"},
+		{"BAR", "/api/ok"},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, "http://example.com"+r.target, nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/metrics", nil)
+	got := httptest.NewRecorder()
+	handler.ServeHTTP(got, req)
+	if got.Code != http.StatusOK {
+		t.Errorf("want = %v, got = %v", http.StatusOK, got.Code)
+	}
+	if got, want := got.Header().Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+
+	body := got.Body.String()
+	wants := []string{
+		"# TYPE geojp_http_requests_total counter\n",
+		`geojp_http_requests_total{code="200",handler="/api/ok",method="GET"} 2` + "\n",
+		`geojp_http_requests_total{code="200",handler="/api/ok",method="POST"} 1` + "\n",
+		`geojp_http_requests_total{code="400",handler="/api/bad",method="POST"} 1` + "\n",
+		`geojp_http_requests_total{code="200",handler="/api/ok",method="OTHER"} 2` + "\n",
+		"# TYPE geojp_http_request_duration_seconds histogram\n",
+		`geojp_http_request_duration_seconds_bucket{handler="/api/ok",le="+Inf"} 5` + "\n",
+		`geojp_http_request_duration_seconds_count{handler="/api/bad"} 1` + "\n",
+		"geojp_dataset_records 42\n",
+		"geojp_index_build_duration_seconds 1.5\n",
+		"geojp_dataset_load_timestamp_seconds 1.6e+09\n",
+		"# TYPE go_goroutines gauge\n",
+		"# TYPE go_memstats_alloc_bytes gauge\n",
+	}
+	for _, want := range wants {
+		if !strings.Contains(body, want) {
+			t.Errorf("\nwant = %v\ngot  = %v", want, body)
+		}
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{[]string{}, "{}"},
+		{[]string{"a", "b"}, `{a="b"}`},
+		{[]string{"a", "b", "c", "d"}, `{a="b",c="d"}`},
+		{[]string{"a", "x\"y\\z\n"}, `{a="x\"y\\z\n"}`},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+			if got := formatLabels(tt.in...); got != tt.want {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
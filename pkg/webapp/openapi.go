@@ -0,0 +1,659 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// object is a JSON object of the OpenAPI document.
+type object = map[string]interface{}
+
+// openAPIDocument returns the OpenAPI 3 document of the API. The parameters
+// and schemas are derived from the input and output types of the handlers
+// and their doc and example tags.
+func openAPIDocument() object {
+	query := func(summary, description string, in interface{}, ok object) object {
+		return formOperations(summary, description, reflect.TypeOf(in), ok)
+	}
+	return object{
+		"openapi": "3.0.3",
+		"info": object{
+			"title":       "geojp API",
+			"version":     "1.0.0",
+			"description": "Geocoding and reverse geocoding of Japanese addresses.",
+			"license":     object{"name": "MIT"},
+		},
+		"paths": object{
+			"/api/geocoding": query(
+				"Geocoding",
+				"Finds areas by name.",
+				geocodingInput{},
+				pagedResponse("Areas with the name.", arrayOf(ref("GeocodingResult"))),
+			),
+			"/api/reverse-geocoding": addResponse(query(
+				"Reverse geocoding",
+				"Finds the area nearest to a position, or the areas around it if zoom is given. "+
+					"The prefecture and city filters restrict the areas before the search.",
+				reverseGeocodingInput{},
+				jsonResponse("The nearest area, or an array of areas if zoom is given.", object{
+					"oneOf": []interface{}{
+						ref("ReverseGeocodingResult"),
+						arrayOf(ref("ReverseGeocodingResult")),
+					},
+				}),
+			), "404", errorResponse("No area matches the prefecture and city filters.")),
+			"/api/route-reverse-geocoding": query(
+				"Route reverse geocoding",
+				"Finds the areas traversed by a route in order.",
+				routeReverseGeocodingInput{},
+				jsonResponse("Areas traversed by the route.", arrayOf(ref("RouteArea"))),
+			),
+			staticMapURL: query(
+				"Static map",
+				"Renders a PNG image of the map with the representative points of the areas around the center "+
+					"and the markers. The background tiles are drawn from TILES_PATH if configured.",
+				staticMapInput{},
+				object{
+					"description": "The image of the map.",
+					"content":     object{"image/png": object{"schema": object{"type": "string", "format": "binary"}}},
+				},
+			),
+			streamURL: object{"get": streamOperation()},
+			layersURL + "{name}/nearest": layerOperations(
+				"Layer nearest",
+				"Finds the feature of a layer nearest to a position.",
+				reflect.TypeOf(layerNearestInput{}),
+				jsonResponse("The nearest feature.", ref("LayerFeature")),
+			),
+			layersURL + "{name}/near": layerOperations(
+				"Layer near",
+				"Finds the features of a layer around a position, closest first.",
+				reflect.TypeOf(layerNearInput{}),
+				jsonResponse("Features around the position.", arrayOf(ref("LayerFeature"))),
+			),
+			layersURL + "{name}/search": layerOperations(
+				"Layer search",
+				"Finds the features of a layer by name.",
+				reflect.TypeOf(layerSearchInput{}),
+				pagedResponse("Features with the name.", arrayOf(ref("LayerFeature"))),
+			),
+			"/graphql":             graphqlOperations(),
+			geofencesURL:           geofenceOperations(),
+			geofencesURL + "/{id}": zoneOperations(),
+			positionsURL: object{
+				"post": bodyOperation(
+					"Position",
+					"Records the position of a device and returns the enter, exit and dwell events "+
+						"of the geofences it causes. The events are also posted to WEBHOOK_URL if configured.",
+					"postPosition",
+					reflect.TypeOf(positionInput{}),
+					object{
+						"200": jsonResponse("Events of the position, annotated with the area nearest to it.", arrayOf(ref("GeofenceEvent"))),
+						"409": errorResponse("The position is older than the last position of the device."),
+						"503": errorResponse("The client tracks GEOFENCE_MAX_DEVICES devices."),
+					},
+				),
+			},
+			conf.HealthCheckURL: object{
+				"get": object{
+					"summary":     "Health check",
+					"operationId": "health",
+					"responses": object{
+						"200": jsonResponse("The server is up.", object{
+							"type":       "object",
+							"properties": object{"status": object{"type": "string", "enum": []string{"up"}}},
+							"required":   []string{"status"},
+						}),
+					},
+				},
+			},
+			conf.AdminUsageURL: object{
+				"get": object{
+					"summary":     "API key usage",
+					"description": "Usage of every API key. Available only with API keys and requires an admin key.",
+					"operationId": "adminUsage",
+					"security":    []object{{"ApiKey": []string{}}, {"Bearer": []string{}}},
+					"responses": object{
+						"200": jsonResponse("Usage sorted by label.", arrayOf(ref("ClientUsage"))),
+						"401": ref("#/components/responses/Unauthorized"),
+						"403": errorResponse("The API key is not an admin key."),
+					},
+				},
+			},
+		},
+		"components": object{
+			"schemas": object{
+				"GeocodingResult":        schemaOf(reflect.TypeOf(geocodingOutput{})),
+				"ReverseGeocodingResult": schemaOf(reflect.TypeOf(reverseGeocodingOutput{})),
+				"RouteArea":              schemaOf(reflect.TypeOf(routeReverseGeocodingOutput{})),
+				"ClientUsage":            schemaOf(reflect.TypeOf(clientUsage{})),
+				"LayerFeature":           schemaOf(reflect.TypeOf(layerFeatureOutput{})),
+				"GraphQLRequest":         schemaOf(reflect.TypeOf(graphqlRequest{})),
+				"StreamPosition":         schemaOf(reflect.TypeOf(streamPosition{})),
+				"StreamArea":             schemaOf(reflect.TypeOf(streamAreaOutput{})),
+				"StreamError":            schemaOf(reflect.TypeOf(streamErrorOutput{})),
+				"Error":                  schemaOf(reflect.TypeOf(errorOutput{})),
+				"Geofence":               schemaOf(reflect.TypeOf(geofenceOutput{})),
+				"GeofenceEvent":          schemaOf(reflect.TypeOf(geofenceEventOutput{})),
+				"GraphQLResponse": object{
+					"type": "object",
+					"properties": object{
+						"data": object{"description": "The result of the query in the shape of its selection."},
+						"errors": arrayOf(object{
+							"type": "object",
+							"properties": object{
+								"message": object{"type": "string"},
+								"locations": arrayOf(object{
+									"type": "object",
+									"properties": object{
+										"line":   object{"type": "integer"},
+										"column": object{"type": "integer"},
+									},
+								}),
+								"path":       arrayOf(object{}),
+								"extensions": object{},
+							},
+							"required": []string{"message"},
+						}),
+					},
+				},
+			},
+			"responses": object{
+				"NotModified":          object{"description": "The response matching If-None-Match has not changed."},
+				"BadRequest":           errorResponse("A parameter is missing, invalid or repeated, or the body is malformed."),
+				"UnsupportedMediaType": errorResponse("The body is neither a form nor JSON."),
+				"Unauthorized": withHeaders(errorResponse("The API key is missing or invalid."), object{
+					"WWW-Authenticate": object{"schema": object{"type": "string"}},
+				}),
+				"TooManyRequests": withHeaders(errorResponse("The rate limit or the daily quota of the client is exceeded."), object{
+					"Retry-After": object{
+						"description": "Seconds until the request may be retried.",
+						"schema":      object{"type": "integer"},
+					},
+				}),
+				"InternalServerError": object{
+					"description": "An unexpected error occurred.",
+					"content":     object{"text/plain": object{"schema": object{"type": "string"}}},
+				},
+			},
+			"securitySchemes": object{
+				"ApiKey": object{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+				"Bearer": object{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// formOperations returns GET and POST operations that take the fields of the
+// input type as query parameters and as a form or JSON body respectively.
+// GET responses may be cached by shared caches, and POST responses may not.
+func formOperations(summary, description string, in reflect.Type, ok object) object {
+	operationID := strings.Join(strings.Fields(strings.ToLower(summary)), "-")
+	operationID = lowerCamel(operationID)
+	responses := func() object {
+		return object{
+			"200": ok,
+			"400": ref("#/components/responses/BadRequest"),
+			"401": ref("#/components/responses/Unauthorized"),
+			"429": ref("#/components/responses/TooManyRequests"),
+			"500": ref("#/components/responses/InternalServerError"),
+		}
+	}
+	get := responses()
+	get["304"] = ref("#/components/responses/NotModified")
+	post := responses()
+	post["415"] = ref("#/components/responses/UnsupportedMediaType")
+	// The API keys are optional unless the server is configured with them.
+	security := []object{{}, {"ApiKey": []string{}}, {"Bearer": []string{}}}
+
+	var params []object
+	properties := object{}
+	var required []string
+	for _, f := range inputFields(in) {
+		params = append(params, object{
+			"name":        f.name,
+			"in":          "query",
+			"description": f.doc,
+			"required":    f.required,
+			"schema":      f.schema,
+		})
+		properties[f.name] = f.schema
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	form := object{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		form["required"] = required
+	}
+	return object{
+		"get": object{
+			"summary":     summary,
+			"description": description + " The response is cacheable and carries an ETag.",
+			"operationId": operationID,
+			"parameters":  params,
+			"security":    security,
+			"responses":   get,
+		},
+		"post": object{
+			"summary":     summary,
+			"description": description + " Parameters in the query string are added to the body. The response is not cacheable.",
+			"operationId": operationID + "Form",
+			"requestBody": object{
+				"required": true,
+				"content": object{
+					"application/x-www-form-urlencoded": object{"schema": form},
+					"application/json":                  object{"schema": form},
+				},
+			},
+			"security":  security,
+			"responses": post,
+		},
+	}
+}
+
+// addResponse adds the response to every operation of the path.
+func addResponse(ops object, code string, response object) object {
+	for _, op := range ops {
+		op.(object)["responses"].(object)[code] = response
+	}
+	return ops
+}
+
+// layerOperations returns the operations of a layer endpoint, which takes
+// the name of the layer in the path.
+func layerOperations(summary, description string, in reflect.Type, ok object) object {
+	name := object{
+		"name":        "name",
+		"in":          "path",
+		"description": "Name of the layer given in ADDR_POS_PATH.",
+		"required":    true,
+		"schema":      object{"type": "string", "example": "stores"},
+	}
+	ops := formOperations(summary, description, in, ok)
+	for _, op := range ops {
+		op := op.(object)
+		params, _ := op["parameters"].([]object)
+		op["parameters"] = append([]object{name}, params...)
+	}
+	return addResponse(ops, "404", errorResponse("The layer does not exist or has no features."))
+}
+
+// graphqlOperations returns the operations of the GraphQL endpoint, which
+// takes the query as query parameters or as a JSON body.
+func graphqlOperations() object {
+	description := "Runs a GraphQL query over the prefecture, city and area hierarchy. " +
+		"Errors of the query are reported in the errors of the response with status 200."
+	responses := func() object {
+		return object{
+			"200": jsonResponse("The result of the query.", ref("GraphQLResponse")),
+			"400": ref("#/components/responses/BadRequest"),
+			"401": ref("#/components/responses/Unauthorized"),
+			"429": ref("#/components/responses/TooManyRequests"),
+			"500": ref("#/components/responses/InternalServerError"),
+		}
+	}
+	security := []object{{}, {"ApiKey": []string{}}, {"Bearer": []string{}}}
+	post := responses()
+	post["415"] = errorResponse("The body is not JSON.")
+	return object{
+		"get": object{
+			"summary":     "GraphQL",
+			"description": description,
+			"operationId": "graphql",
+			"parameters": []object{
+				{
+					"name":        "query",
+					"in":          "query",
+					"description": "The GraphQL document.",
+					"required":    true,
+					"schema":      object{"type": "string", "example": "{ prefectures { code name } }"},
+				},
+				{
+					"name":        "operationName",
+					"in":          "query",
+					"description": "The operation to run if the document has several.",
+					"schema":      object{"type": "string"},
+				},
+				{
+					"name":        "variables",
+					"in":          "query",
+					"description": "Values of the variables of the operation as a JSON object.",
+					"schema":      object{"type": "string"},
+				},
+			},
+			"security":  security,
+			"responses": responses(),
+		},
+		"post": object{
+			"summary":     "GraphQL",
+			"description": description,
+			"operationId": "graphqlJSON",
+			"requestBody": object{
+				"required": true,
+				"content": object{
+					"application/json": object{"schema": ref("GraphQLRequest")},
+				},
+			},
+			"security":  security,
+			"responses": post,
+		},
+	}
+}
+
+// streamOperation returns the operation of the reverse geocoding stream,
+// which upgrades to a WebSocket carrying JSON messages.
+func streamOperation() object {
+	var params []object
+	for _, f := range inputFields(reflect.TypeOf(streamInput{})) {
+		params = append(params, object{
+			"name":        f.name,
+			"in":          "query",
+			"description": f.doc,
+			"required":    f.required,
+			"schema":      f.schema,
+		})
+	}
+	return object{
+		"summary": "Reverse geocoding stream",
+		"description": "Opens a WebSocket on which the client sends the positions of devices as StreamPosition " +
+			"messages and receives a StreamArea message only when the area of a device changes. " +
+			"A device keeps its area until another area is nearer by more than margin meters. " +
+			"Messages that cannot be processed, including positions over the rate limit or the daily quota, " +
+			"are answered with a StreamError message. Each position counts as a request, and a client may " +
+			"have up to 8 streams open, beyond which the handshake receives 429. Browsers must be on the same host or on an origin allowed by CORS_ORIGINS.",
+		"operationId": "reverseGeocodingStream",
+		"parameters":  params,
+		"security":    []object{{}, {"ApiKey": []string{}}, {"Bearer": []string{}}},
+		"responses": object{
+			"101": object{"description": "The connection is upgraded to a WebSocket."},
+			"400": ref("#/components/responses/BadRequest"),
+			"401": ref("#/components/responses/Unauthorized"),
+			"403": errorResponse("The origin is not allowed."),
+			"404": errorResponse("No area matches the prefecture and city filters."),
+			"426": errorResponse("The request is not a WebSocket handshake."),
+			"429": ref("#/components/responses/TooManyRequests"),
+			"500": ref("#/components/responses/InternalServerError"),
+		},
+	}
+}
+
+// geofenceOperations returns the operations listing and putting geofences.
+func geofenceOperations() object {
+	return object{
+		"get": object{
+			"summary":     "Geofences",
+			"description": "Lists the geofences sorted by ID.",
+			"operationId": "listGeofences",
+			"security":    []object{{}, {"ApiKey": []string{}}, {"Bearer": []string{}}},
+			"responses": object{
+				"200": jsonResponse("All geofences.", arrayOf(ref("Geofence"))),
+				"401": ref("#/components/responses/Unauthorized"),
+				"429": ref("#/components/responses/TooManyRequests"),
+				"500": ref("#/components/responses/InternalServerError"),
+			},
+		},
+		"post": bodyOperation(
+			"Put geofence",
+			"Adds a geofence given as a GeoJSON polygon or as a circle, replacing the geofence with the same ID.",
+			"putGeofence",
+			reflect.TypeOf(geofenceInput{}),
+			object{
+				"200": jsonResponse("The geofence replaced an existing one.", ref("Geofence")),
+				"201": jsonResponse("The geofence was added.", ref("Geofence")),
+				"409": errorResponse("The client has GEOFENCE_MAX_ZONES geofences."),
+			},
+		),
+	}
+}
+
+// zoneOperations returns the operations of a geofence, which takes its ID in
+// the path.
+func zoneOperations() object {
+	security := []object{{}, {"ApiKey": []string{}}, {"Bearer": []string{}}}
+	id := object{
+		"name":        "id",
+		"in":          "path",
+		"description": "ID of the geofence.",
+		"required":    true,
+		"schema":      object{"type": "string", "example": "shibakoen"},
+	}
+	responses := func(code string, ok object) object {
+		return object{
+			code:  ok,
+			"401": ref("#/components/responses/Unauthorized"),
+			"404": errorResponse("The geofence does not exist."),
+			"429": ref("#/components/responses/TooManyRequests"),
+			"500": ref("#/components/responses/InternalServerError"),
+		}
+	}
+	return object{
+		"get": object{
+			"summary":     "Geofence",
+			"description": "Returns a geofence.",
+			"operationId": "getGeofence",
+			"parameters":  []object{id},
+			"security":    security,
+			"responses":   responses("200", jsonResponse("The geofence.", ref("Geofence"))),
+		},
+		"delete": object{
+			"summary":     "Delete geofence",
+			"description": "Deletes a geofence. Devices in it report an exit with their next position.",
+			"operationId": "deleteGeofence",
+			"parameters":  []object{id},
+			"security":    security,
+			"responses":   responses("204", object{"description": "The geofence was deleted."}),
+		},
+	}
+}
+
+// bodyOperation returns a POST operation that takes the fields of the input
+// type as a form or JSON body and is not cached.
+func bodyOperation(summary, description, operationID string, in reflect.Type, ok object) object {
+	properties := object{}
+	var required []string
+	for _, f := range inputFields(in) {
+		schema := object{}
+		for k, v := range f.schema {
+			schema[k] = v
+		}
+		schema["description"] = f.doc
+		properties[f.name] = schema
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	form := object{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		form["required"] = required
+	}
+	responses := object{
+		"400": ref("#/components/responses/BadRequest"),
+		"401": ref("#/components/responses/Unauthorized"),
+		"415": ref("#/components/responses/UnsupportedMediaType"),
+		"429": ref("#/components/responses/TooManyRequests"),
+		"500": ref("#/components/responses/InternalServerError"),
+	}
+	for code, r := range ok {
+		responses[code] = r
+	}
+	return object{
+		"summary":     summary,
+		"description": description,
+		"operationId": operationID,
+		"requestBody": object{
+			"required": true,
+			"content": object{
+				"application/x-www-form-urlencoded": object{"schema": form},
+				"application/json":                  object{"schema": form},
+			},
+		},
+		"security":  []object{{}, {"ApiKey": []string{}}, {"Bearer": []string{}}},
+		"responses": responses,
+	}
+}
+
+type inputField struct {
+	name     string
+	doc      string
+	required bool
+	schema   object
+}
+
+// inputFields returns the fields of an input type with strmap tags.
+func inputFields(t reflect.Type) []inputField {
+	var fields []inputField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("strmap")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		schema := schemaOf(f.Type)
+		if ex := f.Tag.Get("example"); ex != "" {
+			schema["example"] = ex
+		}
+		field := inputField{name: opts[0], doc: f.Tag.Get("doc"), schema: schema}
+		for _, o := range opts[1:] {
+			if o == "required" {
+				field.required = true
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// schemaOf returns the JSON schema of a type. The properties of a struct are
+// its fields with json tags, and the fields without omitempty are required.
+func schemaOf(t reflect.Type) object {
+	switch t.Kind() {
+	case reflect.String:
+		return object{"type": "string"}
+	case reflect.Bool:
+		return object{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return object{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return arrayOf(schemaOf(t.Elem()))
+	case reflect.Map:
+		return object{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		properties := object{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			opts := strings.Split(tag, ",")
+			schema := schemaOf(f.Type)
+			if doc := f.Tag.Get("doc"); doc != "" {
+				schema["description"] = doc
+			}
+			properties[opts[0]] = schema
+			omitempty := false
+			for _, o := range opts[1:] {
+				if o == "omitempty" {
+					omitempty = true
+				}
+			}
+			if !omitempty {
+				required = append(required, opts[0])
+			}
+		}
+		s := object{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return object{}
+	}
+}
+
+func arrayOf(items object) object {
+	return object{"type": "array", "items": items}
+}
+
+// ref returns a reference to a schema by name or to any component by path.
+func ref(name string) object {
+	if !strings.HasPrefix(name, "#") {
+		name = "#/components/schemas/" + name
+	}
+	return object{"$ref": name}
+}
+
+func jsonResponse(description string, schema object) object {
+	return object{
+		"description": description,
+		"content": object{
+			"application/json": object{"schema": schema},
+		},
+	}
+}
+
+// errorResponse returns a response with the shared error schema, which
+// describes the error in its message.
+func errorResponse(description string) object {
+	return jsonResponse(description, ref("Error"))
+}
+
+// withHeaders returns the response with the headers.
+func withHeaders(response, headers object) object {
+	response["headers"] = headers
+	return response
+}
+
+// pagedResponse returns a JSON response with the headers of paginated
+// results.
+func pagedResponse(description string, schema object) object {
+	r := jsonResponse(description, schema)
+	r["headers"] = object{
+		"X-Total-Count": object{
+			"description": "Number of results of all pages.",
+			"schema":      object{"type": "integer"},
+		},
+		"X-Next-Cursor": object{
+			"description": "Cursor of the next page, if there are more results.",
+			"schema":      object{"type": "string"},
+		},
+		"Link": object{
+			"description": "Link to the next page with rel=\"next\", if there are more results.",
+			"schema":      object{"type": "string"},
+		},
+	}
+	return r
+}
+
+// lowerCamel converts a hyphenated name to lower camel case.
+func lowerCamel(s string) string {
+	parts := strings.Split(s, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(openAPIDocument()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/fence"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/geo/layer"
+)
+
+// loadOpenAPIDocument returns the document served by the handler decoded
+// into generic JSON values.
+func loadOpenAPIDocument(t *testing.T) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/openapi.json", nil)
+	got := httptest.NewRecorder()
+	openAPI(got, req)
+	if got.Code != http.StatusOK {
+		t.Fatalf("want = %v, got = %v", http.StatusOK, got.Code)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(got.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// resolve returns the value referenced by a local JSON pointer.
+func resolve(doc map[string]interface{}, ref string) (map[string]interface{}, error) {
+	var v interface{} = doc
+	for _, p := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+		if v, ok = m[p]; !ok {
+			return nil, fmt.Errorf("unresolved reference %s", ref)
+		}
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unresolved reference %s", ref)
+	}
+	return m, nil
+}
+
+// validate checks a JSON value against a schema of the document. Objects may
+// not have properties that are not in the schema, so that fields added to a
+// handler without updating the document are detected.
+func validate(doc, schema map[string]interface{}, v interface{}, path string) []string {
+	if r, ok := schema["$ref"].(string); ok {
+		s, err := resolve(doc, r)
+		if err != nil {
+			return []string{path + ": " + err.Error()}
+		}
+		return validate(doc, s, v, path)
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		var matched int
+		for _, s := range oneOf {
+			if len(validate(doc, s.(map[string]interface{}), v, path)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: matches %d schemas of oneOf", path, matched)}
+		}
+		return nil
+	}
+
+	var errs []string
+	switch schema["type"] {
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{path + ": not an object"}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		additional, _ := schema["additionalProperties"].(map[string]interface{})
+		for k, pv := range m {
+			ps, ok := properties[k].(map[string]interface{})
+			if !ok && additional != nil {
+				ps, ok = additional, true
+			}
+			if !ok {
+				errs = append(errs, path+"."+k+": undocumented property")
+				continue
+			}
+			errs = append(errs, validate(doc, ps, pv, path+"."+k)...)
+		}
+		required, _ := schema["required"].([]interface{})
+		for _, k := range required {
+			if _, ok := m[k.(string)]; !ok {
+				errs = append(errs, path+"."+k.(string)+": missing required property")
+			}
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return []string{path + ": not an array"}
+		}
+		items := schema["items"].(map[string]interface{})
+		for i, item := range a {
+			errs = append(errs, validate(doc, items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			errs = append(errs, path+": not a string")
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			errs = append(errs, path+": not a number")
+		}
+	case "integer":
+		if f, ok := v.(float64); !ok || f != math.Trunc(f) {
+			errs = append(errs, path+": not an integer")
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			errs = append(errs, path+": not a boolean")
+		}
+	}
+	return errs
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	doc := loadOpenAPIDocument(t)
+	var check func(v interface{}, path string)
+	check = func(v interface{}, path string) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if r, ok := v["$ref"].(string); ok {
+				if _, err := resolve(doc, r); err != nil {
+					t.Errorf("%s: %v", path, err)
+				}
+			}
+			for k, c := range v {
+				check(c, path+"/"+k)
+			}
+		case []interface{}:
+			for i, c := range v {
+				check(c, fmt.Sprintf("%s/%d", path, i))
+			}
+		}
+	}
+	check(doc, "#")
+
+	ids := map[string]bool{}
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for method, op := range item.(map[string]interface{}) {
+			id, _ := op.(map[string]interface{})["operationId"].(string)
+			if id == "" || ids[id] {
+				t.Errorf("%s %s: want = unique operationId, got = %q", method, path, id)
+			}
+			ids[id] = true
+		}
+	}
+}
+
+func TestOpenAPIHandlers(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores, err := layer.ReadFile("stores", "../../testdata/stores.csv", geo.Spherical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, []*layer.Layer{stores}, time.Now())
+	resetGeofences(t, fence.Zone{ID: "shibakoen", Region: geo.Cap{Center: geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}, Radius: 500}})
+	defer func(l *logger) { appLogger = l }(appLogger)
+	appLogger = &logger{out: ioutil.Discard, level: levelError, now: time.Now}
+	handler := setupServer().Handler
+	doc := loadOpenAPIDocument(t)
+
+	// do sends the parameters as a query string, a form or a JSON body.
+	do := func(method, path string, params url.Values) *httptest.ResponseRecorder {
+		target := "http://example.com" + path
+		var req *http.Request
+		switch method {
+		case http.MethodGet:
+			req = httptest.NewRequest(method, target+"?"+params.Encode(), nil)
+		case "JSON":
+			body := map[string]string{}
+			for k := range params {
+				body[k] = params.Get(k)
+			}
+			b, err := json.Marshal(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = httptest.NewRequest(http.MethodPost, target, strings.NewReader(string(b)))
+			req.Header.Set("Content-Type", "application/json")
+		default:
+			req = httptest.NewRequest(method, target, strings.NewReader(params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		got := httptest.NewRecorder()
+		handler.ServeHTTP(got, req)
+		return got
+	}
+	check := func(name string, op map[string]interface{}, got *httptest.ResponseRecorder) {
+		t.Helper()
+		status := fmt.Sprint(got.Code)
+		resp, ok := op["responses"].(map[string]interface{})[status].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: undocumented status %s", name, status)
+			return
+		}
+		if r, ok := resp["$ref"].(string); ok {
+			if resp, err = resolve(doc, r); err != nil {
+				t.Fatal(err)
+			}
+		}
+		content, _ := resp["content"].(map[string]interface{})
+		media, ok := content["application/json"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		var body interface{}
+		if err := json.Unmarshal(got.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: %v", name, err)
+			return
+		}
+		for _, e := range validate(doc, media["schema"].(map[string]interface{}), body, "body") {
+			t.Errorf("%s: %s", name, e)
+		}
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+	var names []string
+	for path := range paths {
+		names = append(names, path)
+	}
+	sort.Strings(names)
+	for _, path := range names {
+		item := paths[path].(map[string]interface{})
+		get, ok := item["get"].(map[string]interface{})
+		// The stream only answers WebSocket handshakes.
+		if !ok || path == conf.AdminUsageURL || path == streamURL {
+			continue
+		}
+
+		// Request with the examples of the parameters.
+		params := url.Values{}
+		var required []string
+		list, _ := get["parameters"].([]interface{})
+		target := path
+		for _, p := range list {
+			p := p.(map[string]interface{})
+			name := p["name"].(string)
+			ex, hasExample := p["schema"].(map[string]interface{})["example"].(string)
+			if p["in"] == "path" {
+				target = strings.Replace(target, "{"+name+"}", ex, 1)
+				continue
+			}
+			if hasExample {
+				params.Set(name, ex)
+			}
+			if p["required"] == true {
+				required = append(required, name)
+			}
+		}
+		methods := []string{http.MethodGet}
+		// Only the POST variant of the GET operation takes the same
+		// parameters.
+		if post, ok := item["post"].(map[string]interface{}); ok && post["operationId"] == get["operationId"].(string)+"Form" {
+			content := post["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+			if _, ok := content["application/x-www-form-urlencoded"]; ok {
+				methods = append(methods, http.MethodPost)
+			}
+			if _, ok := content["application/json"]; ok {
+				methods = append(methods, "JSON")
+			}
+		}
+		for _, method := range methods {
+			opMethod := method
+			if method == "JSON" {
+				opMethod = http.MethodPost
+			}
+			op := item[strings.ToLower(opMethod)].(map[string]interface{})
+			got := do(method, target, params)
+			if got.Code != http.StatusOK {
+				t.Errorf("%s %s: want = %v, got = %v", method, path, http.StatusOK, got.Code)
+			}
+			check(method+" "+path, op, got)
+
+			// A request without a required parameter is rejected.
+			for _, name := range required {
+				p := url.Values{}
+				for k, v := range params {
+					p[k] = v
+				}
+				p.Del(name)
+				got := do(method, target, p)
+				if got.Code != http.StatusBadRequest {
+					t.Errorf("%s %s without %s: want = %v, got = %v",
+						method, path, name, http.StatusBadRequest, got.Code)
+				}
+				check(method+" "+path, op, got)
+			}
+		}
+	}
+
+	// Responses that the examples do not cover.
+	extra := []struct {
+		path   string
+		params url.Values
+	}{
+		{"/api/geocoding", url.Values{"area_name": {"芝公園三丁目"}}},
+		{"/api/reverse-geocoding", url.Values{"latitude": {"35.658584"}, "longitude": {"139.7454316"}, "zoom": {"15"}}},
+		{"/api/route-reverse-geocoding", url.Values{
+			"geojson": {`{"type":"LineString","coordinates":[[139.74721,35.65994],[139.75142,35.65893]]}`},
+		}},
+		{"/api/layers/{name}/near", url.Values{"latitude": {"35.658584"}, "longitude": {"139.7454316"}, "zoom": {"13"}}},
+		{"/api/layers/{name}/search", url.Values{"name": {"店"}, "latitude": {"35.658584"}, "longitude": {"139.7454316"}}},
+	}
+	for _, tt := range extra {
+		op := paths[tt.path].(map[string]interface{})["get"].(map[string]interface{})
+		got := do(http.MethodGet, strings.Replace(tt.path, "{name}", "stores", 1), tt.params)
+		if got.Code != http.StatusOK {
+			t.Errorf("%s %v: want = %v, got = %v", tt.path, tt.params, http.StatusOK, got.Code)
+		}
+		check(tt.path, op, got)
+	}
+
+	// Operations that only take a body.
+	bodies := []struct {
+		method string
+		path   string
+		params url.Values
+		want   int
+	}{
+		{"JSON", geofencesURL, url.Values{"id": {"hamamatsu"}, "latitude": {"35.655391"}, "longitude": {"139.757135"}, "radius": {"300"}}, http.StatusCreated},
+		{http.MethodPost, geofencesURL, url.Values{
+			"id":      {"hamamatsu"},
+			"geojson": {`{"type":"Polygon","coordinates":[[[139.75,35.65],[139.76,35.65],[139.76,35.66],[139.75,35.66],[139.75,35.65]]]}`},
+		}, http.StatusOK},
+		{http.MethodPost, positionsURL, url.Values{"device_id": {"truck-1"}, "latitude": {"35.658584"}, "longitude": {"139.7454316"}}, http.StatusOK},
+		{"JSON", positionsURL, url.Values{"device_id": {"truck-1"}, "latitude": {"35.655391"}, "longitude": {"139.757135"}}, http.StatusOK},
+		{http.MethodPost, positionsURL, url.Values{"latitude": {"35.658584"}, "longitude": {"139.7454316"}}, http.StatusBadRequest},
+		{http.MethodDelete, geofencesURL + "/{id}", nil, http.StatusNoContent},
+		{http.MethodDelete, geofencesURL + "/{id}", nil, http.StatusNotFound},
+	}
+	for _, tt := range bodies {
+		opMethod := tt.method
+		if opMethod == "JSON" {
+			opMethod = http.MethodPost
+		}
+		op := paths[tt.path].(map[string]interface{})[strings.ToLower(opMethod)].(map[string]interface{})
+		got := do(tt.method, strings.Replace(tt.path, "{id}", "shibakoen", 1), tt.params)
+		if got.Code != tt.want {
+			t.Errorf("%s %s %v: want = %v, got = %v", tt.method, tt.path, tt.params, tt.want, got.Code)
+		}
+		check(tt.method+" "+tt.path, op, got)
+	}
+
+	g := newGuard(testAPIKeys, 0, 0)
+	req := httptest.NewRequest(http.MethodGet, "http://example.com"+conf.AdminUsageURL, nil)
+	req.Header.Set(apiKeyHeader, "admin-key")
+	got := httptest.NewRecorder()
+	g.usageHandler(got, req)
+	op := paths[conf.AdminUsageURL].(map[string]interface{})["get"].(map[string]interface{})
+	check(conf.AdminUsageURL, op, got)
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-structconv/structconv"
+)
+
+// maxRequestBodySize is the maximum size of a JSON request body.
+const maxRequestBodySize = 1 << 20
+
+var (
+	errMethodNotAllowed     = errors.New("method not allowed")
+	errUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// parseParams sets r.Form to the parameters of the request: the query string
+// of a GET or HEAD request, or the form or JSON body of a POST request
+// followed by its query string. It does nothing if r.Form is already set.
+func parseParams(r *http.Request) error {
+	if r.Form != nil {
+		return nil
+	}
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		r.Form = query
+		return nil
+	case http.MethodPost:
+	default:
+		return errMethodNotAllowed
+	}
+
+	var mediaType string
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err = mime.ParseMediaType(ct); err != nil {
+			return errUnsupportedMediaType
+		}
+	}
+	var body url.Values
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		return r.ParseForm()
+	case "application/json":
+		if body, err = jsonParams(http.MaxBytesReader(nil, r.Body, maxRequestBodySize)); err != nil {
+			return err
+		}
+	case "":
+		// The parameters may be only in the query string.
+		if r.ContentLength != 0 {
+			return errUnsupportedMediaType
+		}
+		body = url.Values{}
+	default:
+		return errUnsupportedMediaType
+	}
+	for k, v := range query {
+		body[k] = append(body[k], v...)
+	}
+	r.Form = body
+	return nil
+}
+
+// jsonParams converts a JSON object to parameters. An array gives a value per
+// element, and objects are kept as JSON text, such as a GeoJSON geometry.
+func jsonParams(body io.Reader) (url.Values, error) {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		return nil, errors.New("body must be a JSON object")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON object")
+	}
+	values := url.Values{}
+	for k, v := range obj {
+		elems, ok := v.([]interface{})
+		if !ok {
+			elems = []interface{}{v}
+		}
+		for _, e := range elems {
+			if e == nil {
+				continue
+			}
+			s, err := jsonParam(e)
+			if err != nil {
+				return nil, err
+			}
+			values.Add(k, s)
+		}
+	}
+	return values, nil
+}
+
+func jsonParam(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// decodeParams decodes the parameters into the fields of in with strmap
+// tags. A slice field takes every value of its key, and any other field
+// takes exactly one value.
+func decodeParams(form url.Values, in interface{}) error {
+	v := reflect.ValueOf(in).Elem()
+	slices := map[string]reflect.Value{}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name := strings.Split(f.Tag.Get("strmap"), ",")[0]
+		if name != "" && name != "-" && f.Type.Kind() == reflect.Slice {
+			slices[name] = v.Field(i)
+		}
+	}
+	strMap := map[string]string{}
+	for k, values := range form {
+		if field, ok := slices[k]; ok {
+			if err := setSlice(field, values); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+			continue
+		}
+		if len(values) > 1 {
+			return fmt.Errorf("%s: multiple values", k)
+		}
+		if len(values) == 1 {
+			strMap[k] = values[0]
+		}
+	}
+	return structconv.DecodeStringMap(strMap, in)
+}
+
+func setSlice(field reflect.Value, values []string) error {
+	s := reflect.MakeSlice(field.Type(), len(values), len(values))
+	for i, value := range values {
+		e := s.Index(i)
+		switch e.Kind() {
+		case reflect.String:
+			e.SetString(value)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+			e.SetInt(int64(n))
+		case reflect.Float64:
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+			e.SetFloat(f)
+		default:
+			return fmt.Errorf("unsupported type %s", e.Type())
+		}
+	}
+	field.Set(s)
+	return nil
+}
+
+// readParams parses the parameters of the request and decodes them into in.
+func readParams(r *http.Request, in interface{}) error {
+	if err := parseParams(r); err != nil {
+		return err
+	}
+	return decodeParams(r.Form, in)
+}
+
+// originParam returns the current location given by the latitude and
+// longitude parameters read into lat and long, or nil if neither is given.
+// The parameters are told apart from zero values by r.Form.
+func originParam(r *http.Request, lat, long float64) (*geo.LatLong, error) {
+	_, hasLat := r.Form["latitude"]
+	_, hasLong := r.Form["longitude"]
+	switch {
+	case !hasLat && !hasLong:
+		return nil, nil
+	case !hasLat || !hasLong:
+		return nil, errors.New("latitude and longitude must be given together")
+	case !(lat >= -90 && lat <= 90 && long >= -180 && long <= 180):
+		return nil, errors.New("latitude or longitude is out of range")
+	}
+	return &geo.LatLong{Latitude: lat, Longitude: long}, nil
+}
+
+// writeParamsError writes the status and the message of an error of
+// readParams.
+func writeParamsError(w http.ResponseWriter, err error) {
+	switch err {
+	case errMethodNotAllowed:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeError(w, http.StatusMethodNotAllowed, err.Error())
+	case errUnsupportedMediaType:
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+// errorOutput is the body of an error response.
+type errorOutput struct {
+	Error string `json:"error" doc:"Description of the error."`
+}
+
+// writeError writes an error response with the message in a JSON body.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorOutput{message})
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        map[string][]string
+		wantErr     error
+	}{
+		{"get", http.MethodGet, "/?a=1&b=x&b=y", "", "", map[string][]string{"a": {"1"}, "b": {"x", "y"}}, nil},
+		{"head", http.MethodHead, "/?a=1", "", "", map[string][]string{"a": {"1"}}, nil},
+		{"form", http.MethodPost, "/?c=3", "application/x-www-form-urlencoded", "a=1&a=2",
+			map[string][]string{"a": {"1", "2"}, "c": {"3"}}, nil},
+		{"json", http.MethodPost, "/?c=3", "application/json; charset=utf-8",
+			`{"a":35.658584,"b":["x","y"],"d":true,"e":null,"f":{"type":"Point","coordinates":[139.1,35.1]},"g":"s"}`,
+			map[string][]string{
+				"a": {"35.658584"},
+				"b": {"x", "y"},
+				"c": {"3"},
+				"d": {"true"},
+				"f": {`{"coordinates":[139.1,35.1],"type":"Point"}`},
+				"g": {"s"},
+			}, nil},
+		{"query only", http.MethodPost, "/?a=1", "", "", map[string][]string{"a": {"1"}}, nil},
+		{"body without type", http.MethodPost, "/", "", "a=1", nil, errUnsupportedMediaType},
+		{"unknown type", http.MethodPost, "/", "text/plain", "a=1", nil, errUnsupportedMediaType},
+		{"invalid type", http.MethodPost, "/", "application/", "a=1", nil, errUnsupportedMediaType},
+		{"method", http.MethodPut, "/", "application/json", "{}", nil, errMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			err := parseParams(req)
+			if err != tt.wantErr {
+				t.Fatalf("want = %v, got = %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got := map[string][]string(req.Form); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %v, got = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseParamsInvalidJSON(t *testing.T) {
+	for _, body := range []string{
+		`{"a":`,
+		`[1,2]`,
+		`null`,
+		`{"a":1} {"b":2}`,
+		`{"a":"` + strings.Repeat("x", maxRequestBodySize) + `"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		err := parseParams(req)
+		if err == nil || err == errUnsupportedMediaType {
+			t.Errorf("%.20s: want = %v, got = %v", body, "an invalid body error", err)
+		}
+	}
+}
+
+func TestDecodeParams(t *testing.T) {
+	type input struct {
+		Name   string    `strmap:"name,required"`
+		Value  float64   `strmap:"value"`
+		Tags   []string  `strmap:"tag"`
+		Levels []int     `strmap:"level"`
+		Points []float64 `strmap:"point"`
+	}
+	tests := []struct {
+		name    string
+		form    map[string][]string
+		want    input
+		wantErr bool
+	}{
+		{"scalars", map[string][]string{"name": {"a"}, "value": {"1.5"}}, input{Name: "a", Value: 1.5}, false},
+		{"repeated", map[string][]string{"name": {"a"}, "tag": {"x", "y"}, "level": {"1", "2"}, "point": {"0.5"}},
+			input{Name: "a", Tags: []string{"x", "y"}, Levels: []int{1, 2}, Points: []float64{0.5}}, false},
+		{"repeated scalar", map[string][]string{"name": {"a", "b"}}, input{}, true},
+		{"invalid element", map[string][]string{"name": {"a"}, "level": {"1", "x"}}, input{}, true},
+		{"missing required", map[string][]string{"tag": {"x"}}, input{}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got input
+			err := decodeParams(tt.form, &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want = %v, got = %v", tt.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want = %+v, got = %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandlersAcceptJSON(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aps = a
+	iaps = jp.CreateAPIndex(a, geo.Spherical)
+	regions = jp.NewRegionalIndex(a, iaps)
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		method   string
+		target   string
+		body     string
+		wantCode int
+		want     string
+	}{
+		{
+			"reverse geocoding", reverseGeocoding, http.MethodPost, "/api/reverse-geocoding",
+			`{"latitude":35.658584,"longitude":139.7454316}`, http.StatusOK,
+			`{"pref_name":"東京都","city_name":"港区","area_name":"芝公園三丁目","latitude":35.659943,"longitude":139.747207,"distance":220.37123693585445}`,
+		},
+		{
+			"geocoding", geocoding, http.MethodPost, "/api/geocoding",
+			`{"area_name":"芝公園三丁目"}`, http.StatusOK,
+			`[{"pref_name":"東京都","city_name":"港区","area_name":"芝公園三丁目","latitude":35.659943,"longitude":139.747207}]`,
+		},
+		{
+			"geojson object", routeReverseGeocoding, http.MethodPost, "/api/route-reverse-geocoding",
+			`{"geojson":{"type":"LineString","coordinates":[[139.74721,35.65994],[139.74721,35.65994]]}}`, http.StatusOK,
+			`[{"pref_name":"東京都","city_name":"港区","area_name":"芝公園三丁目","latitude":35.659943,"longitude":139.747207,"entry_latitude":35.65994,"entry_longitude":139.74721,"exit_latitude":35.65994,"exit_longitude":139.74721,"distance":0}]`,
+		},
+		{
+			"get", reverseGeocoding, http.MethodGet, "/api/reverse-geocoding?latitude=35.658584&longitude=139.7454316",
+			"", http.StatusOK,
+			`{"pref_name":"東京都","city_name":"港区","area_name":"芝公園三丁目","latitude":35.659943,"longitude":139.747207,"distance":220.37123693585445}`,
+		},
+		{
+			"repeated scalar", reverseGeocoding, http.MethodGet,
+			"/api/reverse-geocoding?latitude=35.658584&latitude=35&longitude=139.7454316",
+			"", http.StatusBadRequest, `{"error":"latitude: multiple values"}`,
+		},
+		{
+			"wrong type", reverseGeocoding, http.MethodPost, "/api/reverse-geocoding",
+			`{"latitude":"north","longitude":139.7454316}`, http.StatusBadRequest, "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			got := httptest.NewRecorder()
+			tt.handler(got, req)
+			if got.Code != tt.wantCode {
+				t.Errorf("want = %v, got = %v", tt.wantCode, got.Code)
+			}
+			if tt.want == "" {
+				// The message of a type error is not checked.
+				if got := errorMessage(t, got); got == "" {
+					t.Errorf("want = %v, got = %v", "error message", got)
+				}
+				return
+			}
+			if got := strings.TrimSpace(got.Body.String()); got != tt.want {
+				t.Errorf("\nwant = %v\ngot  = %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// errorMessage returns the message of an error response.
+func errorMessage(t *testing.T, got *httptest.ResponseRecorder) string {
+	t.Helper()
+	if ct := got.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("want = %v, got = %v", "application/json; charset=utf-8", ct)
+	}
+	var body errorOutput
+	if err := json.NewDecoder(got.Body).Decode(&body); err != nil {
+		t.Errorf("want = %v, got = %v", "error body", err)
+	}
+	return body.Error
+}
+
+func TestWriteParamsError(t *testing.T) {
+	tests := []struct {
+		err       error
+		wantCode  int
+		wantAllow string
+	}{
+		{errMethodNotAllowed, http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{errUnsupportedMediaType, http.StatusUnsupportedMediaType, ""},
+		{errors.New("invalid"), http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		got := httptest.NewRecorder()
+		writeParamsError(got, tt.err)
+		if got.Code != tt.wantCode || got.Header().Get("Allow") != tt.wantAllow {
+			t.Errorf("want = %v %q, got = %v %q", tt.wantCode, tt.wantAllow, got.Code, got.Header().Get("Allow"))
+		}
+		if got := errorMessage(t, got); got != tt.err.Error() {
+			t.Errorf("want = %v, got = %v", tt.err.Error(), got)
+		}
+	}
+}
@@ -9,46 +9,50 @@ import (
 	"net/http"
 
 	"github.com/twihike/go-geojp/pkg/geo"
-	"github.com/twihike/go-structconv/structconv"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
 )
 
 type reverseGeocodingInput struct {
-	Latitude  float64 `strmap:"latitude,required"`
-	Longitude float64 `strmap:"longitude,required"`
-	Zoom      int     `strmap:"zoom"`
+	Latitude  float64  `strmap:"latitude,required" doc:"Latitude of the position." example:"35.658584"`
+	Longitude float64  `strmap:"longitude,required" doc:"Longitude of the position." example:"139.7454316"`
+	Zoom      int      `strmap:"zoom" doc:"Zoom level from 4 to 23. If given, all areas in the tile at the zoom level and its neighbors are returned as an array."`
+	PrefCode  []string `strmap:"pref_code" doc:"Prefecture codes to restrict the areas to. The nearest area in them is returned even if another area is closer. May be repeated."`
+	PrefName  []string `strmap:"pref_name" doc:"Prefecture names to restrict the areas to. May be repeated."`
+	CityCode  []string `strmap:"city_code" doc:"City codes to restrict the areas to. May be repeated."`
+	CityName  []string `strmap:"city_name" doc:"City names to restrict the areas to. May be repeated."`
 }
 
 type reverseGeocodingOutput struct {
-	PrefName  string  `json:"pref_name"`
-	CityName  string  `json:"city_name"`
-	AreaName  string  `json:"area_name"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
-	Distance  float64 `json:"distance"`
+	PrefName  string  `json:"pref_name" doc:"Prefecture name."`
+	CityName  string  `json:"city_name" doc:"City name."`
+	AreaName  string  `json:"area_name" doc:"Area name."`
+	Latitude  float64 `json:"latitude" doc:"Latitude of the representative point of the area."`
+	Longitude float64 `json:"longitude" doc:"Longitude of the representative point of the area."`
+	Distance  float64 `json:"distance" doc:"Distance in meters from the position."`
 }
 
 func reverseGeocoding(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var in reverseGeocodingInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
 		return
 	}
-	strMap := map[string]string{}
-	for k, v := range r.Form {
-		if len(v) > 0 {
-			strMap[k] = v[0]
-		}
-	}
 
-	var in reverseGeocodingInput
-	if err := structconv.DecodeStringMap(strMap, &in); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	idx := regions.Index(jp.Filter{
+		PrefCodes: in.PrefCode,
+		PrefNames: in.PrefName,
+		CityCodes: in.CityCode,
+		CityNames: in.CityName,
+	})
+	if len(idx) == 0 {
+		writeError(w, http.StatusNotFound, "no area matches the filters")
 		return
 	}
 
 	target := geo.LatLong{Latitude: in.Latitude, Longitude: in.Longitude}
 	var body interface{}
 	if in.Zoom > 0 {
-		filteredAPs := iaps.Near(target, in.Zoom)
+		filteredAPs := idx.Near(target, in.Zoom)
 		b := []reverseGeocodingOutput{}
 		for _, ap := range filteredAPs {
 			b = append(b, reverseGeocodingOutput{
@@ -60,9 +64,10 @@ func reverseGeocoding(w http.ResponseWriter, r *http.Request) {
 				Distance:  ap.Distance,
 			})
 		}
+		setResultCount(r, len(b))
 		body = b
 	} else {
-		filteredAPs := iaps.Nearest(target)
+		filteredAPs := idx.Nearest(target)
 		b := reverseGeocodingOutput{
 			PrefName:  filteredAPs.PrefName,
 			CityName:  filteredAPs.CityName,
@@ -71,6 +76,7 @@ func reverseGeocoding(w http.ResponseWriter, r *http.Request) {
 			Longitude: filteredAPs.Longitude,
 			Distance:  filteredAPs.Distance,
 		}
+		setResultCount(r, 1)
 		body = b
 	}
 
@@ -5,12 +5,14 @@
 package webapp
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/twihike/go-geojp/pkg/geo"
 	"github.com/twihike/go-geojp/pkg/geo/jp"
 )
 
@@ -19,7 +21,8 @@ func TestReverseGeocoding(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	iaps = jp.CreateIndexedAPs(aps)
+	iaps = jp.CreateAPIndex(aps, geo.Spherical)
+	regions = jp.NewRegionalIndex(aps, iaps)
 
 	target := "http://example.com/api/reverse-geocoding"
 	body := url.Values{}
@@ -41,3 +44,59 @@ func TestReverseGeocoding(t *testing.T) {
 		t.Errorf("\nwant = %v\ngot  = %v", want, got)
 	}
 }
+
+func TestReverseGeocodingFilter(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Move the nearest area into another city.
+	for i := range a {
+		if a[i].AreaName == "芝公園三丁目" {
+			a[i].CityCode = "13101"
+			a[i].CityName = "千代田区"
+		}
+	}
+	iaps = jp.CreateAPIndex(a, geo.Spherical)
+	regions = jp.NewRegionalIndex(a, iaps)
+
+	tests := []struct {
+		query    string
+		wantCode int
+		wantArea string
+	}{
+		{"", http.StatusOK, "芝公園三丁目"},
+		{"&city_code=13101", http.StatusOK, "芝公園三丁目"},
+		{"&city_code=13103", http.StatusOK, "東麻布一丁目"},
+		{"&pref_name=東京都&city_name=港区", http.StatusOK, "東麻布一丁目"},
+		{"&pref_code=13&zoom=14", http.StatusOK, "芝公園三丁目"},
+		{"&city_name=港区&zoom=14", http.StatusOK, "東麻布一丁目"},
+		{"&pref_code=27", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		target := "http://example.com/api/reverse-geocoding?latitude=35.658584&longitude=139.7454316" + tt.query
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		got := httptest.NewRecorder()
+		reverseGeocoding(got, req)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s: want = %v, got = %v", tt.query, tt.wantCode, got.Code)
+		}
+		if tt.wantArea == "" {
+			continue
+		}
+		var area reverseGeocodingOutput
+		body := got.Body.Bytes()
+		if strings.Contains(tt.query, "zoom") {
+			var areas []reverseGeocodingOutput
+			if err := json.Unmarshal(body, &areas); err != nil || len(areas) == 0 {
+				t.Fatalf("%s: want = %v, got = %s", tt.query, "areas", body)
+			}
+			area = areas[0]
+		} else if err := json.Unmarshal(body, &area); err != nil {
+			t.Fatal(err)
+		}
+		if area.AreaName != tt.wantArea {
+			t.Errorf("%s: want = %v, got = %v", tt.query, tt.wantArea, area.AreaName)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+const defaultRouteInterval = 50
+
+type routeReverseGeocodingInput struct {
+	Polyline string  `strmap:"polyline" doc:"Route as an encoded polyline with precision 5 and at most 10000 vertices. Either polyline or geojson is required." example:"sysxEak}sYhEiYfAa_@"`
+	GeoJSON  string  `strmap:"geojson" doc:"Route as a GeoJSON LineString geometry. A JSON body may give the geometry as an object."`
+	Interval float64 `strmap:"interval" doc:"Sampling interval in meters. Defaults to 50."`
+}
+
+type routeReverseGeocodingOutput struct {
+	PrefName       string  `json:"pref_name" doc:"Prefecture name."`
+	CityName       string  `json:"city_name" doc:"City name."`
+	AreaName       string  `json:"area_name" doc:"Area name."`
+	Latitude       float64 `json:"latitude" doc:"Latitude of the representative point of the area."`
+	Longitude      float64 `json:"longitude" doc:"Longitude of the representative point of the area."`
+	EntryLatitude  float64 `json:"entry_latitude" doc:"Latitude where the route enters the area."`
+	EntryLongitude float64 `json:"entry_longitude" doc:"Longitude where the route enters the area."`
+	ExitLatitude   float64 `json:"exit_latitude" doc:"Latitude where the route leaves the area."`
+	ExitLongitude  float64 `json:"exit_longitude" doc:"Longitude where the route leaves the area."`
+	Distance       float64 `json:"distance" doc:"Distance in meters travelled in the area."`
+}
+
+func routeReverseGeocoding(w http.ResponseWriter, r *http.Request) {
+	var in routeReverseGeocodingInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	route, ok := decodeRoute(in)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid route")
+		return
+	}
+	if in.Interval <= 0 {
+		in.Interval = defaultRouteInterval
+	}
+
+	areas := iaps.Route(route, in.Interval)
+	body := []routeReverseGeocodingOutput{}
+	for _, a := range areas {
+		body = append(body, routeReverseGeocodingOutput{
+			PrefName:       a.PrefName,
+			CityName:       a.CityName,
+			AreaName:       a.AreaName,
+			Latitude:       a.Latitude,
+			Longitude:      a.Longitude,
+			EntryLatitude:  a.Entry.Latitude,
+			EntryLongitude: a.Entry.Longitude,
+			ExitLatitude:   a.Exit.Latitude,
+			ExitLongitude:  a.Exit.Longitude,
+			Distance:       a.Distance,
+		})
+	}
+	setResultCount(r, len(body))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// decodeRoute decodes a route given as either an encoded polyline or a
+// GeoJSON LineString. The route must have at most jp.MaxRouteVertices
+// vertices, all of which are valid positions.
+func decodeRoute(in routeReverseGeocodingInput) (geo.LineString, bool) {
+	var route geo.LineString
+	switch {
+	case in.Polyline != "" && in.GeoJSON == "":
+		var err error
+		if route, err = geo.DecodePolyline(in.Polyline); err != nil {
+			return nil, false
+		}
+	case in.GeoJSON != "" && in.Polyline == "":
+		g, err := geo.UnmarshalGeoJSON([]byte(in.GeoJSON))
+		if err != nil {
+			return nil, false
+		}
+		var ok bool
+		if route, ok = g.(geo.LineString); !ok {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	if len(route) == 0 || len(route) > jp.MaxRouteVertices {
+		return nil, false
+	}
+	for _, p := range route {
+		if !(p.Latitude >= -90 && p.Latitude <= 90 && p.Longitude >= -180 && p.Longitude <= 180) {
+			return nil, false
+		}
+	}
+	return route, true
+}
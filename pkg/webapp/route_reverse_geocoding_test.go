@@ -0,0 +1,107 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+)
+
+func TestRouteReverseGeocoding(t *testing.T) {
+	aps, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iaps = jp.CreateAPIndex(aps, geo.Spherical)
+
+	route := geo.LineString{
+		{Latitude: 35.65994, Longitude: 139.74721},
+		{Latitude: 35.65893, Longitude: 139.75142},
+		{Latitude: 35.65857, Longitude: 139.75655},
+	}
+	geoJSON, err := geo.MarshalGeoJSON(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		in        url.Values
+		wantCode  int
+		wantAreas []string
+	}{
+		{
+			"polyline",
+			url.Values{"polyline": {geo.EncodePolyline(route)}, "interval": {"20"}},
+			http.StatusOK,
+			[]string{"芝公園三丁目", "芝公園一丁目", "芝大門一丁目", "浜松町一丁目"},
+		},
+		{
+			"geojson",
+			url.Values{"geojson": {string(geoJSON)}},
+			http.StatusOK,
+			[]string{"芝公園三丁目", "芝公園一丁目", "芝大門一丁目", "浜松町一丁目"},
+		},
+		{
+			"both",
+			url.Values{"polyline": {geo.EncodePolyline(route)}, "geojson": {string(geoJSON)}},
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"too many vertices",
+			url.Values{"polyline": {geo.EncodePolyline(make(geo.LineString, jp.MaxRouteVertices+1))}},
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"invalid position",
+			url.Values{"geojson": {`{"type":"LineString","coordinates":[[139.74721,35.65994],[139.75,95]]}`}},
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"not a line string",
+			url.Values{"geojson": {`{"type":"Point","coordinates":[139.74721,35.65994]}`}},
+			http.StatusBadRequest,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			target := "http://example.com/api/route-reverse-geocoding"
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(tt.in.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			got := httptest.NewRecorder()
+			routeReverseGeocoding(got, req)
+
+			if got.Code != tt.wantCode {
+				t.Fatalf("want = %v, got = %v", tt.wantCode, got.Code)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var body []routeReverseGeocodingOutput
+			if err := json.NewDecoder(got.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			var gotAreas []string
+			for _, a := range body {
+				gotAreas = append(gotAreas, a.AreaName)
+			}
+			if strings.Join(gotAreas, ",") != strings.Join(tt.wantAreas, ",") {
+				t.Errorf("want = %v, got = %v", tt.wantAreas, gotAreas)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/grpcapi"
+	"github.com/twihike/go-geojp/pkg/grpcapi/geojppb"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// certCheckInterval is the minimum interval between checks for rotated
+// certificates.
+const certCheckInterval = 10 * time.Second
+
+// serverOptions are the options of the HTTP server parsed from appConfig.
+type serverOptions struct {
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+	maxHeaderBytes    int
+	certPath          string
+	keyPath           string
+	h2c               bool
+}
+
+func newServerOptions(c appConfig) (serverOptions, error) {
+	opts := serverOptions{
+		maxHeaderBytes: c.MaxHeaderBytes,
+		certPath:       c.TLSCertPath,
+		keyPath:        c.TLSKeyPath,
+		h2c:            c.H2C,
+	}
+	durations := []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"read timeout", c.ReadTimeout, &opts.readTimeout},
+		{"read header timeout", c.ReadHeaderTimeout, &opts.readHeaderTimeout},
+		{"write timeout", c.WriteTimeout, &opts.writeTimeout},
+		{"idle timeout", c.IdleTimeout, &opts.idleTimeout},
+		{"shutdown timeout", c.ShutdownTimeout, &opts.shutdownTimeout},
+	}
+	for _, d := range durations {
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return serverOptions{}, fmt.Errorf("invalid %s: %q", d.name, d.value)
+		}
+		*d.dst = v
+	}
+	if opts.maxHeaderBytes <= 0 {
+		return serverOptions{}, fmt.Errorf("invalid max header bytes: %d", opts.maxHeaderBytes)
+	}
+	if (opts.certPath == "") != (opts.keyPath == "") {
+		return serverOptions{}, errors.New("both TLS certificate and key are required")
+	}
+	if opts.tls() && opts.h2c {
+		return serverOptions{}, errors.New("h2c cannot be used with TLS")
+	}
+	return opts, nil
+}
+
+func (o serverOptions) tls() bool {
+	return o.certPath != ""
+}
+
+// configure applies the options to the server. HTTP/2 is enabled over TLS by
+// net/http, and over cleartext if h2c is set.
+func (o serverOptions) configure(server *http.Server) error {
+	server.ReadTimeout = o.readTimeout
+	server.ReadHeaderTimeout = o.readHeaderTimeout
+	server.WriteTimeout = o.writeTimeout
+	server.IdleTimeout = o.idleTimeout
+	server.MaxHeaderBytes = o.maxHeaderBytes
+	if o.h2c {
+		server.Handler = h2c.NewHandler(server.Handler, &http2.Server{IdleTimeout: o.idleTimeout})
+	}
+	if !o.tls() {
+		return nil
+	}
+	reloader, err := newCertReloader(o.certPath, o.keyPath)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	return nil
+}
+
+// certReloader serves a certificate and reloads it when the certificate or
+// key file changes, so rotated certificates are used without a restart.
+type certReloader struct {
+	certPath string
+	keyPath  string
+	now      func() time.Time
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+	lastCheck   time.Time
+}
+
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	r := &certReloader{certPath: certPath, keyPath: keyPath, now: time.Now}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+	return nil
+}
+
+// getCertificate returns the current certificate. It checks the files at
+// most once per certCheckInterval and keeps the previous certificate if the
+// new one cannot be loaded, for example while only one file is replaced.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	if now.Sub(r.lastCheck) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.lastCheck = now
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		appLogger.log(levelError, "failed to check certificate", field{"error", err.Error()})
+		return r.cert, nil
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		appLogger.log(levelError, "failed to check certificate", field{"error", err.Error()})
+		return r.cert, nil
+	}
+	if certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+	if err := r.reload(); err != nil {
+		appLogger.log(levelError, "failed to reload certificate", field{"error", err.Error()})
+		return r.cert, nil
+	}
+	appLogger.log(levelInfo, "certificate reloaded", field{"path", r.certPath})
+	return r.cert, nil
+}
+
+// startGRPCServer serves the GeoJP gRPC service on GRPCPort, with TLS if
+// tlsConfig is not nil. The RPCs are guarded, logged and measured like the
+// HTTP APIs. It returns nil if the port is not set.
+func startGRPCServer(tlsConfig *tls.Config) (*grpc.Server, error) {
+	if conf.GRPCPort == "" {
+		return nil, nil
+	}
+	lis, err := net.Listen("tcp", ":"+conf.GRPCPort)
+	if err != nil {
+		return nil, err
+	}
+	opts := rpcHooks{guard: appGuard, metrics: appMetrics, logger: appLogger}.serverOptions()
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(opts...)
+	geojppb.RegisterGeoJPServer(s, grpcapi.NewServer(aps, iaps))
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Println(err)
+		}
+	}()
+	log.Println("grpc started on port:", conf.GRPCPort)
+	return s, nil
+}
+
+// stopGRPCServer waits for the RPCs in progress to finish until the context
+// is done, and then closes the remaining connections.
+func stopGRPCServer(ctx context.Context, s *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.Stop()
+	}
+}
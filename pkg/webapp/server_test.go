@@ -0,0 +1,275 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 with the
+// serial number to the files.
+func writeTestCert(t *testing.T, certPath, keyPath string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "geojp test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(certPath, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Make the change visible on file systems with a coarse mtime.
+	mtime := time.Now().Add(time.Duration(serial) * time.Second)
+	for _, p := range []string{certPath, keyPath} {
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestCertDir(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "geojp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestNewServerOptions(t *testing.T) {
+	valid := conf
+	tests := []struct {
+		name    string
+		modify  func(c *appConfig)
+		wantErr bool
+	}{
+		{"default", func(c *appConfig) {}, false},
+		{"tls", func(c *appConfig) { c.TLSCertPath, c.TLSKeyPath = "cert.pem", "key.pem" }, false},
+		{"h2c", func(c *appConfig) { c.H2C = true }, false},
+		{"zero timeout", func(c *appConfig) { c.WriteTimeout = "0" }, false},
+		{"invalid timeout", func(c *appConfig) { c.ReadTimeout = "10" }, true},
+		{"negative timeout", func(c *appConfig) { c.IdleTimeout = "-1s" }, true},
+		{"invalid shutdown timeout", func(c *appConfig) { c.ShutdownTimeout = "" }, true},
+		{"max header bytes", func(c *appConfig) { c.MaxHeaderBytes = 0 }, true},
+		{"cert only", func(c *appConfig) { c.TLSCertPath = "cert.pem" }, true},
+		{"h2c with tls", func(c *appConfig) {
+			c.TLSCertPath, c.TLSKeyPath, c.H2C = "cert.pem", "key.pem", true
+		}, true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := valid
+			tt.modify(&c)
+			_, err := newServerOptions(c)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want = %v, got = %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	opts, err := newServerOptions(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.NotFoundHandler()}
+	if err := opts.configure(server); err != nil {
+		t.Fatal(err)
+	}
+	if server.ReadTimeout != 10*time.Second || server.ReadHeaderTimeout != 5*time.Second ||
+		server.WriteTimeout != 30*time.Second || server.IdleTimeout != 120*time.Second {
+		t.Errorf("want = %v, got = %v", "10s 5s 30s 2m0s",
+			[]time.Duration{server.ReadTimeout, server.ReadHeaderTimeout, server.WriteTimeout, server.IdleTimeout})
+	}
+	if server.MaxHeaderBytes != 1<<20 {
+		t.Errorf("want = %v, got = %v", 1<<20, server.MaxHeaderBytes)
+	}
+	if opts.shutdownTimeout != 10*time.Second {
+		t.Errorf("want = %v, got = %v", 10*time.Second, opts.shutdownTimeout)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir, cleanup := newTestCertDir(t)
+	defer cleanup()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	writeTestCert(t, certPath, keyPath, 1)
+
+	r, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	serial := func() int64 {
+		t.Helper()
+		cert, err := r.getCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.SerialNumber.Int64()
+	}
+	if got := serial(); got != 1 {
+		t.Errorf("want = %v, got = %v", 1, got)
+	}
+
+	writeTestCert(t, certPath, keyPath, 2)
+	// The files are not checked again until the interval passes.
+	if got := serial(); got != 1 {
+		t.Errorf("want = %v, got = %v", 1, got)
+	}
+	now = now.Add(certCheckInterval)
+	if got := serial(); got != 2 {
+		t.Errorf("want = %v, got = %v", 2, got)
+	}
+
+	// A broken key keeps the previous certificate.
+	if err := ioutil.WriteFile(keyPath, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(keyPath, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(certCheckInterval)
+	if got := serial(); got != 2 {
+		t.Errorf("want = %v, got = %v", 2, got)
+	}
+}
+
+// serveTest starts the server on a local port and returns its address.
+func serveTest(t *testing.T, server *http.Server, useTLS bool) (string, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		if useTLS {
+			server.ServeTLS(ln, "", "")
+		} else {
+			server.Serve(ln)
+		}
+	}()
+	return ln.Addr().String(), func() { server.Close() }
+}
+
+func protoHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Proto))
+}
+
+func TestServeHTTP2(t *testing.T) {
+	dir, cleanup := newTestCertDir(t)
+	defer cleanup()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	writeTestCert(t, certPath, keyPath, 1)
+
+	c := conf
+	c.TLSCertPath, c.TLSKeyPath = certPath, keyPath
+	opts, err := newServerOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(protoHandler)}
+	if err := opts.configure(server); err != nil {
+		t.Fatal(err)
+	}
+	addr, stop := serveTest(t, server, true)
+	defer stop()
+
+	client := &http.Client{Transport: &http2.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "HTTP/2.0"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+}
+
+func TestServeH2C(t *testing.T) {
+	c := conf
+	c.H2C = true
+	opts, err := newServerOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(protoHandler)}
+	if err := opts.configure(server); err != nil {
+		t.Fatal(err)
+	}
+	addr, stop := serveTest(t, server, false)
+	defer stop()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	resp, err := client.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(body), "HTTP/2.0"; got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+}
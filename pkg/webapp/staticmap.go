@@ -0,0 +1,193 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/staticmap"
+	"github.com/twihike/go-geojp/pkg/geo/tiles"
+)
+
+const (
+	staticMapURL = "/api/staticmap"
+	// staticMapMaxSize is the maximum width and height of the images.
+	staticMapMaxSize = 1280
+	// staticMapMaxZoom is the highest zoom level of the images.
+	staticMapMaxZoom = 20
+	// staticMapMaxMarkers is the maximum number of markers of an image.
+	staticMapMaxMarkers = 100
+	// staticMapAreasMinZoom is the lowest zoom level at which the areas are
+	// drawn, as lower levels would show too many of them.
+	staticMapAreasMinZoom = 12
+)
+
+var (
+	// appTiles is the source of the background tiles, if configured.
+	appTiles tiles.Source
+	// appTilesVersion identifies the file of the background tiles, so that
+	// the cached images are invalidated when it is replaced.
+	appTilesVersion string
+
+	staticMapAreaColor   = color.RGBA{R: 0x1e, G: 0x64, B: 0xc8, A: 0xff}
+	staticMapMarkerColor = color.RGBA{R: 0xd7, G: 0x26, B: 0x26, A: 0xff}
+	staticMapStrokeColor = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// openTiles opens the background tiles and returns the information of their
+// file. The tiles must be images, as the static maps cannot draw vector
+// tiles.
+func openTiles(path string) (tiles.Source, os.FileInfo, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := tiles.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if md, ok := src.(interface{ Metadata() map[string]string }); ok {
+		switch format := md.Metadata()["format"]; format {
+		case "png", "jpg", "jpeg":
+		default:
+			src.Close()
+			return nil, nil, fmt.Errorf("tiles: unsupported format of %s: %q, want png or jpg", path, format)
+		}
+	}
+	return src, fi, nil
+}
+
+// tilesVersion returns the version of the file of the tiles from its
+// modification time and size.
+func tilesVersion(fi os.FileInfo) string {
+	return strconv.FormatInt(fi.ModTime().UnixNano(), 16) + "." + strconv.FormatInt(fi.Size(), 16)
+}
+
+type staticMapInput struct {
+	Center  string   `strmap:"center,required" doc:"Center of the map as latitude,longitude." example:"35.658584,139.7454316"`
+	Zoom    int      `strmap:"zoom,required" doc:"Zoom level from 0 to 20. The areas are drawn from zoom level 12." example:"16"`
+	Size    string   `strmap:"size" doc:"Width and height of the image in pixels as WIDTHxHEIGHT, each up to 1280. Defaults to 600x400." example:"600x400"`
+	Markers []string `strmap:"markers" doc:"Positions of markers as latitude,longitude. Several positions may be separated by |. May be repeated." example:"35.658584,139.7454316"`
+}
+
+// staticMap renders a PNG image of the map with the representative points
+// of the areas around the center and the markers. The background tiles are
+// drawn if TILES_PATH is configured.
+func staticMap(w http.ResponseWriter, r *http.Request) {
+	var in staticMapInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	center, err := parseLatLong(in.Center)
+	if err != nil || in.Zoom < 0 || in.Zoom > staticMapMaxZoom {
+		writeError(w, http.StatusBadRequest, "invalid center or zoom")
+		return
+	}
+	width, height := 600, 400
+	if in.Size != "" {
+		width, height, err = parseSize(in.Size)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid size")
+			return
+		}
+	}
+	var markers []geo.LatLong
+	for _, v := range in.Markers {
+		for _, s := range strings.Split(v, "|") {
+			p, err := parseLatLong(s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid marker")
+				return
+			}
+			markers = append(markers, p)
+		}
+	}
+	if len(markers) > staticMapMaxMarkers {
+		writeError(w, http.StatusBadRequest, "too many markers")
+		return
+	}
+
+	m := staticmap.New(center, in.Zoom, width, height)
+	if appTiles != nil {
+		if err := m.DrawTiles(appTiles); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	areas := 0
+	if in.Zoom >= staticMapAreasMinZoom {
+		for _, ap := range iaps.Near(center, areaZoom(in.Zoom, width, height)) {
+			m.DrawPoint(geo.LatLong{Latitude: ap.Latitude, Longitude: ap.Longitude},
+				3, staticMapAreaColor, staticMapStrokeColor)
+			areas++
+		}
+	}
+	for _, p := range markers {
+		m.DrawPoint(p, 6, staticMapMarkerColor, staticMapStrokeColor)
+	}
+	setResultCount(r, areas)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m.Image()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// areaZoom returns the zoom level of the tiles whose 3x3 block around the
+// center covers the image, which IndexedAPs.Near searches.
+func areaZoom(zoom, width, height int) int {
+	half := width
+	if height > half {
+		half = height
+	}
+	half /= 2
+	k := 0
+	for 256<<uint(k) < half {
+		k++
+	}
+	return zoom - k
+}
+
+// parseLatLong parses a position given as latitude,longitude.
+func parseLatLong(s string) (geo.LatLong, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != 2 {
+		return geo.LatLong{}, errors.New("invalid position")
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	long, err2 := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err1 != nil || err2 != nil || !(lat >= -90 && lat <= 90 && long >= -180 && long <= 180) {
+		return geo.LatLong{}, errors.New("invalid position")
+	}
+	return geo.LatLong{Latitude: lat, Longitude: long}, nil
+}
+
+// parseSize parses the size of an image given as WIDTHxHEIGHT.
+func parseSize(s string) (int, int, error) {
+	i := strings.Index(s, "x")
+	if i < 0 {
+		return 0, 0, errors.New("invalid size")
+	}
+	width, err1 := strconv.Atoi(s[:i])
+	height, err2 := strconv.Atoi(s[i+1:])
+	if err1 != nil || err2 != nil || width < 1 || height < 1 ||
+		width > staticMapMaxSize || height > staticMapMaxSize {
+		return 0, 0, errors.New("invalid size")
+	}
+	return width, height, nil
+}
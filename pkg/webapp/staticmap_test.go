@@ -0,0 +1,213 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/geo/staticmap"
+	"github.com/twihike/go-geojp/pkg/geo/tiles"
+)
+
+func TestStaticMap(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, nil, time.Now())
+	defer func(l *logger) { appLogger = l }(appLogger)
+	appLogger = &logger{out: ioutil.Discard, level: levelError, now: time.Now}
+	defer func(s tiles.Source) { appTiles = s }(appTiles)
+
+	center := geo.LatLong{Latitude: 35.658584, Longitude: 139.7454316}
+	area := geo.LatLong{Latitude: 35.659943, Longitude: 139.747207}
+	marker := geo.LatLong{Latitude: 35.6575, Longitude: 139.7445}
+	render := func(target string) image.Image {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+target, nil)
+		got := httptest.NewRecorder()
+		staticMap(got, req)
+		if got.Code != http.StatusOK {
+			t.Fatalf("%s: want = %v, got = %v", target, http.StatusOK, got.Code)
+		}
+		if ct := got.Header().Get("Content-Type"); ct != "image/png" {
+			t.Errorf("want = %v, got = %v", "image/png", ct)
+		}
+		img, err := png.Decode(bytes.NewReader(got.Body.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	at := func(img image.Image, zoom, width, height int, p geo.LatLong) interface{} {
+		pt := staticmap.New(center, zoom, width, height).Point(p)
+		return img.At(pt.X, pt.Y)
+	}
+
+	appTiles = nil
+	img := render(staticMapURL + "?center=35.658584,139.7454316&zoom=16&size=300x200&markers=35.6575,139.7445")
+	if got, want := img.Bounds(), image.Rect(0, 0, 300, 200); got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := at(img, 16, 300, 200, area); got != staticMapAreaColor {
+		t.Errorf("want = %v, got = %v", staticMapAreaColor, got)
+	}
+	if got := at(img, 16, 300, 200, marker); got != staticMapMarkerColor {
+		t.Errorf("want = %v, got = %v", staticMapMarkerColor, got)
+	}
+	if got := img.At(0, 0); got != staticmap.Background {
+		t.Errorf("want = %v, got = %v", staticmap.Background, got)
+	}
+
+	// The areas are not drawn at low zoom levels.
+	img = render(staticMapURL + "?center=35.658584,139.7454316&zoom=11")
+	if got, want := img.Bounds(), image.Rect(0, 0, 600, 400); got != want {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := at(img, 11, 600, 400, area); got != staticmap.Background {
+		t.Errorf("want = %v, got = %v", staticmap.Background, got)
+	}
+
+	// The tiles are drawn under the areas.
+	appTiles = tiles.Dir("../../testdata/tiles")
+	img = render(staticMapURL + "?center=35.658584,139.7454316&zoom=16&size=300x200")
+	if got := img.At(0, 0); got == staticmap.Background {
+		t.Errorf("want = %v, got = %v", "tile", got)
+	}
+	if got := at(img, 16, 300, 200, area); got != staticMapAreaColor {
+		t.Errorf("want = %v, got = %v", staticMapAreaColor, got)
+	}
+
+	tests := []struct {
+		target   string
+		wantCode int
+	}{
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=16&markers=35.6575,139.7445|35.6596,139.7472&markers=35.66,139.75", http.StatusOK},
+		{staticMapURL + "?zoom=16", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584&zoom=16", http.StatusBadRequest},
+		{staticMapURL + "?center=91,139.7454316&zoom=16", http.StatusBadRequest},
+		{staticMapURL + "?center=NaN,139.7454316&zoom=16", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=21", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=-1", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=16&size=1281x100", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=16&size=0x100", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=16&size=300", http.StatusBadRequest},
+		{staticMapURL + "?center=35.658584,139.7454316&zoom=16&markers=35.6575", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.target, nil)
+		got := httptest.NewRecorder()
+		staticMap(got, req)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s: want = %v, got = %v", tt.target, tt.wantCode, got.Code)
+		}
+	}
+}
+
+func TestAreaZoom(t *testing.T) {
+	tests := []struct {
+		zoom, width, height int
+		want                int
+	}{
+		{16, 600, 400, 15},
+		{16, 400, 300, 16},
+		{16, 1280, 1280, 14},
+		{12, 100, 1280, 10},
+	}
+	for _, tt := range tests {
+		if got := areaZoom(tt.zoom, tt.width, tt.height); got != tt.want {
+			t.Errorf("%dx%d at %d: want = %v, got = %v", tt.width, tt.height, tt.zoom, tt.want, got)
+		}
+	}
+}
+
+func TestOpenTiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geojp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, format string, tile []byte) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		md := tiles.Metadata{Name: name, Format: format, MaxZoom: 1}
+		data := map[geo.Tile][]byte{{Z: 1, X: 1, Y: 0}: tile}
+		if filepath.Ext(name) == ".pmtiles" {
+			err = tiles.WritePMTiles(f, md, data)
+		} else {
+			err = tiles.WriteMBTiles(f, md, data)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"../../testdata/tiles", false},
+		{write("raster.mbtiles", "png", []byte("png")), false},
+		{write("raster.pmtiles", "jpg", []byte("jpg")), false},
+		{write("vector.mbtiles", "pbf", []byte("pbf")), true},
+		{write("vector.pmtiles", "pbf", []byte("pbf")), true},
+		{filepath.Join(dir, "missing.mbtiles"), true},
+	}
+	for _, tt := range tests {
+		src, fi, err := openTiles(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: want = %v, got = %v", tt.path, tt.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		src.Close()
+		if fi == nil || tilesVersion(fi) == "" {
+			t.Errorf("%s: want = %v, got = %v", tt.path, "file info", fi)
+		}
+	}
+
+	// Replacing the tiles changes the version of the cached responses.
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(v string) { appTilesVersion = v }(appTilesVersion)
+	version := func(path string) string {
+		t.Helper()
+		src, fi, err := openTiles(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		src.Close()
+		appTilesVersion = tilesVersion(fi)
+		loadDataset(a, geo.Spherical, nil, time.Now())
+		return appCache.version
+	}
+	path := write("replaced.mbtiles", "png", []byte("png"))
+	before := version(path)
+	path = write("replaced.mbtiles", "png", []byte("replaced png"))
+	if after := version(path); after == before {
+		t.Errorf("want = %v, got = %v", "new version", after)
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	streamURL = "/api/reverse-geocoding/stream"
+	// streamMaxDevices is the maximum number of devices of a connection.
+	streamMaxDevices = 10000
+	// streamMaxClientConns is the maximum number of open connections of a
+	// client, which is an API key or an IP address without authentication.
+	streamMaxClientConns = 8
+	// streamMaxMessageBytes is the maximum size of a received message.
+	streamMaxMessageBytes = 4096
+	// streamIdleTimeout closes connections that send no message.
+	streamIdleTimeout = 2 * time.Minute
+	// streamWriteTimeout closes connections that do not read the messages.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// appStreams are the open stream connections, which are closed on shutdown.
+var appStreams = newStreamSet(streamMaxClientConns)
+
+type streamInput struct {
+	Margin   float64  `strmap:"margin" doc:"Meters by which another area must be nearer than the current area of a device to become its area. Defaults to 30." example:"30"`
+	PrefCode []string `strmap:"pref_code" doc:"Prefecture codes to restrict the areas to. May be repeated."`
+	PrefName []string `strmap:"pref_name" doc:"Prefecture names to restrict the areas to. May be repeated."`
+	CityCode []string `strmap:"city_code" doc:"City codes to restrict the areas to. May be repeated."`
+	CityName []string `strmap:"city_name" doc:"City names to restrict the areas to. May be repeated."`
+}
+
+// streamPosition is a message sent by the client.
+type streamPosition struct {
+	DeviceID  string   `json:"device_id" doc:"ID of the device."`
+	Latitude  *float64 `json:"latitude" doc:"Latitude of the device."`
+	Longitude *float64 `json:"longitude" doc:"Longitude of the device."`
+}
+
+// streamAreaOutput is sent when the area of a device changes.
+type streamAreaOutput struct {
+	DeviceID  string  `json:"device_id" doc:"ID of the device."`
+	PrefName  string  `json:"pref_name" doc:"Prefecture name."`
+	CityName  string  `json:"city_name" doc:"City name."`
+	AreaName  string  `json:"area_name" doc:"Area name."`
+	Latitude  float64 `json:"latitude" doc:"Latitude of the representative point of the area."`
+	Longitude float64 `json:"longitude" doc:"Longitude of the representative point of the area."`
+	Distance  float64 `json:"distance" doc:"Distance in meters from the position."`
+}
+
+// streamErrorOutput is sent for a message that cannot be processed.
+type streamErrorOutput struct {
+	DeviceID string `json:"device_id,omitempty" doc:"ID of the device of the message, if any."`
+	Error    string `json:"error" doc:"Reason the message was rejected."`
+}
+
+// reverseGeocodingStream streams the area of moving devices over a
+// WebSocket. The client sends positions and receives the area of a device
+// only when it changes. Each position counts against the limits of the
+// client as a request.
+func reverseGeocodingStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var in streamInput
+	if err := readParams(r, &in); err != nil {
+		writeParamsError(w, err)
+		return
+	}
+	if in.Margin < 0 {
+		writeError(w, http.StatusBadRequest, "invalid margin")
+		return
+	}
+	if _, ok := r.Form["margin"]; !ok {
+		in.Margin = 30
+	}
+	idx := regions.Index(jp.Filter{
+		PrefCodes: in.PrefCode,
+		PrefNames: in.PrefName,
+		CityCodes: in.CityCode,
+		CityNames: in.CityName,
+	})
+	if len(idx) == 0 {
+		writeError(w, http.StatusNotFound, "no area matches the filters")
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		writeError(w, http.StatusUpgradeRequired, "not a WebSocket handshake")
+		return
+	}
+	if _, ok := w.(http.Hijacker); !ok {
+		writeError(w, http.StatusHTTPVersionNotSupported, "HTTP/1.1 is required")
+		return
+	}
+	// The connection outlives the request, so it keeps the guard and the
+	// streams of the time it was opened.
+	g, streams := appGuard, appStreams
+	var key *apiKey
+	if g.authEnabled() {
+		key = g.lookup(r)
+	}
+	ip := clientIP(r)
+	client := clientID(ip, key)
+	if !streams.acquire(client) {
+		writeError(w, http.StatusTooManyRequests, "too many streams")
+		return
+	}
+	defer streams.release(client)
+	tracker := jp.NewAreaTracker(idx, in.Margin, streamMaxDevices)
+	server := websocket.Server{
+		Handshake: checkStreamOrigin,
+		Handler: func(ws *websocket.Conn) {
+			serveStream(ws, streams, tracker, func() (bool, time.Duration) {
+				return g.allow(ip, key)
+			})
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkStreamOrigin accepts clients without an origin, such as servers, and
+// browsers on the same host or on an origin allowed by CORS.
+func checkStreamOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil || origin == nil {
+		return err
+	}
+	config.Origin = origin
+	if origin.Host == r.Host || appCORS.enabled() && appCORS.allowOrigin(originOf(origin)) {
+		return nil
+	}
+	return errors.New("origin not allowed")
+}
+
+func originOf(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// serveStream reads the positions of the connection, which is one of the
+// streams, until it is closed, and rejects those that allow does not. The
+// changes are written by another goroutine, so a slow client does not block
+// the reading. While the client is behind, the changes of a device replace
+// each other.
+func serveStream(ws *websocket.Conn, streams *streamSet, tracker *jp.AreaTracker, allow func() (bool, time.Duration)) {
+	ws.MaxPayloadBytes = streamMaxMessageBytes
+	if !streams.add(ws) {
+		return
+	}
+	defer streams.remove(ws)
+
+	out := newStreamOutbox()
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for {
+			select {
+			case <-out.ready:
+			case <-done:
+				return
+			}
+			for _, msg := range out.take() {
+				ws.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+				if err := websocket.JSON.Send(ws, msg); err != nil {
+					ws.Close()
+					return
+				}
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		<-writerDone
+	}()
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(streamIdleTimeout))
+		var msg streamPosition
+		err := websocket.JSON.Receive(ws, &msg)
+		switch {
+		case err == websocket.ErrFrameTooLarge:
+			out.put(streamKey{error: true}, streamErrorOutput{Error: "message too large"})
+			continue
+		case err != nil && isStreamClosed(err):
+			return
+		case err != nil:
+			out.put(streamKey{error: true}, streamErrorOutput{Error: "invalid message"})
+			continue
+		}
+		errKey := streamKey{deviceID: msg.DeviceID, error: true}
+		if ok, wait := allow(); !ok {
+			out.put(errKey, streamErrorOutput{
+				DeviceID: msg.DeviceID,
+				Error:    fmt.Sprintf("rate limit or daily quota exceeded, retry after %d seconds", retryAfter(wait)),
+			})
+			continue
+		}
+		if msg.DeviceID == "" || msg.Latitude == nil || msg.Longitude == nil {
+			out.put(errKey, streamErrorOutput{DeviceID: msg.DeviceID, Error: "device_id, latitude and longitude are required"})
+			continue
+		}
+		p := geo.LatLong{Latitude: *msg.Latitude, Longitude: *msg.Longitude}
+		if p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+			out.put(errKey, streamErrorOutput{DeviceID: msg.DeviceID, Error: "invalid position"})
+			continue
+		}
+		ap, changed, err := tracker.Update(msg.DeviceID, p)
+		if err != nil {
+			out.put(errKey, streamErrorOutput{DeviceID: msg.DeviceID, Error: err.Error()})
+			continue
+		}
+		if changed {
+			out.put(streamKey{deviceID: msg.DeviceID}, streamAreaOutput{
+				DeviceID:  msg.DeviceID,
+				PrefName:  ap.PrefName,
+				CityName:  ap.CityName,
+				AreaName:  ap.AreaName,
+				Latitude:  ap.Latitude,
+				Longitude: ap.Longitude,
+				Distance:  ap.Distance,
+			})
+		}
+	}
+}
+
+// isStreamClosed reports whether a receive error ends the connection, as
+// opposed to a message that cannot be decoded.
+func isStreamClosed(err error) bool {
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return false
+	}
+	return true
+}
+
+// streamKey identifies the pending messages of an outbox. A device has at
+// most one pending change and one pending error.
+type streamKey struct {
+	deviceID string
+	error    bool
+}
+
+// streamOutbox holds the messages not yet written to the client, in the
+// order their keys were first put.
+type streamOutbox struct {
+	mu      sync.Mutex
+	keys    []streamKey
+	pending map[streamKey]interface{}
+	// ready has a value while messages are pending.
+	ready chan struct{}
+}
+
+func newStreamOutbox() *streamOutbox {
+	return &streamOutbox{
+		pending: map[streamKey]interface{}{},
+		ready:   make(chan struct{}, 1),
+	}
+}
+
+// put adds the message, replacing the pending message of the key.
+func (o *streamOutbox) put(key streamKey, msg interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.pending[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.pending[key] = msg
+	select {
+	case o.ready <- struct{}{}:
+	default:
+	}
+}
+
+// take removes and returns the pending messages.
+func (o *streamOutbox) take() []interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	msgs := make([]interface{}, len(o.keys))
+	for i, k := range o.keys {
+		msgs[i] = o.pending[k]
+	}
+	o.keys = nil
+	o.pending = map[streamKey]interface{}{}
+	return msgs
+}
+
+// streamSet is a set of open connections, and the number of connections of
+// each client.
+type streamSet struct {
+	mu           sync.Mutex
+	closed       bool
+	conns        map[*websocket.Conn]bool
+	clients      map[string]int
+	maxPerClient int
+}
+
+func newStreamSet(maxPerClient int) *streamSet {
+	return &streamSet{
+		conns:        map[*websocket.Conn]bool{},
+		clients:      map[string]int{},
+		maxPerClient: maxPerClient,
+	}
+}
+
+// acquire counts a connection of the client and reports whether the client
+// has fewer than the maximum number of connections.
+func (s *streamSet) acquire(client string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[client] >= s.maxPerClient {
+		return false
+	}
+	s.clients[client]++
+	return true
+}
+
+// release uncounts a connection of the client.
+func (s *streamSet) release(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[client]--; s.clients[client] <= 0 {
+		delete(s.clients, client)
+	}
+}
+
+// add adds the connection and reports whether the set is still open.
+func (s *streamSet) add(ws *websocket.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[ws] = true
+	return true
+}
+
+func (s *streamSet) remove(ws *websocket.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, ws)
+}
+
+// closeAll closes the connections and rejects new ones. The server does not
+// close hijacked connections when shutting down.
+func (s *streamSet) closeAll() {
+	s.mu.Lock()
+	s.closed = true
+	conns := make([]*websocket.Conn, 0, len(s.conns))
+	for ws := range s.conns {
+		conns = append(conns, ws)
+	}
+	s.mu.Unlock()
+	// Closing waits for a write in progress, which ends by its deadline.
+	for _, ws := range conns {
+		ws.Close()
+	}
+}
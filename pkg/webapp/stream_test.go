@@ -0,0 +1,242 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twihike/go-geojp/pkg/geo"
+	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"golang.org/x/net/websocket"
+)
+
+func TestReverseGeocodingStream(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, nil, time.Now())
+	defer func(l *logger, s *streamSet) { appLogger, appStreams = l, s }(appLogger, appStreams)
+	appLogger = &logger{out: ioutil.Discard, level: levelError, now: time.Now}
+	appStreams = newStreamSet(streamMaxClientConns)
+	srv := newTestStreamServer()
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + streamURL
+
+	ws, err := websocket.Dial(wsURL+"?margin=100", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	receive := func() map[string]interface{} {
+		t.Helper()
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var msg map[string]interface{}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	send := func(msg string) {
+		t.Helper()
+		if _, err := ws.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A device moves from 芝公園三丁目 toward 東麻布一丁目 and the area
+	// changes only once it is well past the boundary.
+	send(`{"device_id":"truck-1","latitude":35.6596185,"longitude":139.746864}`)
+	if got := receive(); got["device_id"] != "truck-1" || got["area_name"] != "芝公園三丁目" {
+		t.Errorf("want = %v, got = %v", "芝公園三丁目", got)
+	}
+	send(`{"device_id":"truck-1","latitude":35.6581585,"longitude":139.7453205}`)
+	send(`{"device_id":"truck-1","latitude":35.6596185,"longitude":139.746864}`)
+	send(`{"device_id":"truck-1","latitude":35.6576720,"longitude":139.744806}`)
+	got := receive()
+	want := map[string]interface{}{
+		"device_id": "truck-1",
+		"pref_name": "東京都",
+		"city_name": "港区",
+		"area_name": "東麻布一丁目",
+		"latitude":  35.656698,
+		"longitude": 139.743777,
+		"distance":  got["distance"],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+
+	send(`{"device_id":"truck-2"}`)
+	if got, want := receive(), (map[string]interface{}{
+		"device_id": "truck-2",
+		"error":     "device_id, latitude and longitude are required",
+	}); !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	send(`{"device_id":"truck-2","latitude":91,"longitude":0}`)
+	if got := receive(); got["error"] != "invalid position" {
+		t.Errorf("want = %v, got = %v", "invalid position", got)
+	}
+	send(`not json`)
+	if got := receive(); got["error"] != "invalid message" {
+		t.Errorf("want = %v, got = %v", "invalid message", got)
+	}
+	send(`{"device_id":"` + strings.Repeat("x", streamMaxMessageBytes) + `"}`)
+	if got := receive(); got["error"] != "message too large" {
+		t.Errorf("want = %v, got = %v", "message too large", got)
+	}
+
+	// The connections are closed on shutdown.
+	appStreams.closeAll()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg interface{}
+	if err := websocket.JSON.Receive(ws, &msg); err == nil {
+		t.Errorf("want = %v, got = %v", "closed", msg)
+	}
+
+	if _, err := websocket.Dial(wsURL, "", "http://other.example.com"); err == nil {
+		t.Errorf("want = %v, got = %v", "forbidden origin", err)
+	}
+
+	tests := []struct {
+		method   string
+		target   string
+		wantCode int
+	}{
+		{http.MethodGet, streamURL, http.StatusUpgradeRequired},
+		{http.MethodPost, streamURL, http.StatusMethodNotAllowed},
+		{http.MethodGet, streamURL + "?margin=-1", http.StatusBadRequest},
+		{http.MethodGet, streamURL + "?margin=x", http.StatusBadRequest},
+		{http.MethodGet, streamURL + "?pref_code=27", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://example.com"+tt.target, nil)
+		got := httptest.NewRecorder()
+		reverseGeocodingStream(got, req)
+		if got.Code != tt.wantCode {
+			t.Errorf("%s %s: want = %v, got = %v", tt.method, tt.target, tt.wantCode, got.Code)
+		}
+	}
+}
+
+func TestReverseGeocodingStreamLimits(t *testing.T) {
+	a, err := jp.ReadAPsFromFile("../../testdata/japanese-addresses.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDataset(a, geo.Spherical, nil, time.Now())
+	defer func(l *logger, g *guard, s *streamSet) { appLogger, appGuard, appStreams = l, g, s }(appLogger, appGuard, appStreams)
+	appLogger = &logger{out: ioutil.Discard, level: levelError, now: time.Now}
+	appGuard, _ = newTestGuard([]apiKey{
+		{Key: "limited-key", Label: "limited", Rate: 1, Burst: 3},
+		{Key: "other-key", Label: "other"},
+	}, 0, 0)
+	appStreams = newStreamSet(1)
+	srv := newTestStreamServer()
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + streamURL
+	dial := func(key string) (*websocket.Conn, error) {
+		config, err := websocket.NewConfig(wsURL, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Header.Set(apiKeyHeader, key)
+		return websocket.DialConfig(config)
+	}
+
+	// The handshake and each position are charged as requests.
+	ws, err := dial("limited-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	for i, want := range []string{"", "", "rate limit or daily quota exceeded, retry after 1 seconds"} {
+		msg := fmt.Sprintf(`{"device_id":"truck-%d","latitude":35.6596185,"longitude":139.746864}`, i)
+		if _, err := ws.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var got map[string]interface{}
+		if err := websocket.JSON.Receive(ws, &got); err != nil {
+			t.Fatal(err)
+		}
+		if e, _ := got["error"].(string); e != want {
+			t.Errorf("#%d: want = %v, got = %v", i, want, got)
+		}
+	}
+
+	// A client has up to the maximum number of streams.
+	other, err := dial("other-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dial("other-key"); err == nil {
+		t.Errorf("want = %v, got = %v", "too many streams", err)
+	}
+	other.Close()
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		ws, err := dial("other-key")
+		if err == nil {
+			ws.Close()
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("want = %v, got = %v", "a released stream", err)
+		}
+	}
+}
+
+// testStreamServer is a server of the application whose Close also waits
+// for the handlers of hijacked connections, which httptest.Server.Close
+// does not, so that the tests restore the globals after the handlers end.
+// The clients must close their connections first.
+type testStreamServer struct {
+	*httptest.Server
+	handlers sync.WaitGroup
+}
+
+func newTestStreamServer() *testStreamServer {
+	s := &testStreamServer{}
+	h := setupServer().Handler
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.handlers.Add(1)
+		defer s.handlers.Done()
+		h.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+func (s *testStreamServer) Close() {
+	s.Server.Close()
+	s.handlers.Wait()
+}
+
+func TestStreamOutbox(t *testing.T) {
+	o := newStreamOutbox()
+	o.put(streamKey{deviceID: "a"}, 1)
+	o.put(streamKey{deviceID: "b"}, 2)
+	o.put(streamKey{deviceID: "a", error: true}, 3)
+	o.put(streamKey{deviceID: "a"}, 4)
+	select {
+	case <-o.ready:
+	default:
+		t.Errorf("want = %v, got = %v", "ready", "not ready")
+	}
+	if got, want := o.take(), []interface{}{4, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	if got := o.take(); len(got) != 0 {
+		t.Errorf("want = %v, got = %v", 0, got)
+	}
+}
@@ -15,28 +15,94 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/twihike/go-geojp/pkg/geo"
 	"github.com/twihike/go-geojp/pkg/geo/jp"
+	"github.com/twihike/go-geojp/pkg/geo/layer"
 	"github.com/twihike/go-structconv/structconv"
+	"google.golang.org/grpc"
 )
 
 type appConfig struct {
-	Port           string
-	StaticDir      string
-	StaticURL      string
-	HealthCheckURL string
-	AddrPosPath    string
+	Port               string
+	StaticDir          string
+	StaticURL          string
+	HealthCheckURL     string
+	MetricsURL         string
+	AddrPosPath        string
+	DistanceModel      string
+	LogLevel           string
+	LogFormat          string
+	CacheSize          int
+	CacheMaxAge        int
+	CacheRoundDigits   int
+	APIKeysPath        string
+	RateLimit          int
+	RateBurst          int
+	AdminUsageURL      string
+	CORSOrigins        string
+	CORSMethods        string
+	CORSHeaders        string
+	CORSExposeHeaders  string
+	CORSCredentials    bool
+	CORSMaxAge         int
+	TLSCertPath        string
+	TLSKeyPath         string
+	H2C                bool
+	ReadTimeout        string
+	ReadHeaderTimeout  string
+	WriteTimeout       string
+	IdleTimeout        string
+	MaxHeaderBytes     int
+	ShutdownTimeout    string
+	GRPCPort           string
+	GeofenceDwell      string
+	GeofenceIdle       string
+	GeofenceMaxDevices int
+	GeofenceMaxZones   int
+	WebhookURL         string
+	WebhookSecret      string
+	WebhookTimeout     string
+	WebhookBackoff     string
+	WebhookMaxAttempts int
+	TilesPath          string
 }
 
 var (
 	conf appConfig = appConfig{
-		Port:           "8080",
-		AddrPosPath:    "latest.csv",
-		HealthCheckURL: "/api/health",
-		StaticDir:      "web/static",
-		StaticURL:      "/",
-	}
-	aps  jp.AddressPositions
-	iaps jp.IndexedAPs
+		Port:               "8080",
+		AddrPosPath:        "latest.csv",
+		DistanceModel:      "spherical",
+		LogLevel:           "info",
+		LogFormat:          "json",
+		CacheSize:          1024,
+		CacheMaxAge:        3600,
+		CacheRoundDigits:   -1,
+		AdminUsageURL:      "/api/admin/usage",
+		CORSMethods:        "GET, POST, DELETE",
+		CORSHeaders:        "Authorization, Content-Type, If-None-Match, X-API-Key, X-Request-ID",
+		CORSExposeHeaders:  "ETag, Link, Retry-After, X-Cache, X-Next-Cursor, X-Request-ID, X-Total-Count",
+		CORSMaxAge:         600,
+		ReadTimeout:        "10s",
+		ReadHeaderTimeout:  "5s",
+		WriteTimeout:       "30s",
+		IdleTimeout:        "120s",
+		MaxHeaderBytes:     1 << 20,
+		ShutdownTimeout:    "10s",
+		GeofenceDwell:      "5m",
+		GeofenceIdle:       "1h",
+		GeofenceMaxDevices: 10000,
+		GeofenceMaxZones:   1000,
+		WebhookTimeout:     "10s",
+		WebhookBackoff:     "1s",
+		WebhookMaxAttempts: 5,
+		HealthCheckURL:     "/api/health",
+		MetricsURL:         "/metrics",
+		StaticDir:          "web/static",
+		StaticURL:          "/",
+	}
+	aps     jp.AddressPositions
+	iaps    jp.APIndex
+	regions *jp.RegionalIndex
 )
 
 // RunServer runs the web application server.
@@ -47,17 +113,123 @@ func RunServer() {
 	if err := structconv.DecodeEnv(&conf); err != nil {
 		log.Fatal(err)
 	}
-	log.Printf("config: %+v\n", conf)
+	logged := conf
+	if logged.WebhookSecret != "" {
+		logged.WebhookSecret = "***"
+	}
+	log.Printf("config: %+v\n", logged)
 
 	var err error
-	aps, err = jp.ReadAPsFromFile(conf.AddrPosPath)
+	appLogger, err = newLogger(os.Stderr, conf.LogLevel, conf.LogFormat)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	opts, err := newServerOptions(conf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	model, err := geo.ParseDistanceModel(conf.DistanceModel)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var keys []apiKey
+	if conf.APIKeysPath != "" {
+		keys, err = readAPIKeys(conf.APIKeysPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	appGuard = newGuard(keys, float64(conf.RateLimit), conf.RateBurst)
+	appCORS, err = newCORS(conf.CORSOrigins, conf.CORSMethods, conf.CORSHeaders,
+		conf.CORSExposeHeaders, conf.CORSCredentials, conf.CORSMaxAge)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	iaps = jp.CreateIndexedAPs(aps)
+	appCache = newResponseCache(conf.CacheSize, conf.CacheMaxAge, conf.CacheRoundDigits)
+	if err := setupGeofences(conf); err != nil {
+		log.Fatalln(err)
+	}
+	var tilesInfo os.FileInfo
+	if conf.TilesPath != "" {
+		appTiles, tilesInfo, err = openTiles(conf.TilesPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		appTilesVersion = tilesVersion(tilesInfo)
+	}
+	addrPath, layerPaths, err := parseDatasetPaths(conf.AddrPosPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	a, err := jp.ReadAPsFromFile(addrPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fi, err := os.Stat(addrPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	modTime := fi.ModTime()
+	var layers []*layer.Layer
+	for _, lp := range layerPaths {
+		l, err := layer.ReadFile(lp.name, lp.path, model)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fi, err := os.Stat(lp.path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+		layers = append(layers, l)
+	}
+	if tilesInfo != nil && tilesInfo.ModTime().After(modTime) {
+		modTime = tilesInfo.ModTime()
+	}
+	loadDataset(a, model, layers, modTime)
 
 	server := setupServer()
-	runServer(server)
+	if err := opts.configure(server); err != nil {
+		log.Fatalln(err)
+	}
+	grpcServer, err := startGRPCServer(server.TLSConfig)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	runServer(server, opts, grpcServer)
+}
+
+// loadDataset replaces the dataset, indexed with the distance model, and the
+// layers served by the application and invalidates the cached responses.
+func loadDataset(a jp.AddressPositions, model geo.DistanceModel, layers []*layer.Layer, modTime time.Time) {
+	start := time.Now()
+	aps = a
+	iaps = jp.CreateAPIndex(a, model)
+	// Every prefecture and city is indexed up front, so filters of the
+	// requests build no index.
+	regions = jp.NewRegionalIndex(a, iaps)
+	addrTree = newAddressTree(a)
+	appMetrics.setDataset(len(a), time.Since(start), time.Now())
+	appLayers = make(map[string]*layer.Layer, len(layers))
+	for _, l := range layers {
+		appLayers[l.Name()] = l
+	}
+	version := datasetVersion(a)
+	if len(layers) > 0 {
+		version += "-" + layersVersion(appLayers)
+	}
+	if appTilesVersion != "" {
+		version += "-" + appTilesVersion
+	}
+	appCache.reset(version, modTime)
+}
+
+// api wraps a handler of the API with authentication, rate limiting and
+// caching.
+func api(h http.HandlerFunc) http.HandlerFunc {
+	return appGuard.middleware(appCache.middleware(h))
 }
 
 func setupServer() *http.Server {
@@ -65,16 +237,32 @@ func setupServer() *http.Server {
 	files := http.FileServer(http.Dir(conf.StaticDir))
 	mux.Handle(conf.StaticURL, http.StripPrefix(conf.StaticURL, files))
 	mux.HandleFunc(conf.HealthCheckURL, health)
-	mux.HandleFunc("/api/geocoding", geocoding)
-	mux.HandleFunc("/api/reverse-geocoding", reverseGeocoding)
+	mux.HandleFunc(conf.MetricsURL, appMetrics.handler)
+	mux.HandleFunc("/api/openapi.json", openAPI)
+	mux.HandleFunc("/api/geocoding", api(geocoding))
+	mux.HandleFunc("/api/reverse-geocoding", api(reverseGeocoding))
+	mux.HandleFunc("/api/route-reverse-geocoding", api(routeReverseGeocoding))
+	mux.HandleFunc(streamURL, appGuard.middleware(reverseGeocodingStream))
+	mux.HandleFunc(layersURL, api(layerHandler))
+	mux.HandleFunc(staticMapURL, api(staticMap))
+	// Geofences and positions change state, so they are not cached.
+	mux.HandleFunc(geofencesURL, appGuard.middleware(geofencesHandler))
+	mux.HandleFunc(geofencesURL+"/", appGuard.middleware(geofenceHandler))
+	mux.HandleFunc(positionsURL, appGuard.middleware(positionsHandler))
+	// GraphQL queries are not cached as the form does not identify them.
+	mux.HandleFunc("/graphql", appGuard.middleware(graphqlHandler))
+	if appGuard.authEnabled() {
+		mux.HandleFunc(conf.AdminUsageURL, appGuard.usageHandler)
+	}
 	server := &http.Server{
 		Addr:    ":" + conf.Port,
-		Handler: mux,
+		Handler: appLogger.accessLog(appCORS.middleware(appMetrics.middleware(mux))),
 	}
+	server.RegisterOnShutdown(appStreams.closeAll)
 	return server
 }
 
-func runServer(server *http.Server) {
+func runServer(server *http.Server, opts serverOptions, grpcServer *grpc.Server) {
 	idleConnsClosed := make(chan struct{})
 	go func() {
 		sig := make(chan os.Signal, 1)
@@ -82,16 +270,32 @@ func runServer(server *http.Server) {
 		<-sig
 
 		log.Println("stopping app...")
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
 		defer cancel()
 		if err := server.Shutdown(ctx); err != nil {
 			log.Println(err)
 		}
+		if grpcServer != nil {
+			stopGRPCServer(ctx, grpcServer)
+		}
+		if appWebhook != nil {
+			appWebhook.close(ctx)
+		}
+		if appTiles != nil {
+			appTiles.Close()
+		}
 		close(idleConnsClosed)
 	}()
 
 	log.Println("app started on port:", conf.Port)
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	var err error
+	if opts.tls() {
+		// The certificate is provided by the TLS config.
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
 	<-idleConnsClosed
@@ -0,0 +1,248 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// webhookQueueSize is the number of requests waiting for delivery, and
+	// the number of requests waiting for a retry. New requests are dropped
+	// while the queue is full, and failed ones while the retries are.
+	webhookQueueSize = 1024
+	// webhookMaxBackoff is the maximum delay between attempts.
+	webhookMaxBackoff = time.Minute
+	// webhookSignatureHeader has the HMAC-SHA256 of the body keyed by the
+	// secret, if configured.
+	webhookSignatureHeader = "X-Geojp-Signature"
+)
+
+// webhook posts JSON bodies to a URL. A worker posts them in the order they
+// are sent, and hands the failures to another worker that retries them with
+// exponential backoff, so that a retry does not hold up the bodies behind
+// it. Retried bodies may therefore arrive out of order.
+type webhook struct {
+	url         string
+	secret      string
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan []byte
+	// retries receives the failed deliveries, and is closed when the queue
+	// is drained.
+	retries chan delivery
+	// ctx is canceled to abandon the deliveries when closing times out.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// delivery is a body to post and its attempts so far.
+type delivery struct {
+	body    []byte
+	attempt int
+	// delay is the backoff before the next attempt, which is due at due.
+	delay time.Duration
+	due   time.Time
+}
+
+// newWebhook returns a webhook and starts its workers. Each attempt times
+// out after timeout, and the first retry waits for backoff.
+func newWebhook(url, secret string, timeout, backoff time.Duration, maxAttempts int) *webhook {
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &webhook{
+		url:         url,
+		secret:      secret,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+		queue:       make(chan []byte, webhookQueueSize),
+		retries:     make(chan delivery, webhookQueueSize),
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go h.run()
+	go h.retry()
+	return h
+}
+
+// send queues the body and reports whether it was accepted.
+func (h *webhook) send(body []byte) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	select {
+	case h.queue <- body:
+		return true
+	default:
+		appLogger.log(levelError, "webhook queue is full", field{"url", h.url})
+		return false
+	}
+}
+
+// close stops accepting bodies and waits for the queued ones and their
+// retries to be delivered until the context is done.
+func (h *webhook) close(ctx context.Context) {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.queue)
+	}
+	h.mu.Unlock()
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		h.cancel()
+		<-h.done
+	}
+}
+
+// run makes the first attempt of each queued body.
+func (h *webhook) run() {
+	defer close(h.retries)
+	for body := range h.queue {
+		d, ok := h.attempt(delivery{body: body, delay: h.backoff})
+		if !ok {
+			continue
+		}
+		select {
+		case h.retries <- d:
+		default:
+			appLogger.log(levelError, "webhook delivery failed",
+				field{"url", h.url}, field{"error", "too many retries are waiting"})
+		}
+	}
+}
+
+// retry makes the retries when they are due, earliest first, until the
+// queue is drained and no retry is left.
+func (h *webhook) retry() {
+	defer close(h.done)
+	retries := h.retries
+	var pending []delivery
+	for retries != nil || len(pending) > 0 {
+		var timer *time.Timer
+		var due <-chan time.Time
+		if len(pending) > 0 {
+			timer = time.NewTimer(time.Until(pending[0].due))
+			due = timer.C
+		}
+		select {
+		case d, ok := <-retries:
+			if ok {
+				pending = schedule(pending, d)
+			} else {
+				retries = nil
+			}
+		case <-due:
+			d := pending[0]
+			pending = pending[1:]
+			if d, ok := h.attempt(d); ok {
+				pending = schedule(pending, d)
+			}
+		case <-h.ctx.Done():
+			// Abandon the retries, and wait for the first attempts to
+			// fail.
+			if retries != nil {
+				for d := range retries {
+					pending = append(pending, d)
+				}
+			}
+			for range pending {
+				appLogger.log(levelError, "webhook delivery failed",
+					field{"url", h.url}, field{"error", h.ctx.Err().Error()})
+			}
+			pending, retries = nil, nil
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// schedule inserts the delivery into the deliveries sorted by due time.
+func schedule(pending []delivery, d delivery) []delivery {
+	i := sort.Search(len(pending), func(i int) bool { return pending[i].due.After(d.due) })
+	pending = append(pending, delivery{})
+	copy(pending[i+1:], pending[i:])
+	pending[i] = d
+	return pending
+}
+
+// attempt posts the body once. It returns the delivery to retry and true if
+// the attempt failed and may succeed on retry, and logs the other failures.
+func (h *webhook) attempt(d delivery) (delivery, bool) {
+	d.attempt++
+	retry, err := h.post(h.ctx, d.body)
+	if err == nil {
+		return delivery{}, false
+	}
+	if !retry || d.attempt >= h.maxAttempts {
+		appLogger.log(levelError, "webhook delivery failed",
+			field{"url", h.url}, field{"attempt", d.attempt}, field{"error", err.Error()})
+		return delivery{}, false
+	}
+	appLogger.log(levelWarn, "webhook delivery will be retried",
+		field{"url", h.url}, field{"attempt", d.attempt}, field{"error", err.Error()},
+		field{"retry_in_ms", float64(d.delay) / float64(time.Millisecond)})
+	d.due = time.Now().Add(d.delay)
+	if d.delay *= 2; d.delay > webhookMaxBackoff {
+		d.delay = webhookMaxBackoff
+	}
+	return d, true
+}
+
+// post posts the body once and reports whether a failure may succeed on
+// retry. Network errors, 429 and 5xx are retried.
+func (h *webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if h.secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signature(h.secret, body))
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+// signature returns the hex encoded HMAC-SHA256 of the body.
+func signature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
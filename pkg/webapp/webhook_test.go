@@ -0,0 +1,133 @@
+// Copyright (c) 2020 twihike. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE file.
+
+package webapp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records the requests of a webhook and responds with the
+// statuses in order, then with 200.
+type webhookServer struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	sigs     []string
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bodies = append(s.bodies, string(b))
+	s.sigs = append(s.sigs, r.Header.Get(webhookSignatureHeader))
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestWebhook_Deliver(t *testing.T) {
+	defer func(l *logger) { appLogger = l }(appLogger)
+
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"success", nil, 3, 1, false},
+		{"retried", []int{http.StatusInternalServerError, http.StatusTooManyRequests}, 3, 3, false},
+		{"exhausted", []int{502, 503, 504}, 3, 3, true},
+		{"permanent", []int{http.StatusBadRequest}, 3, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			appLogger = &logger{out: &logs, level: levelError, now: time.Now}
+			s := &webhookServer{statuses: tt.statuses}
+			srv := httptest.NewServer(s)
+			defer srv.Close()
+			h := newWebhook(srv.URL, "secret", time.Second, time.Millisecond, tt.maxAttempts)
+			h.send([]byte(`{"events":[]}`))
+			h.close(context.Background())
+
+			if got := strings.Contains(logs.String(), "webhook delivery failed"); got != tt.wantErr {
+				t.Errorf("want = %v, got = %v", tt.wantErr, logs.String())
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if len(s.bodies) != tt.wantAttempts {
+				t.Errorf("want = %v, got = %v", tt.wantAttempts, len(s.bodies))
+			}
+			want := "sha256=" + signature("secret", []byte(`{"events":[]}`))
+			for i, b := range s.bodies {
+				if b != `{"events":[]}` || s.sigs[i] != want {
+					t.Errorf("want = %v %v, got = %v %v", `{"events":[]}`, want, b, s.sigs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWebhook_Close(t *testing.T) {
+	defer func(l *logger) { appLogger = l }(appLogger)
+	appLogger = &logger{out: ioutil.Discard, level: levelError, now: time.Now}
+
+	// The queued bodies are delivered in order and their retries after
+	// them before closing returns.
+	s := &webhookServer{statuses: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	h := newWebhook(srv.URL, "", time.Second, 200*time.Millisecond, 3)
+	for _, b := range []string{"1", "2", "3"} {
+		if !h.send([]byte(b)) {
+			t.Fatalf("want = %v, got = %v", true, false)
+		}
+	}
+	h.close(context.Background())
+	if h.send([]byte("4")) {
+		t.Errorf("want = %v, got = %v", false, true)
+	}
+	s.mu.Lock()
+	got := s.bodies
+	s.mu.Unlock()
+	if want := []string{"1", "2", "3", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want = %v, got = %v", want, got)
+	}
+	for _, sig := range s.sigs {
+		if sig != "" {
+			t.Errorf("want = %v, got = %v", "", sig)
+		}
+	}
+
+	// Closing abandons the deliveries when the context is done.
+	blocked := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer slow.Close()
+	defer close(blocked)
+	h = newWebhook(slow.URL, "", time.Minute, time.Minute, 3)
+	h.send([]byte("1"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	h.close(ctx)
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("want = %v, got = %v", "abandoned", d)
+	}
+}